@@ -0,0 +1,443 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// AdminConfig configures the optional admin HTTP API
+type AdminConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_ADMIN_ENABLED" default:"false"`
+
+	// Listen address, host:port or unix:/path/to.sock; keep it on localhost
+	// unless access is otherwise restricted
+	Addr string `json:"addr" yaml:"addr" env:"WORKER_ADMIN_ADDR" default:"127.0.0.1:9090"`
+
+	// Client IPs or CIDRs the HTTP and gRPC listeners accept connections
+	// from; empty accepts any
+	AllowFrom []string `json:"allowFrom" yaml:"allowFrom"`
+
+	// Bearer token required on every request
+	Token string `json:"token" yaml:"token" env:"WORKER_ADMIN_TOKEN" secret:"true"`
+
+	// Further accepted bearer tokens, e.g. one per operator or while rotating
+	Tokens []string `json:"tokens" yaml:"tokens" secret:"true"`
+
+	// TLS of the HTTP and gRPC listeners; with a client CA, requests also
+	// need a client certificate
+	TLS ListenerTLSConfig `json:"tls" yaml:"tls"`
+
+	// Serve /healthz, /readyz and the gRPC health service without a token or
+	// client certificate, e.g. for load balancer probes
+	PublicHealth bool `json:"publicHealth" yaml:"publicHealth" env:"WORKER_ADMIN_PUBLIC_HEALTH" default:"false"`
+
+	// Serve net/http/pprof under /debug/pprof/ for the profile command
+	Pprof bool `json:"pprof" yaml:"pprof" env:"WORKER_ADMIN_PPROF" default:"false"`
+
+	// Listen address of the gRPC control plane; empty disables it
+	GRPCAddr string `json:"grpcAddr" yaml:"grpcAddr" env:"WORKER_ADMIN_GRPC_ADDR"`
+}
+
+// validate validates the admin configuration
+func (ac *AdminConfig) validate() error {
+	if !ac.Enabled {
+		return nil
+	}
+	if ac.Addr == "" {
+		return fmt.Errorf("admin address cannot be empty when the admin API is enabled")
+	}
+	if err := validateListen(ac.Addr, ac.AllowFrom); err != nil {
+		return err
+	}
+	if ac.GRPCAddr != "" {
+		if err := validateListen(ac.GRPCAddr, ac.AllowFrom); err != nil {
+			return fmt.Errorf("grpc: %w", err)
+		}
+	}
+	if ac.Token == "" && len(ac.Tokens) == 0 && !ac.TLS.mutual() {
+		return fmt.Errorf("admin token or TLS client CA is required when the admin API is enabled")
+	}
+	if err := ac.TLS.validate(); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	return nil
+}
+
+// auth returns the authentication of the admin listeners
+func (ac *AdminConfig) auth() *listenerAuth {
+	var public func(string) bool
+	if ac.PublicHealth {
+		public = func(name string) bool {
+			return name == "/healthz" || name == "/readyz" || strings.HasPrefix(name, "/grpc.health.v1.Health/")
+		}
+	}
+	return newListenerAuth(ac.Token, ac.Tokens, &ac.TLS, public)
+}
+
+// adminServer exposes runtime controls over HTTP
+type adminServer struct {
+	w    *Workerd
+	auth *listenerAuth
+	mux  *http.ServeMux
+	srv  *http.Server
+}
+
+// newAdminServer creates the admin server and registers its routes
+func newAdminServer(w *Workerd) *adminServer {
+	a := &adminServer{w: w, auth: w.config.Admin.auth(), mux: http.NewServeMux()}
+	a.mux.HandleFunc("GET /queues", a.handleListQueues)
+	a.mux.HandleFunc("GET /queues/{queue}", a.handleGetQueue)
+	a.mux.HandleFunc("POST /queues/{queue}/pause", a.handlePauseQueue)
+	a.mux.HandleFunc("POST /queues/{queue}/resume", a.handleResumeQueue)
+	a.mux.HandleFunc("POST /queues/{queue}/tasks/{id}/requeue", a.handleRequeueTask)
+	a.mux.HandleFunc("POST /queues/{queue}/tasks/{id}/cancel", a.handleCancelTask)
+	a.mux.HandleFunc("GET /archive", a.handleListArchived)
+	a.mux.HandleFunc("POST /archive/requeue", a.handleRequeueArchived)
+	a.mux.HandleFunc("POST /archive/purge", a.handlePurgeArchived)
+	a.mux.HandleFunc("GET /history", a.handleListHistory)
+	a.mux.HandleFunc("GET /workers", a.handleListWorkers)
+	a.mux.HandleFunc("GET /handlers", a.handleListHandlers)
+	a.mux.HandleFunc("GET /schedules", a.handleListSchedules)
+	a.mux.HandleFunc("POST /schedules", a.handleAddSchedule)
+	a.mux.HandleFunc("DELETE /schedules/{id}", a.handleRemoveSchedule)
+	a.mux.HandleFunc("GET /healthz", a.handleHealth)
+	a.mux.HandleFunc("GET /readyz", a.handleReady)
+	a.mux.HandleFunc("POST /drain", a.handleDrain)
+	a.mux.HandleFunc("GET /loglevel", a.handleGetLogLevel)
+	a.mux.HandleFunc("PUT /loglevel", a.handleSetLogLevel)
+	a.mux.HandleFunc("POST /shutdown", a.handleShutdown)
+	if w.config.Admin.Pprof {
+		a.registerProfiling()
+	}
+	a.srv = &http.Server{
+		Handler:           a.auth.http(a.mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return a
+}
+
+// start binds the listener and serves in the background
+func (a *adminServer) start(addr string) error {
+	ln, err := a.auth.listen(addr, a.w.config.Admin.AllowFrom, a.w.log)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := a.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.w.log.Error("admin server stopped unexpectedly", "error", err)
+		}
+	}()
+
+	a.w.log.Info("admin API listening", "addr", ln.Addr().String())
+	return nil
+}
+
+// stop gracefully shuts the server down
+func (a *adminServer) stop(ctx context.Context) error {
+	return a.srv.Shutdown(ctx)
+}
+
+func (a *adminServer) handleListQueues(rw http.ResponseWriter, r *http.Request) {
+	queues, err := a.w.queueNames()
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	infos := make([]*asynq.QueueInfo, 0, len(queues))
+	for _, q := range queues {
+		info, err := a.w.readQueueInspector(q).GetQueueInfo(q)
+		if err != nil {
+			writeError(rw, http.StatusInternalServerError, err)
+			return
+		}
+		infos = append(infos, info)
+	}
+	writeJSON(rw, http.StatusOK, infos)
+}
+
+func (a *adminServer) handleGetQueue(rw http.ResponseWriter, r *http.Request) {
+	queue := r.PathValue("queue")
+	info, err := a.w.readQueueInspector(queue).GetQueueInfo(queue)
+	if errors.Is(err, asynq.ErrQueueNotFound) {
+		writeError(rw, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, info)
+}
+
+func (a *adminServer) handlePauseQueue(rw http.ResponseWriter, r *http.Request) {
+	queue := r.PathValue("queue")
+	if err := a.w.PauseQueue(queue); err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]any{"queue": queue, "paused": true})
+}
+
+func (a *adminServer) handleResumeQueue(rw http.ResponseWriter, r *http.Request) {
+	queue := r.PathValue("queue")
+	if err := a.w.ResumeQueue(queue); err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]any{"queue": queue, "paused": false})
+}
+
+func (a *adminServer) handleRequeueTask(rw http.ResponseWriter, r *http.Request) {
+	queue, id := r.PathValue("queue"), r.PathValue("id")
+	err := a.w.RequeueTask(queue, id)
+	if errors.Is(err, asynq.ErrQueueNotFound) || errors.Is(err, asynq.ErrTaskNotFound) {
+		writeError(rw, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]any{"queue": queue, "task_id": id, "requeued": true})
+}
+
+func (a *adminServer) handleCancelTask(rw http.ResponseWriter, r *http.Request) {
+	queue, id := r.PathValue("queue"), r.PathValue("id")
+	err := a.w.CancelTask(queue, id)
+	if errors.Is(err, asynq.ErrQueueNotFound) || errors.Is(err, asynq.ErrTaskNotFound) {
+		writeError(rw, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, ErrTaskNotActive) {
+		writeError(rw, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]any{"queue": queue, "task_id": id, "cancelled": true})
+}
+
+// archiveFilter reads an ArchiveFilter and the dry_run flag from the query string
+func archiveFilter(r *http.Request) (ArchiveFilter, bool, error) {
+	q := r.URL.Query()
+	f := ArchiveFilter{Queue: q.Get("queue"), Type: q.Get("type")}
+	if v := q.Get("older_than"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return f, false, fmt.Errorf("invalid older_than: %w", err)
+		}
+		f.OlderThan = d
+	}
+	var dryRun bool
+	if v := q.Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, false, fmt.Errorf("invalid dry_run: %w", err)
+		}
+		dryRun = b
+	}
+	return f, dryRun, nil
+}
+
+func (a *adminServer) handleListArchived(rw http.ResponseWriter, r *http.Request) {
+	f, _, err := archiveFilter(r)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+	tasks, err := a.w.ArchivedTasks(f)
+	if errors.Is(err, asynq.ErrQueueNotFound) {
+		writeError(rw, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, tasks)
+}
+
+func (a *adminServer) handleListHistory(rw http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := HistoryFilter{Queue: q.Get("queue"), Type: q.Get("type"), Outcome: q.Get("outcome"), TaskID: q.Get("task_id"), Limit: 100}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		f.Limit = limit
+	}
+	executions, err := a.w.History(r.Context(), f)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, executions)
+}
+
+func (a *adminServer) handleRequeueArchived(rw http.ResponseWriter, r *http.Request) {
+	a.handleArchiveAction(rw, r, a.w.RequeueArchived)
+}
+
+func (a *adminServer) handlePurgeArchived(rw http.ResponseWriter, r *http.Request) {
+	a.handleArchiveAction(rw, r, a.w.PurgeArchived)
+}
+
+// handleArchiveAction requeues or purges the archived tasks selected by the query
+func (a *adminServer) handleArchiveAction(rw http.ResponseWriter, r *http.Request, action func(ArchiveFilter, bool) ([]ArchivedTask, error)) {
+	f, dryRun, err := archiveFilter(r)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+	tasks, err := action(f, dryRun)
+	if errors.Is(err, asynq.ErrQueueNotFound) {
+		writeError(rw, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]any{"dry_run": dryRun, "count": len(tasks), "tasks": tasks})
+}
+
+func (a *adminServer) handleListWorkers(rw http.ResponseWriter, r *http.Request) {
+	workers, err := a.w.Workers(r.Context())
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, workers)
+}
+
+func (a *adminServer) handleListHandlers(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, a.w.Handlers())
+}
+
+func (a *adminServer) handleListSchedules(rw http.ResponseWriter, r *http.Request) {
+	schedules, err := a.w.Schedules(r.Context())
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, schedules)
+}
+
+func (a *adminServer) handleAddSchedule(rw http.ResponseWriter, r *http.Request) {
+	var sc ScheduleConfig
+	if err := json.NewDecoder(r.Body).Decode(&sc); err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if err := sc.validate(); err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid schedule: %w", err))
+		return
+	}
+	id, err := a.w.AddSchedule(r.Context(), sc)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (a *adminServer) handleRemoveSchedule(rw http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := a.w.RemoveSchedule(r.Context(), id)
+	if errors.Is(err, ErrScheduleNotFound) {
+		writeError(rw, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]any{"id": id, "removed": true})
+}
+
+func (a *adminServer) handleHealth(rw http.ResponseWriter, r *http.Request) {
+	if err := a.w.checkHealth(); err != nil {
+		writeError(rw, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]any{"status": "ok", "redis": a.w.RedisStatus()})
+}
+
+func (a *adminServer) handleReady(rw http.ResponseWriter, r *http.Request) {
+	if a.w.Draining() {
+		writeJSON(rw, http.StatusServiceUnavailable, map[string]any{"status": "draining", "active": a.w.active.Load()})
+		return
+	}
+	if err := a.w.checkReady(); err != nil {
+		writeJSON(rw, http.StatusServiceUnavailable, map[string]any{"error": err.Error(), "checks": a.w.health.status()})
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]any{"status": "ready", "checks": a.w.health.status()})
+}
+
+func (a *adminServer) handleDrain(rw http.ResponseWriter, r *http.Request) {
+	a.w.log.Warn("drain requested through the admin API", "remote", r.RemoteAddr)
+	if err := a.w.Drain(); err != nil {
+		writeError(rw, http.StatusConflict, err)
+		return
+	}
+	writeJSON(rw, http.StatusAccepted, map[string]any{"status": "draining", "active": a.w.active.Load()})
+}
+
+func (a *adminServer) handleGetLogLevel(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, map[string]string{"level": a.w.LogLevel().String()})
+}
+
+func (a *adminServer) handleSetLogLevel(rw http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Level string `json:"level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(body.Level)); err != nil {
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	}
+	a.w.SetLogLevel(level)
+	writeJSON(rw, http.StatusOK, map[string]string{"level": level.String()})
+}
+
+func (a *adminServer) handleShutdown(rw http.ResponseWriter, r *http.Request) {
+	a.w.log.Warn("graceful shutdown requested through the admin API", "remote", r.RemoteAddr)
+	if err := requestShutdown(); err != nil {
+		writeError(rw, http.StatusNotImplemented, err)
+		return
+	}
+	writeJSON(rw, http.StatusAccepted, map[string]string{"status": "shutting down"})
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+// writeError writes err as a JSON error response
+func writeError(rw http.ResponseWriter, status int, err error) {
+	writeJSON(rw, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,5 @@
+// Package adminpb contains the generated gRPC bindings for the workerd
+// control-plane service defined in admin.proto.
+package adminpb
+
+//go:generate protoc -I.. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative adminpb/admin.proto
@@ -0,0 +1,411 @@
+package workerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/time/rate"
+)
+
+// Alert severities
+const (
+	AlertWarning  = "warning"
+	AlertCritical = "critical"
+)
+
+// AlertsConfig configures where operational alerts, such as missed SLAs, are sent
+type AlertsConfig struct {
+	Webhook   AlertWebhookConfig   `json:"webhook" yaml:"webhook"`
+	Slack     AlertSlackConfig     `json:"slack" yaml:"slack"`
+	PagerDuty AlertPagerDutyConfig `json:"pagerduty" yaml:"pagerduty"`
+	Email     AlertEmailConfig     `json:"email" yaml:"email"`
+
+	// Alert when a task fails with no retries left
+	ArchivedTasks bool `json:"archivedTasks" yaml:"archivedTasks" env:"WORKER_ALERTS_ARCHIVED_TASKS" default:"false"`
+
+	// Queue depth thresholds checked by the scheduler leader
+	Queues []QueueAlertRule `json:"queues" yaml:"queues"`
+
+	// How often queue depths are checked
+	CheckInterval time.Duration `json:"checkInterval" yaml:"checkInterval" env:"WORKER_ALERTS_CHECK_INTERVAL" default:"30s"`
+
+	// Alerts with the same key are sent at most once per cooldown
+	Cooldown time.Duration `json:"cooldown" yaml:"cooldown" env:"WORKER_ALERTS_COOLDOWN" default:"5m"`
+
+	// Alerts sent per minute at most, across all keys; further alerts are
+	// dropped. Resolutions are not limited. 0 disables the limit.
+	MaxPerMinute int `json:"maxPerMinute" yaml:"maxPerMinute" env:"WORKER_ALERTS_MAX_PER_MINUTE" default:"20"`
+
+	// Timeout of each delivery
+	Timeout time.Duration `json:"timeout" yaml:"timeout" env:"WORKER_ALERTS_TIMEOUT" default:"10s"`
+
+	// Alerts waiting for delivery; further alerts are dropped
+	BufferSize int `json:"bufferSize" yaml:"bufferSize" env:"WORKER_ALERTS_BUFFER_SIZE" default:"100"`
+}
+
+// AlertWebhookConfig POSTs each alert as JSON to a URL
+type AlertWebhookConfig struct {
+	URL string `json:"url" yaml:"url" env:"WORKER_ALERTS_WEBHOOK_URL" secret:"true"`
+}
+
+// AlertPagerDutyConfig triggers and resolves PagerDuty incidents through the Events API v2
+type AlertPagerDutyConfig struct {
+	// Integration key of the PagerDuty service
+	RoutingKey string `json:"routingKey" yaml:"routingKey" env:"WORKER_ALERTS_PAGERDUTY_ROUTING_KEY" secret:"true"`
+
+	URL string `json:"url" yaml:"url" env:"WORKER_ALERTS_PAGERDUTY_URL" default:"'https://events.pagerduty.com/v2/enqueue'"`
+}
+
+// enabled reports whether any notifier is configured
+func (ac *AlertsConfig) enabled() bool {
+	return ac.Webhook.URL != "" || ac.Slack.WebhookURL != "" || ac.PagerDuty.RoutingKey != "" || ac.Email.Host != ""
+}
+
+// validate validates the alerts configuration
+func (ac *AlertsConfig) validate() error {
+	if !ac.enabled() {
+		if len(ac.Queues) > 0 || ac.ArchivedTasks {
+			return fmt.Errorf("queue and archived task alerts need a notifier")
+		}
+		return nil
+	}
+	if ac.Webhook.URL != "" && !isAbsoluteURL(ac.Webhook.URL) {
+		return fmt.Errorf("alerts webhook URL %q must be an absolute URL", ac.Webhook.URL)
+	}
+	if ac.Slack.WebhookURL != "" {
+		if !isAbsoluteURL(ac.Slack.WebhookURL) {
+			return fmt.Errorf("Slack webhook URL %q must be an absolute URL", ac.Slack.WebhookURL)
+		}
+		if _, err := ac.Slack.template(); err != nil {
+			return err
+		}
+	}
+	if ac.PagerDuty.RoutingKey != "" && !isAbsoluteURL(ac.PagerDuty.URL) {
+		return fmt.Errorf("PagerDuty URL %q must be an absolute URL", ac.PagerDuty.URL)
+	}
+	if ac.Email.Host != "" {
+		if err := ac.Email.validate(); err != nil {
+			return err
+		}
+	}
+	seen := make(map[string]bool, len(ac.Queues))
+	for i, r := range ac.Queues {
+		if r.Queue == "" {
+			return fmt.Errorf("queue alert %d: queue cannot be empty", i)
+		}
+		if seen[r.Queue] {
+			return fmt.Errorf("queue alert %q: duplicate queue", r.Queue)
+		}
+		seen[r.Queue] = true
+		if r.MaxPending < 0 || r.For < 0 {
+			return fmt.Errorf("queue alert %q: max pending and duration must be non-negative", r.Queue)
+		}
+		switch r.Severity {
+		case "", AlertWarning, AlertCritical:
+		default:
+			return fmt.Errorf("queue alert %q: unknown severity %q, expected %q or %q", r.Queue, r.Severity, AlertWarning, AlertCritical)
+		}
+	}
+	if len(ac.Queues) > 0 && ac.CheckInterval <= 0 {
+		return fmt.Errorf("alerts check interval must be positive, got %v", ac.CheckInterval)
+	}
+	if ac.Cooldown < 0 {
+		return fmt.Errorf("alerts cooldown must be non-negative, got %v", ac.Cooldown)
+	}
+	if ac.MaxPerMinute < 0 {
+		return fmt.Errorf("alerts max per minute must be non-negative, got %d", ac.MaxPerMinute)
+	}
+	if ac.Timeout <= 0 {
+		return fmt.Errorf("alerts timeout must be positive, got %v", ac.Timeout)
+	}
+	if ac.BufferSize <= 0 {
+		return fmt.Errorf("alerts buffer size must be positive, got %d", ac.BufferSize)
+	}
+	return nil
+}
+
+// isAbsoluteURL reports whether s is a URL with a scheme and host
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// Alert is an operational notification
+type Alert struct {
+	Time time.Time `json:"time"`
+	// Component raising the alert: "sla", "queue" or "task"
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+	// Identifies the condition; repeats within the cooldown are not sent
+	Key     string `json:"key"`
+	Summary string `json:"summary"`
+	// Set when the condition raised under Key has cleared
+	Resolved bool   `json:"resolved,omitempty"`
+	Host     string `json:"host"`
+	// Details such as the queue and task type
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// notifier delivers alerts to one destination
+type notifier interface {
+	notify(ctx context.Context, a Alert) error
+	close() error
+}
+
+// alerter sends alerts to every notifier in the background so tasks never
+// wait for a notification. Delivery is best effort: alerts are dropped when
+// the buffer is full and failures are only logged.
+type alerter struct {
+	notifiers []notifier
+	config    *AlertsConfig
+	host      string
+	log       *slog.Logger
+	queue     chan Alert
+	done      chan struct{}
+	// limiter caps the alerts sent per minute, nil without a limit
+	limiter *rate.Limiter
+
+	mu     sync.Mutex
+	closed bool
+	// sent holds when each alert key was last sent
+	sent map[string]time.Time
+}
+
+// newAlerter creates the configured notifiers, or returns nil if alerting is disabled
+func newAlerter(config *AlertsConfig, logger *slog.Logger) (*alerter, error) {
+	var notifiers []notifier
+	if config.Webhook.URL != "" {
+		notifiers = append(notifiers, &webhookNotifier{client: &http.Client{}, url: config.Webhook.URL})
+	}
+	if config.Slack.WebhookURL != "" {
+		n, err := newSlackNotifier(&config.Slack)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if config.PagerDuty.RoutingKey != "" {
+		notifiers = append(notifiers, &pagerDutyNotifier{
+			client:     &http.Client{},
+			url:        config.PagerDuty.URL,
+			routingKey: config.PagerDuty.RoutingKey,
+		})
+	}
+	if config.Email.Host != "" {
+		notifiers = append(notifiers, newEmailNotifier(&config.Email))
+	}
+	if len(notifiers) == 0 {
+		return nil, nil
+	}
+
+	host, _ := os.Hostname()
+	a := &alerter{
+		notifiers: notifiers,
+		config:    config,
+		host:      host,
+		log:       logger,
+		queue:     make(chan Alert, config.BufferSize),
+		done:      make(chan struct{}),
+		sent:      make(map[string]time.Time),
+	}
+	if config.MaxPerMinute > 0 {
+		a.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(config.MaxPerMinute)), config.MaxPerMinute)
+	}
+	go a.run()
+	return a, nil
+}
+
+// send queues an alert unless one with the same key was sent within the
+// cooldown or the rate limit is reached. Resolutions are always sent and end
+// the cooldown of their key.
+func (a *alerter) send(alert Alert) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now().UTC()
+	}
+	alert.Host = a.host
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	if last, ok := a.sent[alert.Key]; ok && !alert.Resolved && alert.Time.Sub(last) < a.config.Cooldown {
+		return
+	}
+	if a.limiter != nil && !alert.Resolved && !a.limiter.Allow() {
+		a.log.Debug("dropping alert, rate limit reached", "source", alert.Source, "key", alert.Key)
+		return
+	}
+	select {
+	case a.queue <- alert:
+		if alert.Resolved {
+			delete(a.sent, alert.Key)
+		} else {
+			a.sent[alert.Key] = alert.Time
+		}
+	default:
+		a.log.Warn("dropping alert, buffer full", "source", alert.Source, "key", alert.Key)
+	}
+}
+
+// run delivers queued alerts until the alerter is closed
+func (a *alerter) run() {
+	defer close(a.done)
+	for alert := range a.queue {
+		for _, n := range a.notifiers {
+			ctx, cancel := context.WithTimeout(context.Background(), a.config.Timeout)
+			if err := n.notify(ctx, alert); err != nil {
+				a.log.Warn("could not send alert", "source", alert.Source, "key", alert.Key, "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// close delivers the buffered alerts and closes the notifiers
+func (a *alerter) close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	<-a.done
+	var firstErr error
+	for _, n := range a.notifiers {
+		if err := n.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// webhookNotifier POSTs each alert as JSON to a URL
+type webhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (n *webhookNotifier) notify(ctx context.Context, a Alert) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func (n *webhookNotifier) close() error {
+	n.client.CloseIdleConnections()
+	return nil
+}
+
+// pagerDutyNotifier sends alerts as PagerDuty events, deduplicated by alert key
+type pagerDutyNotifier struct {
+	client     *http.Client
+	url        string
+	routingKey string
+}
+
+// pagerDutyEvent is an Events API v2 event
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Component     string            `json:"component"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+func (n *pagerDutyNotifier) notify(ctx context.Context, a Alert) error {
+	event := pagerDutyEvent{RoutingKey: n.routingKey, EventAction: "resolve", DedupKey: a.Key}
+	if !a.Resolved {
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:       a.Summary,
+			Source:        a.Host,
+			Severity:      a.Severity,
+			Timestamp:     a.Time,
+			Component:     a.Source,
+			CustomDetails: a.Labels,
+		}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("PagerDuty responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func (n *pagerDutyNotifier) close() error {
+	n.client.CloseIdleConnections()
+	return nil
+}
+
+// alertArchived is the asynq error handler sending an alert when a task fails
+// with no retries left
+func (w *Workerd) alertArchived(ctx context.Context, t *asynq.Task, err error) {
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if w.alerts == nil || errors.Is(err, asynq.RevokeTask) ||
+		(retried < maxRetry && !errors.Is(err, asynq.SkipRetry)) {
+		return
+	}
+	id, _ := asynq.GetTaskID(ctx)
+	queue, _ := asynq.GetQueueName(ctx)
+	w.alerts.send(Alert{
+		Source:   "task",
+		Severity: AlertWarning,
+		Key:      "archived:" + queue + ":" + t.Type(),
+		Summary:  fmt.Sprintf("%s task %s archived after %d retries: %v", t.Type(), id, retried, err),
+		Labels: map[string]string{
+			"queue":    queue,
+			"type":     t.Type(),
+			"task_id":  id,
+			"category": string(FailureCategoryOf(err)),
+			"error":    err.Error(),
+		},
+	})
+}
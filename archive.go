@@ -0,0 +1,208 @@
+package workerd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const archiveCommandUsage = "archive list|requeue|purge [-queue name] [-type prefix] [-older-than 24h] [-dry-run]  manage archived (dead-letter) tasks"
+
+// archivePageSize is the number of archived tasks read per Redis round trip
+const archivePageSize = 500
+
+// ArchiveFilter selects archived tasks; the zero value selects all of them
+type ArchiveFilter struct {
+	// Queue to look in; empty looks in every queue
+	Queue string `json:"queue,omitempty"`
+
+	// Task type or type prefix, e.g. "email:" or "email:*"
+	Type string `json:"type,omitempty"`
+
+	// Only tasks archived at least this long ago
+	OlderThan time.Duration `json:"olderThan,omitempty"`
+}
+
+// match reports whether an archived task passes the filter
+func (f ArchiveFilter) match(info *asynq.TaskInfo, now time.Time) bool {
+	if !matchTaskType(f.Type, info.Type) {
+		return false
+	}
+	return f.OlderThan <= 0 || now.Sub(info.LastFailedAt) >= f.OlderThan
+}
+
+// ArchivedTask describes a task in the archive
+type ArchivedTask struct {
+	ID       string    `json:"id"`
+	Queue    string    `json:"queue"`
+	Type     string    `json:"type"`
+	Retried  int       `json:"retried"`
+	MaxRetry int       `json:"max_retry"`
+	LastErr  string    `json:"last_error"`
+	Archived time.Time `json:"archived"`
+}
+
+// ArchivedTasks lists the archived tasks matching the filter, most recently
+// archived first within each queue
+func (w *Workerd) ArchivedTasks(f ArchiveFilter) ([]ArchivedTask, error) {
+	queues := []string{f.Queue}
+	if f.Queue == "" {
+		var err error
+		if queues, err = w.queueNames(); err != nil {
+			return nil, fmt.Errorf("failed to list queues: %w", err)
+		}
+	}
+
+	now := time.Now()
+	var tasks []ArchivedTask
+	for _, q := range queues {
+		inspector := w.readQueueInspector(q)
+		for page := 1; ; page++ {
+			infos, err := inspector.ListArchivedTasks(q, asynq.PageSize(archivePageSize), asynq.Page(page))
+			if err != nil {
+				return nil, fmt.Errorf("failed to list archived tasks in queue %q: %w", q, err)
+			}
+			for _, info := range infos {
+				if f.match(info, now) {
+					tasks = append(tasks, ArchivedTask{
+						ID:       info.ID,
+						Queue:    info.Queue,
+						Type:     info.Type,
+						Retried:  info.Retried,
+						MaxRetry: info.MaxRetry,
+						LastErr:  info.LastErr,
+						Archived: info.LastFailedAt.UTC(),
+					})
+				}
+			}
+			if len(infos) < archivePageSize {
+				break
+			}
+		}
+	}
+	return tasks, nil
+}
+
+// RequeueArchived moves the archived tasks matching the filter back to
+// pending and returns them. With dryRun it only returns what it would requeue.
+func (w *Workerd) RequeueArchived(f ArchiveFilter, dryRun bool) ([]ArchivedTask, error) {
+	return w.eachArchived(f, dryRun, "requeue", func(queue, id string) error {
+		return w.QueueInspector(queue).RunTask(queue, id)
+	})
+}
+
+// PurgeArchived deletes the archived tasks matching the filter for good and
+// returns them. With dryRun it only returns what it would delete.
+func (w *Workerd) PurgeArchived(f ArchiveFilter, dryRun bool) ([]ArchivedTask, error) {
+	return w.eachArchived(f, dryRun, "purge", func(queue, id string) error {
+		return w.QueueInspector(queue).DeleteTask(queue, id)
+	})
+}
+
+// eachArchived applies op to the archived tasks matching the filter. Tasks
+// that left the archive in the meantime are skipped.
+func (w *Workerd) eachArchived(f ArchiveFilter, dryRun bool, action string, op func(queue, id string) error) ([]ArchivedTask, error) {
+	tasks, err := w.ArchivedTasks(f)
+	if err != nil || dryRun {
+		return tasks, err
+	}
+
+	done := tasks[:0]
+	for _, t := range tasks {
+		err := op(t.Queue, t.ID)
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			continue
+		}
+		if err != nil {
+			return done, fmt.Errorf("failed to %s archived task %q in queue %q: %w", action, t.ID, t.Queue, err)
+		}
+		done = append(done, t)
+	}
+	w.log.Info("archived tasks "+action+"d", "count", len(done), "queue", f.Queue, "type", f.Type, "older_than", f.OlderThan)
+	return done, nil
+}
+
+// runArchiveCommand implements `archive`
+func runArchiveCommand(w *Workerd, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", archiveCommandUsage)
+	}
+	action := args[0]
+	switch action {
+	case "list", "requeue", "purge":
+	default:
+		return fmt.Errorf("unknown archive action %q (valid actions: list, requeue, purge)", action)
+	}
+
+	var f ArchiveFilter
+	fs := flag.NewFlagSet("archive "+action, flag.ContinueOnError)
+	fs.StringVar(&f.Queue, "queue", "", "Only tasks in this queue")
+	fs.StringVar(&f.Type, "type", "", "Only tasks whose type starts with this prefix")
+	fs.DurationVar(&f.OlderThan, "older-than", 0, "Only tasks archived at least this long ago")
+	dryRun := fs.Bool("dry-run", false, "Show the matching tasks without changing them")
+	output := fs.String("output", "text", "Output format (text, json)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: %s", archiveCommandUsage)
+	}
+
+	var (
+		tasks []ArchivedTask
+		err   error
+	)
+	switch action {
+	case "list":
+		tasks, err = w.ArchivedTasks(f)
+	case "requeue":
+		tasks, err = w.RequeueArchived(f, *dryRun)
+	case "purge":
+		tasks, err = w.PurgeArchived(f, *dryRun)
+	}
+	if err != nil {
+		return err
+	}
+	if err := printArchivedTasks(tasks, *output); err != nil {
+		return err
+	}
+
+	switch {
+	case action == "list":
+	case *dryRun:
+		fmt.Fprintf(os.Stderr, "dry run: would %s %d archived tasks\n", action, len(tasks))
+	default:
+		fmt.Fprintf(os.Stderr, "%sd %d archived tasks\n", action, len(tasks))
+	}
+	return nil
+}
+
+// printArchivedTasks renders archived tasks as a table or JSON
+func printArchivedTasks(tasks []ArchivedTask, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tasks)
+	case "", "text":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "QUEUE\tID\tTYPE\tRETRIED\tARCHIVED\tERROR")
+		for _, t := range tasks {
+			lastErr := t.LastErr
+			if len(lastErr) > 60 {
+				lastErr = lastErr[:57] + "..."
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%s\t%s\n", t.Queue, t.ID, t.Type, t.Retried, t.MaxRetry,
+				t.Archived.Format(time.RFC3339), lastErr)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (valid formats: text, json)", format)
+	}
+}
@@ -0,0 +1,225 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// Audit event names
+const (
+	AuditAccepted  = "accepted"
+	AuditStarted   = "started"
+	AuditSucceeded = "succeeded"
+	AuditFailed    = "failed"
+	AuditArchived  = "archived"
+)
+
+// Supported audit sinks
+const (
+	AuditSinkFile  = "file"
+	AuditSinkRedis = "redis"
+)
+
+// AuditConfig configures the append-only audit trail of task lifecycle events
+type AuditConfig struct {
+	// "file" or "redis"; empty disables auditing
+	Sink string `json:"sink" yaml:"sink" env:"WORKER_AUDIT_SINK"`
+
+	// File receiving one JSON event per line when Sink is "file"
+	Path string `json:"path" yaml:"path" env:"WORKER_AUDIT_PATH"`
+
+	// Redis stream receiving events when Sink is "redis"
+	Stream string `json:"stream" yaml:"stream" env:"WORKER_AUDIT_STREAM" default:"workerd:audit"`
+
+	// Approximate maximum stream length; 0 keeps every event
+	MaxLen int64 `json:"maxLen" yaml:"maxLen" env:"WORKER_AUDIT_MAX_LEN" default:"0"`
+}
+
+// validate validates the audit configuration
+func (ac *AuditConfig) validate() error {
+	switch ac.Sink {
+	case "":
+	case AuditSinkFile:
+		if ac.Path == "" {
+			return fmt.Errorf("audit path is required for the file sink")
+		}
+	case AuditSinkRedis:
+		if ac.Stream == "" {
+			return fmt.Errorf("audit stream is required for the redis sink")
+		}
+		if ac.MaxLen < 0 {
+			return fmt.Errorf("audit max length must be non-negative, got %d", ac.MaxLen)
+		}
+	default:
+		return fmt.Errorf("unknown audit sink %q, expected %q or %q", ac.Sink, AuditSinkFile, AuditSinkRedis)
+	}
+	return nil
+}
+
+// AuditEvent is one entry of the audit trail
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`
+	TaskID string    `json:"task_id"`
+	Type   string    `json:"type"`
+	Queue  string    `json:"queue"`
+	Host   string    `json:"host"`
+	PID    int       `json:"pid"`
+	Retry  int       `json:"retry"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// auditSink persists audit events
+type auditSink interface {
+	write(ctx context.Context, e AuditEvent) error
+	close() error
+}
+
+// auditLog stamps events with the host and hands them to the sink.
+// Write failures are logged and never fail the task or the enqueue.
+type auditLog struct {
+	sink auditSink
+	host string
+	log  *slog.Logger
+}
+
+// newAuditLog opens the configured sink, or returns nil if auditing is disabled
+func newAuditLog(config *AuditConfig, redisOpt asynq.RedisConnOpt, logger *slog.Logger) (*auditLog, error) {
+	var sink auditSink
+	switch config.Sink {
+	case "":
+		return nil, nil
+	case AuditSinkFile:
+		f, err := os.OpenFile(config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit file: %w", err)
+		}
+		sink = &fileAuditSink{f: f}
+	case AuditSinkRedis:
+		rdb, ok := redisOpt.MakeRedisClient().(redis.UniversalClient)
+		if !ok {
+			return nil, fmt.Errorf("unsupported redis connection for the audit stream")
+		}
+		sink = &redisAuditSink{rdb: rdb, stream: config.Stream, maxLen: config.MaxLen}
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", config.Sink)
+	}
+
+	host, _ := os.Hostname()
+	return &auditLog{sink: sink, host: host, log: logger}, nil
+}
+
+// record writes one event
+func (a *auditLog) record(ctx context.Context, event, taskID, taskType, queue string, retry int, err error) {
+	e := AuditEvent{
+		Time:   time.Now().UTC(),
+		Event:  event,
+		TaskID: taskID,
+		Type:   taskType,
+		Queue:  queue,
+		Host:   a.host,
+		PID:    os.Getpid(),
+		Retry:  retry,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if werr := a.sink.write(ctx, e); werr != nil {
+		a.log.Warn("could not write audit event", "event", event, "task_id", taskID, "error", werr)
+	}
+}
+
+func (a *auditLog) close() error {
+	return a.sink.close()
+}
+
+// fileAuditSink appends JSON lines to a file
+type fileAuditSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func (s *fileAuditSink) write(_ context.Context, e AuditEvent) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *fileAuditSink) close() error {
+	return s.f.Close()
+}
+
+// redisAuditSink appends events to a Redis stream
+type redisAuditSink struct {
+	rdb    redis.UniversalClient
+	stream string
+	maxLen int64
+}
+
+func (s *redisAuditSink) write(ctx context.Context, e AuditEvent) error {
+	// Audit writes outlive a cancelled task context
+	ctx = context.WithoutCancel(ctx)
+	return s.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: s.maxLen,
+		Approx: s.maxLen > 0,
+		Values: map[string]any{
+			"time":    e.Time.Format(time.RFC3339Nano),
+			"event":   e.Event,
+			"task_id": e.TaskID,
+			"type":    e.Type,
+			"queue":   e.Queue,
+			"host":    e.Host,
+			"pid":     e.PID,
+			"retry":   e.Retry,
+			"error":   e.Error,
+		},
+	}).Err()
+}
+
+func (s *redisAuditSink) close() error {
+	return s.rdb.Close()
+}
+
+// auditMiddleware records started, succeeded, failed and archived events.
+// A failure is recorded as archived when no retries are left.
+func auditMiddleware(a *auditLog) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if a == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			id, _ := asynq.GetTaskID(ctx)
+			queue, _ := asynq.GetQueueName(ctx)
+			retried, _ := asynq.GetRetryCount(ctx)
+			maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+			a.record(ctx, AuditStarted, id, t.Type(), queue, retried, nil)
+			err := next.ProcessTask(ctx, t)
+
+			switch {
+			case err == nil:
+				a.record(ctx, AuditSucceeded, id, t.Type(), queue, retried, nil)
+			case retried >= maxRetry || errors.Is(err, asynq.SkipRetry):
+				a.record(ctx, AuditArchived, id, t.Type(), queue, retried, err)
+			default:
+				a.record(ctx, AuditFailed, id, t.Type(), queue, retried, err)
+			}
+			return err
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrQueueFull is returned by Client when a queue holds more pending tasks
+// than its backpressure limit; HTTP APIs can answer 429 Too Many Requests
+var ErrQueueFull = errors.New("queue is full")
+
+// BackpressureConfig makes Client reject tasks for queues with too many
+// pending tasks, instead of letting Redis grow without bound
+type BackpressureConfig struct {
+	// Pending tasks from which enqueues to any queue fail; 0 disables the
+	// check
+	MaxPending int `json:"maxPending" yaml:"maxPending" env:"WORKER_BACKPRESSURE_MAX_PENDING" default:"0"`
+
+	// Limits by queue, overriding MaxPending; 0 exempts a queue
+	Queues map[string]int `json:"queues" yaml:"queues"`
+
+	// How long a queue depth read from Redis is reused, so busy producers
+	// check a queue at most once per interval
+	CacheTTL time.Duration `json:"cacheTTL" yaml:"cacheTTL" env:"WORKER_BACKPRESSURE_CACHE_TTL" default:"1s"`
+}
+
+// validate validates the backpressure configuration
+func (bc *BackpressureConfig) validate() error {
+	if bc.MaxPending < 0 {
+		return fmt.Errorf("max pending must be non-negative, got %d", bc.MaxPending)
+	}
+	for queue, limit := range bc.Queues {
+		if limit < 0 {
+			return fmt.Errorf("queue %q limit must be non-negative, got %d", queue, limit)
+		}
+	}
+	if bc.CacheTTL < 0 {
+		return fmt.Errorf("cache TTL must be non-negative, got %v", bc.CacheTTL)
+	}
+	return nil
+}
+
+// enabled reports whether any queue has a limit, with maxPending overriding
+// MaxPending when positive
+func (bc *BackpressureConfig) enabled(maxPending int) bool {
+	if maxPending > 0 || bc.MaxPending > 0 {
+		return true
+	}
+	for _, limit := range bc.Queues {
+		if limit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WithClientBackpressure makes the client fail with ErrQueueFull when a queue
+// holds maxPending pending tasks or more, overriding backpressure.maxPending;
+// backpressure.queues still sets the limits of the queues it lists
+func WithClientBackpressure(maxPending int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxPending = maxPending
+	}
+}
+
+// backpressure reads the number of pending tasks of queues, from the Redis
+// storing each of them
+type backpressure struct {
+	config     *BackpressureConfig
+	asynq      *AsynqConfig
+	maxPending int
+	// Connections to the primary and to every shard, in order
+	primary redis.UniversalClient
+	shards  []redis.UniversalClient
+
+	mu     sync.Mutex
+	depths map[string]queueDepth
+}
+
+// queueDepth is a pending count and when it was read
+type queueDepth struct {
+	pending int64
+	read    time.Time
+}
+
+func newBackpressure(config *workerConfig, redisOpt asynq.RedisConnOpt, maxPending int) *backpressure {
+	if maxPending <= 0 {
+		maxPending = config.Backpressure.MaxPending
+	}
+	b := &backpressure{
+		config:     &config.Backpressure,
+		asynq:      config.AsynqConfig,
+		maxPending: maxPending,
+		primary:    redisOpt.MakeRedisClient().(redis.UniversalClient),
+		depths:     make(map[string]queueDepth),
+	}
+	for i := range config.AsynqConfig.Shards {
+		b.shards = append(b.shards, config.AsynqConfig.shardConnOpt(i).MakeRedisClient().(redis.UniversalClient))
+	}
+	return b
+}
+
+// limit returns the pending tasks queue may hold, 0 meaning no limit
+func (b *backpressure) limit(queue string) int {
+	if limit, ok := b.config.Queues[queue]; ok {
+		return limit
+	}
+	return b.maxPending
+}
+
+// check returns ErrQueueFull when queue is at its limit. The count may be up
+// to CacheTTL old, so a burst can overshoot the limit slightly.
+func (b *backpressure) check(ctx context.Context, queue string) error {
+	limit := b.limit(queue)
+	if limit <= 0 {
+		return nil
+	}
+	pending, err := b.pending(ctx, queue)
+	if err != nil {
+		return fmt.Errorf("failed to read depth of queue %q: %w", queue, err)
+	}
+	if pending >= int64(limit) {
+		return fmt.Errorf("%w: queue %q has %d pending tasks, limit %d", ErrQueueFull, queue, pending, limit)
+	}
+	return nil
+}
+
+// pending returns the number of pending tasks of queue, read again from
+// Redis once the cached count is older than CacheTTL
+func (b *backpressure) pending(ctx context.Context, queue string) (int64, error) {
+	now := time.Now()
+	b.mu.Lock()
+	d, ok := b.depths[queue]
+	b.mu.Unlock()
+	if ok && now.Sub(d.read) < b.config.CacheTTL {
+		return d.pending, nil
+	}
+
+	client := b.primary
+	if i := b.asynq.shardOf(queue); i >= 0 {
+		client = b.shards[i]
+	}
+	// The list asynq keeps pending task IDs in
+	pending, err := client.LLen(ctx, asynqKeyPrefix+"{"+queue+"}:pending").Result()
+	if err != nil {
+		return 0, err
+	}
+	b.mu.Lock()
+	b.depths[queue] = queueDepth{pending: pending, read: now}
+	b.mu.Unlock()
+	return pending, nil
+}
+
+// close closes the connections
+func (b *backpressure) close() error {
+	errs := []error{b.primary.Close()}
+	for _, c := range b.shards {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
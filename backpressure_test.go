@@ -0,0 +1,21 @@
+package workerd
+
+import "testing"
+
+func TestBackpressureLimits(t *testing.T) {
+	bc := &BackpressureConfig{MaxPending: 100, Queues: map[string]int{"bulk": 0, "critical": 10}}
+	if err := bc.validate(); err != nil {
+		t.Fatal(err)
+	}
+	b := &backpressure{config: bc, maxPending: bc.MaxPending}
+	for queue, want := range map[string]int{"default": 100, "bulk": 0, "critical": 10} {
+		if got := b.limit(queue); got != want {
+			t.Errorf("limit(%q) = %d, want %d", queue, got, want)
+		}
+	}
+
+	off := &BackpressureConfig{}
+	if off.enabled(0) || !off.enabled(5) {
+		t.Error("enabled should only follow a positive max pending")
+	}
+}
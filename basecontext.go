@@ -0,0 +1,37 @@
+package workerd
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+)
+
+// ContextDecorator derives the context handlers receive, e.g. to attach
+// database pools, API clients or tenant information
+type ContextDecorator func(ctx context.Context) context.Context
+
+// WithBaseContext registers decorators applied, in order, to the context of
+// every task before its handler runs. Register decorators before calling Run.
+func (w *Workerd) WithBaseContext(decorators ...ContextDecorator) *Workerd {
+	for _, d := range decorators {
+		if d != nil {
+			w.decorators = append(w.decorators, d)
+		}
+	}
+	return w
+}
+
+// contextMiddleware applies the registered context decorators
+func contextMiddleware(decorators []ContextDecorator) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if len(decorators) == 0 {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			for _, d := range decorators {
+				ctx = d(ctx)
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
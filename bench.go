@@ -0,0 +1,289 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const benchCommandUsage = "bench [-task noop|sleep|cpu] [-count 10000] [-concurrency n] [-producers 4] [-output text|json]  measure throughput and latency against Redis"
+
+// benchTaskPrefix prefixes the types of the synthetic benchmark tasks
+const benchTaskPrefix = "workerd:bench:"
+
+// BenchOptions configures a benchmark run
+type BenchOptions struct {
+	// Synthetic task: "noop" returns at once, "sleep" waits for Duration and
+	// "cpu" spins for Duration
+	Task     string
+	Duration time.Duration
+	// Number of tasks enqueued
+	Count int
+	// Tasks processed at once; zero uses the worker's concurrency
+	Concurrency int
+	// Goroutines enqueueing tasks
+	Producers int
+	// Payload size in bytes
+	PayloadSize int
+	// Queue used for the run; it must be empty and is deleted afterwards
+	Queue string
+}
+
+// LatencyPercentiles summarizes a latency distribution
+type LatencyPercentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// BenchResult is the outcome of a benchmark run
+type BenchResult struct {
+	Task        string `json:"task"`
+	Count       int    `json:"count"`
+	Concurrency int    `json:"concurrency"`
+	Producers   int    `json:"producers"`
+	// Tasks that could not be enqueued
+	Failed int `json:"failed"`
+	// Tasks per second enqueued and processed
+	EnqueueRate float64 `json:"enqueue_rate"`
+	ProcessRate float64 `json:"process_rate"`
+	// Latency of the enqueue call
+	EnqueueLatency LatencyPercentiles `json:"enqueue_latency"`
+	// Time from the enqueue call to the end of the handler
+	Latency  LatencyPercentiles `json:"latency"`
+	Duration time.Duration      `json:"duration"`
+}
+
+// benchHandler returns the handler of a synthetic task
+func benchHandler(task string, d time.Duration) (asynq.HandlerFunc, error) {
+	switch task {
+	case "noop":
+		return func(context.Context, *asynq.Task) error { return nil }, nil
+	case "sleep":
+		return func(ctx context.Context, _ *asynq.Task) error {
+			select {
+			case <-time.After(d):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, nil
+	case "cpu":
+		return func(context.Context, *asynq.Task) error {
+			for end := time.Now().Add(d); time.Now().Before(end); {
+			}
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown bench task %q (valid tasks: noop, sleep, cpu)", task)
+	}
+}
+
+// Bench enqueues synthetic tasks and processes them with a dedicated server
+// on the worker's Redis, measuring throughput and latency. Nothing else
+// should process the benchmark queue during the run.
+func (w *Workerd) Bench(ctx context.Context, opts BenchOptions) (*BenchResult, error) {
+	if opts.Count <= 0 {
+		return nil, fmt.Errorf("count must be positive, got %d", opts.Count)
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = w.config.Concurrency
+	}
+	opts.Producers = max(opts.Producers, 1)
+	handler, err := benchHandler(opts.Task, opts.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	inspector := w.Inspector()
+	if info, err := inspector.GetQueueInfo(opts.Queue); err == nil && info.Size > 0 {
+		return nil, fmt.Errorf("bench queue %q is not empty (%d tasks)", opts.Queue, info.Size)
+	} else if err != nil && !errors.Is(err, asynq.ErrQueueNotFound) {
+		return nil, fmt.Errorf("failed to inspect bench queue %q: %w", opts.Queue, err)
+	}
+	defer func() {
+		if err := inspector.DeleteQueue(opts.Queue, true); err != nil && !errors.Is(err, asynq.ErrQueueNotFound) {
+			w.log.Warn("could not delete bench queue", "queue", opts.Queue, "error", err)
+		}
+	}()
+
+	client, err := w.Client()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	// Task IDs carry the index of the task, so the handler finds its enqueue
+	// time even when it runs before the enqueue call returns
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	enqueued := make([]time.Time, opts.Count)
+	enqueueLatency := make([]time.Duration, 0, opts.Count)
+	latency := make([]time.Duration, opts.Count)
+	var processed, failed, settled atomic.Int64
+	done := make(chan struct{})
+	settle := func() {
+		if settled.Add(1) == int64(opts.Count) {
+			close(done)
+		}
+	}
+
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(benchTaskPrefix+opts.Task, func(ctx context.Context, t *asynq.Task) error {
+		err := handler(ctx, t)
+		id, _ := asynq.GetTaskID(ctx)
+		if i, perr := strconv.Atoi(id[strings.LastIndexByte(id, '-')+1:]); perr == nil && i < opts.Count {
+			latency[i] = time.Since(enqueued[i])
+		}
+		processed.Add(1)
+		settle()
+		return err
+	})
+	srv := asynq.NewServer(w.redisOpt, asynq.Config{
+		Concurrency: opts.Concurrency,
+		Queues:      map[string]int{opts.Queue: 1},
+		LogLevel:    asynq.WarnLevel,
+	})
+	if err := srv.Start(mux); err != nil {
+		return nil, fmt.Errorf("failed to start bench server: %w", err)
+	}
+	defer srv.Shutdown()
+
+	payload := make([]byte, opts.PayloadSize)
+	next := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range opts.Producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				enqueued[i] = time.Now()
+				_, err := client.EnqueueContext(ctx, asynq.NewTask(benchTaskPrefix+opts.Task, payload),
+					asynq.Queue(opts.Queue), asynq.TaskID(fmt.Sprintf("bench-%s-%d", run, i)), asynq.MaxRetry(0))
+				d := time.Since(enqueued[i])
+				if err != nil {
+					failed.Add(1)
+					settle()
+					continue
+				}
+				mu.Lock()
+				enqueueLatency = append(enqueueLatency, d)
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range opts.Count {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	enqueueDuration := time.Since(start)
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("bench interrupted after %d of %d tasks: %w", processed.Load(), opts.Count, ctx.Err())
+	}
+	duration := time.Since(start)
+
+	n := int(processed.Load())
+	res := &BenchResult{
+		Task:           opts.Task,
+		Count:          opts.Count,
+		Concurrency:    opts.Concurrency,
+		Producers:      opts.Producers,
+		Failed:         int(failed.Load()),
+		EnqueueRate:    float64(len(enqueueLatency)) / enqueueDuration.Seconds(),
+		ProcessRate:    float64(n) / duration.Seconds(),
+		EnqueueLatency: percentiles(enqueueLatency),
+		Latency:        percentiles(slices.DeleteFunc(latency, func(d time.Duration) bool { return d == 0 })),
+		Duration:       duration,
+	}
+	if res.Failed == opts.Count {
+		return res, fmt.Errorf("no task could be enqueued")
+	}
+	return res, nil
+}
+
+// percentiles summarizes the durations, sorting them in place
+func percentiles(durations []time.Duration) LatencyPercentiles {
+	if len(durations) == 0 {
+		return LatencyPercentiles{}
+	}
+	slices.Sort(durations)
+	at := func(q float64) time.Duration { return durations[int(q*float64(len(durations)-1))] }
+	return LatencyPercentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: durations[len(durations)-1]}
+}
+
+// runBenchCommand implements `bench`
+func runBenchCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var opts BenchOptions
+	fs.StringVar(&opts.Task, "task", "noop", "Synthetic task (noop, sleep, cpu)")
+	fs.DurationVar(&opts.Duration, "duration", 10*time.Millisecond, "How long sleep and cpu tasks run")
+	fs.IntVar(&opts.Count, "count", 10000, "Number of tasks")
+	fs.IntVar(&opts.Concurrency, "concurrency", 0, "Tasks processed at once (default: the worker's concurrency)")
+	fs.IntVar(&opts.Producers, "producers", 4, "Goroutines enqueueing tasks")
+	fs.IntVar(&opts.PayloadSize, "payload", 128, "Payload size in bytes")
+	fs.StringVar(&opts.Queue, "queue", "workerd:bench", "Queue used for the run, deleted afterwards")
+	timeout := fs.Duration("timeout", 10*time.Minute, "Maximum duration of the run")
+	output := fs.String("output", "text", "Output format (text, json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: %s", benchCommandUsage)
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("unknown output format %q (valid formats: text, json)", *output)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	res, err := w.Bench(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return printBench(os.Stdout, res, *output)
+}
+
+// printBench renders a benchmark result as a text table or JSON
+func printBench(out io.Writer, res *BenchResult, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+
+	fmt.Fprintf(out, "%d %s tasks in %v: concurrency %d, %d producers, %d failed to enqueue\n\n",
+		res.Count, res.Task, res.Duration.Round(time.Millisecond), res.Concurrency, res.Producers, res.Failed)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tTASKS/S\tP50\tP90\tP99\tMAX")
+	for _, row := range []struct {
+		phase string
+		rate  float64
+		l     LatencyPercentiles
+	}{
+		{"enqueue", res.EnqueueRate, res.EnqueueLatency},
+		{"end to end", res.ProcessRate, res.Latency},
+	} {
+		fmt.Fprintf(tw, "%s\t%.0f\t%v\t%v\t%v\t%v\n", row.phase, row.rate, row.l.P50.Round(time.Microsecond),
+			row.l.P90.Round(time.Microsecond), row.l.P99.Round(time.Microsecond), row.l.Max.Round(time.Microsecond))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,170 @@
+package workerd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blobEncoding is recorded in the envelope as "blob:<key>"
+const blobEncoding = "blob"
+
+// Supported blob stores
+const (
+	BlobStoreFile = "file"
+)
+
+// BlobConfig configures the claim-check store for oversized payloads.
+// Payloads above the threshold are written to the store and Redis only holds
+// a reference; workers fetch the blob before the handler runs.
+type BlobConfig struct {
+	// "file"; empty disables offloading unless a store is set with WithBlobStore
+	Store string `json:"store" yaml:"store" env:"WORKER_BLOB_STORE"`
+
+	// Directory of the file store, shared by producers and workers
+	Dir string `json:"dir" yaml:"dir" env:"WORKER_BLOB_DIR"`
+
+	// Payloads larger than this many bytes are offloaded
+	Threshold int `json:"threshold" yaml:"threshold" env:"WORKER_BLOB_THRESHOLD" default:"1048576"`
+}
+
+// validate validates the blob configuration
+func (bc *BlobConfig) validate() error {
+	switch bc.Store {
+	case "":
+	case BlobStoreFile:
+		if bc.Dir == "" {
+			return fmt.Errorf("blob dir is required for the file store")
+		}
+	default:
+		return fmt.Errorf("unknown blob store %q, expected %q", bc.Store, BlobStoreFile)
+	}
+	if bc.Threshold < 0 {
+		return fmt.Errorf("blob threshold must be non-negative, got %d", bc.Threshold)
+	}
+	return nil
+}
+
+// BlobStore holds oversized payloads, e.g. on a shared volume, S3 or GCS
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// blobStore opens the configured store, or returns nil if none is configured
+func (bc *BlobConfig) blobStore() (BlobStore, error) {
+	switch bc.Store {
+	case BlobStoreFile:
+		if err := os.MkdirAll(bc.Dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create blob dir: %w", err)
+		}
+		return &fileBlobStore{dir: bc.Dir}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// fileBlobStore keeps one file per blob in a directory
+type fileBlobStore struct {
+	dir string
+}
+
+func (s *fileBlobStore) path(key string) (string, error) {
+	if !filepath.IsLocal(key) || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+func (s *fileBlobStore) Put(_ context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	// Write then rename so workers never read a partial blob
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *fileBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (s *fileBlobStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// blobEncoder offloads payloads larger than threshold to the store
+func blobEncoder(store BlobStore, threshold int) payloadEncoder {
+	return func(ctx context.Context, payload []byte) (string, []byte, error) {
+		if len(payload) <= threshold {
+			return "", payload, nil
+		}
+		key, err := newBlobKey()
+		if err != nil {
+			return "", nil, err
+		}
+		if err := store.Put(ctx, key, payload); err != nil {
+			return "", nil, fmt.Errorf("failed to store payload blob: %w", err)
+		}
+		return blobEncoding + ":" + key, nil, nil
+	}
+}
+
+// blobDecoder fetches the payload referenced by the envelope
+func blobDecoder(store BlobStore) payloadDecoder {
+	return func(ctx context.Context, key string, _ []byte) ([]byte, error) {
+		return store.Get(ctx, key)
+	}
+}
+
+// blobKeys returns the keys of the blobs referenced by encodings
+func blobKeys(encodings []string) []string {
+	var keys []string
+	for _, enc := range encodings {
+		if algorithm, key, _ := strings.Cut(enc, ":"); algorithm == blobEncoding {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// deleteBlobs removes the blobs referenced by encodings once they are no longer needed
+func deleteBlobs(ctx context.Context, store BlobStore, encodings []string, logger *slog.Logger) {
+	if store == nil {
+		return
+	}
+	for _, key := range blobKeys(encodings) {
+		if err := store.Delete(context.WithoutCancel(ctx), key); err != nil {
+			logger.Warn("could not delete payload blob", "key", key, "error", err)
+		}
+	}
+}
+
+func newBlobKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,161 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// errBulkheadFull is returned for tasks postponed because a resource pool
+// they need is in use. The task is requeued to try again later, so it keeps
+// its retries.
+var errBulkheadFull = errors.New("resource pool is full")
+
+// BulkheadRule tags a task type with the resource pools its handler uses
+type BulkheadRule struct {
+	// Task type the rule applies to: an exact type name or a prefix such as "email:"
+	Type string `json:"type" yaml:"type"`
+
+	// Names of the pools the handler holds a slot of while it runs
+	Pools []string `json:"pools" yaml:"pools"`
+}
+
+// BulkheadConfig limits how many handlers of each worker process use a
+// dependency at once, so one slow dependency can't take all worker slots
+type BulkheadConfig struct {
+	// Concurrent slots by pool name, e.g. {"smtp": 5, "image-cpu": 2}
+	Pools map[string]int `json:"pools" yaml:"pools"`
+
+	Rules []BulkheadRule `json:"rules" yaml:"rules"`
+
+	// How long a task waits for a slot before it is put back in the queue
+	Wait time.Duration `json:"wait" yaml:"wait" env:"WORKER_BULKHEAD_WAIT" default:"1s"`
+
+	// Delay before a task that got no slot is tried again
+	RetryDelay time.Duration `json:"retryDelay" yaml:"retryDelay" env:"WORKER_BULKHEAD_RETRY_DELAY" default:"5s"`
+}
+
+// validate validates the bulkhead configuration
+func (bc *BulkheadConfig) validate() error {
+	for name, size := range bc.Pools {
+		if size <= 0 {
+			return fmt.Errorf("bulkhead pool %q: size must be positive, got %d", name, size)
+		}
+	}
+	seen := make(map[string]bool, len(bc.Rules))
+	for i, r := range bc.Rules {
+		if strings.TrimSpace(r.Type) == "" {
+			return fmt.Errorf("bulkhead rule %d: type cannot be empty", i)
+		}
+		if seen[r.Type] {
+			return fmt.Errorf("bulkhead rule %q: duplicate type", r.Type)
+		}
+		seen[r.Type] = true
+		if len(r.Pools) == 0 {
+			return fmt.Errorf("bulkhead rule %q: pools cannot be empty", r.Type)
+		}
+		for _, p := range r.Pools {
+			if _, ok := bc.Pools[p]; !ok {
+				return fmt.Errorf("bulkhead rule %q: unknown pool %q", r.Type, p)
+			}
+		}
+	}
+	if bc.Wait < 0 || bc.RetryDelay < 0 {
+		return fmt.Errorf("bulkhead wait and retry delay must be non-negative")
+	}
+	return nil
+}
+
+// ruleFor returns the most specific rule matching the task type, or nil
+func (bc *BulkheadConfig) ruleFor(taskType string) *BulkheadRule {
+	var best *BulkheadRule
+	for i := range bc.Rules {
+		r := &bc.Rules[i]
+		if r.Type == taskType {
+			return r
+		}
+		if strings.HasPrefix(taskType, r.Type) && (best == nil || len(r.Type) > len(best.Type)) {
+			best = r
+		}
+	}
+	return best
+}
+
+// bulkheads holds one semaphore per pool
+type bulkheads map[string]chan struct{}
+
+func newBulkheads(config *BulkheadConfig) bulkheads {
+	b := make(bulkheads, len(config.Pools))
+	for name, size := range config.Pools {
+		b[name] = make(chan struct{}, size)
+	}
+	return b
+}
+
+// acquire takes a slot of every pool, in name order so tasks needing several
+// pools don't block each other, and returns the function releasing them
+func (b bulkheads) acquire(ctx context.Context, pools []string, wait time.Duration) (func(), error) {
+	pools = slices.Sorted(slices.Values(pools))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	held := make([]chan struct{}, 0, len(pools))
+	release := func() {
+		for _, sem := range held {
+			<-sem
+		}
+	}
+	for _, name := range pools {
+		sem := b[name]
+		select {
+		case sem <- struct{}{}:
+			held = append(held, sem)
+			continue
+		default:
+		}
+		select {
+		case sem <- struct{}{}:
+			held = append(held, sem)
+		case <-timer.C:
+			release()
+			return nil, fmt.Errorf("%w: %s", errBulkheadFull, name)
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// bulkheadMiddleware runs a handler only once it holds a slot of every pool
+// its task type is tagged with. Tasks that get no slot in time are requeued
+// to try again later, without using up a retry.
+func bulkheadMiddleware(config *BulkheadConfig) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if len(config.Rules) == 0 {
+			return next
+		}
+		pools := newBulkheads(config)
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			r := config.ruleFor(t.Type())
+			if r == nil {
+				return next.ProcessTask(ctx, t)
+			}
+
+			release, err := pools.acquire(ctx, r.Pools, config.Wait)
+			if errors.Is(err, errBulkheadFull) {
+				return requeueIn(ctx, config.RetryDelay, err)
+			}
+			if err != nil {
+				return err
+			}
+			defer release()
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
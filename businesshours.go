@@ -0,0 +1,193 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// errOutsideBusinessHours is returned for tasks postponed until their
+// business hours. The task is requeued to run at the next opening, so it
+// keeps its retries.
+var errOutsideBusinessHours = errors.New("outside business hours")
+
+// BusinessHoursRule restricts a task type to working hours
+type BusinessHoursRule struct {
+	// Task type the rule applies to: an exact type name or a prefix such as "sms:"
+	Type string `json:"type" yaml:"type"`
+
+	// IANA time zone of the hours; empty means UTC
+	TZ string `json:"tz" yaml:"tz"`
+
+	// Working days such as "mon" or "fri"; empty means every day
+	Days []string `json:"days" yaml:"days"`
+
+	// Opening and closing time of day as "15:04"; the closing time is
+	// excluded and must be after the opening time
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+
+	// Dates as "2006-01-02" on which the type is not processed at all
+	Holidays []string `json:"holidays" yaml:"holidays"`
+}
+
+// BusinessHoursConfig restricts task types to business hours. Clients
+// schedule tasks enqueued outside the hours for the next opening; workers
+// postpone tasks that become due outside them, e.g. retries.
+type BusinessHoursConfig struct {
+	Rules []BusinessHoursRule `json:"rules" yaml:"rules"`
+}
+
+// validate validates the business hours
+func (bc *BusinessHoursConfig) validate() error {
+	_, err := newBusinessCalendar(bc)
+	return err
+}
+
+// weekdays maps day names to time.Weekday
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// businessWindow is a parsed rule
+type businessWindow struct {
+	pattern  string
+	loc      *time.Location
+	days     [7]bool
+	start    time.Duration
+	end      time.Duration
+	holidays map[string]bool
+}
+
+// next returns t if it is within business hours, or the next opening
+func (bw *businessWindow) next(t time.Time) time.Time {
+	local := t.In(bw.loc)
+	y, m, d := local.Date()
+	// Holidays may close a few weeks in a row; a year is plenty
+	for i := range 366 {
+		day := time.Date(y, m, d+i, 0, 0, 0, 0, bw.loc)
+		if !bw.days[day.Weekday()] || bw.holidays[day.Format(time.DateOnly)] {
+			continue
+		}
+		// Minutes past midnight on the wall clock, right on DST changes too
+		open := time.Date(y, m, d+i, 0, int(bw.start/time.Minute), 0, 0, bw.loc)
+		if i > 0 || local.Before(open) {
+			return open
+		}
+		if local.Before(time.Date(y, m, d+i, 0, int(bw.end/time.Minute), 0, 0, bw.loc)) {
+			return t
+		}
+	}
+	return t
+}
+
+// businessCalendar holds the parsed business hours rules
+type businessCalendar struct {
+	windows []businessWindow
+}
+
+// newBusinessCalendar parses the rules, or returns nil without rules
+func newBusinessCalendar(config *BusinessHoursConfig) (*businessCalendar, error) {
+	if len(config.Rules) == 0 {
+		return nil, nil
+	}
+	c := &businessCalendar{}
+	seen := make(map[string]bool, len(config.Rules))
+	for _, r := range config.Rules {
+		if strings.TrimSpace(r.Type) == "" {
+			return nil, fmt.Errorf("business hours rule: type cannot be empty")
+		}
+		if seen[r.Type] {
+			return nil, fmt.Errorf("business hours rule %q: duplicate type", r.Type)
+		}
+		seen[r.Type] = true
+
+		bw := businessWindow{pattern: r.Type, loc: time.UTC, holidays: make(map[string]bool, len(r.Holidays))}
+		if r.TZ != "" {
+			loc, err := time.LoadLocation(r.TZ)
+			if err != nil {
+				return nil, fmt.Errorf("business hours rule %q: invalid time zone %q: %w", r.Type, r.TZ, err)
+			}
+			bw.loc = loc
+		}
+		if len(r.Days) == 0 {
+			bw.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		for _, name := range r.Days {
+			day, ok := weekdays[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("business hours rule %q: unknown day %q, expected mon, tue, wed, thu, fri, sat or sun", r.Type, name)
+			}
+			bw.days[day] = true
+		}
+		var err error
+		if bw.start, err = parseTimeOfDay(r.Start); err != nil {
+			return nil, fmt.Errorf("business hours rule %q: invalid start: %w", r.Type, err)
+		}
+		if bw.end, err = parseTimeOfDay(r.End); err != nil {
+			return nil, fmt.Errorf("business hours rule %q: invalid end: %w", r.Type, err)
+		}
+		if bw.end <= bw.start {
+			return nil, fmt.Errorf("business hours rule %q: end %s must be after start %s", r.Type, r.End, r.Start)
+		}
+		for _, h := range r.Holidays {
+			if _, err := time.Parse(time.DateOnly, h); err != nil {
+				return nil, fmt.Errorf("business hours rule %q: invalid holiday %q, expected YYYY-MM-DD", r.Type, h)
+			}
+			bw.holidays[h] = true
+		}
+		c.windows = append(c.windows, bw)
+	}
+	return c, nil
+}
+
+// parseTimeOfDay parses "15:04" into the time since midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day like 09:30", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// windowFor returns the most specific window matching the task type, or nil
+func (c *businessCalendar) windowFor(taskType string) *businessWindow {
+	if c == nil {
+		return nil
+	}
+	var best *businessWindow
+	for i := range c.windows {
+		bw := &c.windows[i]
+		if bw.pattern == taskType {
+			return bw
+		}
+		if matchTaskType(bw.pattern, taskType) && (best == nil || len(bw.pattern) > len(best.pattern)) {
+			best = bw
+		}
+	}
+	return best
+}
+
+// businessHoursMiddleware requeues tasks to run when the business hours of
+// their type begin
+func businessHoursMiddleware(c *businessCalendar) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if c == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			if bw := c.windowFor(t.Type()); bw != nil {
+				now := time.Now()
+				if open := bw.next(now); open.After(now) {
+					return requeueAt(ctx, open, errOutsideBusinessHours)
+				}
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ErrInjectedFault is returned by handlers failed on purpose in chaos mode
+var ErrInjectedFault = errors.New("chaos: injected fault")
+
+// ChaosConfig injects failures into task processing, to check that handlers
+// are idempotent and that retries behave before going to production. It is
+// meant for test and staging environments only.
+type ChaosConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_CHAOS_ENABLED" default:"false"`
+
+	// Fraction of tasks failing with ErrInjectedFault instead of running
+	ErrorRate float64 `json:"errorRate" yaml:"errorRate" env:"WORKER_CHAOS_ERROR_RATE" default:"0"`
+
+	// Fraction of tasks panicking instead of running
+	PanicRate float64 `json:"panicRate" yaml:"panicRate" env:"WORKER_CHAOS_PANIC_RATE" default:"0"`
+
+	// Fraction of tasks whose context is canceled at a random point within
+	// MaxDelay while the handler runs
+	CancelRate float64 `json:"cancelRate" yaml:"cancelRate" env:"WORKER_CHAOS_CANCEL_RATE" default:"0"`
+
+	// Fraction of tasks delayed by up to MaxDelay before running
+	DelayRate float64 `json:"delayRate" yaml:"delayRate" env:"WORKER_CHAOS_DELAY_RATE" default:"0"`
+
+	MaxDelay time.Duration `json:"maxDelay" yaml:"maxDelay" env:"WORKER_CHAOS_MAX_DELAY" default:"5s"`
+
+	// Task types or type prefixes faults are injected into; empty means every
+	// type except workerd's built-in tasks
+	Types []string `json:"types" yaml:"types"`
+
+	// Queues faults are injected into; empty means every queue
+	Queues []string `json:"queues" yaml:"queues"`
+}
+
+// validate validates the chaos configuration
+func (cc *ChaosConfig) validate() error {
+	if !cc.Enabled {
+		return nil
+	}
+	total := 0.0
+	for name, rate := range map[string]float64{
+		"error": cc.ErrorRate, "panic": cc.PanicRate, "cancel": cc.CancelRate, "delay": cc.DelayRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("chaos %s rate must be in [0, 1], got %v", name, rate)
+		}
+		total += rate
+	}
+	if total > 1 {
+		return fmt.Errorf("chaos rates must add up to at most 1, got %v", total)
+	}
+	if (cc.CancelRate > 0 || cc.DelayRate > 0) && cc.MaxDelay <= 0 {
+		return fmt.Errorf("chaos max delay must be positive, got %v", cc.MaxDelay)
+	}
+	return nil
+}
+
+// targets reports whether faults are injected into the task
+func (cc *ChaosConfig) targets(queue, taskType string) bool {
+	if len(cc.Queues) > 0 && !slices.Contains(cc.Queues, queue) {
+		return false
+	}
+	if len(cc.Types) == 0 {
+		return !matchTaskType("workerd:", taskType)
+	}
+	for _, prefix := range cc.Types {
+		if matchTaskType(prefix, taskType) {
+			return true
+		}
+	}
+	return false
+}
+
+// chaosMiddleware injects the configured faults right before the handler, so
+// they go through the same retry, metrics and error reporting as real ones
+func chaosMiddleware(config *ChaosConfig) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if !config.Enabled {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			queue, _ := asynq.GetQueueName(ctx)
+			if !config.targets(queue, t.Type()) {
+				return next.ProcessTask(ctx, t)
+			}
+
+			roll := rand.Float64()
+			switch {
+			case roll < config.ErrorRate:
+				Logger(ctx).Warn("chaos: failing task", "type", t.Type())
+				return ErrInjectedFault
+
+			case roll < config.ErrorRate+config.PanicRate:
+				Logger(ctx).Warn("chaos: panicking", "type", t.Type())
+				panic(ErrInjectedFault)
+
+			case roll < config.ErrorRate+config.PanicRate+config.CancelRate:
+				after := rand.N(config.MaxDelay)
+				Logger(ctx).Warn("chaos: canceling task context", "type", t.Type(), "after", after)
+				ctx, cancel := context.WithTimeoutCause(ctx, after, ErrInjectedFault)
+				defer cancel()
+				return next.ProcessTask(ctx, t)
+
+			case roll < config.ErrorRate+config.PanicRate+config.CancelRate+config.DelayRate:
+				delay := rand.N(config.MaxDelay)
+				Logger(ctx).Warn("chaos: delaying task", "type", t.Type(), "delay", delay)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// CheckpointConfig configures the Redis checkpoint store used by
+// SaveCheckpoint and LoadCheckpoint
+type CheckpointConfig struct {
+	// Prefix of the Redis keys holding checkpoints
+	Prefix string `json:"prefix" yaml:"prefix" env:"WORKER_CHECKPOINT_PREFIX" default:"'workerd:checkpoint:'"`
+
+	// How long a checkpoint is kept after its last save; bounds the leftovers of archived tasks
+	TTL time.Duration `json:"ttl" yaml:"ttl" env:"WORKER_CHECKPOINT_TTL" default:"168h"`
+}
+
+// validate validates the checkpoint configuration
+func (cc *CheckpointConfig) validate() error {
+	if cc.Prefix == "" {
+		return fmt.Errorf("checkpoint prefix cannot be empty")
+	}
+	if cc.TTL <= 0 {
+		return fmt.Errorf("checkpoint TTL must be positive, got %v", cc.TTL)
+	}
+	return nil
+}
+
+// errNoCheckpoints is returned outside of a task handler
+var errNoCheckpoints = errors.New("checkpoints are only available inside a task handler")
+
+// checkpointStore keeps one checkpoint per task in Redis
+type checkpointStore struct {
+	rdb    redis.UniversalClient
+	config *CheckpointConfig
+}
+
+// newCheckpointStore creates the store on the asynq Redis
+func newCheckpointStore(config *CheckpointConfig, redisOpt asynq.RedisConnOpt) (*checkpointStore, error) {
+	rdb, ok := redisOpt.MakeRedisClient().(redis.UniversalClient)
+	if !ok {
+		return nil, fmt.Errorf("unsupported redis connection for checkpoints")
+	}
+	return &checkpointStore{rdb: rdb, config: config}, nil
+}
+
+func (s *checkpointStore) close() error {
+	return s.rdb.Close()
+}
+
+type checkpointKey struct{}
+
+// taskCheckpoint binds the store to the task being processed
+type taskCheckpoint struct {
+	store *checkpointStore
+	key   string
+	// exists is set once a checkpoint is saved or found, so tasks that never
+	// checkpoint cost no extra Redis round trip
+	exists atomic.Bool
+}
+
+// SaveCheckpoint stores v, encoded as JSON, as the progress of the running
+// task. A retry of the task reads it back with LoadCheckpoint; the checkpoint
+// is deleted once the task succeeds.
+func SaveCheckpoint(ctx context.Context, v any) error {
+	cp, ok := ctx.Value(checkpointKey{}).(*taskCheckpoint)
+	if !ok {
+		return errNoCheckpoints
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	// Saving progress should not be lost because the handler is being cancelled
+	ctx = context.WithoutCancel(ctx)
+	if err := cp.store.rdb.Set(ctx, cp.key, data, cp.store.config.TTL).Err(); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	cp.exists.Store(true)
+	return nil
+}
+
+// LoadCheckpoint decodes the last checkpoint of the running task into v.
+// It reports false if no checkpoint was saved, e.g. on the first attempt.
+func LoadCheckpoint(ctx context.Context, v any) (bool, error) {
+	cp, ok := ctx.Value(checkpointKey{}).(*taskCheckpoint)
+	if !ok {
+		return false, errNoCheckpoints
+	}
+	data, err := cp.store.rdb.Get(ctx, cp.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	cp.exists.Store(true)
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return true, nil
+}
+
+// checkpointMiddleware makes the checkpoint of each task available to its
+// handler and deletes it when the task succeeds
+func checkpointMiddleware(s *checkpointStore) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if s == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			id, _ := asynq.GetTaskID(ctx)
+			queue, _ := asynq.GetQueueName(ctx)
+			cp := &taskCheckpoint{store: s, key: s.config.Prefix + queue + ":" + id}
+
+			err := next.ProcessTask(context.WithValue(ctx, checkpointKey{}, cp), t)
+			if err == nil && cp.exists.Load() {
+				if derr := s.rdb.Del(context.WithoutCancel(ctx), cp.key).Err(); derr != nil {
+					Logger(ctx).Warn("could not delete checkpoint", "error", derr)
+				}
+			}
+			return err
+		})
+	}
+}
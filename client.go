@@ -0,0 +1,353 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Client enqueues tasks with the worker's configuration applied
+type Client struct {
+	client    *asynq.Client
+	shards    []*asynq.Client
+	config    *workerConfig
+	log       *slog.Logger
+	migration *dualWriter
+	audit     *auditLog
+	events    *eventPublisher
+	encoders  []payloadEncoder
+	blobs     BlobStore
+	calendar  *businessCalendar
+	values    RequestValuesFunc
+	// enqueue is enqueueTask wrapped in the middleware added with Use
+	enqueue     EnqueueFunc
+	middlewares []EnqueueMiddleware
+
+	// Rejects tasks for full queues; nil without limits
+	backpressure *backpressure
+}
+
+// Enqueuer is satisfied by *Client, *asynq.Client and workerdtest.Broker.
+// Code that enqueues tasks can accept it to be tested without a Redis server.
+type Enqueuer interface {
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+}
+
+// === Client Functional Options ===
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	configPath string
+	logger     *slog.Logger
+	keys       KeyProvider
+	blobs      BlobStore
+	values     RequestValuesFunc
+	// Connection of a client made by the worker, to share its pool metrics
+	redisOpt asynq.RedisConnOpt
+	// Overrides backpressure.maxPending when positive
+	maxPending int
+}
+
+func WithClientConfigPath(path string) ClientOption {
+	return func(o *clientOptions) {
+		o.configPath = path
+	}
+}
+
+func WithClientLogger(logger *slog.Logger) ClientOption {
+	return func(o *clientOptions) {
+		o.logger = logger
+	}
+}
+
+// WithClientKeyProvider encrypts payloads with keys from p, e.g. fetched from a
+// KMS, instead of the keys in the encryption config section
+func WithClientKeyProvider(p KeyProvider) ClientOption {
+	return func(o *clientOptions) {
+		o.keys = p
+	}
+}
+
+// WithClientBlobStore offloads oversized payloads to s, e.g. an S3 or GCS
+// bucket, instead of the store in the blobs config section
+func WithClientBlobStore(s BlobStore) ClientOption {
+	return func(o *clientOptions) {
+		o.blobs = s
+	}
+}
+
+// WithClientRequestValues reads the priority, trace ID and tenant applied to
+// enqueued tasks from the context with fn, e.g. from the values stored by an
+// existing HTTP middleware, instead of from WithRequestValues
+func WithClientRequestValues(fn RequestValuesFunc) ClientOption {
+	return func(o *clientOptions) {
+		o.values = fn
+	}
+}
+
+// NewClient creates a producer client loading configuration the same way as NewWorkerd
+func NewClient(opts ...ClientOption) (*Client, error) {
+	o := &clientOptions{}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(o)
+	}
+
+	config, err := newWorkerConfig(splitConfigPath(o.configPath)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load worker config: %w", err)
+	}
+
+	logger := o.logger
+	if logger == nil {
+		logger = newLogger(config.LogLevel, os.Stdout)
+	}
+
+	if o.keys == nil {
+		if o.keys, err = config.Encryption.keyProvider(); err != nil {
+			return nil, fmt.Errorf("failed to load encryption keys: %w", err)
+		}
+	}
+	if o.blobs == nil {
+		if o.blobs, err = config.Blobs.blobStore(); err != nil {
+			return nil, fmt.Errorf("failed to open blob store: %w", err)
+		}
+	}
+
+	return newClient(config, logger, o)
+}
+
+// newClient creates a client from an already loaded configuration
+func newClient(config *workerConfig, logger *slog.Logger, o *clientOptions) (*Client, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+
+	redisOpt := o.redisOpt
+	if redisOpt == nil {
+		var err error
+		if redisOpt, err = config.AsynqConfig.RedisConnOpt(); err != nil {
+			return nil, fmt.Errorf("failed to get Redis client options: %w", err)
+		}
+	}
+
+	calendar, err := newBusinessCalendar(&config.BusinessHours)
+	if err != nil {
+		return nil, fmt.Errorf("invalid business hours: %w", err)
+	}
+
+	c := &Client{
+		client:   asynq.NewClient(redisOpt),
+		config:   config,
+		log:      logger,
+		calendar: calendar,
+		values:   o.values,
+	}
+	if c.values == nil {
+		c.values = RequestValuesFromContext
+	}
+	c.enqueue = c.enqueueTask
+	for i := range config.AsynqConfig.Shards {
+		c.shards = append(c.shards, asynq.NewClient(config.AsynqConfig.shardConnOpt(i)))
+	}
+
+	if config.Migration.active() {
+		legacyOpt, err := config.Migration.legacyRedisOpt()
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("invalid migration configuration: %w", err)
+		}
+		c.migration = newDualWriter(&config.Migration, legacyOpt)
+	}
+
+	if config.Backpressure.enabled(o.maxPending) {
+		c.backpressure = newBackpressure(config, redisOpt, o.maxPending)
+	}
+
+	if enc := compressionEncoder(&config.Compression); enc != nil {
+		c.encoders = append(c.encoders, enc)
+	}
+	// Encrypt after compressing, ciphertext would not shrink
+	if o.keys != nil {
+		c.encoders = append(c.encoders, encryptionEncoder(o.keys))
+	}
+	// Offload last so blobs are stored compressed and encrypted
+	if o.blobs != nil {
+		c.blobs = o.blobs
+		c.encoders = append(c.encoders, blobEncoder(o.blobs, config.Blobs.Threshold))
+	}
+
+	c.audit, err = newAuditLog(&config.Audit, redisOpt, logger)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	c.events, err = newEventPublisher(&config.Events, redisOpt, logger)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to start event publisher: %w", err)
+	}
+
+	return c, nil
+}
+
+// Client returns a client sharing the worker's configuration, creating it on first use
+func (w *Workerd) Client() (*Client, error) {
+	if w.client != nil {
+		return w.client, nil
+	}
+
+	c, err := newClient(w.config, w.log, &clientOptions{keys: w.keys, blobs: w.blobs, redisOpt: w.redisOpt})
+	if err != nil {
+		return nil, err
+	}
+	w.client = c
+	return c, nil
+}
+
+// Enqueue enqueues a task using a background context
+func (c *Client) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	return c.EnqueueContext(context.Background(), task, opts...)
+}
+
+// EnqueueContext enqueues a task through the middleware added with Use.
+// Pass options here rather than to asynq.NewTask: workerd reads them to pick
+// the Redis and backpressure limit of the queue, and a task given metadata or
+// an encoded payload is rebuilt with these options only.
+func (c *Client) EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	return c.enqueue(ctx, task, opts...)
+}
+
+// enqueueTask enqueues a task, applying configured defaults before the caller's options
+func (c *Client) enqueueTask(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	if task == nil {
+		return nil, fmt.Errorf("task cannot be nil")
+	}
+
+	// Configured defaults and values inherited from a parent task come
+	// first so explicit options override them; the routing table overrides
+	// the queue inherited from the parent
+	inherited, md := inheritFromParent(ctx)
+	defaults := append(c.config.Retention.EnqueueOptions(task.Type()), inherited...)
+	defaults = append(defaults, c.config.Routing.EnqueueOptions(task.Type())...)
+	// Values of the request being served, e.g. its priority, come next
+	requested, err := c.requestOptions(ctx, md)
+	if err != nil {
+		return nil, err
+	}
+	defaults = append(defaults, requested...)
+	opts = append(defaults, opts...)
+	// Types with a heartbeat policy may run longer than asynq's default
+	// timeout, unless the caller bounded the run
+	if !hasRunLimit(opts) {
+		opts = append(c.config.Heartbeat.EnqueueOptions(task.Type()), opts...)
+	}
+
+	// Full queues are rejected before anything is stored
+	queue := queueOf(opts)
+	if c.backpressure != nil {
+		if err := c.backpressure.check(ctx, queue); err != nil {
+			return nil, err
+		}
+	}
+
+	// Tasks due outside the business hours of their type wait for the
+	// next opening
+	now := time.Now()
+	if bw := c.calendar.windowFor(task.Type()); bw != nil {
+		due := dueTime(opts, now)
+		if open := bw.next(due); open.After(due) {
+			opts = append(opts, asynq.ProcessAt(open))
+		}
+	}
+
+	// Tasks with an SLA carry their due time, unless set through WithMetadata
+	if _, ok := md[MetaDueAt]; !ok && c.config.SLA.ruleFor(task.Type()) != nil {
+		md[MetaDueAt] = dueTime(opts, now).UTC().Format(time.RFC3339Nano)
+	}
+
+	payload, encoding, err := c.encodePayload(ctx, task.Payload())
+	if err != nil {
+		return nil, err
+	}
+	if needsEnvelope(md) || len(encoding) > 0 {
+		payload, err = encodeEnvelope(envelopeHeader{Meta: md, Encoding: encoding}, payload)
+		if err != nil {
+			deleteBlobs(ctx, c.blobs, encoding, c.log)
+			return nil, fmt.Errorf("failed to encode task metadata: %w", err)
+		}
+		task = asynq.NewTask(task.Type(), payload)
+	}
+
+	info, err := c.clientFor(queue).EnqueueContext(ctx, task, opts...)
+	if err != nil {
+		deleteBlobs(ctx, c.blobs, encoding, c.log)
+		return nil, err
+	}
+
+	if c.migration != nil && c.migration.active() {
+		c.migration.write(ctx, c.log, task, info, opts)
+	}
+
+	if c.audit != nil {
+		c.audit.record(ctx, AuditAccepted, info.ID, info.Type, info.Queue, 0, nil)
+	}
+	if c.events != nil {
+		c.events.publish(EventEnqueued, info.ID, info.Type, info.Queue, 0, md, nil)
+	}
+
+	return info, nil
+}
+
+// encodePayload runs the configured encoders, such as compression, in order
+func (c *Client) encodePayload(ctx context.Context, payload []byte) ([]byte, []string, error) {
+	var encoding []string
+	for _, encode := range c.encoders {
+		name, out, err := encode(ctx, payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode payload: %w", err)
+		}
+		if name != "" {
+			encoding = append(encoding, name)
+			payload = out
+		}
+	}
+	return payload, encoding, nil
+}
+
+// Close closes the underlying Redis connections
+func (c *Client) Close() error {
+	if c.migration != nil {
+		if err := c.migration.close(); err != nil {
+			c.log.Warn("could not close legacy client", "error", err)
+		}
+	}
+	if c.audit != nil {
+		if err := c.audit.close(); err != nil {
+			c.log.Warn("could not close audit log", "error", err)
+		}
+	}
+	if c.events != nil {
+		if err := c.events.close(); err != nil {
+			c.log.Warn("could not close event publisher", "error", err)
+		}
+	}
+	if c.backpressure != nil {
+		if err := c.backpressure.close(); err != nil {
+			c.log.Warn("could not close backpressure connections", "error", err)
+		}
+	}
+	for _, shard := range c.shards {
+		if err := shard.Close(); err != nil {
+			c.log.Warn("could not close shard client", "error", err)
+		}
+	}
+	return c.client.Close()
+}
@@ -0,0 +1,32 @@
+package workerd
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+)
+
+// EnqueueFunc enqueues a task, like Client.EnqueueContext
+type EnqueueFunc func(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+
+// EnqueueMiddleware wraps the enqueueing of tasks, the producer counterpart
+// of asynq.MiddlewareFunc, e.g. to trace, measure, validate or transform
+// tasks before they reach Redis
+type EnqueueMiddleware func(next EnqueueFunc) EnqueueFunc
+
+// Use adds middleware around every enqueue of the client, the first one
+// outermost. Middleware sees the task and options as passed by the caller,
+// before configured defaults, compression and encryption are applied. Add
+// middleware before enqueueing tasks.
+func (c *Client) Use(mws ...EnqueueMiddleware) {
+	for _, mw := range mws {
+		if mw != nil {
+			c.middlewares = append(c.middlewares, mw)
+		}
+	}
+	enqueue := EnqueueFunc(c.enqueueTask)
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		enqueue = c.middlewares[i](enqueue)
+	}
+	c.enqueue = enqueue
+}
@@ -0,0 +1,117 @@
+package workerd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a CLI subcommand executed instead of running the service
+type command struct {
+	usage string
+	run   func(w *Workerd, args []string) error
+}
+
+const configCommandUsage = "config print [-output text|json]  print the effective configuration"
+
+// commands holds the subcommands available through WorkerdWithFlags
+var commands = map[string]command{
+	"archive": {
+		usage: archiveCommandUsage,
+		run:   runArchiveCommand,
+	},
+	"bench": {
+		usage: benchCommandUsage,
+		run:   runBenchCommand,
+	},
+	"config": {
+		usage: configCommandUsage,
+		run:   runConfigCommand,
+	},
+	"drain": {
+		usage: drainCommandUsage,
+		run:   runDrainCommand,
+	},
+	"enqueue": {
+		usage: enqueueCommandUsage,
+		run:   runEnqueueCommand,
+	},
+	"handlers": {
+		usage: handlersCommandUsage,
+		run:   runHandlersCommand,
+	},
+	"history": {
+		usage: historyCommandUsage,
+		run:   runHistoryCommand,
+	},
+	"profile": {
+		usage: profileCommandUsage,
+		run:   runProfileCommand,
+	},
+	"queues": {
+		usage: queuesCommandUsage,
+		run:   runQueuesCommand,
+	},
+	"replay": {
+		usage: replayCommandUsage,
+		run:   runReplayCommand,
+	},
+	"selftest": {
+		usage: selfTestCommandUsage,
+		run:   runSelfTestCommand,
+	},
+	"stats": {
+		usage: statsCommandUsage,
+		run:   runStatsCommand,
+	},
+	"tasks": {
+		usage: tasksCommandUsage,
+		run:   runTasksCommand,
+	},
+	"workers": {
+		usage: workersCommandUsage,
+		run:   runWorkersCommand,
+	},
+}
+
+// runCommand dispatches a subcommand by name
+func runCommand(w *Workerd, args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], commandsUsage())
+	}
+	return cmd.run(w, args[1:])
+}
+
+// commandsUsage lists the available subcommands
+func commandsUsage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %s\n", commands[name].usage)
+	}
+	return b.String()
+}
+
+// runConfigCommand implements `config print`
+func runConfigCommand(w *Workerd, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return fmt.Errorf("usage: %s", configCommandUsage)
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	output := fs.String("output", "text", "Output format (text, json)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	return printConfig(os.Stdout, w.EffectiveConfig(), *output)
+}
@@ -0,0 +1,148 @@
+package workerd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported payload compression algorithms
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// CompressionConfig configures transparent compression of large payloads.
+// Workers always decompress, whatever their own setting.
+type CompressionConfig struct {
+	// "gzip" or "zstd"; empty disables compression on enqueue
+	Algorithm string `json:"algorithm" yaml:"algorithm" env:"WORKER_COMPRESSION_ALGORITHM"`
+
+	// Payloads smaller than this many bytes are stored as is
+	MinSize int `json:"minSize" yaml:"minSize" env:"WORKER_COMPRESSION_MIN_SIZE" default:"16384"`
+}
+
+// validate validates the compression configuration
+func (cc *CompressionConfig) validate() error {
+	switch cc.Algorithm {
+	case "", CompressionGzip, CompressionZstd:
+	default:
+		return fmt.Errorf("unknown compression algorithm %q, expected %q or %q", cc.Algorithm, CompressionGzip, CompressionZstd)
+	}
+	if cc.MinSize < 0 {
+		return fmt.Errorf("compression min size must be non-negative, got %d", cc.MinSize)
+	}
+	return nil
+}
+
+// payloadEncoder transforms a payload on enqueue. It returns the encoding
+// name to record in the envelope, or an empty name if it left the payload as is.
+type payloadEncoder func(ctx context.Context, payload []byte) (name string, out []byte, err error)
+
+// payloadDecoder reverses one encoding, given the argument recorded with it
+type payloadDecoder func(ctx context.Context, arg string, payload []byte) ([]byte, error)
+
+// payloadDecoders reverse payload encodings by algorithm. An encoding is
+// recorded as "algorithm" or "algorithm:arg", e.g. "aesgcm:<key id>".
+type payloadDecoders map[string]payloadDecoder
+
+// decode reverses encodings, which are listed in the order they were applied
+func (d payloadDecoders) decode(ctx context.Context, encodings []string, payload []byte) ([]byte, error) {
+	for i := len(encodings) - 1; i >= 0; i-- {
+		algorithm, arg, _ := strings.Cut(encodings[i], ":")
+		decode, ok := d[algorithm]
+		if !ok {
+			return nil, fmt.Errorf("unsupported payload encoding %q", encodings[i])
+		}
+		var err error
+		if payload, err = decode(ctx, arg, payload); err != nil {
+			return nil, fmt.Errorf("failed to decode %s payload: %w", algorithm, err)
+		}
+	}
+	return payload, nil
+}
+
+// defaultPayloadDecoders returns the decoders every worker supports
+func defaultPayloadDecoders() payloadDecoders {
+	return payloadDecoders{
+		CompressionGzip: func(_ context.Context, _ string, payload []byte) ([]byte, error) { return gunzip(payload) },
+		CompressionZstd: func(_ context.Context, _ string, payload []byte) ([]byte, error) { return unzstd(payload) },
+	}
+}
+
+// compressionEncoder returns the encoder for the configured algorithm, or nil if disabled.
+// The compressed payload is only kept when it is smaller than the original.
+func compressionEncoder(config *CompressionConfig) payloadEncoder {
+	var compress func([]byte) ([]byte, error)
+	switch config.Algorithm {
+	case CompressionGzip:
+		compress = gzipPayload
+	case CompressionZstd:
+		compress = zstdPayload
+	default:
+		return nil
+	}
+
+	return func(_ context.Context, payload []byte) (string, []byte, error) {
+		if len(payload) < config.MinSize {
+			return "", payload, nil
+		}
+		out, err := compress(payload)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(out) >= len(payload) {
+			return "", payload, nil
+		}
+		return config.Algorithm, out, nil
+	}
+}
+
+func gzipPayload(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzip(payload []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// Shared zstd encoder and decoder; EncodeAll and DecodeAll are safe for concurrent use
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) })
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) { return zstd.NewReader(nil) })
+)
+
+func zstdPayload(payload []byte) ([]byte, error) {
+	enc, err := zstdEncoder()
+	if err != nil {
+		return nil, err
+	}
+	return enc.EncodeAll(payload, nil), nil
+}
+
+func unzstd(payload []byte) ([]byte, error) {
+	dec, err := zstdDecoder()
+	if err != nil {
+		return nil, err
+	}
+	return dec.DecodeAll(payload, nil)
+}
@@ -21,7 +21,7 @@ type RedisClient struct {
 	Username string `json:"username" yaml:"username" env:"ASYNQ_REDIS_USERNAME"`
 
 	// Password to authenticate the current connection.
-	Password string `json:"password" yaml:"password" env:"ASYNQ_REDIS_PASSWORD"`
+	Password string `json:"password" yaml:"password" env:"ASYNQ_REDIS_PASSWORD" secret:"true"`
 
 	// Redis DB to select after connecting to a server.
 	DB int `json:"db" yaml:"db" env:"ASYNQ_REDIS_DB" default:"0"`
@@ -39,12 +39,54 @@ type RedisClient struct {
 	// Maximum number of socket connections.
 	// Default is 10 connections per every CPU.
 	PoolSize int `json:"poolSize" yaml:"poolSize" env:"ASYNQ_REDIS_POOL_SIZE" default:"10"`
+
+	// Idle connections kept open, so bursts do not wait for new ones
+	MinIdleConns int `json:"minIdleConns" yaml:"minIdleConns" env:"ASYNQ_REDIS_MIN_IDLE_CONNS" default:"0"`
+
+	// How long a command waits for a free connection once PoolSize are in
+	// use. Default is ReadTimeout + 1 second.
+	PoolTimeout time.Duration `json:"poolTimeout" yaml:"poolTimeout" env:"ASYNQ_REDIS_POOL_TIMEOUT" default:"0s"`
+
+	// How long a connection may stay idle before it is closed. Default is
+	// 30 minutes; negative keeps idle connections open.
+	ConnMaxIdleTime time.Duration `json:"connMaxIdleTime" yaml:"connMaxIdleTime" env:"ASYNQ_REDIS_CONN_MAX_IDLE_TIME" default:"0s"`
+
+	// Locate the primary through Redis Sentinel instead of Addr
+	Sentinel RedisSentinel `json:"sentinel" yaml:"sentinel"`
+}
+
+// target describes the Redis server connected to, for the logs
+func (rc *RedisClient) target() string {
+	if rc.Sentinel.enabled() {
+		return "sentinel:" + rc.Sentinel.MasterName
+	}
+	return rc.Addr
 }
 
 type AsynqConfig struct {
 	RedisClient RedisClient `json:"redisClient" yaml:"redisClient" required:"true"`
+
+	// Connection monitoring and startup behaviour when Redis is unreachable
+	Connection ConnectionConfig `json:"connection" yaml:"connection"`
+
+	// Replica that stats, history and the admin API read from
+	ReadReplica RedisReplica `json:"readReplica" yaml:"readReplica"`
+
+	// Prefix of every Redis key, so deployments sharing one Redis do not
+	// consume each other's queues; empty uses asynq's keys as they are
+	Namespace string `json:"namespace" yaml:"namespace" env:"ASYNQ_NAMESPACE"`
+
+	// Redis servers storing some of the queues instead of the primary, each
+	// consumed by its own asynq server
+	Shards []RedisShard `json:"shards" yaml:"shards"`
+
+	// Polling intervals of the asynq servers
+	Server ServerTuning `json:"server" yaml:"server"`
 }
 
+// GetRedisClientOpt returns the options of a direct connection to
+// RedisClient.Addr, ignoring Sentinel and the pool settings asynq's options
+// lack; RedisConnOpt honours both.
 func (a *AsynqConfig) GetRedisClientOpt() (*asynq.RedisClientOpt, error) {
 	if a == nil {
 		return nil, fmt.Errorf("AsynqConfig is nil")
@@ -67,9 +109,26 @@ func (a *AsynqConfig) GetRedisClientOpt() (*asynq.RedisClientOpt, error) {
 	}, nil
 }
 
+// RedisConnOpt returns the options of the connection to Redis, through
+// Sentinel when it is configured and with keys under the namespace
+func (a *AsynqConfig) RedisConnOpt() (asynq.RedisConnOpt, error) {
+	if a == nil {
+		return nil, fmt.Errorf("AsynqConfig is nil")
+	}
+	if err := a.validate(); err != nil {
+		return nil, fmt.Errorf("invalid asynq configuration: %w", err)
+	}
+
+	rc := &a.RedisClient
+	if rc.Sentinel.enabled() {
+		return a.namespaced(redisFailoverOpt{rc.failoverOptions(false)}), nil
+	}
+	return a.namespaced(redisClientOpt{rc.options(rc.Network, rc.Addr)}), nil
+}
+
 // validate validates the AsynqConfig and its RedisClient configuration
 func (a *AsynqConfig) validate() error {
-	if a.RedisClient.Addr == "" {
+	if a.RedisClient.Addr == "" && !a.RedisClient.Sentinel.enabled() {
 		return fmt.Errorf("redis address cannot be empty")
 	}
 	if a.RedisClient.Network == "" {
@@ -78,8 +137,8 @@ func (a *AsynqConfig) validate() error {
 	if a.RedisClient.DB < 0 {
 		return fmt.Errorf("redis DB must be non-negative, got %d", a.RedisClient.DB)
 	}
-	if a.RedisClient.PoolSize <= 0 {
-		return fmt.Errorf("redis pool size must be positive, got %d", a.RedisClient.PoolSize)
+	if err := a.RedisClient.validatePool(); err != nil {
+		return err
 	}
 	if a.RedisClient.DialTimeout <= 0 {
 		return fmt.Errorf("redis dial timeout must be positive, got %v", a.RedisClient.DialTimeout)
@@ -90,17 +149,91 @@ func (a *AsynqConfig) validate() error {
 	if a.RedisClient.WriteTimeout <= 0 {
 		return fmt.Errorf("redis write timeout must be positive, got %v", a.RedisClient.WriteTimeout)
 	}
-	return nil
+	if err := a.RedisClient.Sentinel.validate(); err != nil {
+		return fmt.Errorf("redis sentinel: %w", err)
+	}
+	if err := a.ReadReplica.validate(&a.RedisClient); err != nil {
+		return fmt.Errorf("redis read replica: %w", err)
+	}
+	if err := validateNamespace(a.Namespace); err != nil {
+		return err
+	}
+	if err := validateShards(a.Shards, a.RedisClient.Network); err != nil {
+		return fmt.Errorf("redis shards: %w", err)
+	}
+	if err := a.Server.validate(); err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+	return a.Connection.validate()
 }
 
 // workerConfig defines the workers's settings
 type workerConfig struct {
-	AsynqConfig *AsynqConfig `json:"asynq" yaml:"asynq"`
-	LogLevel    slog.Level   `json:"loglevel" yaml:"loglevel" env:"LOG_LEVEL" default:"DEBUG"`
-	Name        string       `json:"name" yaml:"name" env:"WORKER_NAME" default:"workerd"`
-	DisplayName string       `json:"display_name" yaml:"display_name" env:"WORKER_DISPLAY_NAME" default:"Workerd Service"`
-	Description string       `json:"description" yaml:"description" env:"WORKER_DESCRIPTION" default:"Default background worker service"`
-	Concurrency int          `json:"concurrency" yaml:"concurrency" env:"WORKER_CONCURRENCY" default:"10"`
+	AsynqConfig *AsynqConfig  `json:"asynq" yaml:"asynq"`
+	LogLevel    slog.Level    `json:"loglevel" yaml:"loglevel" env:"LOG_LEVEL" default:"DEBUG"`
+	Logging     LoggingConfig `json:"logging" yaml:"logging"`
+	Name        string        `json:"name" yaml:"name" env:"WORKER_NAME" default:"workerd"`
+	DisplayName string        `json:"display_name" yaml:"display_name" env:"WORKER_DISPLAY_NAME" default:"Workerd Service"`
+	Description string        `json:"description" yaml:"description" env:"WORKER_DESCRIPTION" default:"Default background worker service"`
+	Concurrency int           `json:"concurrency" yaml:"concurrency" env:"WORKER_CONCURRENCY" default:"10"`
+	PIDFile     string        `json:"pid_file" yaml:"pid_file" env:"WORKER_PID_FILE"`
+	// Queues to process with their relative priority; empty means {"default": 1}
+	Queues     map[string]int      `json:"queues" yaml:"queues"`
+	Retention  RetentionConfig     `json:"retention" yaml:"retention"`
+	Migration  MigrationConfig     `json:"migration" yaml:"migration"`
+	Handlers   HandlersConfig      `json:"handlers" yaml:"handlers"`
+	Admin      AdminConfig         `json:"admin" yaml:"admin"`
+	Metrics    MetricsConfig       `json:"metrics" yaml:"metrics"`
+	Sentry     SentryConfig        `json:"sentry" yaml:"sentry"`
+	Audit      AuditConfig         `json:"audit" yaml:"audit"`
+	Events     EventsConfig        `json:"events" yaml:"events"`
+	Heartbeat  HeartbeatConfig     `json:"heartbeat" yaml:"heartbeat"`
+	Checkpoint CheckpointConfig    `json:"checkpoint" yaml:"checkpoint"`
+	Priorities PriorityConfig      `json:"priorities" yaml:"priorities"`
+	Routing    RoutingConfig       `json:"routing" yaml:"routing"`
+	Registry   RegistryConfig      `json:"registry" yaml:"registry"`
+	Scheduler  SchedulerConfig     `json:"scheduler" yaml:"scheduler"`
+	Bulkheads  BulkheadConfig      `json:"bulkheads" yaml:"bulkheads"`
+	Fairness   FairnessConfig      `json:"fairness" yaml:"fairness"`
+	Lifecycle  LifecycleConfig     `json:"lifecycle" yaml:"lifecycle"`
+	Recording  RecordingConfig     `json:"recording" yaml:"recording"`
+	Chaos      ChaosConfig         `json:"chaos" yaml:"chaos"`
+	Resources  ResourceGuardConfig `json:"resources" yaml:"resources"`
+	Usage      UsageConfig         `json:"usage" yaml:"usage"`
+	History    HistoryConfig       `json:"history" yaml:"history"`
+	Failures   FailuresConfig      `json:"failures" yaml:"failures"`
+	SLA        SLAConfig           `json:"sla" yaml:"sla"`
+	Alerts     AlertsConfig        `json:"alerts" yaml:"alerts"`
+	HealthPing HealthPingConfig    `json:"healthPing" yaml:"healthPing"`
+	// Checks added with AddHealthCheck
+	HealthChecks HealthChecksConfig `json:"healthChecks" yaml:"healthChecks"`
+	// Recurring windows during which queues are paused
+	Maintenance MaintenanceConfig `json:"maintenance" yaml:"maintenance"`
+	// Working hours of task types that must not run at night
+	BusinessHours BusinessHoursConfig `json:"businessHours" yaml:"businessHours"`
+	// Column mappings of CSV files enqueued as tasks, by name
+	CSVImports map[string]CSVImportConfig `json:"csvImports" yaml:"csvImports"`
+	// Periodic tasks enqueued by the scheduler
+	Schedules []ScheduleConfig `json:"schedules" yaml:"schedules"`
+	// Additional asynq servers by name, each with its own queues and concurrency
+	Pools map[string]WorkerPoolConfig `json:"pools" yaml:"pools"`
+	// Drain higher priority queues completely before lower ones instead of weighted round-robin
+	StrictPriority bool `json:"strict_priority" yaml:"strict_priority" env:"WORKER_STRICT_PRIORITY" default:"false"`
+	// JSON Schema files by task type, validated before handlers run
+	Schemas     map[string]string `json:"schemas" yaml:"schemas"`
+	Compression CompressionConfig `json:"compression" yaml:"compression"`
+	// AES-GCM encryption of payloads stored in Redis
+	Encryption EncryptionConfig `json:"encryption" yaml:"encryption"`
+	// Claim-check store for payloads too large to keep in Redis
+	Blobs BlobConfig `json:"blobs" yaml:"blobs"`
+	// Transactional outbox table written by Client.EnqueueTx
+	Outbox OutboxConfig `json:"outbox" yaml:"outbox"`
+	// Bridges consuming external queues into tasks
+	Ingest IngestConfig `json:"ingest" yaml:"ingest"`
+	// Queue depth limits above which Client rejects tasks
+	Backpressure BackpressureConfig `json:"backpressure" yaml:"backpressure"`
+	// Application specific sections, read with Workerd.Config().Decode
+	Modules map[string]any `json:"modules" yaml:"modules"`
 }
 
 func newWorkerConfig(files ...string) (*workerConfig, error) {
@@ -132,6 +265,7 @@ func newWorkerConfig(files ...string) (*workerConfig, error) {
 	if err := validateWorkerConfig(config); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
+	config.applyNamespace()
 
 	return config, nil
 }
@@ -141,7 +275,7 @@ func validateWorkerConfig(config *workerConfig) error {
 	if config == nil {
 		return fmt.Errorf("config cannot be nil")
 	}
-	
+
 	if config.AsynqConfig == nil {
 		return fmt.Errorf("asynq configuration is required")
 	}
@@ -150,10 +284,170 @@ func validateWorkerConfig(config *workerConfig) error {
 		return fmt.Errorf("concurrency must be non-negative, got %d", config.Concurrency)
 	}
 
+	for name, pool := range config.Pools {
+		if err := pool.validate(); err != nil {
+			return fmt.Errorf("worker pool %q invalid: %w", name, err)
+		}
+	}
+
+	if err := config.Logging.validate(); err != nil {
+		return fmt.Errorf("logging configuration invalid: %w", err)
+	}
+
 	// Validate asynq config
 	if err := config.AsynqConfig.validate(); err != nil {
 		return fmt.Errorf("asynq configuration invalid: %w", err)
 	}
 
+	if err := config.Retention.validate(); err != nil {
+		return fmt.Errorf("retention configuration invalid: %w", err)
+	}
+
+	if err := config.Migration.validate(); err != nil {
+		return fmt.Errorf("migration configuration invalid: %w", err)
+	}
+
+	if err := config.Handlers.validate(); err != nil {
+		return fmt.Errorf("handlers configuration invalid: %w", err)
+	}
+
+	if err := config.Admin.validate(); err != nil {
+		return fmt.Errorf("admin configuration invalid: %w", err)
+	}
+
+	if err := config.Metrics.validate(); err != nil {
+		return fmt.Errorf("metrics configuration invalid: %w", err)
+	}
+
+	if err := config.Sentry.validate(); err != nil {
+		return fmt.Errorf("sentry configuration invalid: %w", err)
+	}
+
+	if err := config.Audit.validate(); err != nil {
+		return fmt.Errorf("audit configuration invalid: %w", err)
+	}
+
+	if err := config.Events.validate(); err != nil {
+		return fmt.Errorf("events configuration invalid: %w", err)
+	}
+
+	if err := config.Heartbeat.validate(); err != nil {
+		return fmt.Errorf("heartbeat configuration invalid: %w", err)
+	}
+
+	if err := config.Checkpoint.validate(); err != nil {
+		return fmt.Errorf("checkpoint configuration invalid: %w", err)
+	}
+
+	if err := config.Priorities.validate(); err != nil {
+		return fmt.Errorf("priorities configuration invalid: %w", err)
+	}
+
+	if err := config.Routing.validate(); err != nil {
+		return fmt.Errorf("routing configuration invalid: %w", err)
+	}
+
+	if err := config.Registry.validate(); err != nil {
+		return fmt.Errorf("registry configuration invalid: %w", err)
+	}
+
+	if err := config.Scheduler.validate(); err != nil {
+		return fmt.Errorf("scheduler configuration invalid: %w", err)
+	}
+
+	if err := validateSchedules(config.Schedules); err != nil {
+		return fmt.Errorf("schedules configuration invalid: %w", err)
+	}
+
+	if err := config.Bulkheads.validate(); err != nil {
+		return fmt.Errorf("bulkheads configuration invalid: %w", err)
+	}
+
+	if err := config.Fairness.validate(); err != nil {
+		return fmt.Errorf("fairness configuration invalid: %w", err)
+	}
+
+	if err := config.Lifecycle.validate(); err != nil {
+		return fmt.Errorf("lifecycle configuration invalid: %w", err)
+	}
+
+	if err := config.Recording.validate(); err != nil {
+		return fmt.Errorf("recording configuration invalid: %w", err)
+	}
+
+	if err := config.Chaos.validate(); err != nil {
+		return fmt.Errorf("chaos configuration invalid: %w", err)
+	}
+
+	if err := config.Resources.validate(); err != nil {
+		return fmt.Errorf("resource guard configuration invalid: %w", err)
+	}
+
+	if err := config.Usage.validate(); err != nil {
+		return fmt.Errorf("usage configuration invalid: %w", err)
+	}
+
+	if err := config.History.validate(); err != nil {
+		return fmt.Errorf("history configuration invalid: %w", err)
+	}
+
+	if err := config.Failures.validate(); err != nil {
+		return fmt.Errorf("failures configuration invalid: %w", err)
+	}
+
+	if err := config.SLA.validate(); err != nil {
+		return fmt.Errorf("SLA configuration invalid: %w", err)
+	}
+
+	if err := config.Alerts.validate(); err != nil {
+		return fmt.Errorf("alerts configuration invalid: %w", err)
+	}
+
+	if err := config.HealthPing.validate(); err != nil {
+		return fmt.Errorf("health ping configuration invalid: %w", err)
+	}
+
+	if err := config.HealthChecks.validate(); err != nil {
+		return fmt.Errorf("health checks configuration invalid: %w", err)
+	}
+
+	if err := config.Maintenance.validate(); err != nil {
+		return fmt.Errorf("maintenance configuration invalid: %w", err)
+	}
+
+	if err := config.BusinessHours.validate(); err != nil {
+		return fmt.Errorf("business hours configuration invalid: %w", err)
+	}
+
+	if err := config.Compression.validate(); err != nil {
+		return fmt.Errorf("compression configuration invalid: %w", err)
+	}
+
+	if err := config.Encryption.validate(); err != nil {
+		return fmt.Errorf("encryption configuration invalid: %w", err)
+	}
+
+	if err := config.Blobs.validate(); err != nil {
+		return fmt.Errorf("blob configuration invalid: %w", err)
+	}
+
+	if err := config.Outbox.validate(); err != nil {
+		return fmt.Errorf("outbox configuration invalid: %w", err)
+	}
+
+	if err := config.Ingest.validate(); err != nil {
+		return fmt.Errorf("ingest configuration invalid: %w", err)
+	}
+
+	if err := config.Backpressure.validate(); err != nil {
+		return fmt.Errorf("backpressure configuration invalid: %w", err)
+	}
+
+	for name, ci := range config.CSVImports {
+		if err := ci.validate(); err != nil {
+			return fmt.Errorf("CSV import %q invalid: %w", name, err)
+		}
+	}
+
 	return nil
 }
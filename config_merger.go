@@ -10,6 +10,7 @@ type ConfigMerger struct {
 	defaultConfig *workerConfig
 	fileConfig    *workerConfig
 	optionsConfig *WorkerdOptions
+	sources       map[string]string
 }
 
 // WorkerdOptions represents configuration options from functional options
@@ -21,6 +22,9 @@ type WorkerdOptions struct {
 	Logger      *slog.Logger
 	ConfigPath  string
 	ServiceFlag string
+	PIDFile     string
+	// Only an explicit WithStrictPriority counts as an override
+	StrictPriority bool
 }
 
 // NewConfigMerger creates a new configuration merger
@@ -52,6 +56,7 @@ func (cm *ConfigMerger) Merge() (*MergedConfig, error) {
 		return nil, fmt.Errorf("default configuration is required")
 	}
 
+	cm.sources = make(map[string]string)
 	merged := &MergedConfig{
 		Name:        cm.getStringValue("name"),
 		DisplayName: cm.getStringValue("displayName"),
@@ -59,15 +64,29 @@ func (cm *ConfigMerger) Merge() (*MergedConfig, error) {
 		Concurrency: cm.getIntValue("concurrency"),
 		ConfigPath:  cm.getStringValue("configPath"),
 		ServiceFlag: cm.getStringValue("serviceFlag"),
+		PIDFile:     cm.getStringValue("pidFile"),
 		Logger:      cm.getLoggerValue(),
 		Config:      cm.fileConfig,
 	}
+	merged.StrictPriority = cm.getBoolValue("strictPriority")
 
 	// Use default config if file config is not available
 	if merged.Config == nil {
 		merged.Config = cm.defaultConfig
 	}
 
+	// Write the merged values back so components built from Config,
+	// such as the ServerBuilder, see the effective settings
+	config := *merged.Config
+	config.Name = merged.Name
+	config.DisplayName = merged.DisplayName
+	config.Description = merged.Description
+	config.Concurrency = merged.Concurrency
+	config.PIDFile = merged.PIDFile
+	config.StrictPriority = merged.StrictPriority
+	merged.Config = &config
+	merged.Sources = cm.sources
+
 	return merged, nil
 }
 
@@ -79,8 +98,13 @@ type MergedConfig struct {
 	Concurrency int
 	ConfigPath  string
 	ServiceFlag string
+	PIDFile     string
 	Logger      *slog.Logger
 	Config      *workerConfig
+	// Strict queue priority instead of weighted round-robin
+	StrictPriority bool
+	// Sources records which layer (options, file, default) supplied each merged field
+	Sources map[string]string
 }
 
 // getStringValue gets string value with priority: options > file > defaults
@@ -90,23 +114,27 @@ func (cm *ConfigMerger) getStringValue(field string) string {
 		switch field {
 		case "name":
 			if cm.optionsConfig.Name != "" {
-				return cm.optionsConfig.Name
+				return trackSource(cm, field, "options", cm.optionsConfig.Name)
 			}
 		case "displayName":
 			if cm.optionsConfig.DisplayName != "" {
-				return cm.optionsConfig.DisplayName
+				return trackSource(cm, field, "options", cm.optionsConfig.DisplayName)
 			}
 		case "description":
 			if cm.optionsConfig.Description != "" {
-				return cm.optionsConfig.Description
+				return trackSource(cm, field, "options", cm.optionsConfig.Description)
 			}
 		case "configPath":
 			if cm.optionsConfig.ConfigPath != "" {
-				return cm.optionsConfig.ConfigPath
+				return trackSource(cm, field, "options", cm.optionsConfig.ConfigPath)
 			}
 		case "serviceFlag":
 			if cm.optionsConfig.ServiceFlag != "" {
-				return cm.optionsConfig.ServiceFlag
+				return trackSource(cm, field, "options", cm.optionsConfig.ServiceFlag)
+			}
+		case "pidFile":
+			if cm.optionsConfig.PIDFile != "" {
+				return trackSource(cm, field, "options", cm.optionsConfig.PIDFile)
 			}
 		}
 	}
@@ -116,15 +144,19 @@ func (cm *ConfigMerger) getStringValue(field string) string {
 		switch field {
 		case "name":
 			if cm.fileConfig.Name != "" {
-				return cm.fileConfig.Name
+				return trackSource(cm, field, "file", cm.fileConfig.Name)
 			}
 		case "displayName":
 			if cm.fileConfig.DisplayName != "" {
-				return cm.fileConfig.DisplayName
+				return trackSource(cm, field, "file", cm.fileConfig.DisplayName)
 			}
 		case "description":
 			if cm.fileConfig.Description != "" {
-				return cm.fileConfig.Description
+				return trackSource(cm, field, "file", cm.fileConfig.Description)
+			}
+		case "pidFile":
+			if cm.fileConfig.PIDFile != "" {
+				return trackSource(cm, field, "file", cm.fileConfig.PIDFile)
 			}
 		}
 	}
@@ -133,11 +165,11 @@ func (cm *ConfigMerger) getStringValue(field string) string {
 	if cm.defaultConfig != nil {
 		switch field {
 		case "name":
-			return cm.defaultConfig.Name
+			return trackSource(cm, field, "default", cm.defaultConfig.Name)
 		case "displayName":
-			return cm.defaultConfig.DisplayName
+			return trackSource(cm, field, "default", cm.defaultConfig.DisplayName)
 		case "description":
-			return cm.defaultConfig.Description
+			return trackSource(cm, field, "default", cm.defaultConfig.Description)
 		}
 	}
 
@@ -151,7 +183,7 @@ func (cm *ConfigMerger) getIntValue(field string) int {
 		switch field {
 		case "concurrency":
 			if cm.optionsConfig.Concurrency > 0 {
-				return cm.optionsConfig.Concurrency
+				return trackSource(cm, field, "options", cm.optionsConfig.Concurrency)
 			}
 		}
 	}
@@ -161,7 +193,7 @@ func (cm *ConfigMerger) getIntValue(field string) int {
 		switch field {
 		case "concurrency":
 			if cm.fileConfig.Concurrency > 0 {
-				return cm.fileConfig.Concurrency
+				return trackSource(cm, field, "file", cm.fileConfig.Concurrency)
 			}
 		}
 	}
@@ -170,17 +202,59 @@ func (cm *ConfigMerger) getIntValue(field string) int {
 	if cm.defaultConfig != nil {
 		switch field {
 		case "concurrency":
-			return cm.defaultConfig.Concurrency
+			return trackSource(cm, field, "default", cm.defaultConfig.Concurrency)
 		}
 	}
 
 	return 0
 }
 
+// getBoolValue gets bool value with priority: options > file > defaults.
+// Options and files can only switch a setting on.
+func (cm *ConfigMerger) getBoolValue(field string) bool {
+	// Check options first
+	if cm.optionsConfig != nil {
+		switch field {
+		case "strictPriority":
+			if cm.optionsConfig.StrictPriority {
+				return trackSource(cm, field, "options", true)
+			}
+		}
+	}
+
+	// Check file config second
+	if cm.fileConfig != nil {
+		switch field {
+		case "strictPriority":
+			if cm.fileConfig.StrictPriority {
+				return trackSource(cm, field, "file", true)
+			}
+		}
+	}
+
+	// Fall back to defaults
+	if cm.defaultConfig != nil {
+		switch field {
+		case "strictPriority":
+			return trackSource(cm, field, "default", cm.defaultConfig.StrictPriority)
+		}
+	}
+
+	return false
+}
+
 // getLoggerValue gets logger value with priority: options > defaults
 func (cm *ConfigMerger) getLoggerValue() *slog.Logger {
 	if cm.optionsConfig != nil && cm.optionsConfig.Logger != nil {
 		return cm.optionsConfig.Logger
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// trackSource records the layer a merged value came from and returns the value
+func trackSource[T any](cm *ConfigMerger, field, source string, value T) T {
+	if cm.sources != nil {
+		cm.sources[field] = source
+	}
+	return value
+}
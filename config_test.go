@@ -0,0 +1,56 @@
+package workerd
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewWorkerConfigDefaults(t *testing.T) {
+	config, err := newWorkerConfig()
+	if err != nil {
+		t.Fatalf("newWorkerConfig() without a config file: %v", err)
+	}
+	if got := config.Alerts.Email.SubjectPrefix; got != "[workerd]" {
+		t.Errorf("alerts.email.subjectPrefix = %q, want %q", got, "[workerd]")
+	}
+	if got := config.Checkpoint.Prefix; got != "workerd:checkpoint:" {
+		t.Errorf("checkpoint.prefix = %q, want %q", got, "workerd:checkpoint:")
+	}
+	checkDefaultsApplied(t, reflect.ValueOf(config).Elem(), "")
+}
+
+// checkDefaultsApplied fails for defaults that are not valid YAML, and for
+// fields left zero despite a default, which happens to all fields after a
+// default configor can't parse
+func checkDefaultsApplied(t *testing.T, v reflect.Value, prefix string) {
+	t.Helper()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		name := prefix + field.Name
+		if fv.Kind() == reflect.Struct {
+			checkDefaultsApplied(t, fv, name+".")
+			continue
+		}
+		def, ok := field.Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		want := reflect.New(fv.Type())
+		if err := yaml.Unmarshal([]byte(def), want.Interface()); err != nil {
+			t.Errorf("%s: invalid default %q: %v", name, def, err)
+			continue
+		}
+		if !want.Elem().IsZero() && fv.IsZero() {
+			t.Errorf("%s: default %q not applied", name, def)
+		}
+	}
+}
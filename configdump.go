@@ -0,0 +1,231 @@
+package workerd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigValue is one setting of the effective configuration
+type ConfigValue struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // options, env, file or default
+}
+
+// redactedValue replaces secrets in configuration dumps
+const redactedValue = "[REDACTED]"
+
+// mergedConfigKeys maps ConfigMerger fields to their configuration keys
+var mergedConfigKeys = map[string]string{
+	"name":           "name",
+	"displayName":    "display_name",
+	"description":    "description",
+	"concurrency":    "concurrency",
+	"pidFile":        "pid_file",
+	"strictPriority": "strict_priority",
+}
+
+// EffectiveConfig returns the fully merged configuration with secrets redacted,
+// along with the layer each value came from
+func (w *Workerd) EffectiveConfig() []ConfigValue {
+	var values []ConfigValue
+	if w.config != nil {
+		collectConfigValues(reflect.ValueOf(w.config).Elem(), "", &values)
+	}
+
+	merged := map[string]string{
+		"name":         w.name,
+		"display_name": w.displayName,
+		"description":  w.description,
+		"concurrency":  fmt.Sprint(w.concurrency),
+		"pid_file":     w.pidFilePath,
+	}
+	if w.config != nil {
+		merged["strict_priority"] = fmt.Sprint(w.config.StrictPriority)
+	}
+	for i := range values {
+		v, ok := merged[values[i].Key]
+		if !ok {
+			continue
+		}
+		values[i].Value = v
+		for field, key := range mergedConfigKeys {
+			if key == values[i].Key && w.configSources[field] == "options" {
+				values[i].Source = "options"
+			}
+		}
+	}
+
+	return values
+}
+
+// collectConfigValues walks a configuration struct and appends its leaf values
+func collectConfigValues(v reflect.Value, prefix string, out *[]ConfigValue) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+
+		switch {
+		case fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}):
+			collectConfigValues(fv, key, out)
+			continue
+		case fv.Kind() == reflect.Map:
+			collectMapValues(fv, key, isSecretField(field), out)
+			continue
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+			for j := 0; j < fv.Len(); j++ {
+				collectConfigValues(fv.Index(j), fmt.Sprintf("%s[%d]", key, j), out)
+			}
+			continue
+		}
+
+		value := fmt.Sprint(fv.Interface())
+		if fv.IsZero() && fv.Type() == reflect.TypeOf(time.Time{}) {
+			value = ""
+		}
+		switch {
+		case !isSecretField(field):
+			value = redactConfigValue(value)
+		case !fv.IsZero() && (fv.Kind() != reflect.Slice || fv.Len() > 0):
+			value = redactedValue
+		}
+		*out = append(*out, ConfigValue{
+			Key:    key,
+			Value:  value,
+			Source: configValueSource(field, fv),
+		})
+	}
+}
+
+// collectMapValues appends the leaves of free-form sections such as modules.
+// Every leaf is redacted when the section itself holds secrets.
+func collectMapValues(v reflect.Value, prefix string, secret bool, out *[]ConfigValue) {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+
+	for _, k := range keys {
+		name := fmt.Sprint(k.Interface())
+		key := prefix + "." + name
+		value := v.MapIndex(k)
+		for value.Kind() == reflect.Interface && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Map {
+			collectMapValues(value, key, secret, out)
+			continue
+		}
+		leaf := fmt.Sprint(value.Interface())
+		if secret && leaf != "" {
+			leaf = redactedValue
+		}
+		*out = append(*out, ConfigValue{
+			Key:    key,
+			Value:  redactConfigValue(leaf),
+			Source: "file",
+		})
+	}
+}
+
+// configValueSource guesses whether a loaded value came from env, defaults or a file
+func configValueSource(field reflect.StructField, fv reflect.Value) string {
+	if env := field.Tag.Get("env"); env != "" {
+		if _, ok := os.LookupEnv(env); ok {
+			return "env"
+		}
+	}
+
+	def, ok := field.Tag.Lookup("default")
+	if !ok {
+		if fv.IsZero() {
+			return "default"
+		}
+		return "file"
+	}
+	// Defaults are parsed as YAML, like configor does
+	want := reflect.New(fv.Type())
+	if err := yaml.Unmarshal([]byte(def), want.Interface()); err == nil && reflect.DeepEqual(want.Elem().Interface(), fv.Interface()) {
+		return "default"
+	}
+	return "file"
+}
+
+// isSecretField reports whether a configuration field holds credentials, which
+// is marked with a secret:"true" tag
+func isSecretField(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true"
+}
+
+// redactConfigValue hides credentials embedded in URIs
+func redactConfigValue(value string) string {
+	if value == "" {
+		return value
+	}
+	if strings.Contains(value, "://") {
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+	}
+	return value
+}
+
+// printConfig renders the effective configuration as text or JSON
+func printConfig(out io.Writer, values []ConfigValue, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(values)
+	case "", "text":
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+		for _, v := range values {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", v.Key, v.Value, v.Source)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (valid formats: text, json)", format)
+	}
+}
+
+// logEffectiveConfig writes the effective configuration as a single startup log line
+func (w *Workerd) logEffectiveConfig() {
+	values := w.EffectiveConfig()
+	attrs := make([]any, 0, len(values)*2)
+	for _, v := range values {
+		attrs = append(attrs, v.Key, v.Value)
+	}
+	w.log.Info("effective configuration", attrs...)
+}
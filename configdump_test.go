@@ -0,0 +1,86 @@
+package workerd
+
+import "testing"
+
+func TestEffectiveConfigDefaultSources(t *testing.T) {
+	config, err := newWorkerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Workerd{config: config}
+	for _, v := range w.EffectiveConfig() {
+		if v.Source != "default" {
+			t.Errorf("%s = %q: source %q, want default", v.Key, v.Value, v.Source)
+		}
+	}
+}
+
+func TestEffectiveConfigRedactsSecrets(t *testing.T) {
+	config, err := newWorkerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Alerts.Slack.WebhookURL = "https://hooks.slack.com/services/T0/B0/secret"
+	config.HealthPing.URL = "https://hc-ping.com/check-token"
+	config.HealthPing.FailURL = "https://hc-ping.com/check-token/fail"
+	config.AsynqConfig.RedisClient.Password = "hunter2"
+	config.Admin.Tokens = []string{"admin-token"}
+	config.Ingest.Gateway.Tokens = []string{"gateway-token"}
+	config.Admin.Token = "admin-token"
+	config.Encryption.Key = "c2VjcmV0"
+	config.Sentry.DSN = "https://public@sentry.example.com/1"
+	config.Events.URL = "https://events.example.com/hook?sig=secret"
+	config.Alerts.Webhook.URL = "https://alerts.example.com/hook?token=secret"
+
+	w := &Workerd{config: config}
+	values := make(map[string]string)
+	for _, v := range w.EffectiveConfig() {
+		values[v.Key] = v.Value
+	}
+	for _, key := range []string{
+		"alerts.slack.webhookUrl",
+		"healthPing.url",
+		"healthPing.failUrl",
+		"asynq.redisClient.password",
+		"admin.tokens",
+		"ingest.gateway.tokens",
+		"admin.token",
+		"encryption.key",
+		"sentry.dsn",
+		"events.url",
+		"alerts.webhook.url",
+	} {
+		if got, ok := values[key]; !ok || got != redactedValue {
+			t.Errorf("%s = %q, want %s", key, got, redactedValue)
+		}
+	}
+
+	config.Admin.Tokens = nil
+	for _, v := range w.EffectiveConfig() {
+		if v.Key == "admin.tokens" && v.Value != "[]" {
+			t.Errorf("admin.tokens = %q, want empty tokens left as is", v.Value)
+		}
+	}
+}
+
+func TestEffectiveConfigKeepsRedisKeys(t *testing.T) {
+	config, err := newWorkerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Workerd{config: config}
+	values := make(map[string]string)
+	for _, v := range w.EffectiveConfig() {
+		values[v.Key] = v.Value
+	}
+	for key, want := range map[string]string{
+		"fairness.key":    config.Fairness.Key,
+		"history.key":     config.History.Key,
+		"maintenance.key": config.Maintenance.Key,
+	} {
+		if got := values[key]; got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
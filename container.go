@@ -0,0 +1,139 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/hibiken/asynq"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	taskType    = reflect.TypeOf((*asynq.Task)(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// container is a registry of dependencies keyed by type
+type container struct {
+	mu     sync.RWMutex
+	values []reflect.Value
+}
+
+// Provide registers dependencies that HandleWith injects into handlers.
+// Values are matched by type; register each concrete type at most once.
+func (w *Workerd) Provide(deps ...any) error {
+	w.container.mu.Lock()
+	defer w.container.mu.Unlock()
+
+	for _, dep := range deps {
+		if dep == nil {
+			return fmt.Errorf("cannot provide a nil dependency")
+		}
+		v := reflect.ValueOf(dep)
+		for _, existing := range w.container.values {
+			if existing.Type() == v.Type() {
+				return fmt.Errorf("dependency of type %s is already provided", v.Type())
+			}
+		}
+		w.container.values = append(w.container.values, v)
+	}
+	return nil
+}
+
+// resolve finds the provided value for t, preferring an exact type match
+// over a unique value assignable to t
+func (c *container) resolve(t reflect.Type) (reflect.Value, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var candidates []reflect.Value
+	for _, v := range c.values {
+		if v.Type() == t {
+			return v, nil
+		}
+		if v.Type().AssignableTo(t) {
+			candidates = append(candidates, v)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return reflect.Value{}, fmt.Errorf("no dependency provided for type %s", t)
+	case 1:
+		return candidates[0], nil
+	default:
+		return reflect.Value{}, fmt.Errorf("ambiguous dependency for type %s: %d candidates", t, len(candidates))
+	}
+}
+
+// build constructs a dependency set of type t: either a provided value of that
+// type or a struct whose exported fields are filled from the container.
+// Fields tagged `inject:"-"` are left untouched.
+func (c *container) build(t reflect.Type) (reflect.Value, error) {
+	if v, err := c.resolve(t); err == nil {
+		return v, nil
+	}
+
+	structType := t
+	if t.Kind() == reflect.Pointer {
+		structType = t.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return c.resolve(t)
+	}
+
+	deps := reflect.New(structType).Elem()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() || field.Tag.Get("inject") == "-" {
+			continue
+		}
+		v, err := c.resolve(field.Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s.%s: %w", structType.Name(), field.Name, err)
+		}
+		deps.Field(i).Set(v)
+	}
+
+	if t.Kind() == reflect.Pointer {
+		return deps.Addr(), nil
+	}
+	return deps, nil
+}
+
+// HandleWith registers a handler of the form
+//
+//	func(ctx context.Context, t *asynq.Task, deps D) error
+//
+// where D is a struct (or pointer to struct) whose exported fields are
+// injected from values registered with Provide, or a provided type itself.
+// Dependencies are resolved once at registration, so Provide must be called first.
+func (w *Workerd) HandleWith(pattern string, fn any) error {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != 3 || ft.NumOut() != 1 ||
+		ft.In(0) != contextType || ft.In(1) != taskType || ft.Out(0) != errorType {
+		return fmt.Errorf("handler for %q must be func(context.Context, *asynq.Task, D) error, got %s", pattern, ft)
+	}
+
+	deps, err := w.container.build(ft.In(2))
+	if err != nil {
+		return fmt.Errorf("failed to resolve dependencies for %q: %w", pattern, err)
+	}
+
+	w.Handle(pattern, asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		out := fv.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(t), deps})
+		err, _ := out[0].Interface().(error)
+		return err
+	}))
+
+	w.handlers.mu.Lock()
+	defer w.handlers.mu.Unlock()
+	if w.handlers.names == nil {
+		w.handlers.names = make(map[string]string)
+	}
+	w.handlers.names[pattern] = handlerName(fn)
+	return nil
+}
@@ -0,0 +1,216 @@
+package workerd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"golang.org/x/time/rate"
+)
+
+// CSV column types
+const (
+	CSVString = "string"
+	CSVInt    = "int"
+	CSVFloat  = "float"
+	CSVBool   = "bool"
+	CSVJSON   = "json"
+)
+
+// CSVColumn maps a CSV column to a field of the task payload
+type CSVColumn struct {
+	// Header of the column
+	Column string `json:"column" yaml:"column"`
+
+	// Payload field; empty uses the header
+	Field string `json:"field" yaml:"field"`
+
+	// string, int, float, bool or json; empty cells of other types than
+	// string are left out of the payload
+	Type string `json:"type" yaml:"type"`
+}
+
+// field returns the payload field of the column
+func (cc *CSVColumn) field() string {
+	if cc.Field != "" {
+		return cc.Field
+	}
+	return cc.Column
+}
+
+// CSVImportConfig maps the rows of a CSV file to tasks, one JSON object
+// payload per row
+type CSVImportConfig struct {
+	// Task type of every row
+	Type string `json:"type" yaml:"type"`
+
+	// Queue of the tasks; empty keeps the routing table or the default
+	Queue string `json:"queue" yaml:"queue"`
+
+	// Columns put in the payload; empty puts every column as a string
+	Columns []CSVColumn `json:"columns" yaml:"columns"`
+
+	// Column used as task ID, so importing a file again skips rows already
+	// enqueued while their tasks are retained
+	TaskIDColumn string `json:"taskIdColumn" yaml:"taskIdColumn"`
+
+	// Field separator
+	Delimiter string `json:"delimiter" yaml:"delimiter"`
+
+	// Tasks enqueued per second; 0 is unlimited
+	Rate float64 `json:"rate" yaml:"rate"`
+}
+
+// validate validates the column mapping
+func (ci *CSVImportConfig) validate() error {
+	if ci.Type == "" {
+		return fmt.Errorf("task type cannot be empty")
+	}
+	if ci.Delimiter != "" && utf8.RuneCountInString(ci.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character, got %q", ci.Delimiter)
+	}
+	if ci.Rate < 0 {
+		return fmt.Errorf("rate must be non-negative, got %v", ci.Rate)
+	}
+	fields := make(map[string]bool, len(ci.Columns))
+	for i := range ci.Columns {
+		c := &ci.Columns[i]
+		if c.Column == "" {
+			return fmt.Errorf("column %d: header cannot be empty", i)
+		}
+		switch c.Type {
+		case "", CSVString, CSVInt, CSVFloat, CSVBool, CSVJSON:
+		default:
+			return fmt.Errorf("column %q: unknown type %q, expected string, int, float, bool or json", c.Column, c.Type)
+		}
+		if fields[c.field()] {
+			return fmt.Errorf("column %q: duplicate field %q", c.Column, c.field())
+		}
+		fields[c.field()] = true
+	}
+	return nil
+}
+
+// limiter returns the rate limiter of the import, or nil when unlimited
+func (ci *CSVImportConfig) limiter() *rate.Limiter {
+	if ci.Rate == 0 {
+		return nil
+	}
+	return newRateLimiter(ci.Rate)
+}
+
+// csvValue converts a cell to the JSON value of the column type
+func csvValue(typ, cell string) (any, error) {
+	switch typ {
+	case "", CSVString:
+		return cell, nil
+	case CSVInt:
+		return strconv.ParseInt(cell, 10, 64)
+	case CSVFloat:
+		return strconv.ParseFloat(cell, 64)
+	case CSVBool:
+		return strconv.ParseBool(cell)
+	default:
+		if !json.Valid([]byte(cell)) {
+			return nil, fmt.Errorf("invalid JSON")
+		}
+		return json.RawMessage(cell), nil
+	}
+}
+
+// csvSpecs reads the task specs of the rows of r, which starts with a header
+func csvSpecs(config *CSVImportConfig, r io.Reader) specSource {
+	return func(emit func(line int, spec TaskSpec, err error) bool) error {
+		reader := csv.NewReader(r)
+		if config.Delimiter != "" {
+			reader.Comma, _ = utf8.DecodeRuneInString(config.Delimiter)
+		}
+		header, err := reader.Read()
+		if err != nil {
+			return fmt.Errorf("failed to read CSV header: %w", err)
+		}
+		index := make(map[string]int, len(header))
+		for i, h := range header {
+			index[h] = i
+		}
+
+		columns := config.Columns
+		if len(columns) == 0 {
+			for _, h := range header {
+				columns = append(columns, CSVColumn{Column: h})
+			}
+		}
+		for _, c := range columns {
+			if _, ok := index[c.Column]; !ok {
+				return fmt.Errorf("CSV header has no column %q", c.Column)
+			}
+		}
+		idIndex := -1
+		if config.TaskIDColumn != "" {
+			var ok bool
+			if idIndex, ok = index[config.TaskIDColumn]; !ok {
+				return fmt.Errorf("CSV header has no task ID column %q", config.TaskIDColumn)
+			}
+		}
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			var perr *csv.ParseError
+			if err != nil && !(errors.As(err, &perr) && errors.Is(err, csv.ErrFieldCount)) {
+				return fmt.Errorf("failed to read CSV: %w", err)
+			}
+			line, _ := reader.FieldPos(0)
+			if err != nil {
+				// Rows with a missing or extra cell are skipped
+				if !emit(perr.StartLine, TaskSpec{}, err) {
+					return nil
+				}
+				continue
+			}
+
+			spec := TaskSpec{Type: config.Type, Queue: config.Queue}
+			if idIndex >= 0 {
+				spec.Options.TaskID = record[idIndex]
+			}
+			payload := make(map[string]any, len(columns))
+			for _, c := range columns {
+				cell := record[index[c.Column]]
+				if cell == "" && c.Type != "" && c.Type != CSVString {
+					continue
+				}
+				v, verr := csvValue(c.Type, cell)
+				if verr != nil {
+					err = fmt.Errorf("column %q: invalid %s %q", c.Column, c.Type, cell)
+					break
+				}
+				payload[c.field()] = v
+			}
+			if err == nil {
+				spec.Payload, err = json.Marshal(payload)
+			}
+			if !emit(line, spec, err) {
+				return nil
+			}
+		}
+	}
+}
+
+// EnqueueCSV enqueues a task for every row of r, a CSV file with a header,
+// mapped to payloads by the csvImports entry name. Rows that are invalid or
+// fail to enqueue are reported in the result while the rest of the file is
+// still enqueued; the error is set when r cannot be read any further.
+func (c *Client) EnqueueCSV(ctx context.Context, name string, r io.Reader) (*BulkResult, error) {
+	config, ok := c.config.CSVImports[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown CSV import %q", name)
+	}
+	return bulkEnqueue(ctx, c.EnqueueContext, csvSpecs(&config, r), "", 1, config.limiter(), 0, nil)
+}
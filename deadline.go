@@ -0,0 +1,71 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// MetaDeadline holds the absolute time by which the producer needs a task
+// done. Handlers receive it as their context deadline and follow-up tasks
+// inherit it.
+const MetaDeadline = "deadline"
+
+// errDeadlineExceeded is returned for tasks whose producer deadline passed
+var errDeadlineExceeded = errors.New("task deadline exceeded")
+
+// EnqueueDeadline enqueues task to be done by deadline. The handler context
+// expires at the deadline, and tasks still pending or failing by then are
+// archived instead of being retried.
+func (c *Client) EnqueueDeadline(ctx context.Context, deadline time.Time, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	if !deadline.After(time.Now()) {
+		return nil, fmt.Errorf("%w: deadline %s already passed", errDeadlineExceeded, deadline.Format(time.RFC3339))
+	}
+	ctx = WithMetadata(ctx, Metadata{MetaDeadline: deadline.UTC().Format(time.RFC3339Nano)})
+	return c.EnqueueContext(ctx, task, opts...)
+}
+
+// EnqueueTimeout enqueues task to be done within timeout from now
+func (c *Client) EnqueueTimeout(ctx context.Context, timeout time.Duration, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	return c.EnqueueDeadline(ctx, time.Now().Add(timeout), task, opts...)
+}
+
+// taskDeadline returns the deadline recorded in the metadata, if any
+func taskDeadline(md Metadata) (time.Time, bool) {
+	v, ok := md[MetaDeadline]
+	if !ok {
+		return time.Time{}, false
+	}
+	deadline, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return deadline, true
+}
+
+// deadlineMiddleware sets the handler context deadline from the task
+// metadata and archives tasks that run out of time, since retrying them
+// past the deadline is pointless
+func deadlineMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		deadline, ok := taskDeadline(MetadataFromContext(ctx))
+		if !ok {
+			return next.ProcessTask(ctx, t)
+		}
+		if !deadline.After(time.Now()) {
+			return fmt.Errorf("%w: deadline %s passed before processing: %w",
+				errDeadlineExceeded, deadline.Format(time.RFC3339), asynq.SkipRetry)
+		}
+
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+		err := next.ProcessTask(ctx, t)
+		if err != nil && !deadline.After(time.Now()) && !errors.Is(err, asynq.SkipRetry) && !errors.Is(err, asynq.RevokeTask) {
+			return fmt.Errorf("%w: %w: %w", errDeadlineExceeded, err, asynq.SkipRetry)
+		}
+		return err
+	})
+}
@@ -0,0 +1,87 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const drainCommandUsage = "drain [-timeout 10m]  drain the worker holding -pid-file and wait for it to exit"
+
+// Drain stops fetching new tasks and exits the worker once its in-flight
+// tasks have finished. It returns immediately; while draining, /readyz on
+// the admin API reports the worker as not ready.
+func (w *Workerd) Drain() error {
+	if !w.running.Load() {
+		return fmt.Errorf("worker is not running")
+	}
+	if !w.draining.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	w.log.Info("draining: no longer fetching new tasks", "active", w.active.Load())
+	w.scheduler.stop()
+	w.srv.Stop()
+	for _, p := range w.pools {
+		p.srv.Stop()
+	}
+
+	go func() {
+		for w.active.Load() > 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.log.Info("drained, shutting down")
+		if err := requestShutdown(); err != nil {
+			w.log.Warn("drained but could not shut down", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Draining reports whether Drain was called
+func (w *Workerd) Draining() bool {
+	return w.draining.Load()
+}
+
+// activeMiddleware counts the tasks being processed so Drain knows when to exit
+func activeMiddleware(w *Workerd) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			w.active.Add(1)
+			defer w.active.Add(-1)
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
+
+// runDrainCommand implements `drain`
+func runDrainCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("drain", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 10*time.Minute, "How long to wait for the worker to exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if w.pidFilePath == "" {
+		return fmt.Errorf("drain requires -pid-file to find the running worker")
+	}
+
+	if err := signalDrain(w.pidFilePath); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%s: draining\n", w.name)
+
+	deadline := time.Now().Add(*timeout)
+	for pidFileHeld(w.pidFilePath) {
+		if time.Now().After(deadline) {
+			return errors.New("worker still draining after timeout")
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	fmt.Fprintf(os.Stderr, "%s: drained and stopped\n", w.name)
+	return nil
+}
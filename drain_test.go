@@ -0,0 +1,13 @@
+package workerd
+
+import "testing"
+
+func TestDrainRequiresRunningWorker(t *testing.T) {
+	w := &Workerd{}
+	if err := w.Drain(); err == nil {
+		t.Fatal("Drain on a stopped worker succeeded")
+	}
+	if w.draining.Load() {
+		t.Error("Drain on a stopped worker set draining")
+	}
+}
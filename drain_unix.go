@@ -0,0 +1,40 @@
+//go:build !windows
+
+package workerd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// drainSignal asks a running worker to drain and exit
+const drainSignal = syscall.SIGUSR1
+
+// watchDrainSignal drains the worker when SIGUSR1 is received.
+// The returned function stops watching.
+func (w *Workerd) watchDrainSignal() func() {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, drainSignal)
+
+	go func() {
+		select {
+		case <-sigs:
+			if err := w.Drain(); err != nil {
+				w.log.Warn("could not drain", "error", err)
+			}
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// signalDrain asks the worker owning the PID file to drain
+func signalDrain(pidFilePath string) error {
+	return signalPIDFile(pidFilePath, drainSignal)
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package workerd
+
+import "errors"
+
+// watchDrainSignal is a no-op on Windows; use the admin API to drain instead
+func (w *Workerd) watchDrainSignal() func() {
+	return func() {}
+}
+
+// signalDrain is not supported on Windows; use POST /drain on the admin API
+func signalDrain(pidFilePath string) error {
+	return errors.New("drain signal is not supported on windows, use the admin API")
+}
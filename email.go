@@ -0,0 +1,154 @@
+package workerd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlertEmailConfig sends alerts by email through an SMTP server
+type AlertEmailConfig struct {
+	Host string `json:"host" yaml:"host" env:"WORKER_ALERTS_EMAIL_HOST"`
+	Port int    `json:"port" yaml:"port" env:"WORKER_ALERTS_EMAIL_PORT" default:"587"`
+
+	// Connect with TLS right away (SMTPS, usually port 465) instead of
+	// upgrading with STARTTLS when the server offers it
+	TLS bool `json:"tls" yaml:"tls" env:"WORKER_ALERTS_EMAIL_TLS" default:"false"`
+
+	// PLAIN authentication, skipped without a username
+	Username string `json:"username" yaml:"username" env:"WORKER_ALERTS_EMAIL_USERNAME"`
+	Password string `json:"password" yaml:"password" env:"WORKER_ALERTS_EMAIL_PASSWORD" secret:"true"`
+
+	From string   `json:"from" yaml:"from" env:"WORKER_ALERTS_EMAIL_FROM"`
+	To   []string `json:"to" yaml:"to"`
+
+	// Put in front of the subject of every email
+	SubjectPrefix string `json:"subjectPrefix" yaml:"subjectPrefix" env:"WORKER_ALERTS_EMAIL_SUBJECT_PREFIX" default:"'[workerd]'"`
+}
+
+// validate validates the email settings of a configured host
+func (ec *AlertEmailConfig) validate() error {
+	if ec.Port <= 0 || ec.Port > 65535 {
+		return fmt.Errorf("alerts email port must be between 1 and 65535, got %d", ec.Port)
+	}
+	if _, err := mail.ParseAddress(ec.From); err != nil {
+		return fmt.Errorf("invalid alerts email sender %q: %w", ec.From, err)
+	}
+	if len(ec.To) == 0 {
+		return fmt.Errorf("alerts email recipients cannot be empty")
+	}
+	for _, to := range ec.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid alerts email recipient %q: %w", to, err)
+		}
+	}
+	return nil
+}
+
+// emailNotifier sends each alert as a plain text email
+type emailNotifier struct {
+	config *AlertEmailConfig
+	addr   string
+}
+
+func newEmailNotifier(config *AlertEmailConfig) *emailNotifier {
+	return &emailNotifier{config: config, addr: net.JoinHostPort(config.Host, strconv.Itoa(config.Port))}
+}
+
+func (n *emailNotifier) notify(ctx context.Context, a Alert) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: n.config.Host}
+	if n.config.TLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && !n.config.TLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if n.config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := c.Mail(n.config.From); err != nil {
+		return err
+	}
+	for _, to := range n.config.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", to, err)
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write(n.message(a)); err != nil {
+		wc.Close()
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message formats the alert as an email with headers
+func (n *emailNotifier) message(a Alert) []byte {
+	status := strings.ToUpper(a.Severity)
+	if a.Resolved {
+		status = "RESOLVED"
+	}
+	subject := fmt.Sprintf("%s %s: %s", n.config.SubjectPrefix, status, a.Summary)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(strings.TrimSpace(subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&b, "%s\r\n\r\n", a.Summary)
+	fmt.Fprintf(&b, "Source: %s\r\nSeverity: %s\r\nHost: %s\r\nTime: %s\r\n",
+		a.Source, a.Severity, a.Host, a.Time.Format(time.RFC3339))
+	keys := make([]string, 0, len(a.Labels))
+	for k := range a.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, a.Labels[k])
+	}
+	return []byte(b.String())
+}
+
+func (n *emailNotifier) close() error {
+	return nil
+}
+
+// headerValue keeps a header value on one line
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
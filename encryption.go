@@ -0,0 +1,182 @@
+package workerd
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encryptionAlgorithm is recorded in the envelope as "aesgcm:<key id>"
+const encryptionAlgorithm = "aesgcm"
+
+// EncryptionConfig configures AES-GCM encryption of payloads at rest.
+// Setting a key enables encryption on enqueue; workers decrypt with any known key.
+type EncryptionConfig struct {
+	// Base64 encoded 16, 24 or 32 byte AES key
+	Key string `json:"key" yaml:"key" env:"WORKER_ENCRYPTION_KEY" secret:"true"`
+
+	// File holding the base64 key, e.g. written by a KMS or secrets agent
+	KeyFile string `json:"keyFile" yaml:"keyFile" env:"WORKER_ENCRYPTION_KEY_FILE"`
+
+	// Identifier stored with each payload so keys can be rotated
+	KeyID string `json:"keyID" yaml:"keyID" env:"WORKER_ENCRYPTION_KEY_ID" default:"1"`
+
+	// Retired base64 keys by ID, still accepted for decryption
+	PreviousKeys map[string]string `json:"previousKeys" yaml:"previousKeys" secret:"true"`
+}
+
+// enabled reports whether a key is configured
+func (ec *EncryptionConfig) enabled() bool {
+	return ec.Key != "" || ec.KeyFile != ""
+}
+
+// validate validates the encryption configuration
+func (ec *EncryptionConfig) validate() error {
+	if ec.Key != "" && ec.KeyFile != "" {
+		return fmt.Errorf("encryption key and key file are mutually exclusive")
+	}
+	if ec.enabled() && (ec.KeyID == "" || strings.Contains(ec.KeyID, ":")) {
+		return fmt.Errorf("encryption key ID must be non-empty and must not contain ':', got %q", ec.KeyID)
+	}
+	if ec.Key != "" {
+		if _, err := parseEncryptionKey(ec.Key); err != nil {
+			return err
+		}
+	}
+	for id, key := range ec.PreviousKeys {
+		if _, err := parseEncryptionKey(key); err != nil {
+			return fmt.Errorf("previous key %q: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// KeyProvider supplies payload encryption keys, for example data keys
+// decrypted through a KMS at startup
+type KeyProvider interface {
+	// CurrentKey returns the ID and key used to encrypt new payloads
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with the given ID to decrypt a payload
+	Key(id string) ([]byte, error)
+}
+
+// staticKeys is the KeyProvider built from EncryptionConfig
+type staticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (s *staticKeys) CurrentKey() (string, []byte, error) {
+	if s.current == "" {
+		return "", nil, fmt.Errorf("no encryption key configured")
+	}
+	return s.current, s.keys[s.current], nil
+}
+
+func (s *staticKeys) Key(id string) ([]byte, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+// keyProvider loads the configured keys, or returns nil if none are configured
+func (ec *EncryptionConfig) keyProvider() (KeyProvider, error) {
+	if !ec.enabled() && len(ec.PreviousKeys) == 0 {
+		return nil, nil
+	}
+
+	keys := &staticKeys{keys: make(map[string][]byte)}
+	for id, encoded := range ec.PreviousKeys {
+		key, err := parseEncryptionKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("previous key %q: %w", id, err)
+		}
+		keys.keys[id] = key
+	}
+
+	encoded := ec.Key
+	if ec.KeyFile != "" {
+		data, err := os.ReadFile(ec.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+		encoded = strings.TrimSpace(string(data))
+	}
+	if encoded != "" {
+		key, err := parseEncryptionKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+		keys.keys[ec.KeyID] = key
+		keys.current = ec.KeyID
+	}
+	return keys, nil
+}
+
+// parseEncryptionKey decodes a base64 AES key
+func parseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be base64 encoded: %w", err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+}
+
+// encryptionEncoder encrypts every payload with the provider's current key.
+// The output is the random nonce followed by the sealed payload.
+func encryptionEncoder(keys KeyProvider) payloadEncoder {
+	return func(_ context.Context, payload []byte) (string, []byte, error) {
+		id, key, err := keys.CurrentKey()
+		if err != nil {
+			return "", nil, err
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return "", nil, err
+		}
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", nil, err
+		}
+		return encryptionAlgorithm + ":" + id, aead.Seal(nonce, nonce, payload, nil), nil
+	}
+}
+
+// encryptionDecoder decrypts payloads with the key named in the envelope
+func encryptionDecoder(keys KeyProvider) payloadDecoder {
+	return func(_ context.Context, id string, payload []byte) ([]byte, error) {
+		key, err := keys.Key(id)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) < aead.NonceSize() {
+			return nil, fmt.Errorf("ciphertext too short")
+		}
+		nonce, sealed := payload[:aead.NonceSize()], payload[aead.NonceSize():]
+		return aead.Open(nil, nonce, sealed, nil)
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,328 @@
+package workerd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/time/rate"
+)
+
+const enqueueCommandUsage = "enqueue -file tasks.ndjson|rows.csv [-csv import] [-queue name] [-concurrency 8] [-rate n] [-progress 1000] [-dry-run]  enqueue newline-delimited JSON task specs or CSV rows, e.g. for a backfill"
+
+// TaskSpec describes a task to enqueue in JSON
+type TaskSpec struct {
+	Type string `json:"type"`
+
+	// Payload of the task, any JSON value
+	Payload json.RawMessage `json:"payload"`
+
+	// Queue of the task; empty keeps the routing table or the default
+	Queue string `json:"queue"`
+
+	Options TaskSpecOptions `json:"options"`
+}
+
+// TaskSpecOptions are the enqueue options of a TaskSpec. Durations are Go
+// durations such as "90s", times are RFC 3339; zero values keep the default.
+type TaskSpecOptions struct {
+	MaxRetry  int       `json:"maxRetry"`
+	Timeout   string    `json:"timeout"`
+	Deadline  time.Time `json:"deadline"`
+	ProcessIn string    `json:"processIn"`
+	ProcessAt time.Time `json:"processAt"`
+	Unique    string    `json:"unique"`
+	Retention string    `json:"retention"`
+	TaskID    string    `json:"taskId"`
+	Group     string    `json:"group"`
+}
+
+// Task builds the task and its enqueue options
+func (s *TaskSpec) Task() (*asynq.Task, []asynq.Option, error) {
+	if s.Type == "" {
+		return nil, nil, fmt.Errorf("task type cannot be empty")
+	}
+	o := &s.Options
+	if o.MaxRetry < 0 {
+		return nil, nil, fmt.Errorf("max retry must be non-negative, got %d", o.MaxRetry)
+	}
+
+	var opts []asynq.Option
+	if s.Queue != "" {
+		opts = append(opts, asynq.Queue(s.Queue))
+	}
+	if o.MaxRetry > 0 {
+		opts = append(opts, asynq.MaxRetry(o.MaxRetry))
+	}
+	for _, d := range []struct {
+		name   string
+		value  string
+		option func(time.Duration) asynq.Option
+	}{
+		{"timeout", o.Timeout, asynq.Timeout},
+		{"processIn", o.ProcessIn, asynq.ProcessIn},
+		{"unique", o.Unique, asynq.Unique},
+		{"retention", o.Retention, asynq.Retention},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return nil, nil, fmt.Errorf("invalid %s %q, expected a non-negative duration such as 90s", d.name, d.value)
+		}
+		opts = append(opts, d.option(v))
+	}
+	if !o.Deadline.IsZero() {
+		opts = append(opts, asynq.Deadline(o.Deadline))
+	}
+	if !o.ProcessAt.IsZero() {
+		opts = append(opts, asynq.ProcessAt(o.ProcessAt))
+	}
+	if o.TaskID != "" {
+		opts = append(opts, asynq.TaskID(o.TaskID))
+	}
+	if o.Group != "" {
+		opts = append(opts, asynq.Group(o.Group))
+	}
+
+	var payload []byte
+	if len(s.Payload) > 0 && !bytes.Equal(s.Payload, []byte("null")) {
+		payload = s.Payload
+	}
+	return asynq.NewTask(s.Type, payload), opts, nil
+}
+
+// maxReportedFailures limits the failures kept in a BulkResult
+const maxReportedFailures = 20
+
+// BulkFailure is a line of the input that was not enqueued
+type BulkFailure struct {
+	Line int
+	Err  error
+}
+
+// BulkResult summarizes a bulk enqueue
+type BulkResult struct {
+	Enqueued int
+	Failed   int
+	// The first failures by line
+	Failures []BulkFailure
+}
+
+// specSource calls emit with every task spec of the input, or with the error
+// of an invalid line, until emit returns false. It returns an error when the
+// input cannot be read any further.
+type specSource func(emit func(line int, spec TaskSpec, err error) bool) error
+
+// bulkLine is a line of the input to enqueue
+type bulkLine struct {
+	n    int
+	spec TaskSpec
+}
+
+// bulkEnqueue enqueues the task specs of src with concurrency goroutines, at
+// most limit tasks per second if limit is not nil. Invalid and failed lines
+// are counted and the rest of the input is still enqueued. Every progress
+// tasks, and at the end, a progress line is written to out if not nil.
+func bulkEnqueue(ctx context.Context, enqueue EnqueueFunc, src specSource, queue string, concurrency int, limit *rate.Limiter, progress int, out io.Writer) (*BulkResult, error) {
+	res := &BulkResult{}
+	var mu sync.Mutex
+	start := time.Now()
+	report := func() {
+		if out != nil {
+			fmt.Fprintf(out, "%d enqueued, %d failed in %v\n", res.Enqueued, res.Failed, time.Since(start).Round(time.Millisecond))
+		}
+	}
+	record := func(line int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			res.Failed++
+			res.Failures = append(res.Failures, BulkFailure{Line: line, Err: err})
+			// Keep the first failures by line, workers finish out of order
+			sort.Slice(res.Failures, func(i, j int) bool { return res.Failures[i].Line < res.Failures[j].Line })
+			if len(res.Failures) > maxReportedFailures {
+				res.Failures = res.Failures[:maxReportedFailures]
+			}
+		} else {
+			res.Enqueued++
+		}
+		if progress > 0 && (res.Enqueued+res.Failed)%progress == 0 {
+			report()
+		}
+	}
+
+	lines := make(chan bulkLine)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l := range lines {
+				task, opts, err := l.spec.Task()
+				if err == nil && limit != nil {
+					err = limit.Wait(ctx)
+				}
+				if err == nil {
+					_, err = enqueue(ctx, task, opts...)
+				}
+				record(l.n, err)
+			}
+		}()
+	}
+
+	last := 0
+	err := src(func(line int, spec TaskSpec, err error) bool {
+		last = line
+		if err != nil {
+			record(line, err)
+			return ctx.Err() == nil
+		}
+		if queue != "" {
+			spec.Queue = queue
+		}
+		select {
+		case lines <- bulkLine{n: line, spec: spec}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	close(lines)
+	wg.Wait()
+
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("stopped after line %d: %w", last, ctx.Err())
+	}
+	report()
+	return res, err
+}
+
+// newRateLimiter allows perSecond tasks per second, with bursts of as many
+func newRateLimiter(perSecond float64) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(perSecond), max(1, int(perSecond)))
+}
+
+// ndjsonSpecs reads one JSON task spec per line of r
+func ndjsonSpecs(r io.Reader) specSource {
+	return func(emit func(line int, spec TaskSpec, err error) bool) error {
+		scanner := bufio.NewScanner(r)
+		// Payloads may be large, allow lines of up to 16 MiB
+		scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+		n := 0
+		for scanner.Scan() {
+			n++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			var spec TaskSpec
+			err := json.Unmarshal(data, &spec)
+			if err != nil {
+				err = fmt.Errorf("invalid JSON: %w", err)
+			}
+			if !emit(n, spec, err) {
+				return nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read line %d: %w", n+1, err)
+		}
+		return nil
+	}
+}
+
+// runEnqueueCommand implements `enqueue`
+func runEnqueueCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("enqueue", flag.ContinueOnError)
+	file := fs.String("file", "", "Newline-delimited JSON task specs or CSV rows, - for stdin")
+	csvImport := fs.String("csv", "", "Read CSV rows mapped to tasks by this csvImports entry")
+	queue := fs.String("queue", "", "Queue to enqueue on instead of the one in each spec")
+	concurrency := fs.Int("concurrency", 8, "Tasks enqueued at once")
+	progress := fs.Int("progress", 1000, "Report progress every n tasks, 0 to disable")
+	perSecond := fs.Float64("rate", 0, "Tasks enqueued per second; 0 keeps the CSV import's rate or is unlimited")
+	dryRun := fs.Bool("dry-run", false, "Validate the specs without enqueueing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" || fs.NArg() > 0 {
+		return fmt.Errorf("usage: %s", enqueueCommandUsage)
+	}
+	if *concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive, got %d", *concurrency)
+	}
+	if *perSecond < 0 {
+		return fmt.Errorf("rate must be non-negative, got %v", *perSecond)
+	}
+
+	in := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("failed to open task specs: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	src := ndjsonSpecs(in)
+	limitRate := *perSecond
+	if *csvImport != "" {
+		config, ok := w.config.CSVImports[*csvImport]
+		if !ok {
+			return fmt.Errorf("unknown CSV import %q", *csvImport)
+		}
+		src = csvSpecs(&config, in)
+		if limitRate == 0 {
+			limitRate = config.Rate
+		}
+	}
+	var limit *rate.Limiter
+	if limitRate > 0 && !*dryRun {
+		limit = newRateLimiter(limitRate)
+	}
+
+	enqueue := func(context.Context, *asynq.Task, ...asynq.Option) (*asynq.TaskInfo, error) {
+		return nil, nil
+	}
+	if !*dryRun {
+		client, err := w.Client()
+		if err != nil {
+			return fmt.Errorf("failed to create client: %w", err)
+		}
+		enqueue = client.EnqueueContext
+	}
+
+	// Interrupting stops reading and still prints the summary
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	res, err := bulkEnqueue(ctx, enqueue, src, *queue, *concurrency, limit, *progress, os.Stderr)
+	for _, f := range res.Failures {
+		fmt.Fprintf(os.Stderr, "line %d: %v\n", f.Line, f.Err)
+	}
+	if res.Failed > len(res.Failures) {
+		fmt.Fprintf(os.Stderr, "... and %d more failures\n", res.Failed-len(res.Failures))
+	}
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "dry run: %d valid task specs\n", res.Enqueued)
+	}
+	if res.Failed > 0 {
+		return fmt.Errorf("%d of %d tasks failed to enqueue", res.Failed, res.Enqueued+res.Failed)
+	}
+	return nil
+}
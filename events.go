@@ -0,0 +1,308 @@
+package workerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Task lifecycle event names
+const (
+	EventEnqueued  = "enqueued"
+	EventStarted   = "started"
+	EventSucceeded = "succeeded"
+	EventFailed    = "failed"
+	EventArchived  = "archived"
+)
+
+// Supported event sinks
+const (
+	EventSinkRedis   = "redis"
+	EventSinkWebhook = "webhook"
+	EventSinkNATS    = "nats"
+)
+
+// EventsConfig configures publishing of task lifecycle events so other
+// systems can react to tasks without polling
+type EventsConfig struct {
+	// "redis", "webhook" or "nats"; empty disables publishing
+	Sink string `json:"sink" yaml:"sink" env:"WORKER_EVENTS_SINK"`
+
+	// Redis pub/sub channel or NATS subject events are published to
+	Channel string `json:"channel" yaml:"channel" env:"WORKER_EVENTS_CHANNEL" default:"workerd.events"`
+
+	// Webhook URL for the webhook sink, server URL for the nats sink
+	URL string `json:"url" yaml:"url" env:"WORKER_EVENTS_URL" secret:"true"`
+
+	// Event names to publish; empty publishes all
+	Events []string `json:"events" yaml:"events"`
+
+	// Timeout of a single publish
+	Timeout time.Duration `json:"timeout" yaml:"timeout" env:"WORKER_EVENTS_TIMEOUT" default:"5s"`
+
+	// Events buffered while the sink is slow; further events are dropped
+	BufferSize int `json:"bufferSize" yaml:"bufferSize" env:"WORKER_EVENTS_BUFFER_SIZE" default:"1000"`
+}
+
+// validate validates the events configuration
+func (ec *EventsConfig) validate() error {
+	switch ec.Sink {
+	case "":
+		return nil
+	case EventSinkRedis, EventSinkNATS:
+		if ec.Channel == "" {
+			return fmt.Errorf("events channel is required for the %s sink", ec.Sink)
+		}
+	case EventSinkWebhook:
+	default:
+		return fmt.Errorf("unknown events sink %q, expected %q, %q or %q", ec.Sink, EventSinkRedis, EventSinkWebhook, EventSinkNATS)
+	}
+	if ec.Sink != EventSinkRedis && ec.URL == "" {
+		return fmt.Errorf("events URL is required for the %s sink", ec.Sink)
+	}
+	for _, name := range ec.Events {
+		switch name {
+		case EventEnqueued, EventStarted, EventSucceeded, EventFailed, EventArchived:
+		default:
+			return fmt.Errorf("unknown event %q", name)
+		}
+	}
+	if ec.Timeout <= 0 {
+		return fmt.Errorf("events timeout must be positive, got %v", ec.Timeout)
+	}
+	if ec.BufferSize <= 0 {
+		return fmt.Errorf("events buffer size must be positive, got %d", ec.BufferSize)
+	}
+	return nil
+}
+
+// TaskEvent is one published task lifecycle event
+type TaskEvent struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	TaskID   string    `json:"task_id"`
+	Type     string    `json:"type"`
+	Queue    string    `json:"queue"`
+	Host     string    `json:"host"`
+	Retry    int       `json:"retry"`
+	Error    string    `json:"error,omitempty"`
+	Metadata Metadata  `json:"metadata,omitempty"`
+}
+
+// eventSink delivers encoded events
+type eventSink interface {
+	publish(ctx context.Context, data []byte) error
+	close() error
+}
+
+// eventPublisher queues events and delivers them in the background so a slow
+// sink never delays tasks. Delivery is best effort: events are dropped when
+// the buffer is full and failures are only logged.
+type eventPublisher struct {
+	sink    eventSink
+	events  []string
+	timeout time.Duration
+	host    string
+	log     *slog.Logger
+	queue   chan TaskEvent
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// newEventPublisher connects the configured sink, or returns nil if publishing is disabled
+func newEventPublisher(config *EventsConfig, redisOpt asynq.RedisConnOpt, logger *slog.Logger) (*eventPublisher, error) {
+	var sink eventSink
+	switch config.Sink {
+	case "":
+		return nil, nil
+	case EventSinkRedis:
+		rdb, ok := redisOpt.MakeRedisClient().(redis.UniversalClient)
+		if !ok {
+			return nil, fmt.Errorf("unsupported redis connection for the events channel")
+		}
+		sink = &redisEventSink{rdb: rdb, channel: config.Channel}
+	case EventSinkWebhook:
+		sink = &webhookEventSink{client: &http.Client{}, url: config.URL}
+	case EventSinkNATS:
+		nc, err := nats.Connect(config.URL, nats.Name("workerd"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+		}
+		sink = &natsEventSink{nc: nc, subject: config.Channel}
+	default:
+		return nil, fmt.Errorf("unknown events sink %q", config.Sink)
+	}
+
+	host, _ := os.Hostname()
+	p := &eventPublisher{
+		sink:    sink,
+		events:  config.Events,
+		timeout: config.Timeout,
+		host:    host,
+		log:     logger,
+		queue:   make(chan TaskEvent, config.BufferSize),
+		done:    make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// publish queues one event unless it is filtered out or the buffer is full
+func (p *eventPublisher) publish(event, taskID, taskType, queue string, retry int, md Metadata, err error) {
+	if len(p.events) > 0 && !slices.Contains(p.events, event) {
+		return
+	}
+	e := TaskEvent{
+		Time:     time.Now().UTC(),
+		Event:    event,
+		TaskID:   taskID,
+		Type:     taskType,
+		Queue:    queue,
+		Host:     p.host,
+		Retry:    retry,
+		Metadata: md,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- e:
+	default:
+		p.log.Warn("dropping task event, buffer full", "event", event, "task_id", taskID)
+	}
+}
+
+// run delivers queued events until the publisher is closed
+func (p *eventPublisher) run() {
+	defer close(p.done)
+	for e := range p.queue {
+		data, err := json.Marshal(e)
+		if err != nil {
+			p.log.Warn("could not encode task event", "event", e.Event, "task_id", e.TaskID, "error", err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		if err := p.sink.publish(ctx, data); err != nil {
+			p.log.Warn("could not publish task event", "event", e.Event, "task_id", e.TaskID, "error", err)
+		}
+		cancel()
+	}
+}
+
+// close delivers the buffered events and closes the sink
+func (p *eventPublisher) close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	<-p.done
+	return p.sink.close()
+}
+
+// redisEventSink publishes events on a Redis pub/sub channel
+type redisEventSink struct {
+	rdb     redis.UniversalClient
+	channel string
+}
+
+func (s *redisEventSink) publish(ctx context.Context, data []byte) error {
+	return s.rdb.Publish(ctx, s.channel, data).Err()
+}
+
+func (s *redisEventSink) close() error {
+	return s.rdb.Close()
+}
+
+// webhookEventSink POSTs each event as JSON to a URL
+type webhookEventSink struct {
+	client *http.Client
+	url    string
+}
+
+func (s *webhookEventSink) publish(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookEventSink) close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// natsEventSink publishes events on a NATS subject
+type natsEventSink struct {
+	nc      *nats.Conn
+	subject string
+}
+
+func (s *natsEventSink) publish(_ context.Context, data []byte) error {
+	return s.nc.Publish(s.subject, data)
+}
+
+func (s *natsEventSink) close() error {
+	// Drain flushes pending messages before closing
+	return s.nc.Drain()
+}
+
+// eventsMiddleware publishes started, succeeded, failed and archived events.
+// A failure is published as archived when no retries are left.
+func eventsMiddleware(p *eventPublisher) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if p == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			id, _ := asynq.GetTaskID(ctx)
+			queue, _ := asynq.GetQueueName(ctx)
+			retried, _ := asynq.GetRetryCount(ctx)
+			maxRetry, _ := asynq.GetMaxRetry(ctx)
+			md := MetadataFromContext(ctx)
+
+			p.publish(EventStarted, id, t.Type(), queue, retried, md, nil)
+			err := next.ProcessTask(ctx, t)
+
+			switch {
+			case err == nil:
+				p.publish(EventSucceeded, id, t.Type(), queue, retried, md, nil)
+			case retried >= maxRetry || errors.Is(err, asynq.SkipRetry):
+				p.publish(EventArchived, id, t.Type(), queue, retried, md, err)
+			default:
+				p.publish(EventFailed, id, t.Type(), queue, retried, md, err)
+			}
+			return err
+		})
+	}
+}
@@ -15,6 +15,6 @@ func main() {
 	// Run the workerd
 	if err := wf.Run(); err != nil {
 		log.Printf("Failed to run workerd: %v\n", err)
-		os.Exit(1)
+		os.Exit(workerd.ExitCode(err))
 	}
 }
@@ -0,0 +1,168 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// FailureCategory classifies task errors for metrics and retry decisions
+type FailureCategory string
+
+// Failure categories
+const (
+	// Temporary problem expected to go away on retry, e.g. a timeout
+	FailureTransient FailureCategory = "transient"
+	// Retrying won't help, e.g. the referenced record was deleted
+	FailurePermanent FailureCategory = "permanent"
+	// An external service the handler relies on is down or rejecting calls
+	FailureDependency FailureCategory = "dependency"
+	// The payload is invalid
+	FailureBadInput FailureCategory = "bad_input"
+	// Errors not wrapped with a category
+	FailureUnknown FailureCategory = "unknown"
+)
+
+// Actions of a failure policy
+const (
+	FailureActionRetry   = "retry"
+	FailureActionArchive = "archive"
+	FailureActionDrop    = "drop"
+)
+
+// CategorizedError is a task error with its failure category
+type CategorizedError struct {
+	Category FailureCategory
+	Err      error
+}
+
+func (e *CategorizedError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Category, e.Err)
+}
+
+func (e *CategorizedError) Unwrap() error {
+	return e.Err
+}
+
+// categorize wraps err with a category, keeping nil errors nil
+func categorize(category FailureCategory, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CategorizedError{Category: category, Err: err}
+}
+
+// Transient marks err as a temporary failure, retried with the default backoff
+func Transient(err error) error { return categorize(FailureTransient, err) }
+
+// Permanent marks err as a failure retries won't fix; the task is archived
+func Permanent(err error) error { return categorize(FailurePermanent, err) }
+
+// Dependency marks err as a failure of an external service; the task is
+// retried with a longer backoff
+func Dependency(err error) error { return categorize(FailureDependency, err) }
+
+// BadInput marks err as caused by an invalid payload; the task is archived
+func BadInput(err error) error { return categorize(FailureBadInput, err) }
+
+// FailureCategoryOf returns the category err was wrapped with, FailureUnknown
+// if it has none, or "" for a nil error
+func FailureCategoryOf(err error) FailureCategory {
+	if err == nil {
+		return ""
+	}
+	var ce *CategorizedError
+	if errors.As(err, &ce) {
+		return ce.Category
+	}
+	return FailureUnknown
+}
+
+// FailurePolicy decides what happens to a task failing with a category
+type FailurePolicy struct {
+	// "retry", "archive" or "drop"
+	Action string `json:"action" yaml:"action"`
+
+	// First retry delay, doubled on each retry up to MaxDelay; 0 keeps the
+	// default backoff
+	Delay    time.Duration `json:"delay" yaml:"delay"`
+	MaxDelay time.Duration `json:"maxDelay" yaml:"maxDelay"`
+}
+
+// defaultFailurePolicies apply to categories without a configured policy
+var defaultFailurePolicies = map[FailureCategory]FailurePolicy{
+	FailureTransient:  {Action: FailureActionRetry},
+	FailureDependency: {Action: FailureActionRetry, Delay: 30 * time.Second, MaxDelay: 30 * time.Minute},
+	FailurePermanent:  {Action: FailureActionArchive},
+	FailureBadInput:   {Action: FailureActionArchive},
+}
+
+// FailuresConfig overrides the policies of the failure categories
+type FailuresConfig struct {
+	// Policies by category: transient, permanent, dependency or bad_input
+	Policies map[FailureCategory]FailurePolicy `json:"policies" yaml:"policies"`
+}
+
+// validate validates the failure policies
+func (fc *FailuresConfig) validate() error {
+	for category, p := range fc.Policies {
+		if _, ok := defaultFailurePolicies[category]; !ok {
+			return fmt.Errorf("unknown failure category %q", category)
+		}
+		switch p.Action {
+		case FailureActionRetry, FailureActionArchive, FailureActionDrop:
+		default:
+			return fmt.Errorf("failure policy %q: unknown action %q, expected %q, %q or %q",
+				category, p.Action, FailureActionRetry, FailureActionArchive, FailureActionDrop)
+		}
+		if p.Delay < 0 || p.MaxDelay < 0 {
+			return fmt.Errorf("failure policy %q: delays must be non-negative", category)
+		}
+	}
+	return nil
+}
+
+// policyFor returns the policy of a category
+func (fc *FailuresConfig) policyFor(category FailureCategory) FailurePolicy {
+	if p, ok := fc.Policies[category]; ok {
+		return p
+	}
+	return defaultFailurePolicies[category]
+}
+
+// failureMiddleware applies the policy of the category a handler error was
+// wrapped with. Explicit retry hints such as RetryIn or Archive take
+// precedence. It runs inside retryMiddleware, which turns the resulting
+// directives into asynq's.
+func failureMiddleware(config *FailuresConfig) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			err := next.ProcessTask(ctx, t)
+			category := FailureCategoryOf(err)
+			if category == "" || category == FailureUnknown {
+				return err
+			}
+			var hint *RetryAfterError
+			var directive *retryDirective
+			if errors.As(err, &hint) || errors.As(err, &directive) ||
+				errors.Is(err, asynq.SkipRetry) || errors.Is(err, asynq.RevokeTask) {
+				return err
+			}
+
+			p := config.policyFor(category)
+			switch p.Action {
+			case FailureActionArchive:
+				return Archive(err)
+			case FailureActionDrop:
+				return Drop(err)
+			}
+			if p.Delay > 0 {
+				return RetryWith(ExponentialBackoff(p.Delay, max(p.MaxDelay, p.Delay)), err)
+			}
+			return err
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// errTenantBusy is returned for tasks postponed because their tenant already
+// runs its share of the queue. Like errBulkheadFull, the task is requeued and
+// keeps its retries.
+var errTenantBusy = errors.New("tenant is at its share of the queue")
+
+// FairnessConfig caps the tasks of one tenant a worker process runs at once
+// in a queue, so a tenant's backfill can't take every slot. Tasks over the
+// cap are put back with a short delay, letting other tenants' tasks queued
+// behind them run first.
+type FairnessConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_FAIRNESS_ENABLED" default:"false"`
+
+	// Metadata key naming the tenant of a task; tasks without it are never
+	// held back
+	Key string `json:"key" yaml:"key" env:"WORKER_FAIRNESS_KEY" default:"tenant"`
+
+	// Fraction of the worker's concurrency one tenant may use in a queue
+	MaxShare float64 `json:"maxShare" yaml:"maxShare" env:"WORKER_FAIRNESS_MAX_SHARE" default:"0.5"`
+
+	// Tasks one tenant may run at once in a queue, overriding MaxShare; 0
+	// uses MaxShare
+	MaxActive int `json:"maxActive" yaml:"maxActive" env:"WORKER_FAIRNESS_MAX_ACTIVE" default:"0"`
+
+	// Limits by tenant, overriding MaxActive and MaxShare
+	Tenants map[string]int `json:"tenants" yaml:"tenants"`
+
+	// Queues the limits apply to; empty applies them to every queue
+	Queues []string `json:"queues" yaml:"queues"`
+
+	// Delay before a task held back is tried again
+	RetryDelay time.Duration `json:"retryDelay" yaml:"retryDelay" env:"WORKER_FAIRNESS_RETRY_DELAY" default:"1s"`
+}
+
+// validate validates the fairness configuration
+func (fc *FairnessConfig) validate() error {
+	if !fc.Enabled {
+		return nil
+	}
+	if fc.Key == "" {
+		return fmt.Errorf("fairness key cannot be empty")
+	}
+	if fc.MaxShare <= 0 || fc.MaxShare > 1 {
+		return fmt.Errorf("fairness max share must be in (0, 1], got %v", fc.MaxShare)
+	}
+	if fc.MaxActive < 0 {
+		return fmt.Errorf("fairness max active must be non-negative, got %d", fc.MaxActive)
+	}
+	for tenant, limit := range fc.Tenants {
+		if limit <= 0 {
+			return fmt.Errorf("fairness limit of tenant %q must be positive, got %d", tenant, limit)
+		}
+	}
+	if fc.RetryDelay < 0 {
+		return fmt.Errorf("fairness retry delay must be non-negative, got %v", fc.RetryDelay)
+	}
+	return nil
+}
+
+// limit returns the tasks tenant may run at once in a queue of a worker
+// running concurrency tasks
+func (fc *FairnessConfig) limit(tenant string, concurrency int) int {
+	if limit, ok := fc.Tenants[tenant]; ok {
+		return limit
+	}
+	if fc.MaxActive > 0 {
+		return fc.MaxActive
+	}
+	return max(1, int(math.Ceil(fc.MaxShare*float64(concurrency))))
+}
+
+// appliesTo reports whether the limits apply to queue
+func (fc *FairnessConfig) appliesTo(queue string) bool {
+	return len(fc.Queues) == 0 || slices.Contains(fc.Queues, queue)
+}
+
+// tenantSlot identifies the tasks of a tenant in a queue
+type tenantSlot struct {
+	queue  string
+	tenant string
+}
+
+// tenantCounts counts the running tasks of every tenant by queue
+type tenantCounts struct {
+	mu     sync.Mutex
+	active map[tenantSlot]int
+}
+
+// acquire counts a task of s unless limit are already running
+func (tc *tenantCounts) acquire(s tenantSlot, limit int) bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.active[s] >= limit {
+		return false
+	}
+	tc.active[s]++
+	return true
+}
+
+// release uncounts a task of s
+func (tc *tenantCounts) release(s tenantSlot) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.active[s]--; tc.active[s] <= 0 {
+		delete(tc.active, s)
+	}
+}
+
+// fairnessMiddleware holds back the tasks of tenants already running their
+// share of a queue. Tasks held back are requeued to try again later without
+// using up a retry.
+func fairnessMiddleware(config *FairnessConfig, concurrency int) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if !config.Enabled {
+			return next
+		}
+		counts := &tenantCounts{active: make(map[tenantSlot]int)}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			tenant := MetadataFromContext(ctx)[config.Key]
+			queue, _ := asynq.GetQueueName(ctx)
+			if tenant == "" || !config.appliesTo(queue) {
+				return next.ProcessTask(ctx, t)
+			}
+
+			s := tenantSlot{queue: queue, tenant: tenant}
+			limit := config.limit(tenant, concurrency)
+			if !counts.acquire(s, limit) {
+				return requeueIn(ctx, config.RetryDelay, fmt.Errorf("%w: tenant %q, limit %d, queue %q", errTenantBusy, tenant, limit, queue))
+			}
+			defer counts.release(s)
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestFairnessLimit(t *testing.T) {
+	fc := &FairnessConfig{Enabled: true, Key: "tenant", MaxShare: 0.5, Tenants: map[string]int{"vip": 3}}
+	if err := fc.validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		tenant      string
+		concurrency int
+		want        int
+	}{
+		{"a", 10, 5},
+		{"a", 1, 1},
+		{"vip", 10, 3},
+	} {
+		if got := fc.limit(tt.tenant, tt.concurrency); got != tt.want {
+			t.Errorf("limit(%q, %d) = %d, want %d", tt.tenant, tt.concurrency, got, tt.want)
+		}
+	}
+}
+
+func TestFairnessMiddlewarePostponesBusyTenants(t *testing.T) {
+	fc := &FairnessConfig{Enabled: true, Key: "tenant", MaxShare: 0.5}
+	started, release := make(chan struct{}), make(chan struct{})
+	h := fairnessMiddleware(fc, 2)(asynq.HandlerFunc(func(context.Context, *asynq.Task) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}))
+	ctx := context.WithValue(context.Background(), metadataKey{}, Metadata{"tenant": "acme"})
+
+	done := make(chan error)
+	go func() { done <- h.ProcessTask(ctx, asynq.NewTask("t", nil)) }()
+	<-started
+
+	err := h.ProcessTask(ctx, asynq.NewTask("t", nil))
+	if !errors.Is(err, errTenantBusy) || isFailure(err) {
+		t.Errorf("second task of a busy tenant: got %v, want errTenantBusy not counted as a failure", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
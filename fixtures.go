@@ -0,0 +1,301 @@
+package workerd
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const replayCommandUsage = "replay [-enqueue] [-queue name] [-v] <file or dir>...  dispatch recorded task fixtures locally or enqueue them"
+
+// RecordingConfig captures samples of real task payloads as fixture files
+// that the replay command runs again, e.g. to reproduce a production bug
+type RecordingConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_RECORDING_ENABLED" default:"false"`
+
+	// Directory the fixtures are written to
+	Dir string `json:"dir" yaml:"dir" env:"WORKER_RECORDING_DIR" default:"fixtures"`
+
+	// Fraction of tasks recorded, between 0 and 1
+	SampleRate float64 `json:"sampleRate" yaml:"sampleRate" env:"WORKER_RECORDING_SAMPLE_RATE" default:"0.01"`
+
+	// Task types or type prefixes to record; empty records every type
+	Types []string `json:"types" yaml:"types"`
+
+	// JSON keys whose values are redacted, in addition to keys ending in
+	// password, secret, token, dsn or key(s)
+	Redact []string `json:"redact" yaml:"redact"`
+
+	// Fixtures written per process before recording stops
+	MaxFixtures int `json:"maxFixtures" yaml:"maxFixtures" env:"WORKER_RECORDING_MAX_FIXTURES" default:"1000"`
+}
+
+// validate validates the recording configuration
+func (rc *RecordingConfig) validate() error {
+	if !rc.Enabled {
+		return nil
+	}
+	if rc.Dir == "" {
+		return fmt.Errorf("recording directory cannot be empty")
+	}
+	if rc.SampleRate <= 0 || rc.SampleRate > 1 {
+		return fmt.Errorf("recording sample rate must be in (0, 1], got %v", rc.SampleRate)
+	}
+	if rc.MaxFixtures <= 0 {
+		return fmt.Errorf("recording max fixtures must be positive, got %d", rc.MaxFixtures)
+	}
+	return nil
+}
+
+// records reports whether tasks of the type are recorded
+func (rc *RecordingConfig) records(taskType string) bool {
+	if len(rc.Types) == 0 {
+		return true
+	}
+	for _, prefix := range rc.Types {
+		if matchTaskType(prefix, taskType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fixture is a recorded task
+type Fixture struct {
+	Type     string          `json:"type"`
+	Queue    string          `json:"queue"`
+	TaskID   string          `json:"task_id"`
+	Recorded time.Time       `json:"recorded"`
+	Payload  json.RawMessage `json:"payload"`
+	// File the fixture was loaded from
+	File string `json:"-"`
+}
+
+// Task returns the recorded task
+func (f *Fixture) Task() *asynq.Task {
+	return asynq.NewTask(f.Type, f.Payload)
+}
+
+// LoadFixtures reads the fixtures in the given files and directories
+func LoadFixtures(paths ...string) ([]Fixture, error) {
+	var fixtures []Fixture
+	for _, path := range paths {
+		files := []string{path}
+		if info, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("failed to read fixtures: %w", err)
+		} else if info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+				return nil, fmt.Errorf("failed to list fixtures in %s: %w", path, err)
+			}
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read fixture: %w", err)
+			}
+			var f Fixture
+			if err := json.Unmarshal(data, &f); err != nil {
+				return nil, fmt.Errorf("invalid fixture %s: %w", file, err)
+			}
+			if f.Type == "" {
+				return nil, fmt.Errorf("invalid fixture %s: task type is empty", file)
+			}
+			f.File = file
+			fixtures = append(fixtures, f)
+		}
+	}
+	return fixtures, nil
+}
+
+// secretPayloadKeys matches payload keys redacted from every recorded fixture
+var secretPayloadKeys = regexp.MustCompile(`(?i)(password|secret|tokens?|dsn|keys?)$`)
+
+// recorder writes sampled task payloads as fixtures
+type recorder struct {
+	config  *RecordingConfig
+	log     *slog.Logger
+	redact  *regexp.Regexp
+	written atomic.Int64
+}
+
+func newRecorder(config *RecordingConfig, log *slog.Logger) *recorder {
+	redact := secretPayloadKeys
+	if len(config.Redact) > 0 {
+		keys := make([]string, len(config.Redact))
+		for i, k := range config.Redact {
+			keys[i] = regexp.QuoteMeta(k)
+		}
+		redact = regexp.MustCompile(secretPayloadKeys.String() + `|(?i)^(` + strings.Join(keys, "|") + `)$`)
+	}
+	return &recorder{config: config, log: log, redact: redact}
+}
+
+// record writes the task as a fixture. Payloads that are not JSON are not
+// recorded since they cannot be redacted.
+func (r *recorder) record(ctx context.Context, t *asynq.Task) {
+	var payload any
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		r.log.Debug("task not recorded, payload is not JSON", "type", t.Type())
+		return
+	}
+	if r.written.Add(1) > int64(r.config.MaxFixtures) {
+		return
+	}
+
+	f := Fixture{Type: t.Type(), Recorded: time.Now().UTC()}
+	f.Queue, _ = asynq.GetQueueName(ctx)
+	f.TaskID, _ = asynq.GetTaskID(ctx)
+	redacted, err := json.Marshal(r.redactValue(payload))
+	if err != nil {
+		return
+	}
+	f.Payload = redacted
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return
+	}
+
+	name := fmt.Sprintf("%s-%s.json", fixtureName(f.Type), f.Recorded.Format("20060102T150405.000000000"))
+	if err := os.MkdirAll(r.config.Dir, 0o755); err == nil {
+		err = os.WriteFile(filepath.Join(r.config.Dir, name), data, 0o600)
+	}
+	if err != nil {
+		r.log.Warn("failed to record task fixture", "type", t.Type(), "error", err)
+	}
+}
+
+// redactValue replaces the values of secret keys in decoded JSON
+func (r *recorder) redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if r.redact.MatchString(k) {
+				v[k] = redactedValue
+			} else {
+				v[k] = r.redactValue(item)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = r.redactValue(item)
+		}
+	}
+	return v
+}
+
+// fixtureName makes a task type usable in a file name
+func fixtureName(taskType string) string {
+	return strings.Map(func(c rune) rune {
+		if c == '/' || c == '\\' || c == ':' || c == '*' || c == '?' || c == ' ' {
+			return '_'
+		}
+		return c
+	}, taskType)
+}
+
+// recordingMiddleware records a sample of the tasks before they run. Tasks
+// dispatched without a server, such as replayed fixtures, are not recorded.
+func recordingMiddleware(r *recorder) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if r == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			_, fromServer := asynq.GetTaskID(ctx)
+			if fromServer && r.config.records(t.Type()) && rand.Float64() < r.config.SampleRate {
+				r.record(ctx, t)
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
+
+// runReplayCommand implements `replay`
+func runReplayCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	enqueue := fs.Bool("enqueue", false, "Enqueue the fixtures instead of dispatching them locally")
+	queue := fs.String("queue", "", "Queue to enqueue on instead of the recorded one")
+	verbose := fs.Bool("v", false, "Print the logs of locally dispatched tasks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: %s", replayCommandUsage)
+	}
+
+	fixtures, err := LoadFixtures(fs.Args()...)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if *enqueue {
+		client, err := w.Client()
+		if err != nil {
+			return fmt.Errorf("failed to create client: %w", err)
+		}
+		for _, f := range fixtures {
+			var opts []asynq.Option
+			if q := cmp.Or(*queue, f.Queue); q != "" {
+				opts = append(opts, asynq.Queue(q))
+			}
+			info, err := client.EnqueueContext(ctx, f.Task(), opts...)
+			if err != nil {
+				return fmt.Errorf("failed to enqueue %s: %w", f.File, err)
+			}
+			fmt.Printf("%s\tenqueued %s on %s as %s\n", f.File, f.Type, info.Queue, info.ID)
+		}
+		return nil
+	}
+
+	failed := 0
+	for _, f := range fixtures {
+		res := w.TestDispatch(ctx, f.Task())
+		if res.Err != nil {
+			failed++
+			fmt.Printf("%s\t%s\tFAIL\t%v\t%v\n", f.File, f.Type, res.Duration.Round(time.Microsecond), res.Err)
+		} else {
+			fmt.Printf("%s\t%s\tok\t%v\n", f.File, f.Type, res.Duration.Round(time.Microsecond))
+		}
+		if *verbose {
+			for _, rec := range res.Logs {
+				fmt.Printf("    %s %s %s\n", rec.Level, rec.Message, formatAttrs(rec.Attrs))
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d fixtures failed", failed, len(fixtures))
+	}
+	return nil
+}
+
+// formatAttrs renders captured log attributes as key=value pairs
+func formatAttrs(attrs map[string]any) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	for _, k := range keys {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%s=%v", k, attrs[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,228 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Errors returned by the enqueue gateway for rejected task specs
+var (
+	errTaskTypeNotAccepted = errors.New("task type not accepted")
+	errInvalidTaskSpec     = errors.New("invalid task")
+)
+
+// GatewayConfig configures the enqueue gateway: an HTTP and gRPC API that
+// services without a Redis client library use to submit tasks
+type GatewayConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_GATEWAY_ENABLED" default:"false"`
+
+	// Listen address of the HTTP API, host:port or unix:/path/to.sock; empty
+	// disables it
+	Addr string `json:"addr" yaml:"addr" env:"WORKER_GATEWAY_ADDR" default:":8089"`
+
+	// Listen address of the gRPC API; empty disables it
+	GRPCAddr string `json:"grpcAddr" yaml:"grpcAddr" env:"WORKER_GATEWAY_GRPC_ADDR"`
+
+	// Client IPs or CIDRs the listeners accept connections from; empty
+	// accepts any
+	AllowFrom []string `json:"allowFrom" yaml:"allowFrom"`
+
+	// Bearer token required on every request
+	Token string `json:"token" yaml:"token" env:"WORKER_GATEWAY_TOKEN" secret:"true"`
+
+	// Further accepted bearer tokens, e.g. one per submitting service
+	Tokens []string `json:"tokens" yaml:"tokens" secret:"true"`
+
+	// TLS of the HTTP and gRPC listeners; with a client CA, requests also
+	// need a client certificate
+	TLS ListenerTLSConfig `json:"tls" yaml:"tls"`
+
+	// Largest accepted request body in bytes
+	MaxBodySize int64 `json:"maxBodySize" yaml:"maxBodySize" env:"WORKER_GATEWAY_MAX_BODY_SIZE" default:"1048576"`
+
+	// Most tasks accepted in one gRPC batch
+	MaxBatchSize int `json:"maxBatchSize" yaml:"maxBatchSize" env:"WORKER_GATEWAY_MAX_BATCH_SIZE" default:"1000"`
+
+	// Task types or type prefixes that may be submitted; empty accepts every type
+	Types []string `json:"types" yaml:"types"`
+
+	// Reject task types without a registered schema or payload type
+	RequireSchema bool `json:"requireSchema" yaml:"requireSchema" env:"WORKER_GATEWAY_REQUIRE_SCHEMA" default:"false"`
+}
+
+// validate validates the gateway configuration
+func (gc *GatewayConfig) validate() error {
+	if !gc.Enabled {
+		return nil
+	}
+	if gc.Addr == "" && gc.GRPCAddr == "" {
+		return fmt.Errorf("an HTTP or gRPC address is required when the gateway is enabled")
+	}
+	for _, addr := range []string{gc.Addr, gc.GRPCAddr} {
+		if addr == "" {
+			continue
+		}
+		if err := validateListen(addr, gc.AllowFrom); err != nil {
+			return err
+		}
+	}
+	if gc.Token == "" && len(gc.Tokens) == 0 && !gc.TLS.mutual() {
+		return fmt.Errorf("token or TLS client CA is required when the gateway is enabled")
+	}
+	if err := gc.TLS.validate(); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	if gc.MaxBodySize <= 0 {
+		return fmt.Errorf("max body size must be positive, got %d", gc.MaxBodySize)
+	}
+	if gc.MaxBatchSize <= 0 {
+		return fmt.Errorf("max batch size must be positive, got %d", gc.MaxBatchSize)
+	}
+	return nil
+}
+
+// auth returns the authentication of the gateway listeners
+func (gc *GatewayConfig) auth() *listenerAuth {
+	return newListenerAuth(gc.Token, gc.Tokens, &gc.TLS, nil)
+}
+
+// accepts reports whether tasks of the type may be submitted
+func (gc *GatewayConfig) accepts(taskType string) bool {
+	if len(gc.Types) == 0 {
+		return true
+	}
+	for _, pattern := range gc.Types {
+		if matchTaskType(pattern, taskType) {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewayIngestor serves the enqueue gateway. Payloads are validated against
+// the schema registry before they are enqueued, so bad tasks are rejected
+// with the request instead of failing on a worker.
+type gatewayIngestor struct {
+	config *GatewayConfig
+	srv    *http.Server
+	grpc   *grpcGateway
+}
+
+// start binds the listeners and serves in the background
+func (g *gatewayIngestor) start(w *Workerd) error {
+	client, err := w.Client()
+	if err != nil {
+		return err
+	}
+
+	if g.config.GRPCAddr != "" {
+		if g.grpc, err = newGRPCGateway(g, client, w); err != nil {
+			return err
+		}
+		if err := g.grpc.start(g.config.GRPCAddr); err != nil {
+			return err
+		}
+	}
+	if g.config.Addr == "" {
+		return nil
+	}
+
+	auth := g.config.auth()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", func(rw http.ResponseWriter, r *http.Request) {
+		g.handleEnqueue(rw, r, client, w)
+	})
+	g.srv = &http.Server{
+		// Priority, tenant and trace headers apply to the submitted tasks
+		Handler:           auth.http(RequestValuesMiddleware(mux)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ln, err := auth.listen(g.config.Addr, g.config.AllowFrom, w.log)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := g.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			w.log.Error("enqueue gateway stopped unexpectedly", "error", err)
+		}
+	}()
+
+	w.log.Info("enqueue gateway listening", "addr", ln.Addr().String())
+	return nil
+}
+
+// stop gracefully shuts the servers down, finishing in-flight requests
+func (g *gatewayIngestor) stop(ctx context.Context) error {
+	if g.grpc != nil {
+		g.grpc.stop(ctx)
+	}
+	if g.srv == nil {
+		return nil
+	}
+	return g.srv.Shutdown(ctx)
+}
+
+// enqueue validates a submitted task and enqueues it
+func (g *gatewayIngestor) enqueue(ctx context.Context, task *asynq.Task, opts []asynq.Option, client *Client, w *Workerd) (*asynq.TaskInfo, error) {
+	if !g.config.accepts(task.Type()) {
+		return nil, fmt.Errorf("%w: %q", errTaskTypeNotAccepted, task.Type())
+	}
+	if g.config.RequireSchema && w.schemas.get(task.Type()) == nil {
+		return nil, fmt.Errorf("%w: no schema registered for %q", errTaskTypeNotAccepted, task.Type())
+	}
+	if err := w.ValidatePayload(task.Type(), task.Payload()); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidTaskSpec, err)
+	}
+	return client.EnqueueContext(ctx, task, opts...)
+}
+
+// handleEnqueue implements POST /tasks with a TaskSpec body
+func (g *gatewayIngestor) handleEnqueue(rw http.ResponseWriter, r *http.Request, client *Client, w *Workerd) {
+	body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, g.config.MaxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(rw, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+			return
+		}
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("could not read request body: %w", err))
+		return
+	}
+	var spec TaskSpec
+	if err := json.Unmarshal(body, &spec); err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	task, opts, err := spec.Task()
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("%w: %w", errInvalidTaskSpec, err))
+		return
+	}
+
+	info, err := g.enqueue(r.Context(), task, opts, client, w)
+	switch {
+	case errors.Is(err, errTaskTypeNotAccepted):
+		writeError(rw, http.StatusForbidden, err)
+	case errors.Is(err, errInvalidTaskSpec):
+		writeError(rw, http.StatusBadRequest, err)
+	case errors.Is(err, asynq.ErrTaskIDConflict), errors.Is(err, asynq.ErrDuplicateTask):
+		writeError(rw, http.StatusConflict, err)
+	case errors.Is(err, ErrQueueFull):
+		rw.Header().Set("Retry-After", "1")
+		writeError(rw, http.StatusTooManyRequests, err)
+	case err != nil:
+		w.log.Error("could not enqueue submitted task", "type", spec.Type, "error", err)
+		writeError(rw, http.StatusInternalServerError, errors.New("could not enqueue task"))
+	default:
+		writeJSON(rw, http.StatusAccepted, map[string]any{"task_id": info.ID, "queue": info.Queue})
+	}
+}
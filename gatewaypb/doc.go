@@ -0,0 +1,5 @@
+// Package gatewaypb contains the generated gRPC bindings for the workerd
+// enqueue gateway defined in gateway.proto.
+package gatewaypb
+
+//go:generate protoc -I.. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative gatewaypb/gateway.proto
@@ -0,0 +1,584 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: gatewaypb/gateway.proto
+
+package gatewaypb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Task is a task to enqueue
+type Task struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	// Payload, checked against the schema registered for the type
+	Payload []byte `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	// Queue of the task; empty keeps the routing table or the default
+	Queue   string       `protobuf:"bytes,3,opt,name=queue,proto3" json:"queue,omitempty"`
+	Options *TaskOptions `protobuf:"bytes,4,opt,name=options,proto3" json:"options,omitempty"`
+	// Attached to the task as metadata, e.g. tenant or trace_id
+	Metadata      map[string]string `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Task) Reset() {
+	*x = Task{}
+	mi := &file_gatewaypb_gateway_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Task) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Task) ProtoMessage() {}
+
+func (x *Task) ProtoReflect() protoreflect.Message {
+	mi := &file_gatewaypb_gateway_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Task.ProtoReflect.Descriptor instead.
+func (*Task) Descriptor() ([]byte, []int) {
+	return file_gatewaypb_gateway_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Task) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Task) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Task) GetQueue() string {
+	if x != nil {
+		return x.Queue
+	}
+	return ""
+}
+
+func (x *Task) GetOptions() *TaskOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *Task) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// TaskOptions are the enqueue options of a task; unset fields keep the default
+type TaskOptions struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	MaxRetry int32                  `protobuf:"varint,1,opt,name=max_retry,json=maxRetry,proto3" json:"max_retry,omitempty"`
+	Timeout  *durationpb.Duration   `protobuf:"bytes,2,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	// Time by which the handler must finish
+	Deadline      *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	ProcessIn     *durationpb.Duration   `protobuf:"bytes,4,opt,name=process_in,json=processIn,proto3" json:"process_in,omitempty"`
+	ProcessAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=process_at,json=processAt,proto3" json:"process_at,omitempty"`
+	Unique        *durationpb.Duration   `protobuf:"bytes,6,opt,name=unique,proto3" json:"unique,omitempty"`
+	Retention     *durationpb.Duration   `protobuf:"bytes,7,opt,name=retention,proto3" json:"retention,omitempty"`
+	TaskId        string                 `protobuf:"bytes,8,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	Group         string                 `protobuf:"bytes,9,opt,name=group,proto3" json:"group,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TaskOptions) Reset() {
+	*x = TaskOptions{}
+	mi := &file_gatewaypb_gateway_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaskOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskOptions) ProtoMessage() {}
+
+func (x *TaskOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_gatewaypb_gateway_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskOptions.ProtoReflect.Descriptor instead.
+func (*TaskOptions) Descriptor() ([]byte, []int) {
+	return file_gatewaypb_gateway_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TaskOptions) GetMaxRetry() int32 {
+	if x != nil {
+		return x.MaxRetry
+	}
+	return 0
+}
+
+func (x *TaskOptions) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+func (x *TaskOptions) GetDeadline() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Deadline
+	}
+	return nil
+}
+
+func (x *TaskOptions) GetProcessIn() *durationpb.Duration {
+	if x != nil {
+		return x.ProcessIn
+	}
+	return nil
+}
+
+func (x *TaskOptions) GetProcessAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ProcessAt
+	}
+	return nil
+}
+
+func (x *TaskOptions) GetUnique() *durationpb.Duration {
+	if x != nil {
+		return x.Unique
+	}
+	return nil
+}
+
+func (x *TaskOptions) GetRetention() *durationpb.Duration {
+	if x != nil {
+		return x.Retention
+	}
+	return nil
+}
+
+func (x *TaskOptions) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
+func (x *TaskOptions) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+type EnqueueRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Task          *Task                  `protobuf:"bytes,1,opt,name=task,proto3" json:"task,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnqueueRequest) Reset() {
+	*x = EnqueueRequest{}
+	mi := &file_gatewaypb_gateway_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnqueueRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnqueueRequest) ProtoMessage() {}
+
+func (x *EnqueueRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gatewaypb_gateway_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnqueueRequest.ProtoReflect.Descriptor instead.
+func (*EnqueueRequest) Descriptor() ([]byte, []int) {
+	return file_gatewaypb_gateway_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *EnqueueRequest) GetTask() *Task {
+	if x != nil {
+		return x.Task
+	}
+	return nil
+}
+
+type EnqueueResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TaskId        string                 `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	Queue         string                 `protobuf:"bytes,2,opt,name=queue,proto3" json:"queue,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnqueueResponse) Reset() {
+	*x = EnqueueResponse{}
+	mi := &file_gatewaypb_gateway_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnqueueResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnqueueResponse) ProtoMessage() {}
+
+func (x *EnqueueResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gatewaypb_gateway_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnqueueResponse.ProtoReflect.Descriptor instead.
+func (*EnqueueResponse) Descriptor() ([]byte, []int) {
+	return file_gatewaypb_gateway_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EnqueueResponse) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
+func (x *EnqueueResponse) GetQueue() string {
+	if x != nil {
+		return x.Queue
+	}
+	return ""
+}
+
+type EnqueueBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tasks         []*Task                `protobuf:"bytes,1,rep,name=tasks,proto3" json:"tasks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnqueueBatchRequest) Reset() {
+	*x = EnqueueBatchRequest{}
+	mi := &file_gatewaypb_gateway_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnqueueBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnqueueBatchRequest) ProtoMessage() {}
+
+func (x *EnqueueBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gatewaypb_gateway_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnqueueBatchRequest.ProtoReflect.Descriptor instead.
+func (*EnqueueBatchRequest) Descriptor() ([]byte, []int) {
+	return file_gatewaypb_gateway_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *EnqueueBatchRequest) GetTasks() []*Task {
+	if x != nil {
+		return x.Tasks
+	}
+	return nil
+}
+
+type EnqueueBatchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Outcome of every task, in request order
+	Results       []*EnqueueResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnqueueBatchResponse) Reset() {
+	*x = EnqueueBatchResponse{}
+	mi := &file_gatewaypb_gateway_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnqueueBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnqueueBatchResponse) ProtoMessage() {}
+
+func (x *EnqueueBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gatewaypb_gateway_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnqueueBatchResponse.ProtoReflect.Descriptor instead.
+func (*EnqueueBatchResponse) Descriptor() ([]byte, []int) {
+	return file_gatewaypb_gateway_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EnqueueBatchResponse) GetResults() []*EnqueueResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type EnqueueResult struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	TaskId string                 `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	Queue  string                 `protobuf:"bytes,2,opt,name=queue,proto3" json:"queue,omitempty"`
+	// gRPC status code of the task, 0 when it was enqueued
+	Code          int32  `protobuf:"varint,3,opt,name=code,proto3" json:"code,omitempty"`
+	Error         string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnqueueResult) Reset() {
+	*x = EnqueueResult{}
+	mi := &file_gatewaypb_gateway_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnqueueResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnqueueResult) ProtoMessage() {}
+
+func (x *EnqueueResult) ProtoReflect() protoreflect.Message {
+	mi := &file_gatewaypb_gateway_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnqueueResult.ProtoReflect.Descriptor instead.
+func (*EnqueueResult) Descriptor() ([]byte, []int) {
+	return file_gatewaypb_gateway_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *EnqueueResult) GetTaskId() string {
+	if x != nil {
+		return x.TaskId
+	}
+	return ""
+}
+
+func (x *EnqueueResult) GetQueue() string {
+	if x != nil {
+		return x.Queue
+	}
+	return ""
+}
+
+func (x *EnqueueResult) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *EnqueueResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_gatewaypb_gateway_proto protoreflect.FileDescriptor
+
+const file_gatewaypb_gateway_proto_rawDesc = "" +
+	"\n" +
+	"\x17gatewaypb/gateway.proto\x12\x12workerd.gateway.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x86\x02\n" +
+	"\x04Task\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x18\n" +
+	"\apayload\x18\x02 \x01(\fR\apayload\x12\x14\n" +
+	"\x05queue\x18\x03 \x01(\tR\x05queue\x129\n" +
+	"\aoptions\x18\x04 \x01(\v2\x1f.workerd.gateway.v1.TaskOptionsR\aoptions\x12B\n" +
+	"\bmetadata\x18\x05 \x03(\v2&.workerd.gateway.v1.Task.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa7\x03\n" +
+	"\vTaskOptions\x12\x1b\n" +
+	"\tmax_retry\x18\x01 \x01(\x05R\bmaxRetry\x123\n" +
+	"\atimeout\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\atimeout\x126\n" +
+	"\bdeadline\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\bdeadline\x128\n" +
+	"\n" +
+	"process_in\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\tprocessIn\x129\n" +
+	"\n" +
+	"process_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tprocessAt\x121\n" +
+	"\x06unique\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x06unique\x127\n" +
+	"\tretention\x18\a \x01(\v2\x19.google.protobuf.DurationR\tretention\x12\x17\n" +
+	"\atask_id\x18\b \x01(\tR\x06taskId\x12\x14\n" +
+	"\x05group\x18\t \x01(\tR\x05group\">\n" +
+	"\x0eEnqueueRequest\x12,\n" +
+	"\x04task\x18\x01 \x01(\v2\x18.workerd.gateway.v1.TaskR\x04task\"@\n" +
+	"\x0fEnqueueResponse\x12\x17\n" +
+	"\atask_id\x18\x01 \x01(\tR\x06taskId\x12\x14\n" +
+	"\x05queue\x18\x02 \x01(\tR\x05queue\"E\n" +
+	"\x13EnqueueBatchRequest\x12.\n" +
+	"\x05tasks\x18\x01 \x03(\v2\x18.workerd.gateway.v1.TaskR\x05tasks\"S\n" +
+	"\x14EnqueueBatchResponse\x12;\n" +
+	"\aresults\x18\x01 \x03(\v2!.workerd.gateway.v1.EnqueueResultR\aresults\"h\n" +
+	"\rEnqueueResult\x12\x17\n" +
+	"\atask_id\x18\x01 \x01(\tR\x06taskId\x12\x14\n" +
+	"\x05queue\x18\x02 \x01(\tR\x05queue\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\x05R\x04code\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error2\xc0\x01\n" +
+	"\aGateway\x12R\n" +
+	"\aEnqueue\x12\".workerd.gateway.v1.EnqueueRequest\x1a#.workerd.gateway.v1.EnqueueResponse\x12a\n" +
+	"\fEnqueueBatch\x12'.workerd.gateway.v1.EnqueueBatchRequest\x1a(.workerd.gateway.v1.EnqueueBatchResponseB*Z(github.com/paulgrammer/workerd/gatewaypbb\x06proto3"
+
+var (
+	file_gatewaypb_gateway_proto_rawDescOnce sync.Once
+	file_gatewaypb_gateway_proto_rawDescData []byte
+)
+
+func file_gatewaypb_gateway_proto_rawDescGZIP() []byte {
+	file_gatewaypb_gateway_proto_rawDescOnce.Do(func() {
+		file_gatewaypb_gateway_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gatewaypb_gateway_proto_rawDesc), len(file_gatewaypb_gateway_proto_rawDesc)))
+	})
+	return file_gatewaypb_gateway_proto_rawDescData
+}
+
+var file_gatewaypb_gateway_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_gatewaypb_gateway_proto_goTypes = []any{
+	(*Task)(nil),                  // 0: workerd.gateway.v1.Task
+	(*TaskOptions)(nil),           // 1: workerd.gateway.v1.TaskOptions
+	(*EnqueueRequest)(nil),        // 2: workerd.gateway.v1.EnqueueRequest
+	(*EnqueueResponse)(nil),       // 3: workerd.gateway.v1.EnqueueResponse
+	(*EnqueueBatchRequest)(nil),   // 4: workerd.gateway.v1.EnqueueBatchRequest
+	(*EnqueueBatchResponse)(nil),  // 5: workerd.gateway.v1.EnqueueBatchResponse
+	(*EnqueueResult)(nil),         // 6: workerd.gateway.v1.EnqueueResult
+	nil,                           // 7: workerd.gateway.v1.Task.MetadataEntry
+	(*durationpb.Duration)(nil),   // 8: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+}
+var file_gatewaypb_gateway_proto_depIdxs = []int32{
+	1,  // 0: workerd.gateway.v1.Task.options:type_name -> workerd.gateway.v1.TaskOptions
+	7,  // 1: workerd.gateway.v1.Task.metadata:type_name -> workerd.gateway.v1.Task.MetadataEntry
+	8,  // 2: workerd.gateway.v1.TaskOptions.timeout:type_name -> google.protobuf.Duration
+	9,  // 3: workerd.gateway.v1.TaskOptions.deadline:type_name -> google.protobuf.Timestamp
+	8,  // 4: workerd.gateway.v1.TaskOptions.process_in:type_name -> google.protobuf.Duration
+	9,  // 5: workerd.gateway.v1.TaskOptions.process_at:type_name -> google.protobuf.Timestamp
+	8,  // 6: workerd.gateway.v1.TaskOptions.unique:type_name -> google.protobuf.Duration
+	8,  // 7: workerd.gateway.v1.TaskOptions.retention:type_name -> google.protobuf.Duration
+	0,  // 8: workerd.gateway.v1.EnqueueRequest.task:type_name -> workerd.gateway.v1.Task
+	0,  // 9: workerd.gateway.v1.EnqueueBatchRequest.tasks:type_name -> workerd.gateway.v1.Task
+	6,  // 10: workerd.gateway.v1.EnqueueBatchResponse.results:type_name -> workerd.gateway.v1.EnqueueResult
+	2,  // 11: workerd.gateway.v1.Gateway.Enqueue:input_type -> workerd.gateway.v1.EnqueueRequest
+	4,  // 12: workerd.gateway.v1.Gateway.EnqueueBatch:input_type -> workerd.gateway.v1.EnqueueBatchRequest
+	3,  // 13: workerd.gateway.v1.Gateway.Enqueue:output_type -> workerd.gateway.v1.EnqueueResponse
+	5,  // 14: workerd.gateway.v1.Gateway.EnqueueBatch:output_type -> workerd.gateway.v1.EnqueueBatchResponse
+	13, // [13:15] is the sub-list for method output_type
+	11, // [11:13] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_gatewaypb_gateway_proto_init() }
+func file_gatewaypb_gateway_proto_init() {
+	if File_gatewaypb_gateway_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gatewaypb_gateway_proto_rawDesc), len(file_gatewaypb_gateway_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gatewaypb_gateway_proto_goTypes,
+		DependencyIndexes: file_gatewaypb_gateway_proto_depIdxs,
+		MessageInfos:      file_gatewaypb_gateway_proto_msgTypes,
+	}.Build()
+	File_gatewaypb_gateway_proto = out.File
+	file_gatewaypb_gateway_proto_goTypes = nil
+	file_gatewaypb_gateway_proto_depIdxs = nil
+}
@@ -0,0 +1,171 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/paulgrammer/workerd/adminpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// grpcHealthInterval is how often the gRPC health status is refreshed
+const grpcHealthInterval = 10 * time.Second
+
+// grpcAdminServer exposes the admin API over gRPC
+type grpcAdminServer struct {
+	adminpb.UnimplementedAdminServer
+
+	w      *Workerd
+	srv    *grpc.Server
+	health *health.Server
+}
+
+// newGRPCAdminServer creates the gRPC server with the admin and health services registered
+func newGRPCAdminServer(w *Workerd) (*grpcAdminServer, error) {
+	opts, err := w.config.Admin.auth().grpcOptions()
+	if err != nil {
+		return nil, err
+	}
+	g := &grpcAdminServer{w: w, health: health.NewServer()}
+	g.srv = grpc.NewServer(opts...)
+	adminpb.RegisterAdminServer(g.srv, g)
+	healthpb.RegisterHealthServer(g.srv, g.health)
+	return g, nil
+}
+
+// start binds the listener and serves in the background
+func (g *grpcAdminServer) start(addr string) error {
+	ln, err := listen(addr, g.w.config.Admin.AllowFrom, g.w.log)
+	if err != nil {
+		return err
+	}
+
+	g.updateHealth()
+	go func() {
+		if err := g.srv.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			g.w.log.Error("gRPC admin server stopped unexpectedly", "error", err)
+		}
+	}()
+
+	g.w.log.Info("gRPC admin API listening", "addr", ln.Addr().String())
+	return nil
+}
+
+// stop marks the service as not serving and drains in-flight calls until ctx expires
+func (g *grpcAdminServer) stop(ctx context.Context) {
+	g.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		g.srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		g.srv.Stop()
+	}
+}
+
+// updateHealth reflects broker reachability in the standard health service
+func (g *grpcAdminServer) updateHealth() {
+	state := healthpb.HealthCheckResponse_SERVING
+	if err := g.w.checkReady(); err != nil {
+		state = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	g.health.SetServingStatus("", state)
+	g.health.SetServingStatus(adminpb.Admin_ServiceDesc.ServiceName, state)
+}
+
+func (g *grpcAdminServer) ListQueues(ctx context.Context, _ *adminpb.ListQueuesRequest) (*adminpb.ListQueuesResponse, error) {
+	queues, err := g.w.queueNames()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &adminpb.ListQueuesResponse{Queues: make([]*adminpb.QueueStats, 0, len(queues))}
+	for _, q := range queues {
+		info, err := g.w.readQueueInspector(q).GetQueueInfo(q)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		resp.Queues = append(resp.Queues, queueStats(info))
+	}
+	return resp, nil
+}
+
+func (g *grpcAdminServer) GetQueue(ctx context.Context, req *adminpb.GetQueueRequest) (*adminpb.QueueStats, error) {
+	queue := req.GetQueue()
+	info, err := g.w.readQueueInspector(queue).GetQueueInfo(queue)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return queueStats(info), nil
+}
+
+func (g *grpcAdminServer) PauseQueue(ctx context.Context, req *adminpb.PauseQueueRequest) (*adminpb.PauseQueueResponse, error) {
+	if err := g.w.PauseQueue(req.GetQueue()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminpb.PauseQueueResponse{}, nil
+}
+
+func (g *grpcAdminServer) ResumeQueue(ctx context.Context, req *adminpb.ResumeQueueRequest) (*adminpb.ResumeQueueResponse, error) {
+	if err := g.w.ResumeQueue(req.GetQueue()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminpb.ResumeQueueResponse{}, nil
+}
+
+func (g *grpcAdminServer) RequeueTask(ctx context.Context, req *adminpb.RequeueTaskRequest) (*adminpb.RequeueTaskResponse, error) {
+	if err := g.w.RequeueTask(req.GetQueue(), req.GetTaskId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminpb.RequeueTaskResponse{}, nil
+}
+
+func (g *grpcAdminServer) CancelTask(ctx context.Context, req *adminpb.CancelTaskRequest) (*adminpb.CancelTaskResponse, error) {
+	if err := g.w.CancelTask(req.GetQueue(), req.GetTaskId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminpb.CancelTaskResponse{}, nil
+}
+
+// queueStats converts asynq queue info to its protobuf form
+func queueStats(info *asynq.QueueInfo) *adminpb.QueueStats {
+	return &adminpb.QueueStats{
+		Queue:            info.Queue,
+		Paused:           info.Paused,
+		MemoryUsageBytes: info.MemoryUsage,
+		LatencyMs:        info.Latency.Milliseconds(),
+		Size:             int32(info.Size),
+		Pending:          int32(info.Pending),
+		Active:           int32(info.Active),
+		Scheduled:        int32(info.Scheduled),
+		Retry:            int32(info.Retry),
+		Archived:         int32(info.Archived),
+		Completed:        int32(info.Completed),
+		Processed:        int32(info.Processed),
+		Failed:           int32(info.Failed),
+		ProcessedTotal:   int32(info.ProcessedTotal),
+		FailedTotal:      int32(info.FailedTotal),
+	}
+}
+
+// grpcError maps workerd and asynq errors to gRPC status codes
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, asynq.ErrQueueNotFound), errors.Is(err, asynq.ErrTaskNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrTaskNotActive):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
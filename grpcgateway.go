@@ -0,0 +1,179 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/paulgrammer/workerd/gatewaypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// grpcGateway serves the enqueue gateway over gRPC
+type grpcGateway struct {
+	gatewaypb.UnimplementedGatewayServer
+
+	gateway *gatewayIngestor
+	client  *Client
+	w       *Workerd
+	srv     *grpc.Server
+}
+
+func newGRPCGateway(gateway *gatewayIngestor, client *Client, w *Workerd) (*grpcGateway, error) {
+	opts, err := gateway.config.auth().grpcOptions()
+	if err != nil {
+		return nil, err
+	}
+	g := &grpcGateway{gateway: gateway, client: client, w: w}
+	g.srv = grpc.NewServer(opts...)
+	gatewaypb.RegisterGatewayServer(g.srv, g)
+	return g, nil
+}
+
+// start binds the listener and serves in the background
+func (g *grpcGateway) start(addr string) error {
+	ln, err := listen(addr, g.gateway.config.AllowFrom, g.w.log)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := g.srv.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			g.w.log.Error("gRPC enqueue gateway stopped unexpectedly", "error", err)
+		}
+	}()
+
+	g.w.log.Info("gRPC enqueue gateway listening", "addr", ln.Addr().String())
+	return nil
+}
+
+// stop drains in-flight calls until ctx expires
+func (g *grpcGateway) stop(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		g.srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		g.srv.Stop()
+	}
+}
+
+func (g *grpcGateway) Enqueue(ctx context.Context, req *gatewaypb.EnqueueRequest) (*gatewaypb.EnqueueResponse, error) {
+	info, err := g.enqueue(ctx, req.GetTask())
+	if err != nil {
+		return nil, err
+	}
+	return &gatewaypb.EnqueueResponse{TaskId: info.ID, Queue: info.Queue}, nil
+}
+
+func (g *grpcGateway) EnqueueBatch(ctx context.Context, req *gatewaypb.EnqueueBatchRequest) (*gatewaypb.EnqueueBatchResponse, error) {
+	if n := len(req.GetTasks()); n > g.gateway.config.MaxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d tasks exceeds the limit of %d", n, g.gateway.config.MaxBatchSize)
+	}
+	resp := &gatewaypb.EnqueueBatchResponse{Results: make([]*gatewaypb.EnqueueResult, 0, len(req.GetTasks()))}
+	for _, t := range req.GetTasks() {
+		info, err := g.enqueue(ctx, t)
+		if err != nil {
+			st := status.Convert(err)
+			resp.Results = append(resp.Results, &gatewaypb.EnqueueResult{Code: int32(st.Code()), Error: st.Message()})
+			continue
+		}
+		resp.Results = append(resp.Results, &gatewaypb.EnqueueResult{TaskId: info.ID, Queue: info.Queue})
+	}
+	return resp, nil
+}
+
+// enqueue validates and enqueues one task, returning a status error
+func (g *grpcGateway) enqueue(ctx context.Context, t *gatewaypb.Task) (*asynq.TaskInfo, error) {
+	if t.GetType() == "" {
+		return nil, status.Error(codes.InvalidArgument, "task type cannot be empty")
+	}
+	opts, err := grpcTaskOptions(t)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v: %v", errInvalidTaskSpec, err)
+	}
+	if len(t.GetMetadata()) > 0 {
+		ctx = WithMetadata(ctx, t.GetMetadata())
+	}
+
+	info, err := g.gateway.enqueue(ctx, asynq.NewTask(t.GetType(), t.GetPayload()), opts, g.client, g.w)
+	switch {
+	case err == nil:
+		return info, nil
+	case errors.Is(err, errTaskTypeNotAccepted):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, errInvalidTaskSpec):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, asynq.ErrTaskIDConflict), errors.Is(err, asynq.ErrDuplicateTask):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrQueueFull):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return nil, status.FromContextError(err).Err()
+	default:
+		g.w.log.Error("could not enqueue submitted task", "type", t.GetType(), "error", err)
+		return nil, status.Error(codes.Internal, "could not enqueue task")
+	}
+}
+
+// grpcTaskOptions converts the options of a submitted task
+func grpcTaskOptions(t *gatewaypb.Task) ([]asynq.Option, error) {
+	o := t.GetOptions()
+	var opts []asynq.Option
+	if t.GetQueue() != "" {
+		opts = append(opts, asynq.Queue(t.GetQueue()))
+	}
+	if o == nil {
+		return opts, nil
+	}
+	if o.GetMaxRetry() < 0 {
+		return nil, fmt.Errorf("max retry must be non-negative, got %d", o.GetMaxRetry())
+	}
+	if o.GetMaxRetry() > 0 {
+		opts = append(opts, asynq.MaxRetry(int(o.GetMaxRetry())))
+	}
+	for _, d := range []struct {
+		name   string
+		value  *durationpb.Duration
+		option func(d time.Duration) asynq.Option
+	}{
+		{"timeout", o.GetTimeout(), asynq.Timeout},
+		{"process_in", o.GetProcessIn(), asynq.ProcessIn},
+		{"unique", o.GetUnique(), asynq.Unique},
+		{"retention", o.GetRetention(), asynq.Retention},
+	} {
+		if d.value == nil {
+			continue
+		}
+		if err := d.value.CheckValid(); err != nil || d.value.AsDuration() < 0 {
+			return nil, fmt.Errorf("invalid %s, expected a non-negative duration", d.name)
+		}
+		opts = append(opts, d.option(d.value.AsDuration()))
+	}
+	if ts := o.GetDeadline(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return nil, fmt.Errorf("invalid deadline: %w", err)
+		}
+		opts = append(opts, asynq.Deadline(ts.AsTime()))
+	}
+	if ts := o.GetProcessAt(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return nil, fmt.Errorf("invalid process_at: %w", err)
+		}
+		opts = append(opts, asynq.ProcessAt(ts.AsTime()))
+	}
+	if o.GetTaskId() != "" {
+		opts = append(opts, asynq.TaskID(o.GetTaskId()))
+	}
+	if o.GetGroup() != "" {
+		opts = append(opts, asynq.Group(o.GetGroup()))
+	}
+	return opts, nil
+}
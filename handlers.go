@@ -0,0 +1,186 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Pending handler policies for tasks whose handler has not been registered yet
+const (
+	PendingPolicyRetry   = "retry"
+	PendingPolicyArchive = "archive"
+)
+
+// HandlersConfig configures handlers registered at runtime
+type HandlersConfig struct {
+	// What to do with tasks of a reserved or unregistered dynamic pattern:
+	// "retry" schedules another attempt after PendingRetryDelay,
+	// "archive" archives the task immediately.
+	PendingPolicy string `json:"pendingPolicy" yaml:"pendingPolicy" env:"WORKER_HANDLERS_PENDING_POLICY" default:"retry"`
+
+	PendingRetryDelay time.Duration `json:"pendingRetryDelay" yaml:"pendingRetryDelay" env:"WORKER_HANDLERS_PENDING_RETRY_DELAY" default:"30s"`
+
+	// What to do with tasks of a type no pattern matches, with the same
+	// values as PendingPolicy. A handler set with HandleNotFound takes
+	// precedence.
+	UnknownPolicy string `json:"unknownPolicy" yaml:"unknownPolicy" env:"WORKER_HANDLERS_UNKNOWN_POLICY" default:"retry"`
+
+	UnknownRetryDelay time.Duration `json:"unknownRetryDelay" yaml:"unknownRetryDelay" env:"WORKER_HANDLERS_UNKNOWN_RETRY_DELAY" default:"1m"`
+}
+
+// validate validates the handlers configuration
+func (hc *HandlersConfig) validate() error {
+	switch hc.PendingPolicy {
+	case PendingPolicyRetry:
+		if hc.PendingRetryDelay <= 0 {
+			return fmt.Errorf("pending retry delay must be positive, got %v", hc.PendingRetryDelay)
+		}
+	case PendingPolicyArchive:
+	default:
+		return fmt.Errorf("unknown pending policy %q (valid policies: %s, %s)",
+			hc.PendingPolicy, PendingPolicyRetry, PendingPolicyArchive)
+	}
+	switch hc.UnknownPolicy {
+	case PendingPolicyRetry:
+		if hc.UnknownRetryDelay <= 0 {
+			return fmt.Errorf("unknown task retry delay must be positive, got %v", hc.UnknownRetryDelay)
+		}
+	case PendingPolicyArchive:
+	default:
+		return fmt.Errorf("invalid unknown task policy %q (valid policies: %s, %s)",
+			hc.UnknownPolicy, PendingPolicyRetry, PendingPolicyArchive)
+	}
+	return nil
+}
+
+// handlerRegistry holds handlers that can be registered, replaced or removed
+// while the server is running. Each pattern is routed through the ServeMux
+// once, so mux middleware applies to dynamic handlers as well.
+type handlerRegistry struct {
+	mu      sync.RWMutex
+	entries map[string]asynq.Handler
+	routed  map[string]bool
+	// Descriptions and handler names of patterns, for Handlers
+	docs  map[string]string
+	names map[string]string
+}
+
+// route makes sure the ServeMux forwards pattern to the registry
+func (r *handlerRegistry) route(mux *asynq.ServeMux, pattern string, pending asynq.Handler) error {
+	if strings.TrimSpace(pattern) == "" || strings.ContainsAny(pattern, wildcardChars) {
+		return fmt.Errorf("invalid pattern %q: runtime patterns must be a task type or prefix", pattern)
+	}
+	if r.routed == nil {
+		r.routed = make(map[string]bool)
+		r.entries = make(map[string]asynq.Handler)
+	}
+	if r.routed[pattern] {
+		return nil
+	}
+	if _, existing := mux.Handler(asynq.NewTask(pattern, nil)); existing == pattern {
+		return fmt.Errorf("pattern %q is registered statically on the ServeMux", pattern)
+	}
+
+	mux.Handle(pattern, asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		r.mu.RLock()
+		h := r.entries[pattern]
+		r.mu.RUnlock()
+		if h == nil {
+			return pending.ProcessTask(ctx, t)
+		}
+		return h.ProcessTask(ctx, t)
+	}))
+	r.routed[pattern] = true
+	return nil
+}
+
+// RegisterHandler registers or replaces the handler for pattern.
+// It is safe to call at any time, including after the server has started.
+func (w *Workerd) RegisterHandler(pattern string, handler asynq.Handler) error {
+	if handler == nil {
+		return fmt.Errorf("handler cannot be nil")
+	}
+
+	w.handlers.mu.Lock()
+	defer w.handlers.mu.Unlock()
+
+	if err := w.handlers.route(w.ServeMux, pattern, w.pendingHandler()); err != nil {
+		return err
+	}
+	w.handlers.entries[pattern] = handler
+	w.log.Info("handler registered", "pattern", pattern)
+	return nil
+}
+
+// RegisterHandlerFunc is the function form of RegisterHandler
+func (w *Workerd) RegisterHandlerFunc(pattern string, handler func(context.Context, *asynq.Task) error) error {
+	if handler == nil {
+		return fmt.Errorf("handler cannot be nil")
+	}
+	return w.RegisterHandler(pattern, asynq.HandlerFunc(handler))
+}
+
+// UnregisterHandler removes a dynamic handler; its tasks fall back to the pending policy
+func (w *Workerd) UnregisterHandler(pattern string) {
+	w.handlers.mu.Lock()
+	defer w.handlers.mu.Unlock()
+
+	if _, ok := w.handlers.entries[pattern]; ok {
+		delete(w.handlers.entries, pattern)
+		w.log.Info("handler unregistered", "pattern", pattern)
+	}
+}
+
+// ReserveHandler declares a pattern whose handler will be registered later.
+// Tasks received before then are handled according to the pending policy
+// instead of failing as unknown task types.
+func (w *Workerd) ReserveHandler(pattern string) error {
+	w.handlers.mu.Lock()
+	defer w.handlers.mu.Unlock()
+
+	return w.handlers.route(w.ServeMux, pattern, w.pendingHandler())
+}
+
+// pendingHandler applies the configured pending policy
+func (w *Workerd) pendingHandler() asynq.Handler {
+	policy := w.config.Handlers
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		err := fmt.Errorf("no handler registered yet for task %q", t.Type())
+		if policy.PendingPolicy == PendingPolicyArchive {
+			return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+		}
+		return requeueIn(ctx, policy.PendingRetryDelay, err)
+	})
+}
+
+// HandleNotFound sets the handler for tasks of a type no pattern matches,
+// instead of handlers.unknownPolicy. Call it before the server starts.
+func (w *Workerd) HandleNotFound(handler asynq.Handler) {
+	w.notFound = handler
+}
+
+// dispatch routes tasks to the most specific handler, applying the unknown
+// task policy to types no pattern matches
+func (w *Workerd) dispatch() asynq.Handler {
+	policy := w.config.Handlers
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		if h := w.route(t); h != nil {
+			return h.ProcessTask(ctx, t)
+		}
+		if w.notFound != nil {
+			return w.notFound.ProcessTask(ctx, t)
+		}
+
+		err := fmt.Errorf("no handler for task %q", t.Type())
+		Logger(ctx).Warn("no handler for task type", "type", t.Type(), "policy", policy.UnknownPolicy)
+		if policy.UnknownPolicy == PendingPolicyArchive {
+			return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+		}
+		return requeueIn(ctx, policy.UnknownRetryDelay, err)
+	})
+}
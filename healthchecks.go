@@ -0,0 +1,183 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// errUnhealthy is returned for tasks postponed while a health check fails.
+// The task is requeued, so it keeps its retries.
+var errUnhealthy = errors.New("worker is unhealthy")
+
+// HealthChecksConfig configures the health checks added with AddHealthCheck
+type HealthChecksConfig struct {
+	// How often the checks run, together with asynq's Redis health check
+	Interval time.Duration `json:"interval" yaml:"interval" env:"WORKER_HEALTH_CHECK_INTERVAL" default:"15s"`
+
+	// Time each check may take before it counts as failed
+	Timeout time.Duration `json:"timeout" yaml:"timeout" env:"WORKER_HEALTH_CHECK_TIMEOUT" default:"5s"`
+
+	// Delay before a task received while a check fails is tried again
+	RetryDelay time.Duration `json:"retryDelay" yaml:"retryDelay" env:"WORKER_HEALTH_CHECK_RETRY_DELAY" default:"30s"`
+}
+
+// validate validates the health checks configuration
+func (hc *HealthChecksConfig) validate() error {
+	if hc.Interval <= 0 {
+		return fmt.Errorf("health check interval must be positive, got %v", hc.Interval)
+	}
+	if hc.Timeout <= 0 {
+		return fmt.Errorf("health check timeout must be positive, got %v", hc.Timeout)
+	}
+	if hc.RetryDelay < 0 {
+		return fmt.Errorf("health check retry delay must be non-negative, got %v", hc.RetryDelay)
+	}
+	return nil
+}
+
+// healthCheck is a named check of a dependency
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// healthChecks runs the registered checks and keeps their last results
+type healthChecks struct {
+	config *HealthChecksConfig
+	log    *slog.Logger
+
+	mu      sync.Mutex
+	checks  []healthCheck
+	failing map[string]error
+	// running guards against overlapping runs, e.g. from several worker pools
+	running bool
+	lastRun time.Time
+}
+
+func newHealthChecks(config *HealthChecksConfig, log *slog.Logger) *healthChecks {
+	return &healthChecks{config: config, log: log, failing: make(map[string]error)}
+}
+
+// add registers a check, replacing one with the same name
+func (h *healthChecks) add(name string, check func(ctx context.Context) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.checks {
+		if h.checks[i].name == name {
+			h.checks[i].check = check
+			return
+		}
+	}
+	h.checks = append(h.checks, healthCheck{name: name, check: check})
+}
+
+// run runs every check unless they ran less than half an interval ago, and
+// logs the checks that start or stop failing
+func (h *healthChecks) run(ctx context.Context) {
+	h.mu.Lock()
+	if h.running || time.Since(h.lastRun) < h.config.Interval/2 {
+		h.mu.Unlock()
+		return
+	}
+	h.running = true
+	checks := append([]healthCheck(nil), h.checks...)
+	h.mu.Unlock()
+
+	results := make(map[string]error, len(checks))
+	for _, c := range checks {
+		cctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+		results[c.name] = c.check(cctx)
+		cancel()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, err := range results {
+		prev, wasFailing := h.failing[name]
+		switch {
+		case err != nil && !wasFailing:
+			h.log.Warn("health check failing, postponing tasks", "check", name, "error", err)
+		case err != nil && prev.Error() != err.Error():
+			h.log.Warn("health check still failing", "check", name, "error", err)
+		case err == nil && wasFailing:
+			h.log.Info("health check recovered", "check", name)
+		}
+		if err != nil {
+			h.failing[name] = err
+		} else {
+			delete(h.failing, name)
+		}
+	}
+	h.running, h.lastRun = false, time.Now()
+}
+
+// err returns the error of the first failing check by name, or nil
+func (h *healthChecks) err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.failing) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(h.failing))
+	for name := range h.failing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("health check %q failed: %w", names[0], h.failing[names[0]])
+}
+
+// status returns "ok" or the error of every check by name
+func (h *healthChecks) status() map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := make(map[string]string, len(h.checks))
+	for _, c := range h.checks {
+		status[c.name] = "ok"
+		if err, ok := h.failing[c.name]; ok {
+			status[c.name] = err.Error()
+		}
+	}
+	return status
+}
+
+// AddHealthCheck registers a check of a dependency the handlers need, such
+// as a database or bucket. While a check fails, /readyz reports 503 and tasks
+// are put back in their queue without using up a retry. Checks run every
+// healthChecks.interval while the worker is started.
+func (w *Workerd) AddHealthCheck(name string, check func(ctx context.Context) error) {
+	w.health.add(name, check)
+}
+
+// checkReady returns why the worker cannot process tasks: Redis is
+// unreachable or a health check fails
+func (w *Workerd) checkReady() error {
+	if err := w.failover.err(); err != nil {
+		return err
+	}
+	if err := w.checkHealth(); err != nil {
+		return err
+	}
+	return w.health.err()
+}
+
+// healthMiddleware postpones tasks while a health check fails
+func healthMiddleware(h *healthChecks) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if h == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			if err := h.err(); err != nil {
+				return requeueIn(ctx, h.config.RetryDelay, fmt.Errorf("%w: %w", errUnhealthy, err))
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
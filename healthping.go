@@ -0,0 +1,89 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HealthPingConfig configures a dead man's switch: the worker requests a URL,
+// e.g. of healthchecks.io or Cronitor, while it is healthy, so the monitor
+// alerts when the pings stop
+type HealthPingConfig struct {
+	// Requested on every interval while the worker is healthy
+	URL string `json:"url" yaml:"url" env:"WORKER_HEALTH_PING_URL" secret:"true"`
+
+	// Receives the error as a POST when the worker is unhealthy, e.g. the
+	// "/fail" URL of healthchecks.io; empty only skips the ping
+	FailURL string `json:"failUrl" yaml:"failUrl" env:"WORKER_HEALTH_PING_FAIL_URL" secret:"true"`
+
+	Interval time.Duration `json:"interval" yaml:"interval" env:"WORKER_HEALTH_PING_INTERVAL" default:"1m"`
+
+	Timeout time.Duration `json:"timeout" yaml:"timeout" env:"WORKER_HEALTH_PING_TIMEOUT" default:"10s"`
+}
+
+// validate validates the health ping configuration
+func (hc *HealthPingConfig) validate() error {
+	if hc.URL == "" {
+		if hc.FailURL != "" {
+			return fmt.Errorf("health ping fail URL requires a URL")
+		}
+		return nil
+	}
+	if !isAbsoluteURL(hc.URL) {
+		return fmt.Errorf("health ping URL %q must be an absolute URL", hc.URL)
+	}
+	if hc.FailURL != "" && !isAbsoluteURL(hc.FailURL) {
+		return fmt.Errorf("health ping fail URL %q must be an absolute URL", hc.FailURL)
+	}
+	if hc.Interval <= 0 {
+		return fmt.Errorf("health ping interval must be positive, got %v", hc.Interval)
+	}
+	if hc.Timeout <= 0 || hc.Timeout > hc.Interval {
+		return fmt.Errorf("health ping timeout must be positive and at most the interval, got %v", hc.Timeout)
+	}
+	return nil
+}
+
+// healthPinger pings the configured URLs with the outcome of the health check
+type healthPinger struct {
+	config *HealthPingConfig
+	client *http.Client
+	check  func() error
+}
+
+func newHealthPinger(config *HealthPingConfig, check func() error) *healthPinger {
+	return &healthPinger{config: config, client: &http.Client{Timeout: config.Timeout}, check: check}
+}
+
+// ping runs the health check and reports its outcome
+func (p *healthPinger) ping(ctx context.Context) error {
+	if checkErr := p.check(); checkErr != nil {
+		if p.config.FailURL == "" {
+			return nil
+		}
+		return p.request(ctx, http.MethodPost, p.config.FailURL, checkErr.Error())
+	}
+	return p.request(ctx, http.MethodGet, p.config.URL, "")
+}
+
+func (p *healthPinger) request(ctx context.Context, method, url, body string) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("health ping failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("health ping responded with %s", resp.Status)
+	}
+	return nil
+}
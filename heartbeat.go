@@ -0,0 +1,181 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ErrMaxExtensions is returned by Heartbeat once a task has used all the
+// extensions its policy allows; the current deadline still applies
+var ErrMaxExtensions = errors.New("heartbeat extension limit reached")
+
+// errHeartbeatTimeout cancels a handler that stopped sending heartbeats
+var errHeartbeatTimeout = errors.New("no heartbeat within handler timeout")
+
+// HeartbeatPolicy declares the handler timeout of a task type. A handler
+// calling Heartbeat restarts its timeout, so long tasks run as long as they
+// keep reporting progress.
+type HeartbeatPolicy struct {
+	// Task type the policy applies to: an exact type name or a prefix such as "report:"
+	Type string `json:"type" yaml:"type"`
+
+	// Time a handler may run without calling Heartbeat
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+
+	// Heartbeats honoured per attempt; 0 allows any number
+	MaxExtensions int `json:"maxExtensions" yaml:"maxExtensions"`
+
+	// Total run time allowed, set as the asynq timeout of the tasks enqueued
+	// through Client; 0 allows timeout * (maxExtensions + 1), or 24h when
+	// extensions are unlimited
+	MaxRuntime time.Duration `json:"maxRuntime" yaml:"maxRuntime"`
+}
+
+// defaultHeartbeatRuntime caps the run time of tasks allowed any number of heartbeats
+const defaultHeartbeatRuntime = 24 * time.Hour
+
+// maxRuntime returns the asynq timeout of the policy's tasks
+func (p *HeartbeatPolicy) maxRuntime() time.Duration {
+	switch {
+	case p.MaxRuntime > 0:
+		return p.MaxRuntime
+	case p.MaxExtensions > 0:
+		return p.Timeout * time.Duration(p.MaxExtensions+1)
+	default:
+		return defaultHeartbeatRuntime
+	}
+}
+
+// HeartbeatConfig holds the per task type heartbeat policies
+type HeartbeatConfig struct {
+	Policies []HeartbeatPolicy `json:"policies" yaml:"policies"`
+}
+
+// validate validates the heartbeat configuration
+func (hc *HeartbeatConfig) validate() error {
+	for i, p := range hc.Policies {
+		if strings.TrimSpace(p.Type) == "" {
+			return fmt.Errorf("heartbeat policy %d: type cannot be empty", i)
+		}
+		if p.Timeout <= 0 {
+			return fmt.Errorf("heartbeat policy %q: timeout must be positive, got %v", p.Type, p.Timeout)
+		}
+		if p.MaxExtensions < 0 {
+			return fmt.Errorf("heartbeat policy %q: max extensions must be non-negative, got %d", p.Type, p.MaxExtensions)
+		}
+		if p.MaxRuntime < 0 {
+			return fmt.Errorf("heartbeat policy %q: max runtime must be non-negative, got %v", p.Type, p.MaxRuntime)
+		}
+	}
+	return nil
+}
+
+// policyFor returns the most specific policy matching the task type, or nil
+func (hc *HeartbeatConfig) policyFor(taskType string) *HeartbeatPolicy {
+	var best *HeartbeatPolicy
+	for i := range hc.Policies {
+		p := &hc.Policies[i]
+		if p.Type == taskType {
+			return p
+		}
+		if strings.HasPrefix(taskType, p.Type) && (best == nil || len(p.Type) > len(best.Type)) {
+			best = p
+		}
+	}
+	return best
+}
+
+// EnqueueOptions returns the asynq timeout of the task type, so asynq does
+// not cancel a handler that keeps sending heartbeats after its default 30m
+func (hc *HeartbeatConfig) EnqueueOptions(taskType string) []asynq.Option {
+	p := hc.policyFor(taskType)
+	if p == nil {
+		return nil
+	}
+	return []asynq.Option{asynq.Timeout(p.maxRuntime())}
+}
+
+// hasRunLimit reports whether opts set an asynq timeout or deadline
+func hasRunLimit(opts []asynq.Option) bool {
+	for _, o := range opts {
+		if o != nil && (o.Type() == asynq.TimeoutOpt || o.Type() == asynq.DeadlineOpt) {
+			return true
+		}
+	}
+	return false
+}
+
+type leaseKey struct{}
+
+// lease tracks the heartbeat deadline of a running handler
+type lease struct {
+	mu         sync.Mutex
+	policy     *HeartbeatPolicy
+	timer      *time.Timer
+	extensions int
+}
+
+// Heartbeat reports progress from a long running handler and restarts its
+// handler timeout. It is a no-op for task types without a heartbeat policy.
+// Heartbeat returns ErrMaxExtensions once the policy's limit is used up and
+// the context error once the handler has timed out.
+func Heartbeat(ctx context.Context) error {
+	l, ok := ctx.Value(leaseKey{}).(*lease)
+	if !ok {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l.policy.MaxExtensions > 0 && l.extensions >= l.policy.MaxExtensions {
+		return ErrMaxExtensions
+	}
+	if !l.timer.Reset(l.policy.Timeout) {
+		// The timer fired concurrently; cancellation is already underway
+		return errHeartbeatTimeout
+	}
+	l.extensions++
+	return nil
+}
+
+// heartbeatMiddleware cancels handlers that exceed their policy's timeout
+// without calling Heartbeat. The asynq Timeout or Deadline of the task still
+// caps the total run time; Client sets it from the policy's max runtime.
+func heartbeatMiddleware(config *HeartbeatConfig) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if len(config.Policies) == 0 {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			policy := config.policyFor(t.Type())
+			if policy == nil {
+				return next.ProcessTask(ctx, t)
+			}
+
+			ctx, cancel := context.WithCancelCause(ctx)
+			defer cancel(nil)
+			l := &lease{policy: policy}
+			l.timer = time.AfterFunc(policy.Timeout, func() { cancel(errHeartbeatTimeout) })
+			defer l.timer.Stop()
+
+			err := next.ProcessTask(context.WithValue(ctx, leaseKey{}, l), t)
+			// A handler that finished despite the timeout keeps its result
+			if err != nil && errors.Is(context.Cause(ctx), errHeartbeatTimeout) {
+				l.mu.Lock()
+				extensions := l.extensions
+				l.mu.Unlock()
+				return fmt.Errorf("%w after %v (%d extensions): %v", errHeartbeatTimeout, policy.Timeout, extensions, err)
+			}
+			return err
+		})
+	}
+}
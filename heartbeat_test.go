@@ -0,0 +1,63 @@
+package workerd
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+)
+
+func TestHeartbeatEnqueueOptions(t *testing.T) {
+	hc := &HeartbeatConfig{Policies: []HeartbeatPolicy{
+		{Type: "report:", Timeout: 2 * time.Minute, MaxExtensions: 59},
+		{Type: "export:", Timeout: time.Minute},
+		{Type: "import:", Timeout: time.Minute, MaxRuntime: 3 * time.Hour},
+	}}
+	for taskType, want := range map[string]time.Duration{
+		"report:build": 2 * time.Hour,
+		"export:csv":   defaultHeartbeatRuntime,
+		"import:csv":   3 * time.Hour,
+	} {
+		opts := hc.EnqueueOptions(taskType)
+		if len(opts) != 1 || opts[0].Type() != asynq.TimeoutOpt || opts[0].Value().(time.Duration) != want {
+			t.Errorf("EnqueueOptions(%q) = %v, want timeout %v", taskType, opts, want)
+		}
+	}
+	if opts := hc.EnqueueOptions("email:send"); opts != nil {
+		t.Errorf("EnqueueOptions without a policy = %v", opts)
+	}
+}
+
+func TestClientSetsHeartbeatTimeout(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config, err := newWorkerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Heartbeat.Policies = []HeartbeatPolicy{{Type: "report:", Timeout: time.Minute, MaxRuntime: 3 * time.Hour}}
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()}
+	c, err := newClient(config, slog.Default(), &clientOptions{redisOpt: opt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, tc := range []struct {
+		opts []asynq.Option
+		want time.Duration
+	}{
+		{nil, 3 * time.Hour},
+		{[]asynq.Option{asynq.Timeout(time.Hour)}, time.Hour},
+		{[]asynq.Option{asynq.Deadline(time.Now().Add(time.Hour))}, 0},
+	} {
+		info, err := c.Enqueue(asynq.NewTask("report:build", nil), tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Timeout != tc.want {
+			t.Errorf("timeout with options %v = %v, want %v", tc.opts, info.Timeout, tc.want)
+		}
+	}
+}
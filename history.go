@@ -0,0 +1,234 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+const historyCommandUsage = "history [-queue name] [-type pattern] [-outcome succeeded|failed|archived] [-id task] [-limit 50] [-output text|json]  list recent task executions"
+
+// HistoryConfig configures the bounded history of recent task executions,
+// kept in Redis so completed tasks stay visible after asynq trims them
+type HistoryConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_HISTORY_ENABLED" default:"false"`
+
+	// Redis list holding the executions of the fleet, newest first
+	Key string `json:"key" yaml:"key" env:"WORKER_HISTORY_KEY" default:"workerd:history"`
+
+	// Number of executions kept
+	MaxEntries int64 `json:"maxEntries" yaml:"maxEntries" env:"WORKER_HISTORY_MAX_ENTRIES" default:"10000"`
+}
+
+// validate validates the history configuration
+func (hc *HistoryConfig) validate() error {
+	if !hc.Enabled {
+		return nil
+	}
+	if hc.Key == "" {
+		return fmt.Errorf("history key cannot be empty")
+	}
+	if hc.MaxEntries <= 0 {
+		return fmt.Errorf("history max entries must be positive, got %d", hc.MaxEntries)
+	}
+	return nil
+}
+
+// Execution is one run of a task
+type Execution struct {
+	TaskID   string        `json:"task_id"`
+	Type     string        `json:"type"`
+	Queue    string        `json:"queue"`
+	Worker   string        `json:"worker"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	// AuditSucceeded, AuditFailed (will be retried) or AuditArchived
+	Outcome string `json:"outcome"`
+	Retry   int    `json:"retry"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HistoryFilter selects executions; empty fields match everything
+type HistoryFilter struct {
+	Queue string
+	// Task type prefix, optionally ending in "*"
+	Type    string
+	Outcome string
+	TaskID  string
+	// Maximum number of executions returned; 0 returns every match
+	Limit int
+}
+
+func (f *HistoryFilter) matches(e *Execution) bool {
+	return (f.Queue == "" || e.Queue == f.Queue) &&
+		(f.Type == "" || matchTaskType(f.Type, e.Type)) &&
+		(f.Outcome == "" || e.Outcome == f.Outcome) &&
+		(f.TaskID == "" || e.TaskID == f.TaskID)
+}
+
+// historyStore keeps the latest executions in a capped Redis list
+type historyStore struct {
+	rdb redis.UniversalClient
+	// Connection the list is read from, the read replica when configured
+	read   redis.UniversalClient
+	config *HistoryConfig
+	worker string
+	log    *slog.Logger
+}
+
+func newHistoryStore(config *HistoryConfig, redisOpt, readOpt asynq.RedisConnOpt, worker string, log *slog.Logger) (*historyStore, error) {
+	rdb, ok := redisOpt.MakeRedisClient().(redis.UniversalClient)
+	if !ok {
+		return nil, fmt.Errorf("unsupported redis connection for the task history")
+	}
+	h := &historyStore{rdb: rdb, read: rdb, config: config, worker: worker, log: log}
+	if readOpt != redisOpt {
+		if h.read, ok = readOpt.MakeRedisClient().(redis.UniversalClient); !ok {
+			rdb.Close()
+			return nil, fmt.Errorf("unsupported redis read replica connection for the task history")
+		}
+	}
+	return h, nil
+}
+
+// record adds an execution, dropping the oldest beyond the limit. Failures
+// are logged and never fail the task.
+func (h *historyStore) record(ctx context.Context, e Execution) {
+	data, err := json.Marshal(e)
+	if err == nil {
+		// History writes outlive a cancelled task context
+		ctx = context.WithoutCancel(ctx)
+		_, err = h.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.LPush(ctx, h.config.Key, data)
+			p.LTrim(ctx, h.config.Key, 0, h.config.MaxEntries-1)
+			return nil
+		})
+	}
+	if err != nil {
+		h.log.Warn("could not record task execution", "task_id", e.TaskID, "error", err)
+	}
+}
+
+// list returns the matching executions, newest first
+func (h *historyStore) list(ctx context.Context, f HistoryFilter) ([]Execution, error) {
+	entries, err := h.read.LRange(ctx, h.config.Key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	executions := []Execution{}
+	for _, entry := range entries {
+		var e Execution
+		if err := json.Unmarshal([]byte(entry), &e); err != nil || !f.matches(&e) {
+			continue
+		}
+		executions = append(executions, e)
+		if f.Limit > 0 && len(executions) == f.Limit {
+			break
+		}
+	}
+	return executions, nil
+}
+
+func (h *historyStore) close() error {
+	if h.read != h.rdb {
+		h.read.Close()
+	}
+	return h.rdb.Close()
+}
+
+// History returns the recent executions matching the filter, newest first
+func (w *Workerd) History(ctx context.Context, f HistoryFilter) ([]Execution, error) {
+	if w.history == nil {
+		return nil, errors.New("task history is disabled")
+	}
+	executions, err := w.history.list(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task history: %w", err)
+	}
+	return executions, nil
+}
+
+// historyMiddleware records every execution with its outcome. A failure is
+// recorded as archived when no retries are left.
+func historyMiddleware(h *historyStore) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if h == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			e := Execution{Type: t.Type(), Worker: h.worker, Started: time.Now().UTC()}
+			e.TaskID, _ = asynq.GetTaskID(ctx)
+			e.Queue, _ = asynq.GetQueueName(ctx)
+			e.Retry, _ = asynq.GetRetryCount(ctx)
+			maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+			err := next.ProcessTask(ctx, t)
+
+			e.Duration = time.Since(e.Started)
+			switch {
+			case err == nil:
+				e.Outcome = AuditSucceeded
+			case e.Retry >= maxRetry || errors.Is(err, asynq.SkipRetry):
+				e.Outcome, e.Error = AuditArchived, err.Error()
+			default:
+				e.Outcome, e.Error = AuditFailed, err.Error()
+			}
+			h.record(ctx, e)
+			return err
+		})
+	}
+}
+
+// runHistoryCommand implements `history`
+func runHistoryCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	var f HistoryFilter
+	fs.StringVar(&f.Queue, "queue", "", "Only show this queue")
+	fs.StringVar(&f.Type, "type", "", "Only show task types matching this prefix or pattern")
+	fs.StringVar(&f.Outcome, "outcome", "", "Only show this outcome (succeeded, failed, archived)")
+	fs.StringVar(&f.TaskID, "id", "", "Only show the executions of this task")
+	fs.IntVar(&f.Limit, "limit", 50, "Maximum number of executions; 0 shows all")
+	output := fs.String("output", "text", "Output format (text, json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: %s", historyCommandUsage)
+	}
+
+	executions, err := w.History(context.Background(), f)
+	if err != nil {
+		return err
+	}
+	return printHistory(os.Stdout, executions, *output)
+}
+
+// printHistory renders executions as a text table or JSON
+func printHistory(out io.Writer, executions []Execution, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(executions)
+	case "", "text":
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "STARTED\tQUEUE\tTYPE\tTASK ID\tRETRY\tDURATION\tOUTCOME\tERROR")
+		for _, e := range executions {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%v\t%s\t%s\n", e.Started.Format(time.RFC3339), e.Queue, e.Type,
+				e.TaskID, e.Retry, e.Duration.Round(time.Millisecond), e.Outcome, e.Error)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (valid formats: text, json)", format)
+	}
+}
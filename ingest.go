@@ -0,0 +1,62 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// IngestConfig configures bridges that turn messages from external systems
+// into tasks, so producers can feed workerd without access to Redis
+type IngestConfig struct {
+	SQS     SQSIngestConfig     `json:"sqs" yaml:"sqs"`
+	Kafka   KafkaIngestConfig   `json:"kafka" yaml:"kafka"`
+	Webhook WebhookIngestConfig `json:"webhook" yaml:"webhook"`
+	Gateway GatewayConfig       `json:"gateway" yaml:"gateway"`
+}
+
+// validate validates the ingest configuration
+func (ic *IngestConfig) validate() error {
+	if err := ic.SQS.validate(); err != nil {
+		return fmt.Errorf("sqs: %w", err)
+	}
+	if err := ic.Kafka.validate(); err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	if err := ic.Webhook.validate(); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	if err := ic.Gateway.validate(); err != nil {
+		return fmt.Errorf("gateway: %w", err)
+	}
+	return nil
+}
+
+// ingestor consumes an external source and enqueues its messages as tasks
+type ingestor interface {
+	start(w *Workerd) error
+	stop(ctx context.Context) error
+}
+
+// newIngestors creates the ingestors enabled in the configuration, plus the
+// Kafka ingestor when a consumer is given
+func newIngestors(config *IngestConfig, kafka KafkaConsumer) ([]ingestor, error) {
+	var ingestors []ingestor
+	if config.SQS.Enabled {
+		s, err := newSQSIngestor(&config.SQS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SQS ingestor: %w", err)
+		}
+		ingestors = append(ingestors, s)
+	}
+	if config.Webhook.Enabled {
+		ingestors = append(ingestors, &webhookIngestor{config: &config.Webhook, now: time.Now})
+	}
+	if config.Gateway.Enabled {
+		ingestors = append(ingestors, &gatewayIngestor{config: &config.Gateway})
+	}
+	if kafka != nil {
+		ingestors = append(ingestors, &kafkaIngestor{config: &config.Kafka, consumer: kafka})
+	}
+	return ingestors, nil
+}
@@ -0,0 +1,165 @@
+package workerd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hibiken/asynq"
+)
+
+const handlersCommandUsage = "handlers [-output text|json]  list the task types this binary handles"
+
+// Kinds of registered handlers
+const (
+	// Registered with Handle, HandleFunc, HandleRegexp or HandleWith
+	HandlerKindStatic = "static"
+	// Registered at runtime with RegisterHandler
+	HandlerKindDynamic = "dynamic"
+	// Declared with ReserveHandler or unregistered; tasks follow the pending policy
+	HandlerKindReserved = "reserved"
+	// Registered by workerd itself
+	HandlerKindBuiltin = "builtin"
+)
+
+// HandlerInfo describes a task type pattern the worker handles
+type HandlerInfo struct {
+	Pattern string `json:"pattern"`
+	Kind    string `json:"kind"`
+	// Name of the handler function or type
+	Handler     string `json:"handler,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Go type or JSON schema the payload is validated against, if any
+	Payload string `json:"payload,omitempty"`
+}
+
+// DescribeHandler attaches a human readable description to a pattern, shown
+// by Handlers, the `handlers` command and the admin API
+func (w *Workerd) DescribeHandler(pattern, description string) {
+	w.handlers.mu.Lock()
+	defer w.handlers.mu.Unlock()
+	if w.handlers.docs == nil {
+		w.handlers.docs = make(map[string]string)
+	}
+	w.handlers.docs[pattern] = description
+}
+
+// Handlers lists the registered task type patterns sorted by pattern, with
+// how they were registered and the payload type declared for them
+func (w *Workerd) Handlers() []HandlerInfo {
+	r := &w.handlers
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []HandlerInfo
+	for pattern, h := range muxEntries(w.ServeMux) {
+		info := HandlerInfo{
+			Pattern:     pattern,
+			Kind:        HandlerKindStatic,
+			Handler:     handlerName(h),
+			Description: r.docs[pattern],
+			Payload:     w.schemas.kind(pattern),
+		}
+		if name, ok := r.names[pattern]; ok {
+			info.Handler = name
+		}
+		switch {
+		case pattern == SelfTestTaskType:
+			info.Kind = HandlerKindBuiltin
+		case r.routed[pattern] && r.entries[pattern] != nil:
+			info.Kind, info.Handler = HandlerKindDynamic, handlerName(r.entries[pattern])
+		case r.routed[pattern]:
+			info.Kind, info.Handler = HandlerKindReserved, ""
+		}
+		list = append(list, info)
+	}
+
+	w.patterns.mu.RLock()
+	for _, route := range w.patterns.routes {
+		list = append(list, HandlerInfo{
+			Pattern:     route.pattern,
+			Kind:        HandlerKindStatic,
+			Handler:     handlerName(route.handler),
+			Description: r.docs[route.pattern],
+		})
+	}
+	w.patterns.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Pattern < list[j].Pattern })
+	return list
+}
+
+// muxEntries returns the handlers registered on an asynq ServeMux by pattern.
+// asynq has no API to list them, so the unexported entries are read with
+// reflection; callers must hold w.handlers.mu so runtime registration does not
+// race with the read.
+func muxEntries(mux *asynq.ServeMux) map[string]reflect.Value {
+	entries := make(map[string]reflect.Value)
+	if mux == nil {
+		return entries
+	}
+	m := reflect.ValueOf(mux).Elem().FieldByName("m")
+	if m.Kind() != reflect.Map {
+		return entries
+	}
+	iter := m.MapRange()
+	for iter.Next() {
+		entries[iter.Key().String()] = iter.Value().FieldByName("h")
+	}
+	return entries
+}
+
+// handlerName names a handler after its function or type
+func handlerName(h any) string {
+	v, ok := h.(reflect.Value)
+	if !ok {
+		v = reflect.ValueOf(h)
+	}
+	for v.IsValid() && v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(v.Pointer()); f != nil {
+			// method values are named after a generated wrapper
+			return strings.TrimSuffix(f.Name(), "-fm")
+		}
+	}
+	return v.Type().String()
+}
+
+// runHandlersCommand implements `handlers`
+func runHandlersCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("handlers", flag.ContinueOnError)
+	output := fs.String("output", "text", "Output format (text, json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: %s", handlersCommandUsage)
+	}
+
+	handlers := w.Handlers()
+	switch *output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(handlers)
+	case "", "text":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PATTERN\tKIND\tHANDLER\tPAYLOAD\tDESCRIPTION")
+		for _, h := range handlers {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", h.Pattern, h.Kind, h.Handler, h.Payload, h.Description)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (valid formats: text, json)", *output)
+	}
+}
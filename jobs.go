@@ -0,0 +1,64 @@
+package workerd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// internalJob is a periodic background job run by workerd while the service is started
+type internalJob struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// jobRunner runs internal jobs on their own tickers until stopped
+type jobRunner struct {
+	jobs   []internalJob
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// add registers a job; jobs added after start are not scheduled
+func (r *jobRunner) add(job internalJob) {
+	r.jobs = append(r.jobs, job)
+}
+
+// start launches a goroutine per job, each logging failures through logf
+func (r *jobRunner) start(logf func(name string, err error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	for _, job := range r.jobs {
+		if job.interval <= 0 {
+			continue
+		}
+		r.wg.Add(1)
+		go func(job internalJob) {
+			defer r.wg.Done()
+			ticker := time.NewTicker(job.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := job.run(ctx); err != nil && ctx.Err() == nil {
+						logf(job.name, err)
+					}
+				}
+			}
+		}(job)
+	}
+}
+
+// stop cancels all running jobs and waits for them to return
+func (r *jobRunner) stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+	r.cancel = nil
+}
@@ -0,0 +1,202 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// kafkaRetryDelay is how long the ingestor waits after a failed fetch or enqueue
+const kafkaRetryDelay = 5 * time.Second
+
+// KafkaIngestConfig configures the Kafka ingestor started by WithKafkaConsumer.
+// Each record value becomes the payload of a task whose type is read from a
+// header or mapped from the topic.
+type KafkaIngestConfig struct {
+	// Task type by topic, used when a record has no type header
+	Topics map[string]string `json:"topics" yaml:"topics"`
+
+	// Record header holding the task type
+	TypeHeader string `json:"typeHeader" yaml:"typeHeader" env:"WORKER_KAFKA_TYPE_HEADER" default:"task_type"`
+
+	// Record header overriding the target queue
+	QueueHeader string `json:"queueHeader" yaml:"queueHeader" env:"WORKER_KAFKA_QUEUE_HEADER" default:"queue"`
+
+	// Target queue of records without the queue header; empty uses asynq's default
+	Queue string `json:"queue" yaml:"queue" env:"WORKER_KAFKA_QUEUE"`
+}
+
+// validate validates the Kafka ingest configuration
+func (kc *KafkaIngestConfig) validate() error {
+	if kc.TypeHeader == "" && len(kc.Topics) == 0 {
+		return fmt.Errorf("type header or topic mapping is required")
+	}
+	for topic, taskType := range kc.Topics {
+		if topic == "" || taskType == "" {
+			return fmt.Errorf("topic mapping entries must be non-empty, got %q: %q", topic, taskType)
+		}
+	}
+	return nil
+}
+
+// KafkaMessage is a record fetched from Kafka
+type KafkaMessage struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+}
+
+// KafkaConsumer adapts a Kafka client library, typically a consumer group
+// reader, to the ingestor. Fetch blocks until a record is available and
+// Commit marks it processed; the ingestor commits only after enqueueing.
+type KafkaConsumer interface {
+	Fetch(ctx context.Context) (KafkaMessage, error)
+	Commit(ctx context.Context, msg KafkaMessage) error
+	Close() error
+}
+
+// kafkaIngestor consumes records one at a time and enqueues them as tasks.
+// A record whose task cannot be enqueued is retried before anything after it
+// is committed, so no record is skipped.
+type kafkaIngestor struct {
+	config   *KafkaIngestConfig
+	consumer KafkaConsumer
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// start consumes records in the background
+func (k *kafkaIngestor) start(w *Workerd) error {
+	client, err := w.Client()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+		k.consume(ctx, client, w)
+	}()
+
+	w.log.Info("Kafka ingestor started")
+	return nil
+}
+
+// stop cancels the current fetch, waits for the in-flight record and closes the consumer
+func (k *kafkaIngestor) stop(ctx context.Context) error {
+	if k.cancel == nil {
+		return nil
+	}
+	k.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		k.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return k.consumer.Close()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (k *kafkaIngestor) consume(ctx context.Context, client *Client, w *Workerd) {
+	for ctx.Err() == nil {
+		msg, err := k.consumer.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.log.Error("could not fetch Kafka record", "error", err)
+			k.wait(ctx)
+			continue
+		}
+
+		// Keep retrying the record until enqueued; moving on would commit past it
+		for {
+			err := k.ingest(context.WithoutCancel(ctx), client, msg)
+			if err == nil {
+				break
+			}
+			w.log.Warn("could not ingest Kafka record", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "error", err)
+			if errors.Is(err, errNoTaskType) || !k.wait(ctx) {
+				// Unroutable records are skipped; on stop the record is redelivered
+				if ctx.Err() != nil {
+					return
+				}
+				break
+			}
+		}
+
+		if err := k.consumer.Commit(context.WithoutCancel(ctx), msg); err != nil {
+			w.log.Warn("could not commit Kafka offset", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "error", err)
+		}
+	}
+}
+
+// wait sleeps before a retry, returning false if the ingestor is stopping
+func (k *kafkaIngestor) wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(kafkaRetryDelay):
+		return true
+	}
+}
+
+// errNoTaskType marks records that cannot be mapped to a task type
+var errNoTaskType = errors.New("no task type")
+
+// ingest enqueues one record. Headers other than the type and queue are
+// attached as task metadata. The topic, partition and offset form the task ID
+// so a record redelivered after a failed commit is not enqueued twice.
+func (k *kafkaIngestor) ingest(ctx context.Context, client *Client, msg KafkaMessage) error {
+	taskType := k.config.Topics[msg.Topic]
+	queue := k.config.Queue
+	md := Metadata{
+		"kafka_topic":     msg.Topic,
+		"kafka_partition": strconv.Itoa(msg.Partition),
+		"kafka_offset":    strconv.FormatInt(msg.Offset, 10),
+	}
+	if len(msg.Key) > 0 {
+		md["kafka_key"] = string(msg.Key)
+	}
+
+	for name, value := range msg.Headers {
+		switch name {
+		case k.config.TypeHeader:
+			taskType = value
+		case k.config.QueueHeader:
+			queue = value
+		default:
+			md[name] = value
+		}
+	}
+	if taskType == "" {
+		return fmt.Errorf("%w for topic %q", errNoTaskType, msg.Topic)
+	}
+
+	id := fmt.Sprintf("kafka:%s:%d:%d", msg.Topic, msg.Partition, msg.Offset)
+	opts := []asynq.Option{asynq.TaskID(id)}
+	if queue != "" {
+		opts = append(opts, asynq.Queue(queue))
+	}
+
+	_, err := client.EnqueueContext(WithMetadata(ctx, md), asynq.NewTask(taskType, msg.Value), opts...)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
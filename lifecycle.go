@@ -0,0 +1,150 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"time"
+)
+
+// LifecycleEventType identifies a stage of the worker lifecycle
+type LifecycleEventType string
+
+// Lifecycle events
+const (
+	// The service is starting, before the OnStart hooks run
+	LifecycleStarting LifecycleEventType = "starting"
+	// The worker fetches tasks
+	LifecycleStarted LifecycleEventType = "started"
+	// The service is stopping and no longer fetches tasks
+	LifecycleStopping LifecycleEventType = "stopping"
+	// The service stopped and released its resources
+	LifecycleStopped LifecycleEventType = "stopped"
+	// A setting changed at runtime: the log level or the dynamic schedules
+	LifecycleConfigReloaded LifecycleEventType = "config_reloaded"
+	// Redis stopped answering; Err holds the cause
+	LifecycleRedisDisconnected LifecycleEventType = "redis_disconnected"
+	// Redis answers again after a disconnection
+	LifecycleRedisReconnected LifecycleEventType = "redis_reconnected"
+	// Sentinel started promoting a replica; Detail holds the master name
+	LifecycleRedisFailover LifecycleEventType = "redis_failover"
+	// A failover ended; Detail holds the new primary, or Err why none was
+	// announced
+	LifecycleRedisFailoverEnded LifecycleEventType = "redis_failover_ended"
+)
+
+// LifecycleEvent is passed to the listeners registered with OnLifecycle
+type LifecycleEvent struct {
+	Type LifecycleEventType
+	Time time.Time
+	// What changed, for LifecycleConfigReloaded and the failover events
+	Detail string
+	// Cause of LifecycleRedisDisconnected and of an aborted failover
+	Err error
+}
+
+// LifecycleConfig bounds the hooks registered with OnStart and OnStop
+type LifecycleConfig struct {
+	// Time all OnStart hooks together may take before startup is aborted
+	StartTimeout time.Duration `json:"startTimeout" yaml:"startTimeout" env:"WORKER_START_TIMEOUT" default:"1m"`
+
+	// Time all OnStop hooks together may take before their context is cancelled
+	StopTimeout time.Duration `json:"stopTimeout" yaml:"stopTimeout" env:"WORKER_STOP_TIMEOUT" default:"30s"`
+}
+
+// validate validates the lifecycle configuration
+func (lc *LifecycleConfig) validate() error {
+	if lc.StartTimeout <= 0 {
+		return fmt.Errorf("start timeout must be positive, got %v", lc.StartTimeout)
+	}
+	if lc.StopTimeout <= 0 {
+		return fmt.Errorf("stop timeout must be positive, got %v", lc.StopTimeout)
+	}
+	return nil
+}
+
+// OnStart registers a pre-flight hook, e.g. checking that the database is
+// reachable, that migrations are applied or warming caches. Hooks run in
+// registration order when the service starts, before any task is fetched;
+// the first failing hook aborts startup.
+func (w *Workerd) OnStart(fn func(ctx context.Context) error) {
+	if fn != nil {
+		w.startHooks = append(w.startHooks, fn)
+	}
+}
+
+// runStartHooks runs the OnStart hooks within the start timeout
+func (w *Workerd) runStartHooks() error {
+	if len(w.startHooks) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), w.config.Lifecycle.StartTimeout)
+	defer cancel()
+
+	for i, fn := range w.startHooks {
+		name := hookName(fn)
+		begin := time.Now()
+		if err := fn(ctx); err != nil {
+			w.log.Error("pre-start hook failed, aborting startup", "hook", name, "index", i, "error", err)
+			return fmt.Errorf("pre-start hook %s failed: %w", name, err)
+		}
+		w.log.Debug("pre-start hook passed", "hook", name, "duration", time.Since(begin))
+	}
+	return nil
+}
+
+// OnStop registers a shutdown hook, e.g. flushing buffers, closing database
+// pools or deregistering from service discovery. Hooks run when the service
+// stops, once in-flight tasks have finished, in reverse registration order.
+// Every hook runs even if another one fails.
+func (w *Workerd) OnStop(fn func(ctx context.Context) error) {
+	if fn != nil {
+		w.stopHooks = append(w.stopHooks, fn)
+	}
+}
+
+// runStopHooks runs the OnStop hooks within the stop timeout, logging failures
+func (w *Workerd) runStopHooks() {
+	if len(w.stopHooks) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), w.config.Lifecycle.StopTimeout)
+	defer cancel()
+
+	for i := len(w.stopHooks) - 1; i >= 0; i-- {
+		fn := w.stopHooks[i]
+		if err := fn(ctx); err != nil {
+			w.log.Warn("shutdown hook failed", "hook", hookName(fn), "index", i, "error", err)
+		}
+	}
+}
+
+// OnLifecycle registers fn to be called on every lifecycle event, so an
+// application embedding workerd can coordinate its other subsystems, e.g.
+// mark itself unhealthy while Redis is disconnected. Listeners are called
+// synchronously in registration order and must not block.
+func (w *Workerd) OnLifecycle(fn func(LifecycleEvent)) {
+	if fn != nil {
+		w.lifecycleListeners = append(w.lifecycleListeners, fn)
+	}
+}
+
+// emit calls the lifecycle listeners
+func (w *Workerd) emit(typ LifecycleEventType, detail string, err error) {
+	if len(w.lifecycleListeners) == 0 {
+		return
+	}
+	e := LifecycleEvent{Type: typ, Time: time.Now().UTC(), Detail: detail, Err: err}
+	for _, fn := range w.lifecycleListeners {
+		fn(e)
+	}
+}
+
+// hookName names a hook after its function for the logs
+func hookName(fn any) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		return f.Name()
+	}
+	return "unknown"
+}
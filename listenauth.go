@@ -0,0 +1,239 @@
+package workerd
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ListenerTLSConfig serves a listener over TLS and, with a client CA,
+// requires clients to present a certificate signed by it (mTLS)
+type ListenerTLSConfig struct {
+	// Server certificate and key in PEM; empty serves plain text
+	CertFile string `json:"certFile" yaml:"certFile"`
+	KeyFile  string `json:"keyFile" yaml:"keyFile"`
+
+	// CA bundle in PEM that client certificates must chain to
+	ClientCAFile string `json:"clientCaFile" yaml:"clientCaFile"`
+
+	// Common names or DNS names of the accepted client certificates; empty
+	// accepts every certificate signed by the client CA
+	ClientNames []string `json:"clientNames" yaml:"clientNames"`
+}
+
+// enabled reports whether the listener is served over TLS
+func (tc *ListenerTLSConfig) enabled() bool {
+	return tc.CertFile != ""
+}
+
+// mutual reports whether clients must present a certificate
+func (tc *ListenerTLSConfig) mutual() bool {
+	return tc.ClientCAFile != ""
+}
+
+// validate validates the TLS settings; the files are loaded when the
+// listener starts
+func (tc *ListenerTLSConfig) validate() error {
+	if !tc.enabled() {
+		if tc.KeyFile != "" || tc.ClientCAFile != "" || len(tc.ClientNames) > 0 {
+			return fmt.Errorf("TLS requires a certificate file")
+		}
+		return nil
+	}
+	if tc.KeyFile == "" {
+		return fmt.Errorf("TLS requires a key file")
+	}
+	if len(tc.ClientNames) > 0 && !tc.mutual() {
+		return fmt.Errorf("TLS client names require a client CA file")
+	}
+	return nil
+}
+
+// serverConfig returns the TLS configuration of the listener. With
+// optionalCert, connections without a client certificate are accepted and
+// left to listenerAuth to reject.
+func (tc *ListenerTLSConfig) serverConfig(optionalCert bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if !tc.mutual() {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(tc.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS client CA: %w", err)
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in TLS client CA %s", tc.ClientCAFile)
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	if optionalCert {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if len(tc.ClientNames) > 0 {
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return nil
+			}
+			leaf := cs.PeerCertificates[0]
+			if slices.Contains(tc.ClientNames, leaf.Subject.CommonName) ||
+				slices.ContainsFunc(leaf.DNSNames, func(name string) bool { return slices.Contains(tc.ClientNames, name) }) {
+				return nil
+			}
+			return fmt.Errorf("client certificate %q is not accepted", leaf.Subject.CommonName)
+		}
+	}
+	return config, nil
+}
+
+// listenerAuth authenticates the requests of an admin or gateway listener
+// with bearer tokens, client certificates or both
+type listenerAuth struct {
+	// Accepted bearer tokens; empty requires none
+	tokens []string
+	tls    *ListenerTLSConfig
+	// public reports the HTTP paths or gRPC methods served to anyone; nil
+	// serves none
+	public func(name string) bool
+}
+
+// newListenerAuth accepts token and tokens, ignoring empty ones
+func newListenerAuth(token string, tokens []string, tc *ListenerTLSConfig, public func(string) bool) *listenerAuth {
+	a := &listenerAuth{tls: tc, public: public}
+	for _, t := range append([]string{token}, tokens...) {
+		if t != "" {
+			a.tokens = append(a.tokens, t)
+		}
+	}
+	return a
+}
+
+// isPublic reports whether the path or method is served without
+// authentication
+func (a *listenerAuth) isPublic(name string) bool {
+	return a.public != nil && a.public(name)
+}
+
+// validBearer reports whether the authorization value carries one of the
+// tokens, or whether no token is required
+func (a *listenerAuth) validBearer(authorization string) bool {
+	if len(a.tokens) == 0 {
+		return true
+	}
+	got := []byte(authorization)
+	valid := false
+	// Compare with every token so the time taken does not tell which matched
+	for _, token := range a.tokens {
+		if subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// validCert reports whether the connection carries a verified client
+// certificate, or whether none is required
+func (a *listenerAuth) validCert(state *tls.ConnectionState) bool {
+	return !a.tls.mutual() || (state != nil && len(state.VerifiedChains) > 0)
+}
+
+// listen binds addr like listen, serving TLS when configured. Client
+// certificates are optional at the TLS layer when some paths are public, and
+// required by http for the others.
+func (a *listenerAuth) listen(addr string, allowFrom []string, log *slog.Logger) (net.Listener, error) {
+	ln, err := listen(addr, allowFrom, log)
+	if err != nil {
+		return nil, err
+	}
+	if !a.tls.enabled() {
+		return ln, nil
+	}
+	config, err := a.tls.serverConfig(a.public != nil)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return tls.NewListener(ln, config), nil
+}
+
+// http rejects requests to non-public paths without a valid token or
+// client certificate
+func (a *listenerAuth) http(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !a.isPublic(r.URL.Path) && (!a.validCert(r.TLS) || !a.validBearer(r.Header.Get("Authorization"))) {
+			writeError(rw, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// grpcOptions returns the server options serving TLS when configured and
+// authenticating every call
+func (a *listenerAuth) grpcOptions() ([]grpc.ServerOption, error) {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := a.authorizeGRPC(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := a.authorizeGRPC(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+	if a.tls.enabled() {
+		config, err := a.tls.serverConfig(a.public != nil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+	}
+	return opts, nil
+}
+
+// authorizeGRPC checks the bearer token sent in the authorization metadata
+// and the client certificate of calls to non-public methods
+func (a *listenerAuth) authorizeGRPC(ctx context.Context, method string) error {
+	if a.isPublic(method) {
+		return nil
+	}
+	var state *tls.ConnectionState
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	var authorization string
+	if len(values) > 0 {
+		authorization = values[0]
+	}
+	if !a.validCert(state) || !a.validBearer(authorization) {
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package workerd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// unixSocketPrefix marks listen addresses that are Unix socket paths, e.g.
+// unix:/run/workerd/admin.sock
+const unixSocketPrefix = "unix:"
+
+// listenNetwork splits a listen address into its network and address
+func listenNetwork(addr string) (network, address string) {
+	if path, ok := strings.CutPrefix(addr, unixSocketPrefix); ok {
+		return "unix", path
+	}
+	return "tcp", addr
+}
+
+// parseAllowlist parses CIDRs such as 10.0.0.0/8, or single addresses
+func parseAllowlist(allowFrom []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(allowFrom))
+	for _, s := range allowFrom {
+		if !strings.Contains(s, "/") {
+			ip, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q in allowFrom, expected an IP or CIDR", s)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q in allowFrom: %w", s, err)
+		}
+		if p.Addr().Is4In6() {
+			p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// validateListen validates a listen address and its allowlist
+func validateListen(addr string, allowFrom []string) error {
+	network, address := listenNetwork(addr)
+	if network == "unix" {
+		if address == "" {
+			return fmt.Errorf("unix socket path cannot be empty")
+		}
+		if len(allowFrom) > 0 {
+			return fmt.Errorf("allowFrom does not apply to unix socket %s, restrict its permissions instead", address)
+		}
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	_, err := parseAllowlist(allowFrom)
+	return err
+}
+
+// exposedAddr reports whether addr listens on every interface without an
+// allowlist
+func exposedAddr(addr string, allowFrom []string) bool {
+	network, address := listenNetwork(addr)
+	if network == "unix" || len(allowFrom) > 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return host == "" || (ip != nil && ip.IsUnspecified())
+}
+
+// listen binds addr, a host:port or a unix: socket path. TCP connections
+// from addresses outside allowFrom are closed once accepted; an empty
+// allowFrom accepts every address.
+func listen(addr string, allowFrom []string, log *slog.Logger) (net.Listener, error) {
+	allow, err := parseAllowlist(allowFrom)
+	if err != nil {
+		return nil, err
+	}
+	network, address := listenNetwork(addr)
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	if len(allow) == 0 {
+		return ln, nil
+	}
+	return &allowListener{Listener: ln, allow: allow, log: log}, nil
+}
+
+// removeStaleSocket removes the socket file left behind by a process that did
+// not shut down cleanly, so binding does not fail
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat socket %s: %w", path, err)
+	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// allowListener accepts connections from allowed addresses only
+type allowListener struct {
+	net.Listener
+	allow []netip.Prefix
+	log   *slog.Logger
+}
+
+// Accept waits for the next connection from an allowed address
+func (l *allowListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.allowed(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		l.log.Debug("rejected connection from address not in allowFrom", "addr", l.Addr().String(), "remote_addr", conn.RemoteAddr().String())
+		conn.Close()
+	}
+}
+
+// allowed reports whether addr is within the allowlist
+func (l *allowListener) allowed(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	ip := tcp.AddrPort().Addr().Unmap()
+	for _, p := range l.allow {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
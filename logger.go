@@ -1,16 +1,119 @@
 package workerd
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
+
+	"github.com/kardianos/service"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// LoggingConfig configures workerd's own log output
+type LoggingConfig struct {
+	// Log start, finish, duration and outcome of every task
+	TaskEvents bool `json:"taskEvents" yaml:"taskEvents" env:"WORKER_LOG_TASK_EVENTS" default:"true"`
+
+	// Write logs to this file instead of stdout, rotating it by size and age
+	File string `json:"file" yaml:"file" env:"WORKER_LOG_FILE"`
+
+	// Size in megabytes at which the log file is rotated
+	MaxSizeMB int `json:"maxSizeMB" yaml:"maxSizeMB" env:"WORKER_LOG_MAX_SIZE_MB" default:"100"`
+
+	// Days to keep rotated files; 0 keeps them regardless of age
+	MaxAgeDays int `json:"maxAgeDays" yaml:"maxAgeDays" env:"WORKER_LOG_MAX_AGE_DAYS" default:"28"`
+
+	// Number of rotated files to keep; 0 keeps them all
+	MaxBackups int `json:"maxBackups" yaml:"maxBackups" env:"WORKER_LOG_MAX_BACKUPS" default:"7"`
+
+	// Gzip rotated files
+	Compress bool `json:"compress" yaml:"compress" env:"WORKER_LOG_COMPRESS" default:"false"`
+
+	// Send logs to syslog on Unix or the Windows Event Log when running
+	// under a service manager; interactive runs keep the regular output
+	System bool `json:"system" yaml:"system" env:"WORKER_LOG_SYSTEM" default:"false"`
+}
+
+// validate validates the logging configuration
+func (lc *LoggingConfig) validate() error {
+	if lc.File == "" {
+		return nil
+	}
+	if lc.MaxSizeMB <= 0 {
+		return fmt.Errorf("log max size must be positive, got %d", lc.MaxSizeMB)
+	}
+	if lc.MaxAgeDays < 0 {
+		return fmt.Errorf("log max age must be non-negative, got %d", lc.MaxAgeDays)
+	}
+	if lc.MaxBackups < 0 {
+		return fmt.Errorf("log max backups must be non-negative, got %d", lc.MaxBackups)
+	}
+	return nil
+}
+
+// rotatingFile returns a writer for the configured log file that rotates
+// itself, or nil when logging to stdout
+func (lc *LoggingConfig) rotatingFile() *lumberjack.Logger {
+	if lc.File == "" {
+		return nil
+	}
+	return &lumberjack.Logger{
+		Filename:   lc.File,
+		MaxSize:    lc.MaxSizeMB,
+		MaxAge:     lc.MaxAgeDays,
+		MaxBackups: lc.MaxBackups,
+		Compress:   lc.Compress,
+		LocalTime:  true,
+	}
+}
+
 // newLogger creates a new logger using the global factory
-func newLogger(level slog.Level) *slog.Logger {
+func newLogger(level slog.Leveler, out io.Writer) *slog.Logger {
 	baseAttrs := []slog.Attr{slog.Int("pid", os.Getpid())}
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	handler := slog.NewTextHandler(out, &slog.HandlerOptions{Level: level})
 	handlerWithPID := handler.WithAttrs(baseAttrs)
 	logger := slog.New(handlerWithPID)
 
 	return logger
 }
+
+// newServiceLogger creates a logger writing to the platform log through the
+// service manager. Timestamps are left to the platform log.
+func newServiceLogger(level slog.Leveler, sl service.Logger) *slog.Logger {
+	baseAttrs := []slog.Attr{slog.Int("pid", os.Getpid())}
+	handler := slog.NewTextHandler(serviceLogWriter{sl}, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return slog.New(handler.WithAttrs(baseAttrs))
+}
+
+// serviceLogWriter forwards each formatted record to the service logger at
+// the matching severity; records start with their level since the time is dropped
+type serviceLogWriter struct {
+	logger service.Logger
+}
+
+func (sw serviceLogWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	var err error
+	switch {
+	case strings.HasPrefix(line, "level=ERROR"):
+		err = sw.logger.Error(line)
+	case strings.HasPrefix(line, "level=WARN"):
+		err = sw.logger.Warning(line)
+	default:
+		err = sw.logger.Info(line)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,28 @@
+package workerd
+
+import "log/slog"
+
+// LogLevel returns the level of the built-in logger
+func (w *Workerd) LogLevel() slog.Level {
+	return w.logLevel.Level()
+}
+
+// SetLogLevel changes the level of the built-in logger without a restart.
+// Loggers supplied through WithLogger manage their own level.
+func (w *Workerd) SetLogLevel(level slog.Level) {
+	previous := w.logLevel.Level()
+	w.logLevel.Set(level)
+	w.log.Info("log level changed", "from", previous, "to", level)
+	w.emit(LifecycleConfigReloaded, "log level "+level.String(), nil)
+}
+
+// toggleDebugLogging switches to DEBUG, or back to the level that was in
+// effect before the previous toggle
+func (w *Workerd) toggleDebugLogging() {
+	if w.logLevel.Level() != slog.LevelDebug {
+		w.levelBefore = w.logLevel.Level()
+		w.SetLogLevel(slog.LevelDebug)
+		return
+	}
+	w.SetLogLevel(w.levelBefore)
+}
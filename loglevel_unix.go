@@ -0,0 +1,33 @@
+//go:build !windows
+
+package workerd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchLogLevelSignal toggles debug logging each time SIGUSR2 is received.
+// The returned function stops watching.
+func (w *Workerd) watchLogLevelSignal() func() {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, syscall.SIGUSR2)
+
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				w.toggleDebugLogging()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package workerd
+
+// watchLogLevelSignal is a no-op on Windows, which has no SIGUSR2; use the
+// admin API to change the log level instead
+func (w *Workerd) watchLogLevelSignal() func() {
+	return func() {}
+}
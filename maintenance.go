@@ -0,0 +1,204 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// MaintenanceWindow pauses queues for a while on a recurring schedule
+type MaintenanceWindow struct {
+	Queues []string `json:"queues" yaml:"queues"`
+
+	// Cron expression of the start of each window, e.g. "0 0 * * *"
+	Cron string `json:"cron" yaml:"cron"`
+
+	// IANA time zone the expression is evaluated in; empty means UTC
+	TZ string `json:"tz" yaml:"tz"`
+
+	Duration time.Duration `json:"duration" yaml:"duration"`
+}
+
+// cronspec returns the cron expression with its time zone
+func (mw *MaintenanceWindow) cronspec() string {
+	if mw.TZ == "" {
+		return mw.Cron
+	}
+	return "CRON_TZ=" + mw.TZ + " " + mw.Cron
+}
+
+// MaintenanceConfig configures recurring maintenance windows during which
+// queues are paused
+type MaintenanceConfig struct {
+	Windows []MaintenanceWindow `json:"windows" yaml:"windows"`
+
+	// How often the scheduler leader starts and ends windows
+	Interval time.Duration `json:"interval" yaml:"interval" env:"WORKER_MAINTENANCE_INTERVAL" default:"30s"`
+
+	// Redis set of the queues paused by a window, so queues paused by hand
+	// are never resumed
+	Key string `json:"key" yaml:"key" env:"WORKER_MAINTENANCE_KEY" default:"workerd:maintenance:paused"`
+}
+
+// validate validates the maintenance windows
+func (mc *MaintenanceConfig) validate() error {
+	if len(mc.Windows) == 0 {
+		return nil
+	}
+	for i := range mc.Windows {
+		mw := &mc.Windows[i]
+		if len(mw.Queues) == 0 {
+			return fmt.Errorf("maintenance window %d: queues cannot be empty", i)
+		}
+		if mw.TZ != "" {
+			if _, err := time.LoadLocation(mw.TZ); err != nil {
+				return fmt.Errorf("maintenance window %d: invalid time zone %q: %w", i, mw.TZ, err)
+			}
+		}
+		if _, err := cron.ParseStandard(mw.cronspec()); err != nil {
+			return fmt.Errorf("maintenance window %d: invalid cron expression %q: %w", i, mw.Cron, err)
+		}
+		if mw.Duration <= 0 {
+			return fmt.Errorf("maintenance window %d: duration must be positive, got %v", i, mw.Duration)
+		}
+	}
+	if mc.Interval <= 0 {
+		return fmt.Errorf("maintenance interval must be positive, got %v", mc.Interval)
+	}
+	if mc.Key == "" {
+		return fmt.Errorf("maintenance key cannot be empty")
+	}
+	return nil
+}
+
+// maintenanceWindow is a window with its parsed schedule
+type maintenanceWindow struct {
+	queues   []string
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// active reports whether a window started within its duration before now
+func (mw *maintenanceWindow) active(now time.Time) bool {
+	return !mw.schedule.Next(now.Add(-mw.duration)).After(now)
+}
+
+// maintenanceScheduler pauses the queues of active windows and resumes them
+// when the windows end. It only acts on the scheduler leader.
+type maintenanceScheduler struct {
+	config    *MaintenanceConfig
+	windows   []maintenanceWindow
+	rdb       redis.UniversalClient
+	inspector func() *asynq.Inspector
+	leader    func() bool
+	log       *slog.Logger
+}
+
+func newMaintenanceScheduler(config *MaintenanceConfig, w *Workerd) (*maintenanceScheduler, error) {
+	windows := make([]maintenanceWindow, 0, len(config.Windows))
+	for i := range config.Windows {
+		mw := &config.Windows[i]
+		schedule, err := cron.ParseStandard(mw.cronspec())
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %w", mw.Cron, err)
+		}
+		windows = append(windows, maintenanceWindow{queues: mw.Queues, schedule: schedule, duration: mw.Duration})
+	}
+	rdb, ok := w.redisOpt.MakeRedisClient().(redis.UniversalClient)
+	if !ok {
+		return nil, fmt.Errorf("unsupported redis connection for maintenance windows")
+	}
+	return &maintenanceScheduler{
+		config:    config,
+		windows:   windows,
+		rdb:       rdb,
+		inspector: w.Inspector,
+		leader:    w.IsLeader,
+		log:       w.log,
+	}, nil
+}
+
+// check pauses and resumes queues as windows start and end
+func (m *maintenanceScheduler) check(ctx context.Context) error {
+	if !m.leader() {
+		return nil
+	}
+	now := time.Now()
+	due := make(map[string]bool)
+	for i := range m.windows {
+		if m.windows[i].active(now) {
+			for _, q := range m.windows[i].queues {
+				due[q] = true
+			}
+		}
+	}
+	members, err := m.rdb.SMembers(ctx, m.config.Key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read queues paused for maintenance: %w", err)
+	}
+	ours := make(map[string]bool, len(members))
+	for _, q := range members {
+		ours[q] = true
+	}
+
+	var errs []error
+	for q := range due {
+		if !ours[q] {
+			errs = append(errs, m.pause(ctx, q))
+		}
+	}
+	for q := range ours {
+		if !due[q] {
+			errs = append(errs, m.resume(ctx, q))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pause pauses a queue unless it was paused by hand
+func (m *maintenanceScheduler) pause(ctx context.Context, queue string) error {
+	inspector := m.inspector()
+	if info, err := inspector.GetQueueInfo(queue); err == nil && info.Paused {
+		return nil
+	}
+	// Record the queue first so it is resumed even if this worker stops
+	// right after pausing it
+	if err := m.rdb.SAdd(ctx, m.config.Key, queue).Err(); err != nil {
+		return fmt.Errorf("failed to record queue %q paused for maintenance: %w", queue, err)
+	}
+	if err := inspector.PauseQueue(queue); err != nil {
+		m.rdb.SRem(ctx, m.config.Key, queue)
+		return fmt.Errorf("failed to pause queue %q for maintenance: %w", queue, err)
+	}
+	m.log.Info("maintenance window started, queue paused", "queue", queue)
+	return nil
+}
+
+// resume resumes a queue paused by a window that ended, unless it was
+// resumed by hand in the meantime
+func (m *maintenanceScheduler) resume(ctx context.Context, queue string) error {
+	inspector := m.inspector()
+	info, err := inspector.GetQueueInfo(queue)
+	if err != nil || info.Paused {
+		// Queues without tasks are unknown to asynq but may still be paused,
+		// so failing to resume them is only an error when they were found
+		if uerr := inspector.UnpauseQueue(queue); uerr != nil && err == nil {
+			return fmt.Errorf("failed to resume queue %q after maintenance: %w", queue, uerr)
+		}
+	}
+	if err := m.rdb.SRem(ctx, m.config.Key, queue).Err(); err != nil {
+		return fmt.Errorf("failed to record queue %q resumed after maintenance: %w", queue, err)
+	}
+	m.log.Info("maintenance window ended, queue resumed", "queue", queue)
+	return nil
+}
+
+func (m *maintenanceScheduler) close() error {
+	return m.rdb.Close()
+}
@@ -0,0 +1,201 @@
+package workerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/hibiken/asynq"
+)
+
+// Metadata carries string attributes alongside a task payload.
+// It is stored in a small envelope around the payload by Client and
+// unwrapped by the worker before the handler runs.
+type Metadata map[string]string
+
+// Well known metadata keys propagated to follow-up tasks
+const (
+	MetaTenant   = "tenant"
+	MetaTraceID  = "trace_id"
+	MetaPriority = "priority"
+	MetaParentID = "parent_id"
+)
+
+// inheritedKeys are copied from a parent task to tasks enqueued by its handler
+var inheritedKeys = []string{MetaTenant, MetaTraceID, MetaPriority, MetaDeadline}
+
+// clone returns a copy of the metadata
+func (m Metadata) clone() Metadata {
+	out := make(Metadata, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+type metadataKey struct{}
+type outgoingMetadataKey struct{}
+type parentTaskKey struct{}
+type resultWriterKey struct{}
+
+// parentTask describes the task whose handler is currently running
+type parentTask struct {
+	id       string
+	queue    string
+	metadata Metadata
+}
+
+// WithMetadata returns a context whose metadata is attached to tasks enqueued with it.
+// Keys set here override values inherited from a parent task.
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	merged := outgoingMetadata(ctx).clone()
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, outgoingMetadataKey{}, merged)
+}
+
+// MetadataFromContext returns the metadata of the task being processed
+func MetadataFromContext(ctx context.Context) Metadata {
+	if md, ok := ctx.Value(metadataKey{}).(Metadata); ok {
+		return md
+	}
+	return Metadata{}
+}
+
+// outgoingMetadata returns the metadata added through WithMetadata
+func outgoingMetadata(ctx context.Context) Metadata {
+	if md, ok := ctx.Value(outgoingMetadataKey{}).(Metadata); ok {
+		return md
+	}
+	return Metadata{}
+}
+
+// ResultWriter returns the result writer of the task being processed. Use it
+// instead of task.ResultWriter(): handlers of tasks with metadata or encoded
+// payloads get a copy of the task without the envelope, and asynq has no way
+// to give the copy a result writer.
+func ResultWriter(ctx context.Context) *asynq.ResultWriter {
+	w, _ := ctx.Value(resultWriterKey{}).(*asynq.ResultWriter)
+	return w
+}
+
+// needsEnvelope reports whether md holds more than the parent task ID, which
+// alone is not worth changing the payload format for consumers
+func needsEnvelope(md Metadata) bool {
+	for k := range md {
+		if k != MetaParentID {
+			return true
+		}
+	}
+	return false
+}
+
+// envelopeMagic marks payloads wrapped with metadata
+var envelopeMagic = []byte("\x00wdenv1")
+
+// envelopeHeader is the JSON header stored in front of the payload
+type envelopeHeader struct {
+	Meta Metadata `json:"m,omitempty"`
+	// Encodings applied to the payload, in order
+	Encoding []string `json:"e,omitempty"`
+}
+
+// encodeEnvelope wraps payload with the header
+func encodeEnvelope(h envelopeHeader, payload []byte) ([]byte, error) {
+	header, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(envelopeMagic)+binary.MaxVarintLen64+len(header)+len(payload))
+	out = append(out, envelopeMagic...)
+	out = binary.AppendUvarint(out, uint64(len(header)))
+	out = append(out, header...)
+	out = append(out, payload...)
+	return out, nil
+}
+
+// decodeEnvelope splits an enveloped payload; ok is false for plain payloads
+func decodeEnvelope(data []byte) (h envelopeHeader, payload []byte, ok bool) {
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		return h, data, false
+	}
+	rest := data[len(envelopeMagic):]
+	n, size := binary.Uvarint(rest)
+	if size <= 0 || uint64(len(rest)-size) < n {
+		return h, data, false
+	}
+	if err := json.Unmarshal(rest[size:size+int(n)], &h); err != nil {
+		return envelopeHeader{}, data, false
+	}
+	if h.Meta == nil {
+		h.Meta = Metadata{}
+	}
+	return h, rest[size+int(n):], true
+}
+
+// metadataMiddleware unwraps enveloped payloads, reversing their encodings,
+// and records the parent task in the context. Offloaded payload blobs are
+// deleted once the task succeeds.
+func metadataMiddleware(decoders payloadDecoders, blobs BlobStore, logger *slog.Logger) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			ctx = context.WithValue(ctx, resultWriterKey{}, t.ResultWriter())
+
+			header, payload, ok := decodeEnvelope(t.Payload())
+			md := header.Meta
+			if !ok {
+				md = Metadata{}
+			}
+			ctx = context.WithValue(ctx, metadataKey{}, md)
+
+			parent := parentTask{metadata: md}
+			parent.id, _ = asynq.GetTaskID(ctx)
+			parent.queue, _ = asynq.GetQueueName(ctx)
+			ctx = context.WithValue(ctx, parentTaskKey{}, parent)
+
+			if ok {
+				decoded, err := decoders.decode(ctx, header.Encoding, payload)
+				if err != nil {
+					return err
+				}
+				t = asynq.NewTask(t.Type(), decoded)
+			}
+			if err := next.ProcessTask(ctx, t); err != nil {
+				return err
+			}
+			deleteBlobs(ctx, blobs, header.Encoding, logger)
+			return nil
+		})
+	}
+}
+
+// inheritFromParent returns the options and metadata a follow-up task inherits
+// from the task being processed in ctx, if any
+func inheritFromParent(ctx context.Context) ([]asynq.Option, Metadata) {
+	md := Metadata{}
+	var opts []asynq.Option
+
+	if parent, ok := ctx.Value(parentTaskKey{}).(parentTask); ok {
+		for _, k := range inheritedKeys {
+			if v, ok := parent.metadata[k]; ok {
+				md[k] = v
+			}
+		}
+		if parent.id != "" {
+			md[MetaParentID] = parent.id
+		}
+		if parent.queue != "" {
+			opts = append(opts, asynq.Queue(parent.queue))
+		}
+	}
+
+	// Values set explicitly through WithMetadata win over inherited ones
+	for k, v := range outgoingMetadata(ctx) {
+		md[k] = v
+	}
+
+	return opts, md
+}
@@ -0,0 +1,68 @@
+package workerd
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	header := envelopeHeader{Meta: Metadata{MetaTenant: "acme"}, Encoding: []string{"gzip"}}
+	data, err := encodeEnvelope(header, []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, payload, ok := decodeEnvelope(data)
+	if !ok {
+		t.Fatal("decodeEnvelope: not an envelope")
+	}
+	if !reflect.DeepEqual(got, header) || string(payload) != "payload" {
+		t.Errorf("decodeEnvelope = %v, %q; want %v, %q", got, payload, header, "payload")
+	}
+
+	if _, payload, ok := decodeEnvelope([]byte("plain")); ok || string(payload) != "plain" {
+		t.Errorf("decodeEnvelope(plain) = %q, %v; want plain payload", payload, ok)
+	}
+}
+
+func TestNeedsEnvelope(t *testing.T) {
+	if needsEnvelope(Metadata{MetaParentID: "id"}) {
+		t.Error("a parent ID alone should not wrap the payload")
+	}
+	if !needsEnvelope(Metadata{MetaParentID: "id", MetaTenant: "acme"}) {
+		t.Error("tenant metadata should wrap the payload")
+	}
+}
+
+func TestMetadataMiddlewareUnwrapsEnvelope(t *testing.T) {
+	data, err := encodeEnvelope(envelopeHeader{Meta: Metadata{MetaTenant: "acme"}}, []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := asynq.NewTask("email:send", data)
+
+	called := false
+	h := metadataMiddleware(nil, nil, slog.Default())(asynq.HandlerFunc(func(ctx context.Context, got *asynq.Task) error {
+		called = true
+		if !bytes.Equal(got.Payload(), []byte("payload")) {
+			t.Errorf("payload = %q, want envelope removed", got.Payload())
+		}
+		if MetadataFromContext(ctx)[MetaTenant] != "acme" {
+			t.Errorf("metadata = %v, want tenant acme", MetadataFromContext(ctx))
+		}
+		if ResultWriter(ctx) != task.ResultWriter() {
+			t.Error("ResultWriter(ctx) is not the writer of the original task")
+		}
+		return nil
+	}))
+	if err := h.ProcessTask(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Fatal("handler not called")
+	}
+}
@@ -0,0 +1,350 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Task outcomes used as the status label
+const (
+	statusSuccess = "success"
+	statusFailure = "failure"
+)
+
+// Supported metrics exporters
+const (
+	ExporterPrometheus = "prometheus"
+	ExporterStatsD     = "statsd"
+)
+
+// MetricsConfig configures task metrics
+type MetricsConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_METRICS_ENABLED" default:"false"`
+
+	// Exporter is "prometheus" (pull) or "statsd" (push over UDP)
+	Exporter string `json:"exporter" yaml:"exporter" env:"WORKER_METRICS_EXPORTER" default:"prometheus"`
+
+	// Listen address of the Prometheus endpoint, host:port or
+	// unix:/path/to.sock
+	Addr string `json:"addr" yaml:"addr" env:"WORKER_METRICS_ADDR" default:"127.0.0.1:9400"`
+
+	// Client IPs or CIDRs the Prometheus endpoint accepts connections from,
+	// e.g. the scraper's; empty accepts any
+	AllowFrom []string `json:"allowFrom" yaml:"allowFrom"`
+
+	// HTTP path of the Prometheus endpoint
+	Path string `json:"path" yaml:"path" env:"WORKER_METRICS_PATH" default:"/metrics"`
+
+	// Prefix of every metric name
+	Namespace string `json:"namespace" yaml:"namespace" env:"WORKER_METRICS_NAMESPACE" default:"workerd"`
+
+	// Upper bounds in seconds of the task duration histogram buckets
+	Buckets []float64 `json:"buckets" yaml:"buckets"`
+
+	// StatsD settings used when Exporter is "statsd"
+	StatsD StatsDConfig `json:"statsd" yaml:"statsd"`
+}
+
+// validate validates the metrics configuration
+func (mc *MetricsConfig) validate() error {
+	if !mc.Enabled {
+		return nil
+	}
+	switch mc.Exporter {
+	case ExporterPrometheus:
+	case ExporterStatsD:
+		return mc.StatsD.validate()
+	default:
+		return fmt.Errorf("unknown metrics exporter %q, expected %q or %q", mc.Exporter, ExporterPrometheus, ExporterStatsD)
+	}
+	if mc.Addr == "" {
+		return fmt.Errorf("metrics address cannot be empty when metrics are enabled")
+	}
+	if err := validateListen(mc.Addr, mc.AllowFrom); err != nil {
+		return err
+	}
+	if mc.Path == "" || mc.Path[0] != '/' {
+		return fmt.Errorf("metrics path must start with '/', got %q", mc.Path)
+	}
+	for i := 1; i < len(mc.Buckets); i++ {
+		if mc.Buckets[i] <= mc.Buckets[i-1] {
+			return fmt.Errorf("metrics buckets must be sorted in increasing order")
+		}
+	}
+	return nil
+}
+
+// metricsExporter records task events and ships them to a monitoring backend
+type metricsExporter interface {
+	taskStarted(queue, taskType string)
+	taskFinished(queue, taskType, status string, d time.Duration)
+	taskUsage(queue, taskType string, cpu time.Duration, allocBytes int64)
+	taskFailure(queue, taskType string, category FailureCategory)
+	taskLatency(queue, taskType string, latency time.Duration, breached bool)
+	redisConnected(up bool)
+	redisPool(name string, stats RedisPoolStats)
+	start(w *Workerd) error
+	stop(ctx context.Context) error
+}
+
+// newMetricsExporter creates the exporter selected in the configuration
+func newMetricsExporter(config *MetricsConfig) (metricsExporter, error) {
+	switch config.Exporter {
+	case ExporterStatsD:
+		return newStatsDExporter(config)
+	default:
+		return newTaskMetrics(config), nil
+	}
+}
+
+// taskMetrics holds the per queue and task type Prometheus collectors
+type taskMetrics struct {
+	config     *MetricsConfig
+	registry   *prometheus.Registry
+	processed  *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	inProgress *prometheus.GaugeVec
+	failures   *prometheus.CounterVec
+	cpu        *prometheus.CounterVec
+	allocated  *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	breaches   *prometheus.CounterVec
+	redisUp    prometheus.Gauge
+	srv        *http.Server
+
+	poolConns    *prometheus.GaugeVec
+	poolSize     *prometheus.GaugeVec
+	poolRequests *prometheus.CounterVec
+	poolTimeouts *prometheus.CounterVec
+	// Pool counts as last reported, by Redis name; go-redis keeps totals
+	poolLast map[string]RedisPoolStats
+}
+
+// newTaskMetrics creates the collectors and registers them with a dedicated registry
+func newTaskMetrics(config *MetricsConfig) *taskMetrics {
+	buckets := config.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	m := &taskMetrics{
+		config:   config,
+		registry: prometheus.NewRegistry(),
+		processed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "tasks_processed_total",
+			Help:      "Number of processed tasks by queue, task type and status.",
+		}, []string{"queue", "type", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Name:      "task_duration_seconds",
+			Help:      "Handler duration by queue, task type and status.",
+			Buckets:   buckets,
+		}, []string{"queue", "type", "status"}),
+		inProgress: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Name:      "tasks_in_progress",
+			Help:      "Number of tasks currently being processed by queue and task type.",
+		}, []string{"queue", "type"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "task_failures_total",
+			Help:      "Number of failed task attempts by queue, task type and failure category.",
+		}, []string{"queue", "type", "category"}),
+		cpu: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "task_cpu_seconds_total",
+			Help:      "Approximate CPU time used by tasks by queue and task type, when usage accounting is enabled.",
+		}, []string{"queue", "type"}),
+		allocated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "task_allocated_bytes_total",
+			Help:      "Approximate heap allocations of tasks by queue and task type, when usage accounting is enabled.",
+		}, []string{"queue", "type"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Name:      "task_latency_seconds",
+			Help:      "Time from due to completion of tasks with an SLA by queue and task type.",
+			// 100ms to about 55 minutes
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 16),
+		}, []string{"queue", "type"}),
+		breaches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "sla_breaches_total",
+			Help:      "Number of tasks completed later than their SLA by queue and task type.",
+		}, []string{"queue", "type"}),
+		redisUp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Name:      "redis_up",
+			Help:      "Whether Redis answered the last connection check (1) or not (0).",
+		}),
+		poolConns: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Name:      "redis_pool_connections",
+			Help:      "Open Redis connections by Redis server and state (total, idle or stale).",
+		}, []string{"redis", "state"}),
+		poolSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Name:      "redis_pool_max_connections",
+			Help:      "Most Redis connections the pools may open by Redis server.",
+		}, []string{"redis"}),
+		poolRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "redis_pool_requests_total",
+			Help:      "Connections taken from the pools by Redis server and result (hit when an idle one was reused, miss when one was opened).",
+		}, []string{"redis", "result"}),
+		poolTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "redis_pool_timeouts_total",
+			Help:      "Commands that found no free Redis connection within the pool timeout by Redis server.",
+		}, []string{"redis"}),
+		poolLast: make(map[string]RedisPoolStats),
+	}
+
+	m.registry.MustRegister(
+		m.processed,
+		m.duration,
+		m.inProgress,
+		m.failures,
+		m.cpu,
+		m.allocated,
+		m.latency,
+		m.breaches,
+		m.redisUp,
+		m.poolConns,
+		m.poolSize,
+		m.poolRequests,
+		m.poolTimeouts,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// taskStarted records a task entering its handler
+func (m *taskMetrics) taskStarted(queue, taskType string) {
+	m.inProgress.WithLabelValues(queue, taskType).Inc()
+}
+
+// taskFinished records the outcome and duration of a task
+func (m *taskMetrics) taskFinished(queue, taskType, status string, d time.Duration) {
+	m.inProgress.WithLabelValues(queue, taskType).Dec()
+	m.processed.WithLabelValues(queue, taskType, status).Inc()
+	m.duration.WithLabelValues(queue, taskType, status).Observe(d.Seconds())
+}
+
+// taskFailure records the failure category of a failed task
+func (m *taskMetrics) taskFailure(queue, taskType string, category FailureCategory) {
+	m.failures.WithLabelValues(queue, taskType, string(category)).Inc()
+}
+
+// taskUsage records the resources a task used
+func (m *taskMetrics) taskUsage(queue, taskType string, cpu time.Duration, allocBytes int64) {
+	m.cpu.WithLabelValues(queue, taskType).Add(cpu.Seconds())
+	m.allocated.WithLabelValues(queue, taskType).Add(float64(max(allocBytes, 0)))
+}
+
+// taskLatency records the time a task with an SLA took from due to completion
+func (m *taskMetrics) taskLatency(queue, taskType string, latency time.Duration, breached bool) {
+	m.latency.WithLabelValues(queue, taskType).Observe(latency.Seconds())
+	if breached {
+		m.breaches.WithLabelValues(queue, taskType).Inc()
+	}
+}
+
+// redisConnected records the state of the Redis connection
+func (m *taskMetrics) redisConnected(up bool) {
+	if up {
+		m.redisUp.Set(1)
+	} else {
+		m.redisUp.Set(0)
+	}
+}
+
+// redisPool records the connection pool usage of a Redis server
+func (m *taskMetrics) redisPool(name string, stats RedisPoolStats) {
+	m.poolConns.WithLabelValues(name, "total").Set(float64(stats.Total))
+	m.poolConns.WithLabelValues(name, "idle").Set(float64(stats.Idle))
+	m.poolConns.WithLabelValues(name, "stale").Set(float64(stats.Stale))
+	m.poolSize.WithLabelValues(name).Set(float64(stats.Size))
+
+	last := m.poolLast[name]
+	m.poolRequests.WithLabelValues(name, "hit").Add(float64(stats.Hits - min(last.Hits, stats.Hits)))
+	m.poolRequests.WithLabelValues(name, "miss").Add(float64(stats.Misses - min(last.Misses, stats.Misses)))
+	m.poolTimeouts.WithLabelValues(name).Add(float64(stats.Timeouts - min(last.Timeouts, stats.Timeouts)))
+	m.poolLast[name] = stats
+}
+
+// start serves the registry on the configured address and path in the background
+func (m *taskMetrics) start(w *Workerd) error {
+	addr, path := m.config.Addr, m.config.Path
+	ln, err := listen(addr, m.config.AllowFrom, w.log)
+	if err != nil {
+		return err
+	}
+	if exposedAddr(addr, m.config.AllowFrom) {
+		w.log.Warn("metrics endpoint listens on every interface without allowFrom", "addr", addr)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET "+path, promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	m.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := m.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			w.log.Error("metrics server stopped unexpectedly", "error", err)
+		}
+	}()
+
+	w.log.Info("metrics endpoint listening", "addr", ln.Addr().String(), "path", path)
+	return nil
+}
+
+// stop gracefully shuts the metrics endpoint down
+func (m *taskMetrics) stop(ctx context.Context) error {
+	if m.srv == nil {
+		return nil
+	}
+	return m.srv.Shutdown(ctx)
+}
+
+// MetricsRegisterer returns the registry behind the Prometheus endpoint so
+// applications can expose their own collectors, or nil if it is not in use
+func (w *Workerd) MetricsRegisterer() prometheus.Registerer {
+	if m, ok := w.metrics.(*taskMetrics); ok {
+		return m.registry
+	}
+	return nil
+}
+
+// metricsMiddleware records per queue and task type counters and durations
+func metricsMiddleware(m metricsExporter) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if m == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			queue, _ := asynq.GetQueueName(ctx)
+			m.taskStarted(queue, t.Type())
+
+			start := time.Now()
+			err := next.ProcessTask(ctx, t)
+
+			status := statusSuccess
+			if err != nil {
+				status = statusFailure
+				m.taskFailure(queue, t.Type(), FailureCategoryOf(err))
+			}
+			m.taskFinished(queue, t.Type(), status, time.Since(start))
+			return err
+		})
+	}
+}
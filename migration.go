@@ -0,0 +1,202 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// MigrationConfig configures dual-write mode while moving to a new Redis target.
+// Tasks are enqueued to the configured asynq Redis (the new target, which workers
+// consume from) and mirrored to the legacy Redis until the window closes.
+type MigrationConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_MIGRATION_ENABLED" default:"false"`
+
+	// Legacy Redis target in URI form, e.g. redis://:password@old-host:6379/0
+	LegacyRedisURI string `json:"legacyRedisURI" yaml:"legacyRedisURI" env:"WORKER_MIGRATION_LEGACY_REDIS_URI"`
+
+	// End of the transition window. Zero means dual-write until disabled.
+	Until time.Time `json:"until" yaml:"until"`
+
+	// How often workers log a reconciliation report. Zero disables it.
+	ReconcileInterval time.Duration `json:"reconcileInterval" yaml:"reconcileInterval" env:"WORKER_MIGRATION_RECONCILE_INTERVAL" default:"5m"`
+}
+
+// validate validates the migration configuration
+func (mc *MigrationConfig) validate() error {
+	if !mc.Enabled {
+		return nil
+	}
+	if mc.LegacyRedisURI == "" {
+		return fmt.Errorf("legacy redis URI is required when migration is enabled")
+	}
+	if _, err := mc.legacyRedisOpt(); err != nil {
+		return err
+	}
+	if mc.ReconcileInterval < 0 {
+		return fmt.Errorf("reconcile interval must be non-negative, got %v", mc.ReconcileInterval)
+	}
+	return nil
+}
+
+// active reports whether dual-write is currently in effect
+func (mc *MigrationConfig) active() bool {
+	return mc.Enabled && (mc.Until.IsZero() || time.Now().Before(mc.Until))
+}
+
+// legacyRedisOpt parses the legacy Redis URI
+func (mc *MigrationConfig) legacyRedisOpt() (asynq.RedisConnOpt, error) {
+	opt, err := asynq.ParseRedisURI(mc.LegacyRedisURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid legacy redis URI: %w", err)
+	}
+	return opt, nil
+}
+
+// MigrationStats counts dual-write outcomes since the client was created
+type MigrationStats struct {
+	Mirrored      int64 // tasks written to both targets
+	LegacyFailed  int64 // tasks written to the new target only
+	WindowSkipped int64 // tasks enqueued after the window closed
+}
+
+// dualWriter mirrors enqueued tasks to the legacy Redis target
+type dualWriter struct {
+	config  *MigrationConfig
+	legacy  *asynq.Client
+	mirror  atomic.Int64
+	failed  atomic.Int64
+	skipped atomic.Int64
+}
+
+func newDualWriter(config *MigrationConfig, legacyOpt asynq.RedisConnOpt) *dualWriter {
+	return &dualWriter{
+		config: config,
+		legacy: asynq.NewClient(legacyOpt),
+	}
+}
+
+// active reports whether the transition window is still open
+func (d *dualWriter) active() bool {
+	if d.config.active() {
+		return true
+	}
+	d.skipped.Add(1)
+	return false
+}
+
+// write mirrors the task to the legacy target under the same task ID.
+// Failures are counted and logged but never fail the primary enqueue.
+func (d *dualWriter) write(ctx context.Context, log *slog.Logger, task *asynq.Task, info *asynq.TaskInfo, opts []asynq.Option) {
+	opts = append(opts, asynq.TaskID(info.ID), asynq.Queue(info.Queue))
+	if _, err := d.legacy.EnqueueContext(ctx, task, opts...); err != nil {
+		d.failed.Add(1)
+		log.Warn("dual-write to legacy redis failed", "task_id", info.ID, "type", info.Type, "error", err)
+		return
+	}
+	d.mirror.Add(1)
+}
+
+func (d *dualWriter) close() error {
+	return d.legacy.Close()
+}
+
+// MigrationStats returns dual-write counters, or false if migration is not configured
+func (c *Client) MigrationStats() (MigrationStats, bool) {
+	if c.migration == nil {
+		return MigrationStats{}, false
+	}
+	return MigrationStats{
+		Mirrored:      c.migration.mirror.Load(),
+		LegacyFailed:  c.migration.failed.Load(),
+		WindowSkipped: c.migration.skipped.Load(),
+	}, true
+}
+
+// ReconciliationReport compares pending work between the legacy and new targets
+type ReconciliationReport struct {
+	Queue string
+	// Number of pending/scheduled/retry tasks on each side
+	New    int
+	Legacy int
+	// Task IDs present only on one side
+	MissingInLegacy []string
+	MissingInNew    []string
+}
+
+// Reconcile lists unfinished tasks on both targets and reports the differences per queue.
+// Tasks that were processed on one side since they were mirrored show up as missing on the other.
+func Reconcile(newOpt, legacyOpt asynq.RedisConnOpt, queues ...string) ([]ReconciliationReport, error) {
+	newInspector := asynq.NewInspector(newOpt)
+	defer newInspector.Close()
+	legacyInspector := asynq.NewInspector(legacyOpt)
+	defer legacyInspector.Close()
+
+	if len(queues) == 0 {
+		var err error
+		queues, err = newInspector.Queues()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list queues: %w", err)
+		}
+	}
+
+	reports := make([]ReconciliationReport, 0, len(queues))
+	for _, queue := range queues {
+		newIDs, err := unfinishedTaskIDs(newInspector, queue)
+		if err != nil {
+			return nil, fmt.Errorf("new target queue %q: %w", queue, err)
+		}
+		legacyIDs, err := unfinishedTaskIDs(legacyInspector, queue)
+		if err != nil {
+			return nil, fmt.Errorf("legacy target queue %q: %w", queue, err)
+		}
+
+		report := ReconciliationReport{Queue: queue, New: len(newIDs), Legacy: len(legacyIDs)}
+		for id := range newIDs {
+			if _, ok := legacyIDs[id]; !ok {
+				report.MissingInLegacy = append(report.MissingInLegacy, id)
+			}
+		}
+		for id := range legacyIDs {
+			if _, ok := newIDs[id]; !ok {
+				report.MissingInNew = append(report.MissingInNew, id)
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// unfinishedTaskIDs collects the IDs of pending, scheduled and retry tasks in a queue
+func unfinishedTaskIDs(inspector *asynq.Inspector, queue string) (map[string]struct{}, error) {
+	ids := make(map[string]struct{})
+	lists := []func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error){
+		inspector.ListPendingTasks,
+		inspector.ListScheduledTasks,
+		inspector.ListRetryTasks,
+	}
+	for _, list := range lists {
+		for page := 1; ; page++ {
+			tasks, err := list(queue, asynq.PageSize(500), asynq.Page(page))
+			if err != nil {
+				if errors.Is(err, asynq.ErrQueueNotFound) {
+					break
+				}
+				return nil, err
+			}
+			for _, t := range tasks {
+				ids[t.ID] = struct{}{}
+			}
+			if len(tasks) < 500 {
+				break
+			}
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,82 @@
+package workerd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing/fstest"
+
+	"github.com/jinzhu/configor"
+	"gopkg.in/yaml.v3"
+)
+
+// ModuleConfig gives handlers and modules access to their own configuration
+// sections, declared under `modules:` in the worker's configuration files
+type ModuleConfig struct {
+	sections map[string]any
+}
+
+// Config returns the module configuration loaded with the worker's configuration
+func (w *Workerd) Config() *ModuleConfig {
+	var sections map[string]any
+	if w.config != nil {
+		sections = w.config.Modules
+	}
+	return &ModuleConfig{sections: sections}
+}
+
+// Sections returns the names of the configured module sections
+func (mc *ModuleConfig) Sections() []string {
+	names := make([]string, 0, len(mc.sections))
+	for name := range mc.sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether the section is present in the configuration files
+func (mc *ModuleConfig) Has(section string) bool {
+	_, ok := mc.sections[section]
+	return ok
+}
+
+// Decode loads a section into out, a pointer to a struct, using the same
+// pipeline as the worker configuration: `default` tags first, then the
+// section from the files, then environment variables. Fields without an
+// `env` tag are read from WORKER_<SECTION>_<FIELD>. Fields tagged
+// `required:"true"` must be set, and if out implements Validate() error it
+// is called last.
+func (mc *ModuleConfig) Decode(section string, out any) error {
+	if strings.TrimSpace(section) == "" {
+		return fmt.Errorf("section name cannot be empty")
+	}
+
+	data := []byte("{}")
+	if raw, ok := mc.sections[section]; ok && raw != nil {
+		var err error
+		data, err = yaml.Marshal(raw)
+		if err != nil {
+			return fmt.Errorf("failed to read module section %q: %w", section, err)
+		}
+	}
+
+	const file = "section.yaml"
+	loader := configor.New(&configor.Config{
+		ENVPrefix:            "WORKER_" + strings.ToUpper(section),
+		Silent:               true,
+		ErrorOnUnmatchedKeys: true,
+		FS:                   fstest.MapFS{file: {Data: data}},
+	})
+	if err := loader.Load(out, file); err != nil {
+		return fmt.Errorf("failed to load module section %q: %w", section, err)
+	}
+
+	if v, ok := out.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("module section %q invalid: %w", section, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// asynqKeyPrefix starts every key asynq uses, e.g. asynq:{default}:pending
+const asynqKeyPrefix = "asynq:"
+
+// validateNamespace accepts letters, digits, "-", "_" and "."; braces would
+// change the Redis Cluster hash tags of asynq's keys
+func validateNamespace(ns string) error {
+	for _, r := range ns {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return fmt.Errorf("invalid namespace %q, use letters, digits, '-', '_' and '.' only", ns)
+		}
+	}
+	return nil
+}
+
+// namespaced moves the keys used through opt under the namespace, if any
+func (a *AsynqConfig) namespaced(opt asynq.RedisConnOpt) asynq.RedisConnOpt {
+	if a.Namespace == "" {
+		return opt
+	}
+	return namespaceOpt{RedisConnOpt: opt, prefix: a.Namespace + ":"}
+}
+
+// namespaceOpt adds namespaceHook to the clients it makes, which asynq has no
+// option for
+type namespaceOpt struct {
+	asynq.RedisConnOpt
+	prefix string
+}
+
+// MakeRedisClient implements asynq.RedisConnOpt
+func (o namespaceOpt) MakeRedisClient() interface{} {
+	c := o.RedisConnOpt.MakeRedisClient().(redis.UniversalClient)
+	c.AddHook(namespaceHook{prefix: o.prefix})
+	return c
+}
+
+// namespaceHook prefixes asynq's keys with the namespace, so asynq:{default}:pending
+// becomes staging:asynq:{default}:pending. Every argument is rewritten, not
+// only keys, since asynq's scripts take key prefixes as arguments and sets
+// such as asynq:servers hold key names. Prefixed names no longer start with
+// asynq:, so names read back from Redis are not prefixed twice.
+type namespaceHook struct {
+	prefix string
+}
+
+func (h namespaceHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h namespaceHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.rewrite(cmd)
+		return next(ctx, cmd)
+	}
+}
+
+func (h namespaceHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.rewrite(cmd)
+		}
+		return next(ctx, cmds)
+	}
+}
+
+// rewrite prefixes the asynq keys in the arguments of cmd. Subscriptions do
+// not go through hooks, so published channels such as asynq:cancel are left
+// shared by every namespace; cancellations name task IDs, which are unique.
+func (h namespaceHook) rewrite(cmd redis.Cmder) {
+	switch cmd.Name() {
+	case "publish", "spublish":
+		return
+	}
+	args := cmd.Args()
+	for i := 1; i < len(args); i++ {
+		if s, ok := args[i].(string); ok && strings.HasPrefix(s, asynqKeyPrefix) {
+			args[i] = h.prefix + s
+		}
+	}
+}
+
+// applyNamespace prefixes the keys and channels of workerd's own Redis data
+// with the namespace, as namespaceHook does for asynq's
+func (c *workerConfig) applyNamespace() {
+	ns := c.AsynqConfig.Namespace
+	if ns == "" {
+		return
+	}
+	for _, key := range []*string{
+		&c.Audit.Stream,
+		&c.Events.Channel,
+		&c.History.Key,
+		&c.Maintenance.Key,
+		&c.Registry.Prefix,
+		&c.Usage.Prefix,
+		&c.Checkpoint.Prefix,
+		&c.Scheduler.LeaderKey,
+		&c.Scheduler.EntriesKey,
+	} {
+		if *key != "" && !strings.HasPrefix(*key, ns+":") {
+			*key = ns + ":" + *key
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package workerd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNamespaceHookRewritesAsynqKeys(t *testing.T) {
+	h := namespaceHook{prefix: "staging:"}
+	ctx := context.Background()
+
+	cmd := redis.NewCmd(ctx, "lrange", "asynq:{default}:pending", 0, -1)
+	h.rewrite(cmd)
+	// Names read back from Redis are already prefixed and left alone
+	h.rewrite(cmd)
+	if want := []any{"lrange", "staging:asynq:{default}:pending", 0, -1}; !reflect.DeepEqual(cmd.Args(), want) {
+		t.Errorf("args = %v, want %v", cmd.Args(), want)
+	}
+
+	pub := redis.NewCmd(ctx, "publish", "asynq:cancel", "id")
+	h.rewrite(pub)
+	if pub.Args()[1] != "asynq:cancel" {
+		t.Errorf("published channel rewritten to %v", pub.Args()[1])
+	}
+}
+
+func TestApplyNamespace(t *testing.T) {
+	c := &workerConfig{AsynqConfig: &AsynqConfig{Namespace: "staging"}}
+	c.History.Key = "workerd:history"
+	c.applyNamespace()
+	c.applyNamespace()
+	if c.History.Key != "staging:workerd:history" {
+		t.Errorf("history key = %q, want staging:workerd:history", c.History.Key)
+	}
+	if err := validateNamespace("a{b}"); err == nil {
+		t.Error("namespace with braces accepted")
+	}
+}
@@ -0,0 +1,297 @@
+package workerd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Supported outbox SQL dialects
+const (
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+	DialectSQLite   = "sqlite"
+)
+
+// OutboxConfig configures the transactional outbox. Producers write tasks to
+// the outbox table in their own transaction with Client.EnqueueTx, and the
+// relay started by WithOutbox moves them to Redis.
+type OutboxConfig struct {
+	// "postgres", "mysql" or "sqlite"; selects placeholders and row locking
+	Dialect string `json:"dialect" yaml:"dialect" env:"WORKER_OUTBOX_DIALECT" default:"postgres"`
+
+	// Name of the outbox table
+	Table string `json:"table" yaml:"table" env:"WORKER_OUTBOX_TABLE" default:"workerd_outbox"`
+
+	// How often the relay polls the table
+	Interval time.Duration `json:"interval" yaml:"interval" env:"WORKER_OUTBOX_INTERVAL" default:"1s"`
+
+	// Maximum number of rows relayed per transaction
+	BatchSize int `json:"batchSize" yaml:"batchSize" env:"WORKER_OUTBOX_BATCH_SIZE" default:"100"`
+}
+
+// outboxTablePattern restricts table names since they are spliced into SQL
+var outboxTablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// validate validates the outbox configuration
+func (oc *OutboxConfig) validate() error {
+	switch oc.Dialect {
+	case DialectPostgres, DialectMySQL, DialectSQLite:
+	default:
+		return fmt.Errorf("unknown outbox dialect %q, expected %q, %q or %q", oc.Dialect, DialectPostgres, DialectMySQL, DialectSQLite)
+	}
+	if !outboxTablePattern.MatchString(oc.Table) {
+		return fmt.Errorf("invalid outbox table name %q", oc.Table)
+	}
+	if oc.Interval <= 0 {
+		return fmt.Errorf("outbox interval must be positive, got %v", oc.Interval)
+	}
+	if oc.BatchSize <= 0 {
+		return fmt.Errorf("outbox batch size must be positive, got %d", oc.BatchSize)
+	}
+	return nil
+}
+
+// placeholder returns the n-th (1-based) bind parameter of the dialect
+func (oc *OutboxConfig) placeholder(n int) string {
+	if oc.Dialect == DialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func (oc *OutboxConfig) insertSQL() string {
+	return fmt.Sprintf("INSERT INTO %s (task_type, payload, options, created_at) VALUES (%s, %s, %s, %s)",
+		oc.Table, oc.placeholder(1), oc.placeholder(2), oc.placeholder(3), oc.placeholder(4))
+}
+
+func (oc *OutboxConfig) selectSQL() string {
+	query := fmt.Sprintf("SELECT id, task_type, payload, options FROM %s ORDER BY id LIMIT %s", oc.Table, oc.placeholder(1))
+	// Concurrent relays skip rows another instance is already relaying
+	if oc.Dialect != DialectSQLite {
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+	return query
+}
+
+func (oc *OutboxConfig) deleteSQL() string {
+	return fmt.Sprintf("DELETE FROM %s WHERE id = %s", oc.Table, oc.placeholder(1))
+}
+
+// Execer is satisfied by *sql.Tx, *sql.DB and *sql.Conn
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// EnqueueTx writes task to the outbox table through tx, typically the caller's
+// open transaction, so the task is enqueued if and only if it commits.
+// The relay of a worker started with WithOutbox moves it to Redis.
+func (c *Client) EnqueueTx(ctx context.Context, tx Execer, task *asynq.Task, opts ...asynq.Option) error {
+	if task == nil {
+		return fmt.Errorf("task cannot be nil")
+	}
+	if err := c.config.Outbox.validate(); err != nil {
+		return fmt.Errorf("outbox configuration invalid: %w", err)
+	}
+
+	options, err := encodeOutboxOptions(opts, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, c.config.Outbox.insertSQL(), task.Type(), task.Payload(), options, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to write task to outbox: %w", err)
+	}
+	return nil
+}
+
+// outboxOption is the stored form of an asynq.Option
+type outboxOption struct {
+	Type  asynq.OptionType `json:"t"`
+	Value string           `json:"v"`
+}
+
+// encodeOutboxOptions serializes options. ProcessIn becomes ProcessAt so the
+// delay counts from the original enqueue rather than from the relay.
+func encodeOutboxOptions(opts []asynq.Option, now time.Time) (string, error) {
+	stored := make([]outboxOption, 0, len(opts))
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		o := outboxOption{Type: opt.Type()}
+		switch v := opt.Value().(type) {
+		case int:
+			o.Value = strconv.Itoa(v)
+		case string:
+			o.Value = v
+		case time.Duration:
+			if o.Type == asynq.ProcessInOpt {
+				o.Type, o.Value = asynq.ProcessAtOpt, now.Add(v).Format(time.RFC3339Nano)
+			} else {
+				o.Value = v.String()
+			}
+		case time.Time:
+			o.Value = v.Format(time.RFC3339Nano)
+		default:
+			return "", fmt.Errorf("unsupported outbox option %s", opt)
+		}
+		stored = append(stored, o)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeOutboxOptions restores options written by encodeOutboxOptions
+func decodeOutboxOptions(data string) ([]asynq.Option, error) {
+	var stored []outboxOption
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, err
+	}
+
+	opts := make([]asynq.Option, 0, len(stored))
+	for _, o := range stored {
+		var err error
+		var d time.Duration
+		var t time.Time
+		switch o.Type {
+		case asynq.MaxRetryOpt:
+			var n int
+			n, err = strconv.Atoi(o.Value)
+			opts = append(opts, asynq.MaxRetry(n))
+		case asynq.QueueOpt:
+			opts = append(opts, asynq.Queue(o.Value))
+		case asynq.TaskIDOpt:
+			opts = append(opts, asynq.TaskID(o.Value))
+		case asynq.GroupOpt:
+			opts = append(opts, asynq.Group(o.Value))
+		case asynq.TimeoutOpt:
+			d, err = time.ParseDuration(o.Value)
+			opts = append(opts, asynq.Timeout(d))
+		case asynq.UniqueOpt:
+			d, err = time.ParseDuration(o.Value)
+			opts = append(opts, asynq.Unique(d))
+		case asynq.RetentionOpt:
+			d, err = time.ParseDuration(o.Value)
+			opts = append(opts, asynq.Retention(d))
+		case asynq.ProcessInOpt:
+			d, err = time.ParseDuration(o.Value)
+			opts = append(opts, asynq.ProcessIn(d))
+		case asynq.DeadlineOpt:
+			t, err = time.Parse(time.RFC3339Nano, o.Value)
+			opts = append(opts, asynq.Deadline(t))
+		case asynq.ProcessAtOpt:
+			t, err = time.Parse(time.RFC3339Nano, o.Value)
+			opts = append(opts, asynq.ProcessAt(t))
+		default:
+			return nil, fmt.Errorf("unknown option type %d", o.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid option value %q: %w", o.Value, err)
+		}
+	}
+	return opts, nil
+}
+
+// outboxRelay moves tasks from the outbox table to Redis. Delivery is at
+// least once: a task enqueued just before the relay fails to commit is
+// enqueued again on the next poll, unless it was given a TaskID.
+type outboxRelay struct {
+	db     *sql.DB
+	config *OutboxConfig
+	client func() (*Client, error)
+	log    *slog.Logger
+}
+
+// outboxRow is one pending task
+type outboxRow struct {
+	id       int64
+	taskType string
+	payload  []byte
+	options  string
+}
+
+// run relays batches until the outbox is drained or enqueueing fails
+func (r *outboxRelay) run(ctx context.Context) error {
+	for {
+		n, err := r.relayBatch(ctx)
+		if err != nil || n < r.config.BatchSize {
+			return err
+		}
+	}
+}
+
+// relayBatch enqueues and deletes up to BatchSize rows in one transaction.
+// It stops at the first enqueue failure, committing the rows relayed so far.
+func (r *outboxRelay) relayBatch(ctx context.Context) (int, error) {
+	client, err := r.client()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	batch, err := r.pending(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	relayed := 0
+	var enqueueErr error
+	for _, row := range batch {
+		opts, err := decodeOutboxOptions(row.options)
+		if err != nil {
+			// A corrupt row would otherwise be retried forever
+			r.log.Error("dropping outbox row with invalid options", "id", row.id, "type", row.taskType, "error", err)
+		} else {
+			_, err = client.EnqueueContext(ctx, asynq.NewTask(row.taskType, row.payload), opts...)
+			if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) && !errors.Is(err, asynq.ErrDuplicateTask) {
+				enqueueErr = fmt.Errorf("failed to relay outbox row %d: %w", row.id, err)
+				break
+			}
+		}
+		if _, err := tx.ExecContext(ctx, r.config.deleteSQL(), row.id); err != nil {
+			return relayed, fmt.Errorf("failed to delete outbox row %d: %w", row.id, err)
+		}
+		relayed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox transaction: %w", err)
+	}
+	return relayed, enqueueErr
+}
+
+// pending locks and reads the next batch of rows
+func (r *outboxRelay) pending(ctx context.Context, tx *sql.Tx) ([]outboxRow, error) {
+	rows, err := tx.QueryContext(ctx, r.config.selectSQL(), r.config.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []outboxRow
+	for rows.Next() {
+		var row outboxRow
+		if err := rows.Scan(&row.id, &row.taskType, &row.payload, &row.options); err != nil {
+			return nil, fmt.Errorf("failed to read outbox: %w", err)
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
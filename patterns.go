@@ -0,0 +1,139 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/hibiken/asynq"
+)
+
+// wildcardChars mark a pattern as a wildcard pattern rather than a type prefix:
+// "*" matches any run of characters and "?" exactly one
+const wildcardChars = "*?"
+
+// patternRoute is a handler registered with a wildcard or regular expression
+type patternRoute struct {
+	// Pattern as registered, for Handlers
+	pattern string
+	re      *regexp.Regexp
+	// Number of literal characters; routes with more are more specific
+	literal int
+	handler asynq.Handler
+}
+
+// patternRouter holds the wildcard and regular expression routes
+type patternRouter struct {
+	mu     sync.RWMutex
+	routes []*patternRoute
+}
+
+// add registers a route; a pattern can only be registered once
+func (r *patternRouter) add(route *patternRoute) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.routes {
+		if existing.pattern == route.pattern {
+			return fmt.Errorf("multiple registrations for %s", route.pattern)
+		}
+	}
+	r.routes = append(r.routes, route)
+	return nil
+}
+
+// match returns the most specific route matching the task type, the first
+// registered one on a tie, or nil
+func (r *patternRouter) match(taskType string) *patternRoute {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var best *patternRoute
+	for _, route := range r.routes {
+		if route.re.MatchString(taskType) && (best == nil || route.literal > best.literal) {
+			best = route
+		}
+	}
+	return best
+}
+
+// compileWildcard turns a wildcard pattern into an anchored regular expression
+func compileWildcard(pattern string) (*regexp.Regexp, int) {
+	var b strings.Builder
+	literal := 0
+	b.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			literal++
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()), literal
+}
+
+// Handle registers the handler for pattern. A pattern containing "*" or "?"
+// is a wildcard pattern, e.g. "email:*" or "report:?:daily"; any other
+// pattern is a task type or type prefix routed by the ServeMux. When several
+// patterns match a task, the exact type wins, then the pattern with the most
+// literal characters. Like the ServeMux, it panics on an empty or duplicate
+// pattern.
+func (w *Workerd) Handle(pattern string, handler asynq.Handler) {
+	if !strings.ContainsAny(pattern, wildcardChars) {
+		w.ServeMux.Handle(pattern, handler)
+		return
+	}
+	if handler == nil {
+		panic("workerd: nil handler")
+	}
+	re, literal := compileWildcard(pattern)
+	if err := w.patterns.add(&patternRoute{pattern: pattern, re: re, literal: literal, handler: handler}); err != nil {
+		panic("workerd: " + err.Error())
+	}
+}
+
+// HandleFunc registers the handler function for pattern, see Handle
+func (w *Workerd) HandleFunc(pattern string, handler func(context.Context, *asynq.Task) error) {
+	if handler == nil {
+		panic("workerd: nil handler")
+	}
+	w.Handle(pattern, asynq.HandlerFunc(handler))
+}
+
+// HandleRegexp registers the handler for task types matching the regular
+// expression, e.g. `^(email|sms):send$`. Regular expressions have no literal
+// characters, so any matching type prefix or wildcard pattern takes
+// precedence.
+func (w *Workerd) HandleRegexp(expr string, handler asynq.Handler) error {
+	if handler == nil {
+		return fmt.Errorf("handler cannot be nil")
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid handler pattern %q: %w", expr, err)
+	}
+	return w.patterns.add(&patternRoute{pattern: "/" + expr + "/", re: re, handler: handler})
+}
+
+// route returns the handler for the task: the ServeMux's on an exact match,
+// otherwise the most specific of the ServeMux prefix and the pattern routes,
+// or nil when nothing matches
+func (w *Workerd) route(t *asynq.Task) asynq.Handler {
+	h, prefix := w.ServeMux.Handler(t)
+	if prefix == t.Type() {
+		return h
+	}
+	route := w.patterns.match(t.Type())
+	if route != nil && (prefix == "" || route.literal > len(prefix)) {
+		return route.handler
+	}
+	if prefix != "" {
+		return h
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package workerd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrAlreadyRunning is returned when another instance holds the PID file lock
+var ErrAlreadyRunning = errors.New("another instance is already running")
+
+// pidFile is an exclusively locked file holding the worker's process ID
+type pidFile struct {
+	path string
+	file *os.File
+}
+
+// acquirePIDFile locks the file at path and writes the current PID into it.
+// It fails with ErrAlreadyRunning while another process holds the lock.
+func acquirePIDFile(path string) (*pidFile, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create PID file directory: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PID file: %w", err)
+	}
+
+	if err := lockFile(f); err != nil {
+		owner := readPID(f)
+		f.Close()
+		if owner != "" {
+			return nil, fmt.Errorf("%w: %s is held by pid %s", ErrAlreadyRunning, path, owner)
+		}
+		return nil, fmt.Errorf("%w: %s is locked: %v", ErrAlreadyRunning, path, err)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to truncate PID file: %w", err)
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write PID file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to sync PID file: %w", err)
+	}
+
+	return &pidFile{path: path, file: f}, nil
+}
+
+// release removes the PID file and drops the lock
+func (p *pidFile) release() error {
+	if p == nil || p.file == nil {
+		return nil
+	}
+	removeErr := os.Remove(p.path)
+	closeErr := p.file.Close()
+	p.file = nil
+	if removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove PID file: %w", removeErr)
+	}
+	return closeErr
+}
+
+// readPID returns the PID recorded in the file, if any
+func readPID(f *os.File) string {
+	buf := make([]byte, 32)
+	n, _ := f.ReadAt(buf, 0)
+	return strings.TrimSpace(string(buf[:n]))
+}
+
+// pidFileHeld reports whether a running instance holds the PID file lock
+func pidFileHeld(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return lockFile(f) != nil
+}
@@ -0,0 +1,120 @@
+package workerd
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hibiken/asynq"
+)
+
+// WorkerPoolConfig defines an additional asynq server with its own queues and
+// concurrency, running in the same process as the main worker
+type WorkerPoolConfig struct {
+	Concurrency int            `json:"concurrency" yaml:"concurrency"`
+	Queues      map[string]int `json:"queues" yaml:"queues"`
+}
+
+// validate validates the pool configuration
+func (pc *WorkerPoolConfig) validate() error {
+	if pc.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive, got %d", pc.Concurrency)
+	}
+	if len(pc.Queues) == 0 {
+		return fmt.Errorf("at least one queue is required")
+	}
+	return nil
+}
+
+// workerPool is an extra asynq server sharing the worker's handlers and lifecycle
+type workerPool struct {
+	name   string
+	config WorkerPoolConfig
+	srv    *asynq.Server
+}
+
+// AddWorkerPool adds an asynq server that processes queues with its own
+// concurrency, isolating heavy task types from light ones. Pools share the
+// worker's handlers, middleware and Redis, and are started and stopped with it.
+// Queues consumed by a pool should usually be left out of the main queues.
+func (w *Workerd) AddWorkerPool(name string, queues map[string]int, concurrency int) error {
+	return w.addWorkerPool(name, WorkerPoolConfig{Concurrency: concurrency, Queues: queues}, nil)
+}
+
+// addWorkerPool adds a pool consuming from redisOpt, or from the worker's
+// Redis when nil
+func (w *Workerd) addWorkerPool(name string, config WorkerPoolConfig, redisOpt asynq.RedisConnOpt) error {
+	if redisOpt == nil {
+		redisOpt = w.redisOpt
+	}
+	if name == "" {
+		return fmt.Errorf("worker pool name cannot be empty")
+	}
+	if w.running.Load() {
+		return fmt.Errorf("worker pool %q must be added before the service starts", name)
+	}
+	for _, p := range w.pools {
+		if p.name == name {
+			return fmt.Errorf("worker pool %q already exists", name)
+		}
+	}
+	if err := config.validate(); err != nil {
+		return fmt.Errorf("worker pool %q: %w", name, err)
+	}
+
+	builder, err := NewServerBuilder(w.config)
+	if err != nil {
+		return fmt.Errorf("failed to create server builder: %w", err)
+	}
+	srv, err := builder.WithQueues(config.Queues).WithRedisConnOpt(redisOpt).WithAsynqConfig(w.asynqConfigHooks()...).BuildServer(config.Concurrency)
+	if err != nil {
+		return fmt.Errorf("failed to build worker pool %q: %w", name, err)
+	}
+
+	w.pools = append(w.pools, &workerPool{name: name, config: config, srv: srv})
+	return nil
+}
+
+// addConfiguredPools adds the pools declared in the configuration, in name order
+func (w *Workerd) addConfiguredPools(pools map[string]WorkerPoolConfig) error {
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := w.addWorkerPool(name, pools[name], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startPools starts every pool with handler; on failure the pools already
+// started are shut down again
+func (w *Workerd) startPools(handler asynq.Handler) error {
+	for i, p := range w.pools {
+		if err := p.srv.Start(handler); err != nil {
+			for _, started := range w.pools[:i] {
+				started.srv.Shutdown()
+			}
+			return fmt.Errorf("failed to start worker pool %q: %w", p.name, err)
+		}
+		w.log.Info("worker pool started", "pool", p.name, "concurrency", p.config.Concurrency, "queues", p.config.Queues)
+	}
+	return nil
+}
+
+// shutdownPools shuts all pools down concurrently, waiting for active tasks
+func (w *Workerd) shutdownPools() {
+	var wg sync.WaitGroup
+	for _, p := range w.pools {
+		wg.Add(1)
+		go func(p *workerPool) {
+			defer wg.Done()
+			p.srv.Shutdown()
+		}(p)
+	}
+	wg.Wait()
+}
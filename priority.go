@@ -0,0 +1,97 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Priority is the urgency of a task, mapped to a queue by the priorities config
+type Priority string
+
+// Enqueue priorities
+const (
+	PriorityHigh   Priority = "high"
+	PriorityNormal Priority = "normal"
+	PriorityLow    Priority = "low"
+)
+
+// PriorityRoute is where and how tasks of one priority are enqueued
+type PriorityRoute struct {
+	// Target queue; empty uses "critical", "default" or "low"
+	Queue string `json:"queue" yaml:"queue"`
+
+	// Retries of the task; 0 keeps the default
+	MaxRetry int `json:"maxRetry" yaml:"maxRetry"`
+
+	// Handler timeout; 0 keeps the default
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// PriorityConfig maps priorities to queues so producers enqueue by urgency
+// instead of hardcoding queue names. Workers must process the queues.
+type PriorityConfig struct {
+	High   PriorityRoute `json:"high" yaml:"high"`
+	Normal PriorityRoute `json:"normal" yaml:"normal"`
+	Low    PriorityRoute `json:"low" yaml:"low"`
+}
+
+// validate validates the priority routes
+func (pc *PriorityConfig) validate() error {
+	for _, p := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		r, _ := pc.route(p)
+		if r.MaxRetry < 0 {
+			return fmt.Errorf("%s priority max retry must be non-negative, got %d", p, r.MaxRetry)
+		}
+		if r.Timeout < 0 {
+			return fmt.Errorf("%s priority timeout must be non-negative, got %v", p, r.Timeout)
+		}
+	}
+	return nil
+}
+
+// route returns the route of p with its default queue filled in
+func (pc *PriorityConfig) route(p Priority) (PriorityRoute, error) {
+	var r PriorityRoute
+	var queue string
+	switch p {
+	case PriorityHigh:
+		r, queue = pc.High, "critical"
+	case PriorityNormal:
+		r, queue = pc.Normal, "default"
+	case PriorityLow:
+		r, queue = pc.Low, "low"
+	default:
+		return r, fmt.Errorf("unknown priority %q, expected %q, %q or %q", p, PriorityHigh, PriorityNormal, PriorityLow)
+	}
+	if r.Queue == "" {
+		r.Queue = queue
+	}
+	return r, nil
+}
+
+// options returns the enqueue options of the route
+func (r PriorityRoute) options() []asynq.Option {
+	opts := []asynq.Option{asynq.Queue(r.Queue)}
+	if r.MaxRetry > 0 {
+		opts = append(opts, asynq.MaxRetry(r.MaxRetry))
+	}
+	if r.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(r.Timeout))
+	}
+	return opts
+}
+
+// EnqueuePriority enqueues task on the queue configured for priority p.
+// The route's options come before opts, so explicit options override them,
+// and the priority is recorded in the task metadata.
+func (c *Client) EnqueuePriority(ctx context.Context, p Priority, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	r, err := c.config.Priorities.route(p)
+	if err != nil {
+		return nil, err
+	}
+	ctx = WithMetadata(ctx, Metadata{MetaPriority: string(p)})
+	return c.EnqueueContext(ctx, task, append(r.options(), opts...)...)
+}
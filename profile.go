@@ -0,0 +1,211 @@
+package workerd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const profileCommandUsage = "profile [-type cpu,heap] [-duration 30s] [-dir .] [-addr host:port] [-cacert ca.pem] [-cert client.pem -key client-key.pem]  capture profiles from the running worker's admin API"
+
+// profileTypes are the profiles the profile command captures; cpu and trace
+// are sampled over the duration, the others are snapshots
+var profileTypes = []string{"cpu", "heap", "allocs", "goroutine", "block", "mutex", "threadcreate", "trace"}
+
+// registerProfiling serves net/http/pprof under /debug/pprof/
+func (a *adminServer) registerProfiling() {
+	a.mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	a.mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	a.mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	a.mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	a.mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	a.mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+}
+
+// profilePath returns the admin API path of a profile
+func profilePath(profile string, d time.Duration) (string, error) {
+	seconds := strconv.Itoa(max(int(d.Seconds()), 1))
+	switch profile {
+	case "cpu":
+		return "/debug/pprof/profile?seconds=" + seconds, nil
+	case "trace":
+		return "/debug/pprof/trace?seconds=" + seconds, nil
+	default:
+		for _, p := range profileTypes {
+			if p == profile {
+				return "/debug/pprof/" + profile, nil
+			}
+		}
+		return "", fmt.Errorf("unknown profile %q (valid profiles: %s)", profile, strings.Join(profileTypes, ", "))
+	}
+}
+
+// adminURL returns the base URL of the admin API listening on addr, using
+// the loopback address when it listens on all interfaces. Requests to a Unix
+// socket are sent through adminTransport.
+func adminURL(addr string, useTLS bool) (string, error) {
+	scheme := "http://"
+	if useTLS {
+		scheme = "https://"
+	}
+	if network, _ := listenNetwork(addr); network == "unix" {
+		return scheme + "localhost", nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid admin address %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return scheme + net.JoinHostPort(host, port), nil
+}
+
+// adminTransport returns the transport connecting to the admin API on addr
+func adminTransport(addr string, config *tls.Config) *http.Transport {
+	transport := &http.Transport{TLSClientConfig: config}
+	if network, path := listenNetwork(addr); network == "unix" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+	}
+	return transport
+}
+
+// adminClientTLS returns the TLS configuration verifying the admin API with
+// the CA in caFile, or the system roots when empty, and presenting the client
+// certificate in certFile and keyFile when set
+func adminClientTLS(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// runProfileCommand implements `profile`
+func runProfileCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+	types := fs.String("type", "cpu,heap", "Comma separated profiles: "+strings.Join(profileTypes, ", "))
+	duration := fs.Duration("duration", 30*time.Second, "How long cpu and trace profiles sample")
+	dir := fs.String("dir", ".", "Directory the profiles are written to")
+	addr := fs.String("addr", w.config.Admin.Addr, "Admin API address of the worker, host:port or unix:/path/to.sock")
+	caFile := fs.String("cacert", "", "CA verifying the admin API certificate, when it serves TLS")
+	certFile := fs.String("cert", "", "Client certificate, when the admin API requires one")
+	keyFile := fs.String("key", "", "Key of the client certificate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: %s", profileCommandUsage)
+	}
+
+	useTLS := w.config.Admin.TLS.enabled()
+	base, err := adminURL(*addr, useTLS)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	profiles := strings.Split(*types, ",")
+	for i, p := range profiles {
+		profiles[i] = strings.TrimSpace(p)
+		path, err := profilePath(profiles[i], *duration)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+
+	var config *tls.Config
+	if useTLS {
+		if config, err = adminClientTLS(*caFile, *certFile, *keyFile); err != nil {
+			return err
+		}
+	}
+	client := &http.Client{Transport: adminTransport(*addr, config), Timeout: *duration + 30*time.Second}
+	token := w.config.Admin.Token
+	if token == "" && len(w.config.Admin.Tokens) > 0 {
+		token = w.config.Admin.Tokens[0]
+	}
+	stamp := time.Now().UTC().Format("20060102T150405")
+	for i, p := range profiles {
+		file := filepath.Join(*dir, fmt.Sprintf("%s-%s-%s.pprof", w.name, p, stamp))
+		if p == "trace" {
+			file = strings.TrimSuffix(file, ".pprof") + ".trace"
+		}
+		if p == "cpu" || p == "trace" {
+			fmt.Printf("capturing %s profile for %v...\n", p, *duration)
+		}
+		if err := fetchProfile(client, base+paths[i], token, file); err != nil {
+			return fmt.Errorf("failed to capture %s profile: %w", p, err)
+		}
+		fmt.Printf("wrote %s\n", file)
+	}
+	return nil
+}
+
+// fetchProfile downloads a profile from the admin API to file
+func fetchProfile(client *http.Client, url, token, file string) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return fmt.Errorf("profiling is not enabled on the admin API (admin.pprof)")
+	case http.StatusUnauthorized:
+		return fmt.Errorf("admin API rejected the token")
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("admin API returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
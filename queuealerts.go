@@ -0,0 +1,152 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// QueueAlertRule raises an alert when a queue has too many pending tasks for too long
+type QueueAlertRule struct {
+	// Queue name, or "*" for queues without a rule of their own
+	Queue string `json:"queue" yaml:"queue"`
+
+	// Pending tasks above which the queue is backed up
+	MaxPending int `json:"maxPending" yaml:"maxPending"`
+
+	// How long the queue must stay backed up before the alert is sent
+	For time.Duration `json:"for" yaml:"for"`
+
+	// "warning" (default) or "critical"
+	Severity string `json:"severity" yaml:"severity"`
+}
+
+// queueAlertRuleFor returns the rule of a queue, falling back to the "*" rule
+func queueAlertRuleFor(rules []QueueAlertRule, queue string) *QueueAlertRule {
+	var fallback *QueueAlertRule
+	for i := range rules {
+		switch rules[i].Queue {
+		case queue:
+			return &rules[i]
+		case "*":
+			fallback = &rules[i]
+		}
+	}
+	return fallback
+}
+
+// queueMonitor compares queue depths to their thresholds and sends an alert
+// when a queue stays over it, and a resolution once it drains. It only checks
+// on the scheduler leader so the fleet alerts once.
+type queueMonitor struct {
+	rules     []QueueAlertRule
+	queues    func() ([]string, error)
+	inspector func(queue string) *asynq.Inspector
+	leader    func() bool
+	alerts    *alerter
+	log       *slog.Logger
+	now       func() time.Time
+
+	// over holds since when each queue has been over its threshold
+	over map[string]time.Time
+	// firing holds the queues alerted on and not resolved yet
+	firing map[string]bool
+}
+
+func newQueueMonitor(config *AlertsConfig, w *Workerd) *queueMonitor {
+	return &queueMonitor{
+		rules:     config.Queues,
+		queues:    w.queueNames,
+		inspector: w.readQueueInspector,
+		leader:    w.IsLeader,
+		alerts:    w.alerts,
+		log:       w.log,
+		now:       time.Now,
+		over:      make(map[string]time.Time),
+		firing:    make(map[string]bool),
+	}
+}
+
+// check reads the depth of every queue with a rule
+func (m *queueMonitor) check(ctx context.Context) error {
+	if !m.leader() {
+		// Only the leader tracks queues; state is rebuilt after taking over
+		clear(m.over)
+		clear(m.firing)
+		return nil
+	}
+	queues, err := m.queues()
+	if err != nil {
+		return fmt.Errorf("failed to list queues: %w", err)
+	}
+	for _, q := range queues {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rule := queueAlertRuleFor(m.rules, q)
+		if rule == nil {
+			continue
+		}
+		info, err := m.inspector(q).GetQueueInfo(q)
+		if err != nil {
+			m.log.Warn("could not read queue depth", "queue", q, "error", err)
+			continue
+		}
+		m.observe(rule, q, info.Pending)
+	}
+	return nil
+}
+
+// observe records the pending count of a queue and alerts on changes
+func (m *queueMonitor) observe(rule *QueueAlertRule, queue string, pending int) {
+	now := m.now()
+	if pending <= rule.MaxPending {
+		delete(m.over, queue)
+		if m.firing[queue] {
+			delete(m.firing, queue)
+			m.log.Info("queue backlog cleared", "queue", queue, "pending", pending)
+			m.alerts.send(m.alert(rule, queue, pending, true))
+		}
+		return
+	}
+
+	since, ok := m.over[queue]
+	if !ok {
+		since = now
+		m.over[queue] = now
+	}
+	if m.firing[queue] || now.Sub(since) < rule.For {
+		return
+	}
+	m.firing[queue] = true
+	m.log.Warn("queue backed up", "queue", queue, "pending", pending, "max_pending", rule.MaxPending,
+		"for", now.Sub(since).Round(time.Second))
+	m.alerts.send(m.alert(rule, queue, pending, false))
+}
+
+func (m *queueMonitor) alert(rule *QueueAlertRule, queue string, pending int, resolved bool) Alert {
+	severity := rule.Severity
+	if severity == "" {
+		severity = AlertWarning
+	}
+	summary := fmt.Sprintf("queue %s has %d pending tasks, over %d for %v", queue, pending, rule.MaxPending, rule.For)
+	if resolved {
+		summary = fmt.Sprintf("queue %s is back to %d pending tasks", queue, pending)
+	}
+	return Alert{
+		Source:   "queue",
+		Severity: severity,
+		Key:      "queue:" + queue,
+		Summary:  summary,
+		Resolved: resolved,
+		Labels: map[string]string{
+			"queue":       queue,
+			"pending":     strconv.Itoa(pending),
+			"max_pending": strconv.Itoa(rule.MaxPending),
+		},
+	}
+}
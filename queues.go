@@ -0,0 +1,94 @@
+package workerd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const queuesCommandUsage = "queues list|pause <queue>|resume <queue>  inspect and pause or resume queues"
+
+// PauseQueue stops all workers from consuming the queue until it is resumed
+func (w *Workerd) PauseQueue(queue string) error {
+	if queue == "" {
+		return fmt.Errorf("queue name cannot be empty")
+	}
+	if err := w.QueueInspector(queue).PauseQueue(queue); err != nil {
+		return fmt.Errorf("failed to pause queue %q: %w", queue, err)
+	}
+	w.log.Info("queue paused", "queue", queue)
+	return nil
+}
+
+// ResumeQueue lets workers consume a paused queue again
+func (w *Workerd) ResumeQueue(queue string) error {
+	if queue == "" {
+		return fmt.Errorf("queue name cannot be empty")
+	}
+	if err := w.QueueInspector(queue).UnpauseQueue(queue); err != nil {
+		return fmt.Errorf("failed to resume queue %q: %w", queue, err)
+	}
+	w.log.Info("queue resumed", "queue", queue)
+	return nil
+}
+
+// RequeueTask moves a scheduled, retry or archived task back to pending so it runs immediately
+func (w *Workerd) RequeueTask(queue, id string) error {
+	if queue == "" || id == "" {
+		return fmt.Errorf("queue name and task ID cannot be empty")
+	}
+	if err := w.QueueInspector(queue).RunTask(queue, id); err != nil {
+		return fmt.Errorf("failed to requeue task %q in queue %q: %w", id, queue, err)
+	}
+	w.log.Info("task requeued", "queue", queue, "task_id", id)
+	return nil
+}
+
+// checkHealth reports whether the broker can be reached
+func (w *Workerd) checkHealth() error {
+	if _, err := w.Inspector().Queues(); err != nil {
+		return fmt.Errorf("redis unreachable: %w", err)
+	}
+	return nil
+}
+
+// runQueuesCommand implements `queues`
+func runQueuesCommand(w *Workerd, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", queuesCommandUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return listQueues(w)
+	case "pause", "resume":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: %s", queuesCommandUsage)
+		}
+		if args[0] == "pause" {
+			return w.PauseQueue(args[1])
+		}
+		return w.ResumeQueue(args[1])
+	default:
+		return fmt.Errorf("unknown queues action %q (valid actions: list, pause, resume)", args[0])
+	}
+}
+
+// listQueues prints every queue with its paused state
+func listQueues(w *Workerd) error {
+	queues, err := w.queueNames()
+	if err != nil {
+		return fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUEUE\tPAUSED\tSIZE")
+	for _, q := range queues {
+		info, err := w.readQueueInspector(q).GetQueueInfo(q)
+		if err != nil {
+			return fmt.Errorf("failed to inspect queue %q: %w", q, err)
+		}
+		fmt.Fprintf(tw, "%s\t%t\t%d\n", q, info.Paused, info.Size)
+	}
+	return tw.Flush()
+}
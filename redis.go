@@ -0,0 +1,175 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Startup behaviours when Redis is unreachable
+const (
+	RedisStartupFail = "fail"
+	RedisStartupWait = "wait"
+)
+
+// ConnectionConfig sets how the worker watches its Redis connection and
+// reacts to Redis being unreachable
+type ConnectionConfig struct {
+	// "fail" aborts startup when Redis does not answer; "wait" retries with
+	// backoff until it does
+	Startup string `json:"startup" yaml:"startup" env:"ASYNQ_REDIS_STARTUP" default:"fail"`
+
+	// How often the connection is checked while the worker runs
+	CheckInterval time.Duration `json:"checkInterval" yaml:"checkInterval" env:"ASYNQ_REDIS_CHECK_INTERVAL" default:"5s"`
+
+	// First delay between startup attempts, doubled up to MaxBackoff
+	Backoff time.Duration `json:"backoff" yaml:"backoff" env:"ASYNQ_REDIS_BACKOFF" default:"1s"`
+
+	// Longest delay between startup attempts
+	MaxBackoff time.Duration `json:"maxBackoff" yaml:"maxBackoff" env:"ASYNQ_REDIS_MAX_BACKOFF" default:"30s"`
+
+	// How long startup waits for Redis before failing; 0 waits indefinitely
+	WaitTimeout time.Duration `json:"waitTimeout" yaml:"waitTimeout" env:"ASYNQ_REDIS_WAIT_TIMEOUT" default:"0s"`
+}
+
+// validate validates the connection configuration
+func (cc *ConnectionConfig) validate() error {
+	switch cc.Startup {
+	case RedisStartupFail, RedisStartupWait:
+	default:
+		return fmt.Errorf("unknown redis startup behaviour %q, expected %q or %q", cc.Startup, RedisStartupFail, RedisStartupWait)
+	}
+	if cc.CheckInterval <= 0 {
+		return fmt.Errorf("redis check interval must be positive, got %v", cc.CheckInterval)
+	}
+	if cc.Backoff <= 0 || cc.MaxBackoff < cc.Backoff {
+		return fmt.Errorf("redis backoff must be positive and not above max backoff, got %v and %v", cc.Backoff, cc.MaxBackoff)
+	}
+	if cc.WaitTimeout < 0 {
+		return fmt.Errorf("redis wait timeout must be non-negative, got %v", cc.WaitTimeout)
+	}
+	return nil
+}
+
+// redisMonitor tracks whether Redis answers, logging and reporting changes
+type redisMonitor struct {
+	w *Workerd
+
+	mu        sync.Mutex
+	connected bool
+	since     time.Time
+	failures  int
+	lastErr   error
+}
+
+func newRedisMonitor(w *Workerd) *redisMonitor {
+	return &redisMonitor{w: w, connected: true, since: time.Now()}
+}
+
+// check pings Redis once and records the result
+func (m *redisMonitor) check(ctx context.Context) error {
+	m.record(m.w.checkHealth())
+	m.w.reportRedisPools()
+	return nil
+}
+
+// record updates the connection state with the result of a ping
+func (m *redisMonitor) record(err error) {
+	m.mu.Lock()
+	wasConnected, since := m.connected, m.since
+	m.lastErr = err
+	if err != nil {
+		m.failures++
+		if wasConnected {
+			m.connected, m.since = false, time.Now()
+		}
+	} else {
+		m.failures = 0
+		if !wasConnected {
+			m.connected, m.since = true, time.Now()
+		}
+	}
+	failures := m.failures
+	m.mu.Unlock()
+
+	log := m.w.log
+	switch {
+	case err != nil && wasConnected:
+		log.Error("redis unreachable", "addr", m.w.config.AsynqConfig.RedisClient.target(), "error", err)
+		m.w.emit(LifecycleRedisDisconnected, "", err)
+	case err != nil && failures&(failures-1) == 0:
+		// report the outage again after 2, 4, 8... failed checks
+		log.Warn("redis still unreachable", "down_for", time.Since(since).Round(time.Second), "checks", failures, "error", err)
+	case err == nil && !wasConnected:
+		log.Info("redis connection restored", "down_for", time.Since(since).Round(time.Second))
+		m.w.emit(LifecycleRedisReconnected, "", nil)
+	}
+	if m.w.metrics != nil && (err == nil) != wasConnected {
+		m.w.metrics.redisConnected(err == nil)
+	}
+}
+
+// RedisStatus describes the Redis connection as last checked
+type RedisStatus struct {
+	Connected bool      `json:"connected"`
+	Since     time.Time `json:"since"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// RedisStatus returns the state of the Redis connection as of the last check
+func (w *Workerd) RedisStatus() RedisStatus {
+	m := w.redisMonitor
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := RedisStatus{Connected: m.connected, Since: m.since.UTC()}
+	if m.lastErr != nil {
+		s.Error = m.lastErr.Error()
+	}
+	return s
+}
+
+// WithWaitForRedis makes startup wait up to timeout for Redis to answer,
+// retrying with backoff, instead of the asynq.connection startup behaviour
+func WithWaitForRedis(timeout time.Duration) Option {
+	return func(w *Workerd) {
+		w.waitForRedis = timeout
+	}
+}
+
+// awaitRedis checks Redis before the worker starts: it fails right away or
+// retries with backoff until the wait timeout, depending on the startup
+// behaviour
+func (w *Workerd) awaitRedis() error {
+	cc := &w.config.AsynqConfig.Connection
+	addr := w.config.AsynqConfig.RedisClient.target()
+	startup, timeout := cc.Startup, cc.WaitTimeout
+	if w.waitForRedis > 0 {
+		startup, timeout = RedisStartupWait, w.waitForRedis
+	}
+
+	begin := time.Now()
+	backoff := cc.Backoff
+	for attempt := 1; ; attempt++ {
+		err := w.checkHealth()
+		if err == nil {
+			if attempt > 1 {
+				w.log.Info("redis is available", "addr", addr, "waited", time.Since(begin).Round(time.Millisecond))
+			}
+			return nil
+		}
+		if startup == RedisStartupFail {
+			return fmt.Errorf("%w (address %s)", err, addr)
+		}
+		if timeout > 0 {
+			left := timeout - time.Since(begin)
+			if left <= 0 {
+				return fmt.Errorf("gave up waiting %v for redis at %s: %w", timeout, addr, err)
+			}
+			backoff = min(backoff, left)
+		}
+		w.log.Warn("waiting for redis", "addr", addr, "attempt", attempt, "retry_in", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, cc.MaxBackoff)
+	}
+}
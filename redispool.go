@@ -0,0 +1,217 @@
+package workerd
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// validatePool validates the connection pool settings
+func (rc *RedisClient) validatePool() error {
+	if rc.PoolSize <= 0 {
+		return fmt.Errorf("redis pool size must be positive, got %d", rc.PoolSize)
+	}
+	if rc.MinIdleConns < 0 || rc.MinIdleConns > rc.PoolSize {
+		return fmt.Errorf("redis min idle connections must be between 0 and the pool size %d, got %d", rc.PoolSize, rc.MinIdleConns)
+	}
+	if rc.PoolTimeout < 0 {
+		return fmt.Errorf("redis pool timeout must be non-negative, got %v", rc.PoolTimeout)
+	}
+	return nil
+}
+
+// options returns the go-redis options of a connection to addr
+func (rc *RedisClient) options(network, addr string) *redis.Options {
+	return &redis.Options{
+		Network:         network,
+		Addr:            addr,
+		Username:        rc.Username,
+		Password:        rc.Password,
+		DB:              rc.DB,
+		DialTimeout:     rc.DialTimeout,
+		ReadTimeout:     rc.ReadTimeout,
+		WriteTimeout:    rc.WriteTimeout,
+		PoolSize:        rc.PoolSize,
+		MinIdleConns:    rc.MinIdleConns,
+		PoolTimeout:     rc.PoolTimeout,
+		ConnMaxIdleTime: rc.ConnMaxIdleTime,
+	}
+}
+
+// failoverOptions returns the go-redis options of a connection through
+// Sentinel to the primary, or to one of its replicas
+func (rc *RedisClient) failoverOptions(replicaOnly bool) *redis.FailoverOptions {
+	return &redis.FailoverOptions{
+		MasterName:       rc.Sentinel.MasterName,
+		SentinelAddrs:    rc.Sentinel.Addrs,
+		SentinelUsername: rc.Sentinel.Username,
+		SentinelPassword: rc.Sentinel.Password,
+		ReplicaOnly:      replicaOnly,
+		Username:         rc.Username,
+		Password:         rc.Password,
+		DB:               rc.DB,
+		DialTimeout:      rc.DialTimeout,
+		ReadTimeout:      rc.ReadTimeout,
+		WriteTimeout:     rc.WriteTimeout,
+		PoolSize:         rc.PoolSize,
+		MinIdleConns:     rc.MinIdleConns,
+		PoolTimeout:      rc.PoolTimeout,
+		ConnMaxIdleTime:  rc.ConnMaxIdleTime,
+	}
+}
+
+// redisClientOpt makes clients from go-redis options, which unlike asynq's
+// options cover every pool setting
+type redisClientOpt struct {
+	opt *redis.Options
+}
+
+// MakeRedisClient implements asynq.RedisConnOpt
+func (o redisClientOpt) MakeRedisClient() interface{} {
+	return redis.NewClient(o.opt)
+}
+
+// redisFailoverOpt is redisClientOpt through Sentinel, which can also
+// connect to replicas
+type redisFailoverOpt struct {
+	opt *redis.FailoverOptions
+}
+
+// MakeRedisClient implements asynq.RedisConnOpt
+func (o redisFailoverOpt) MakeRedisClient() interface{} {
+	return redis.NewFailoverClient(o.opt)
+}
+
+// RedisPoolStats sums the connection pools of the clients connected to one
+// Redis server. Timeouts counts commands that found no free connection
+// within the pool timeout, the "connection pool timeout" errors.
+type RedisPoolStats struct {
+	// Most connections the pools may open together
+	Size     int    `json:"size"`
+	Total    uint32 `json:"total"`
+	Idle     uint32 `json:"idle"`
+	Stale    uint32 `json:"stale"`
+	Hits     uint32 `json:"hits"`
+	Misses   uint32 `json:"misses"`
+	Timeouts uint32 `json:"timeouts"`
+}
+
+// redisPools tracks the clients made through the worker's connection
+// options, asynq's included, to report the usage of their pools
+type redisPools struct {
+	mu      sync.Mutex
+	clients map[string]map[*trackedClient]bool
+	// Counts of the closed clients, so totals do not go down
+	closed map[string]RedisPoolStats
+}
+
+func newRedisPools() *redisPools {
+	return &redisPools{clients: make(map[string]map[*trackedClient]bool), closed: make(map[string]RedisPoolStats)}
+}
+
+// track returns opt recording the clients it makes under name, e.g.
+// "primary", with pools of size connections
+func (p *redisPools) track(opt asynq.RedisConnOpt, name string, size int) asynq.RedisConnOpt {
+	return trackedOpt{RedisConnOpt: opt, pools: p, name: name, size: size}
+}
+
+// stats returns the pool usage of the clients of every name
+func (p *redisPools) stats() map[string]RedisPoolStats {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]RedisPoolStats, len(p.clients))
+	for name, clients := range p.clients {
+		s := p.closed[name]
+		for c := range clients {
+			ps := c.PoolStats()
+			s.Size += c.size
+			s.Total += ps.TotalConns
+			s.Idle += ps.IdleConns
+			s.Stale += ps.StaleConns
+			s.Hits += ps.Hits
+			s.Misses += ps.Misses
+			s.Timeouts += ps.Timeouts
+		}
+		stats[name] = s
+	}
+	return stats
+}
+
+// trackedOpt records the clients it makes in pools
+type trackedOpt struct {
+	asynq.RedisConnOpt
+	pools *redisPools
+	name  string
+	size  int
+}
+
+// MakeRedisClient implements asynq.RedisConnOpt
+func (o trackedOpt) MakeRedisClient() interface{} {
+	c := &trackedClient{
+		UniversalClient: o.RedisConnOpt.MakeRedisClient().(redis.UniversalClient),
+		pools:           o.pools,
+		name:            o.name,
+		size:            o.size,
+	}
+	o.pools.mu.Lock()
+	if o.pools.clients[o.name] == nil {
+		o.pools.clients[o.name] = make(map[*trackedClient]bool)
+	}
+	o.pools.clients[o.name][c] = true
+	o.pools.mu.Unlock()
+	return c
+}
+
+// trackedClient stops being tracked once closed, e.g. the scheduler's client
+// when leadership moves
+type trackedClient struct {
+	redis.UniversalClient
+	pools *redisPools
+	name  string
+	size  int
+}
+
+// Close closes the client, keeping its counts in the totals
+func (c *trackedClient) Close() error {
+	p := c.pools
+	p.mu.Lock()
+	if p.clients[c.name][c] {
+		delete(p.clients[c.name], c)
+		ps, closed := c.PoolStats(), p.closed[c.name]
+		closed.Hits += ps.Hits
+		closed.Misses += ps.Misses
+		closed.Timeouts += ps.Timeouts
+		p.closed[c.name] = closed
+	}
+	p.mu.Unlock()
+	return c.UniversalClient.Close()
+}
+
+// RedisPoolStats returns the connection pool usage of the worker's clients
+// by Redis server: "primary", "replica" and the address of each shard
+func (w *Workerd) RedisPoolStats() map[string]RedisPoolStats {
+	return w.redisPools.stats()
+}
+
+// reportRedisPools passes the pool usage of every Redis server to the
+// metrics exporter, in name order
+func (w *Workerd) reportRedisPools() {
+	if w.metrics == nil {
+		return
+	}
+	stats := w.redisPools.stats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.metrics.redisPool(name, stats[name])
+	}
+}
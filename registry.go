@@ -0,0 +1,250 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+const workersCommandUsage = "workers [-queue name]  list live workers in the fleet"
+
+// RegistryConfig configures the fleet registry where every running worker
+// announces itself with a heartbeat
+type RegistryConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_REGISTRY_ENABLED" default:"true"`
+
+	// Prefix of the Redis keys holding the registry
+	Prefix string `json:"prefix" yaml:"prefix" env:"WORKER_REGISTRY_PREFIX" default:"workerd:workers"`
+
+	// How often a worker refreshes its entry
+	Interval time.Duration `json:"interval" yaml:"interval" env:"WORKER_REGISTRY_INTERVAL" default:"10s"`
+
+	// How long an entry survives without a heartbeat, e.g. after a crash
+	TTL time.Duration `json:"ttl" yaml:"ttl" env:"WORKER_REGISTRY_TTL" default:"30s"`
+}
+
+// validate validates the registry configuration
+func (rc *RegistryConfig) validate() error {
+	if !rc.Enabled {
+		return nil
+	}
+	if rc.Prefix == "" {
+		return fmt.Errorf("registry prefix cannot be empty")
+	}
+	if rc.Interval <= 0 {
+		return fmt.Errorf("registry interval must be positive, got %v", rc.Interval)
+	}
+	if rc.TTL <= rc.Interval {
+		return fmt.Errorf("registry TTL must be longer than the interval, got %v", rc.TTL)
+	}
+	return nil
+}
+
+// WorkerInfo describes a live worker instance
+type WorkerInfo struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Version     string         `json:"version"`
+	Host        string         `json:"host"`
+	PID         int            `json:"pid"`
+	Queues      map[string]int `json:"queues"`
+	Concurrency int            `json:"concurrency"`
+	Active      int64          `json:"active"`
+	Draining    bool           `json:"draining"`
+	Leader      bool           `json:"leader"`
+	Started     time.Time      `json:"started"`
+	Heartbeat   time.Time      `json:"heartbeat"`
+}
+
+// workerRegistry stores one expiring entry per worker, indexed by a sorted
+// set scored by expiry so dead workers can be pruned without a key scan
+type workerRegistry struct {
+	rdb    redis.UniversalClient
+	config *RegistryConfig
+}
+
+func newWorkerRegistry(config *RegistryConfig, redisOpt asynq.RedisConnOpt) (*workerRegistry, error) {
+	rdb, ok := redisOpt.MakeRedisClient().(redis.UniversalClient)
+	if !ok {
+		return nil, fmt.Errorf("unsupported redis connection for the worker registry")
+	}
+	return &workerRegistry{rdb: rdb, config: config}, nil
+}
+
+func (r *workerRegistry) key(id string) string {
+	return r.config.Prefix + ":" + id
+}
+
+// register writes or refreshes the entry of a worker
+func (r *workerRegistry) register(ctx context.Context, info WorkerInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	expires := info.Heartbeat.Add(r.config.TTL)
+	_, err = r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, r.key(info.ID), data, r.config.TTL)
+		p.ZAdd(ctx, r.config.Prefix, redis.Z{Score: float64(expires.Unix()), Member: info.ID})
+		return nil
+	})
+	return err
+}
+
+// deregister removes the entry of a stopped worker
+func (r *workerRegistry) deregister(ctx context.Context, id string) error {
+	_, err := r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, r.key(id))
+		p.ZRem(ctx, r.config.Prefix, id)
+		return nil
+	})
+	return err
+}
+
+// list returns the live workers, pruning expired index entries
+func (r *workerRegistry) list(ctx context.Context) ([]WorkerInfo, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := r.rdb.ZRemRangeByScore(ctx, r.config.Prefix, "-inf", "("+now).Err(); err != nil {
+		return nil, err
+	}
+	ids, err := r.rdb.ZRange(ctx, r.config.Prefix, 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.key(id)
+	}
+	values, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make([]WorkerInfo, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var info WorkerInfo
+		if err := json.Unmarshal([]byte(s), &info); err != nil {
+			continue
+		}
+		workers = append(workers, info)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers, nil
+}
+
+func (r *workerRegistry) close() error {
+	return r.rdb.Close()
+}
+
+// buildVersion returns the module version the binary was built from
+func buildVersion() string {
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	return "unknown"
+}
+
+// workerInfo describes this instance for the registry
+func (w *Workerd) workerInfo() WorkerInfo {
+	queues := maps.Clone(w.config.Queues)
+	if len(queues) == 0 {
+		queues = map[string]int{"default": 1}
+	}
+	concurrency := w.concurrency
+	for _, p := range w.pools {
+		maps.Copy(queues, p.config.Queues)
+		concurrency += p.config.Concurrency
+	}
+
+	host, _ := os.Hostname()
+	return WorkerInfo{
+		ID:          fmt.Sprintf("%s:%d", host, os.Getpid()),
+		Name:        w.name,
+		Version:     w.version,
+		Host:        host,
+		PID:         os.Getpid(),
+		Queues:      queues,
+		Concurrency: concurrency,
+		Active:      w.active.Load(),
+		Draining:    w.Draining(),
+		Leader:      w.IsLeader(),
+		Started:     w.started,
+		Heartbeat:   time.Now().UTC(),
+	}
+}
+
+// heartbeat refreshes this worker's registry entry
+func (w *Workerd) heartbeat(ctx context.Context) error {
+	if err := w.registry.register(ctx, w.workerInfo()); err != nil {
+		return fmt.Errorf("failed to update worker registry: %w", err)
+	}
+	return nil
+}
+
+// Workers lists the live workers registered by every instance sharing the Redis
+func (w *Workerd) Workers(ctx context.Context) ([]WorkerInfo, error) {
+	if w.registry == nil {
+		return nil, errors.New("worker registry is disabled")
+	}
+	workers, err := w.registry.list(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workers: %w", err)
+	}
+	return workers, nil
+}
+
+// runWorkersCommand implements `workers`
+func runWorkersCommand(w *Workerd, args []string) error {
+	if len(args) > 0 && args[0] == "list" {
+		args = args[1:]
+	}
+	var queue string
+	if len(args) == 2 && args[0] == "-queue" {
+		queue = args[1]
+	} else if len(args) != 0 {
+		return fmt.Errorf("usage: %s", workersCommandUsage)
+	}
+
+	workers, err := w.Workers(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tVERSION\tQUEUES\tCONCURRENCY\tACTIVE\tSTATE\tUPTIME")
+	for _, info := range workers {
+		if _, ok := info.Queues[queue]; queue != "" && !ok {
+			continue
+		}
+		names := make([]string, 0, len(info.Queues))
+		for q := range info.Queues {
+			names = append(names, q)
+		}
+		sort.Strings(names)
+		state := "running"
+		if info.Draining {
+			state = "draining"
+		} else if info.Leader {
+			state = "leader"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", info.ID, info.Name, info.Version,
+			strings.Join(names, ","), info.Concurrency, info.Active, state, time.Since(info.Started).Round(time.Second))
+	}
+	return tw.Flush()
+}
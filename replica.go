@@ -0,0 +1,66 @@
+package workerd
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/hibiken/asynq"
+)
+
+// RedisReplica is a read replica serving inspection, so heavy stats, history
+// and admin API reads stay off the primary the worker writes to. Reads may
+// lag behind the primary by the replication delay.
+type RedisReplica struct {
+	// Replica address in "host:port" format; credentials, DB and timeouts
+	// are those of the primary
+	Addr string `json:"address" yaml:"address" env:"ASYNQ_REDIS_REPLICA_ADDRESS"`
+
+	// With Sentinel, read from a replica it reports instead of Addr
+	Sentinel bool `json:"sentinel" yaml:"sentinel" env:"ASYNQ_REDIS_REPLICA_SENTINEL" default:"false"`
+}
+
+// enabled reports whether inspection reads from a replica
+func (rr *RedisReplica) enabled() bool {
+	return rr.Addr != "" || rr.Sentinel
+}
+
+// validate validates the replica configuration
+func (rr *RedisReplica) validate(primary *RedisClient) error {
+	if rr.Sentinel {
+		if rr.Addr != "" {
+			return fmt.Errorf("set either an address or sentinel, not both")
+		}
+		if !primary.Sentinel.enabled() {
+			return fmt.Errorf("sentinel requires redisClient.sentinel")
+		}
+		return nil
+	}
+	if rr.Addr != "" {
+		if _, _, err := net.SplitHostPort(rr.Addr); err != nil {
+			return fmt.Errorf("invalid address %q: %w", rr.Addr, err)
+		}
+	}
+	return nil
+}
+
+// readConnOpt returns the options of the connection to the read replica
+func (a *AsynqConfig) readConnOpt() asynq.RedisConnOpt {
+	rc := &a.RedisClient
+	if a.ReadReplica.Sentinel {
+		return a.namespaced(redisFailoverOpt{rc.failoverOptions(true)})
+	}
+	return a.namespaced(redisClientOpt{rc.options("tcp", a.ReadReplica.Addr)})
+}
+
+// ReadInspector returns an asynq inspector reading from the replica set in
+// asynq.readReplica, or from the primary when none is. Use it for listing and
+// stats only: writes through it fail on a replica.
+func (w *Workerd) ReadInspector() *asynq.Inspector {
+	if !w.config.AsynqConfig.ReadReplica.enabled() {
+		return w.Inspector()
+	}
+	if w.readInspector == nil {
+		w.readInspector = asynq.NewInspector(w.readRedisOpt)
+	}
+	return w.readInspector
+}
@@ -0,0 +1,85 @@
+package workerd
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/hibiken/asynq"
+)
+
+// RequestValues are the attributes of an incoming request that tasks
+// enqueued while serving it carry: the priority picks the queue through the
+// priorities config, the trace ID and tenant are stored as metadata
+type RequestValues struct {
+	Priority Priority
+	TraceID  string
+	Tenant   string
+}
+
+// RequestValuesFunc extracts request values from a context, e.g. one
+// populated by the tracing or tenancy middleware of an HTTP service
+type RequestValuesFunc func(ctx context.Context) RequestValues
+
+type requestValuesKey struct{}
+
+// WithRequestValues returns a context whose values are applied to tasks
+// enqueued with it by Client
+func WithRequestValues(ctx context.Context, v RequestValues) context.Context {
+	return context.WithValue(ctx, requestValuesKey{}, v)
+}
+
+// RequestValuesFromContext returns the values set with WithRequestValues
+func RequestValuesFromContext(ctx context.Context) RequestValues {
+	v, _ := ctx.Value(requestValuesKey{}).(RequestValues)
+	return v
+}
+
+// Request headers read by RequestValuesMiddleware
+const (
+	HeaderPriority    = "X-Priority"
+	HeaderTenant      = "X-Tenant-ID"
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceParent = "traceparent"
+)
+
+// RequestValuesMiddleware stores the priority, tenant and trace ID of each
+// request in its context for Client to apply. The trace ID is taken from a
+// W3C traceparent header, or else from X-Request-ID; unknown priorities are
+// ignored.
+func RequestValuesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := RequestValues{Tenant: r.Header.Get(HeaderTenant), TraceID: r.Header.Get(HeaderRequestID)}
+		switch p := Priority(strings.ToLower(r.Header.Get(HeaderPriority))); p {
+		case PriorityHigh, PriorityNormal, PriorityLow:
+			v.Priority = p
+		}
+		// version-traceid-parentid-flags
+		if parts := strings.Split(r.Header.Get(HeaderTraceParent), "-"); len(parts) == 4 && len(parts[1]) == 32 {
+			v.TraceID = parts[1]
+		}
+		next.ServeHTTP(w, r.WithContext(WithRequestValues(r.Context(), v)))
+	})
+}
+
+// requestOptions applies the request values in ctx to a task being enqueued:
+// it returns the options of the priority route and fills in the metadata not
+// set explicitly through WithMetadata
+func (c *Client) requestOptions(ctx context.Context, md Metadata) ([]asynq.Option, error) {
+	v := c.values(ctx)
+	explicit := outgoingMetadata(ctx)
+	for k, val := range map[string]string{MetaPriority: string(v.Priority), MetaTraceID: v.TraceID, MetaTenant: v.Tenant} {
+		if _, ok := explicit[k]; !ok && val != "" {
+			md[k] = val
+		}
+	}
+	// EnqueuePriority records its priority explicitly and passes its route
+	if _, ok := explicit[MetaPriority]; ok || v.Priority == "" {
+		return nil, nil
+	}
+	r, err := c.config.Priorities.route(v.Priority)
+	if err != nil {
+		return nil, err
+	}
+	return r.options(), nil
+}
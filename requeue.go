@@ -0,0 +1,119 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// errRequeued marks the error of a task put back in its queue to run later
+var errRequeued = errors.New("requeued")
+
+// requeuer enqueues copies of tasks that cannot run yet, so that waiting for
+// a rate limit, a free slot or business hours does not use up their retries
+type requeuer struct {
+	asynq *AsynqConfig
+	// Connections to the primary and to every shard, in the order of asynq.shards
+	primary redis.UniversalClient
+	shards  []redis.UniversalClient
+}
+
+func newRequeuer(config *AsynqConfig, redisOpt asynq.RedisConnOpt) *requeuer {
+	r := &requeuer{
+		asynq:   config,
+		primary: redisOpt.MakeRedisClient().(redis.UniversalClient),
+	}
+	for i := range config.Shards {
+		r.shards = append(r.shards, config.shardConnOpt(i).MakeRedisClient().(redis.UniversalClient))
+	}
+	return r
+}
+
+// redisFor returns the connection to the Redis storing queue
+func (r *requeuer) redisFor(queue string) redis.UniversalClient {
+	if i := r.asynq.shardOf(queue); i >= 0 {
+		return r.shards[i]
+	}
+	return r.primary
+}
+
+// requeue enqueues a copy of t, a task of queue with the given ID, to be
+// processed at at. The copy keeps the retry limit, timeout, deadline and
+// retention of the task but gets a new ID.
+func (r *requeuer) requeue(ctx context.Context, t *asynq.Task, queue, id string, at time.Time) error {
+	rdb := r.redisFor(queue)
+	info, err := asynq.NewInspectorFromRedisClient(rdb).GetTaskInfo(queue, id)
+	if err != nil {
+		return fmt.Errorf("could not read task %s: %w", id, err)
+	}
+
+	opts := []asynq.Option{asynq.Queue(queue), asynq.MaxRetry(info.MaxRetry), asynq.ProcessAt(at)}
+	if info.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(info.Timeout))
+	}
+	if !info.Deadline.IsZero() {
+		opts = append(opts, asynq.Deadline(info.Deadline))
+	}
+	if info.Retention > 0 {
+		opts = append(opts, asynq.Retention(info.Retention))
+	}
+	_, err = asynq.NewClientFromRedisClient(rdb).EnqueueContext(ctx, asynq.NewTask(t.Type(), t.Payload()), opts...)
+	return err
+}
+
+func (r *requeuer) close() error {
+	errs := []error{r.primary.Close()}
+	for _, c := range r.shards {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+type requeueKey struct{}
+
+// requeueTask is the task being processed as it was received
+type requeueTask struct {
+	requeuer *requeuer
+	task     *asynq.Task
+}
+
+// requeueMiddleware records each task as it was received, before its envelope
+// is unwrapped, so requeueAt enqueues it unchanged
+func requeueMiddleware(r *requeuer) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			if r != nil {
+				ctx = context.WithValue(ctx, requeueKey{}, &requeueTask{requeuer: r, task: t})
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
+
+// requeueAt defers the task being processed until at. A copy is enqueued to
+// run then and the current message is revoked, so the wait does not count
+// as a retry and the task is not archived when it has none left. Outside the
+// worker, or when the copy cannot be enqueued, the task is retried at at.
+func requeueAt(ctx context.Context, at time.Time, err error) error {
+	s, _ := ctx.Value(requeueKey{}).(*requeueTask)
+	queue, okQueue := asynq.GetQueueName(ctx)
+	id, okID := asynq.GetTaskID(ctx)
+	if s == nil || !okQueue || !okID {
+		return RetryIn(time.Until(at), err)
+	}
+
+	if qerr := s.requeuer.requeue(context.WithoutCancel(ctx), s.task, queue, id, at); qerr != nil {
+		Logger(ctx).Warn("could not requeue task, retrying it instead", "error", qerr)
+		return RetryIn(time.Until(at), err)
+	}
+	return fmt.Errorf("%w: %w until %s: %w", err, errRequeued, at.Format(time.RFC3339), asynq.RevokeTask)
+}
+
+// requeueIn is requeueAt d from now
+func requeueIn(ctx context.Context, d time.Duration, err error) error {
+	return requeueAt(ctx, time.Now().Add(d), err)
+}
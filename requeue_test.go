@@ -0,0 +1,102 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+)
+
+func TestRequeueAtWithoutWorkerRetries(t *testing.T) {
+	err := requeueIn(context.Background(), time.Minute, errors.New("not yet"))
+	var retry *RetryAfterError
+	if !errors.As(err, &retry) || retry.Delay <= 0 || retry.Delay > time.Minute {
+		t.Fatalf("requeueIn outside the worker = %v, want a retry within a minute", err)
+	}
+	if errors.Is(err, asynq.RevokeTask) {
+		t.Error("task revoked without a copy enqueued")
+	}
+}
+
+func TestRequeueKeepsRetries(t *testing.T) {
+	mr := miniredis.RunT(t)
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()}
+	r := newRequeuer(&AsynqConfig{}, opt)
+	defer r.close()
+
+	type attempt struct {
+		id      string
+		retried int
+		payload string
+	}
+	var (
+		mu       sync.Mutex
+		attempts []attempt
+		done     = make(chan struct{})
+	)
+	handler := requeueMiddleware(r)(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		id, _ := asynq.GetTaskID(ctx)
+		retried, _ := asynq.GetRetryCount(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, attempt{id, retried, string(task.Payload())})
+		if len(attempts) == 1 {
+			return requeueIn(ctx, 10*time.Millisecond, errors.New("not yet"))
+		}
+		close(done)
+		return nil
+	}))
+
+	startTestServer(t, opt, handler)
+	client := asynq.NewClient(opt)
+	defer client.Close()
+	if _, err := client.Enqueue(asynq.NewTask("report:build", []byte("payload")), asynq.MaxRetry(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("requeued task was not processed again")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	first, second := attempts[0], attempts[1]
+	if second.id == first.id || second.retried != 0 || second.payload != "payload" {
+		t.Errorf("second attempt = %+v after %+v, want a new task with the same payload and no retries", second, first)
+	}
+	assertNoneArchived(t, opt)
+}
+
+// startTestServer processes the default queue of opt with h until the test ends
+func startTestServer(t *testing.T, opt asynq.RedisConnOpt, h asynq.Handler) {
+	t.Helper()
+	srv := asynq.NewServer(opt, asynq.Config{
+		Concurrency:              1,
+		DelayedTaskCheckInterval: 50 * time.Millisecond,
+		LogLevel:                 asynq.FatalLevel,
+	})
+	if err := srv.Start(h); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Shutdown)
+}
+
+// assertNoneArchived fails the test if the default queue of opt has archived tasks
+func assertNoneArchived(t *testing.T, opt asynq.RedisConnOpt) {
+	t.Helper()
+	inspector := asynq.NewInspector(opt)
+	defer inspector.Close()
+	archived, err := inspector.ListArchivedTasks("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) > 0 {
+		t.Errorf("%d tasks archived", len(archived))
+	}
+}
@@ -0,0 +1,182 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// errResourcesExhausted is returned for tasks postponed while the process or
+// host is over its resource limits. Like errBulkheadFull, the task is requeued
+// and keeps its retries.
+var errResourcesExhausted = errors.New("resource limits exceeded")
+
+// ResourceGuardConfig holds tasks back while the process uses too much memory
+// or the host is overloaded, so memory hungry handlers don't get the daemon
+// OOM-killed
+type ResourceGuardConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_RESOURCES_ENABLED" default:"false"`
+
+	// Resident memory of the process above which tasks are held back; 0 disables the check
+	MaxRSSMB int `json:"maxRSSMB" yaml:"maxRSSMB" env:"WORKER_RESOURCES_MAX_RSS_MB" default:"0"`
+
+	// One minute system load average above which tasks are held back; 0 disables the check
+	MaxLoad float64 `json:"maxLoad" yaml:"maxLoad" env:"WORKER_RESOURCES_MAX_LOAD" default:"0"`
+
+	// Fraction of the limits usage must drop below before tasks run again
+	ResumeRatio float64 `json:"resumeRatio" yaml:"resumeRatio" env:"WORKER_RESOURCES_RESUME_RATIO" default:"0.9"`
+
+	// How often usage is sampled
+	Interval time.Duration `json:"interval" yaml:"interval" env:"WORKER_RESOURCES_INTERVAL" default:"1s"`
+
+	// How long a task waits for usage to drop before it is put back in the queue
+	Wait time.Duration `json:"wait" yaml:"wait" env:"WORKER_RESOURCES_WAIT" default:"30s"`
+
+	// Delay before a task put back in the queue is tried again
+	RetryDelay time.Duration `json:"retryDelay" yaml:"retryDelay" env:"WORKER_RESOURCES_RETRY_DELAY" default:"30s"`
+
+	// Task types or type prefixes held back; empty holds back every type
+	Types []string `json:"types" yaml:"types"`
+}
+
+// validate validates the resource guard configuration
+func (rc *ResourceGuardConfig) validate() error {
+	if !rc.Enabled {
+		return nil
+	}
+	if rc.MaxRSSMB < 0 || rc.MaxLoad < 0 {
+		return fmt.Errorf("resource limits must be non-negative")
+	}
+	if rc.MaxRSSMB == 0 && rc.MaxLoad == 0 {
+		return fmt.Errorf("resource guard needs maxRSSMB or maxLoad")
+	}
+	if rc.ResumeRatio <= 0 || rc.ResumeRatio > 1 {
+		return fmt.Errorf("resource resume ratio must be in (0, 1], got %v", rc.ResumeRatio)
+	}
+	if rc.Interval <= 0 {
+		return fmt.Errorf("resource sampling interval must be positive, got %v", rc.Interval)
+	}
+	if rc.Wait < 0 || rc.RetryDelay < 0 {
+		return fmt.Errorf("resource wait and retry delay must be non-negative")
+	}
+	return nil
+}
+
+// guards reports whether tasks of the type are held back
+func (rc *ResourceGuardConfig) guards(taskType string) bool {
+	if len(rc.Types) == 0 {
+		return true
+	}
+	for _, prefix := range rc.Types {
+		if matchTaskType(prefix, taskType) {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceUsage is a sample of the process and host usage
+type resourceUsage struct {
+	rssMB float64
+	load  float64
+}
+
+// resourceGuard tracks whether usage is over the limits
+type resourceGuard struct {
+	config *ResourceGuardConfig
+	log    *slog.Logger
+	sample func() (resourceUsage, error)
+
+	mu     sync.Mutex
+	paused bool
+	// Closed when tasks may run again
+	resume chan struct{}
+}
+
+func newResourceGuard(config *ResourceGuardConfig, log *slog.Logger) *resourceGuard {
+	resume := make(chan struct{})
+	close(resume)
+	return &resourceGuard{config: config, log: log, sample: sampleResourceUsage, resume: resume}
+}
+
+// check samples the usage and pauses or resumes tasks accordingly
+func (g *resourceGuard) check(ctx context.Context) error {
+	usage, err := g.sample()
+	if err != nil {
+		return fmt.Errorf("failed to sample resource usage: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ratio := 1.0
+	if g.paused {
+		ratio = g.config.ResumeRatio
+	}
+	over := (g.config.MaxRSSMB > 0 && usage.rssMB > float64(g.config.MaxRSSMB)*ratio) ||
+		(g.config.MaxLoad > 0 && usage.load > g.config.MaxLoad*ratio)
+
+	switch {
+	case over && !g.paused:
+		g.paused = true
+		g.resume = make(chan struct{})
+		g.log.Warn("resource limits exceeded, holding tasks back",
+			"rss_mb", int(usage.rssMB), "max_rss_mb", g.config.MaxRSSMB, "load", usage.load, "max_load", g.config.MaxLoad)
+	case !over && g.paused:
+		g.paused = false
+		close(g.resume)
+		g.log.Info("resource usage back under limits, resuming tasks", "rss_mb", int(usage.rssMB), "load", usage.load)
+	}
+	return nil
+}
+
+// wait blocks until tasks may run, for at most d
+func (g *resourceGuard) wait(ctx context.Context, d time.Duration) error {
+	g.mu.Lock()
+	resume := g.resume
+	g.mu.Unlock()
+
+	select {
+	case <-resume:
+		return nil
+	default:
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-resume:
+		return nil
+	case <-timer.C:
+		return errResourcesExhausted
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resourceMiddleware holds tasks back while the guard is paused. Waiting
+// tasks keep their worker slot, so no more tasks are fetched in the meantime;
+// tasks still waiting after the configured wait are requeued to try again
+// later without using up a retry.
+func resourceMiddleware(g *resourceGuard) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if g == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			if g.config.guards(t.Type()) {
+				err := g.wait(ctx, g.config.Wait)
+				if errors.Is(err, errResourcesExhausted) {
+					return requeueIn(ctx, g.config.RetryDelay, err)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package workerd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sampleResourceUsage reads the resident memory of the process and the load
+// average from /proc
+func sampleResourceUsage() (resourceUsage, error) {
+	var usage resourceUsage
+
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return usage, err
+	}
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return usage, fmt.Errorf("unexpected /proc/self/statm format")
+	}
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return usage, fmt.Errorf("invalid resident size: %w", err)
+	}
+	usage.rssMB = float64(pages*int64(os.Getpagesize())) / (1 << 20)
+
+	loadavg, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return usage, err
+	}
+	fields = strings.Fields(string(loadavg))
+	if len(fields) == 0 {
+		return usage, fmt.Errorf("unexpected /proc/loadavg format")
+	}
+	if usage.load, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return usage, fmt.Errorf("invalid load average: %w", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package workerd
+
+import "runtime"
+
+// sampleResourceUsage approximates the resident memory with the memory the Go
+// runtime obtained from the OS. The load average is not available, so the
+// load limit never triggers.
+func sampleResourceUsage() (resourceUsage, error) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return resourceUsage{rssMB: float64(m.Sys) / (1 << 20)}, nil
+}
@@ -0,0 +1,84 @@
+//go:build !windows
+
+package workerd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+)
+
+// restartSignal asks a running worker to restart gracefully. SIGUSR2 is
+// taken by the log level toggle.
+const restartSignal = syscall.SIGHUP
+
+// watchRestartSignal restarts the worker in place when SIGHUP is received.
+// The returned function stops watching.
+func (w *Workerd) watchRestartSignal() func() {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, restartSignal)
+
+	go func() {
+		select {
+		case <-sigs:
+			w.restart()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// restart stops fetching new tasks, lets in-flight tasks finish within the
+// shutdown timeout, stops every component and re-executes the binary with
+// the same arguments. The PID is kept, so service managers and the PID
+// file keep tracking the process.
+func (w *Workerd) restart() {
+	w.log.Info("graceful restart requested, draining in-flight tasks")
+	w.srv.Stop()
+	for _, p := range w.pools {
+		p.srv.Stop()
+	}
+	w.Stop(nil)
+
+	exe, err := os.Executable()
+	if err == nil {
+		err = syscall.Exec(exe, os.Args, os.Environ())
+	}
+	// The servers cannot be started again; exit so the service manager restarts us
+	fmt.Fprintf(os.Stderr, "workerd: graceful restart failed: %v\n", err)
+	os.Exit(1)
+}
+
+// signalRestart asks the worker owning the PID file to restart gracefully
+func signalRestart(pidFilePath string) error {
+	return signalPIDFile(pidFilePath, restartSignal)
+}
+
+// signalPIDFile sends sig to the worker holding the PID file
+func signalPIDFile(pidFilePath string, sig syscall.Signal) error {
+	f, err := os.Open(pidFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open PID file: %w", err)
+	}
+	defer f.Close()
+
+	// A PID file nobody holds is stale and its PID may have been reused
+	if lockFile(f) == nil {
+		return fmt.Errorf("no running instance holds %s", pidFilePath)
+	}
+	pid, err := strconv.Atoi(readPID(f))
+	if err != nil {
+		return fmt.Errorf("invalid PID file %s: %w", pidFilePath, err)
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("failed to signal pid %d: %w", pid, err)
+	}
+	return nil
+}
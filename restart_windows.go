@@ -0,0 +1,16 @@
+//go:build windows
+
+package workerd
+
+import "errors"
+
+// watchRestartSignal is a no-op on Windows, which has no SIGHUP
+func (w *Workerd) watchRestartSignal() func() {
+	return func() {}
+}
+
+// signalRestart is not supported on Windows; the service is restarted
+// through the service control manager instead
+func signalRestart(pidFilePath string) error {
+	return errors.New("graceful restart is not supported on windows")
+}
@@ -0,0 +1,250 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// RetentionPolicy declares how long finished tasks of a given type are kept
+type RetentionPolicy struct {
+	// Task type the policy applies to. Matched like ServeMux patterns:
+	// an exact type name or a prefix such as "email:".
+	Type string `json:"type" yaml:"type"`
+
+	// How long completed tasks and their results are retained.
+	// Applied as asynq.Retention on enqueue and enforced by the retention job.
+	Completed time.Duration `json:"completed" yaml:"completed"`
+
+	// How long archived (dead) tasks are retained before deletion.
+	Archived time.Duration `json:"archived" yaml:"archived"`
+
+	// Fallback retention for any finished task of this type when the
+	// state specific TTL above is not set.
+	History time.Duration `json:"history" yaml:"history"`
+}
+
+// completedTTL returns the effective retention for completed tasks
+func (p *RetentionPolicy) completedTTL() time.Duration {
+	if p.Completed > 0 {
+		return p.Completed
+	}
+	return p.History
+}
+
+// archivedTTL returns the effective retention for archived tasks
+func (p *RetentionPolicy) archivedTTL() time.Duration {
+	if p.Archived > 0 {
+		return p.Archived
+	}
+	return p.History
+}
+
+// QueueRetentionPolicy declares how long finished tasks of a queue are kept
+// when no task type policy sets a shorter or longer period
+type QueueRetentionPolicy struct {
+	// Queue name, or "*" for every queue without a policy of its own
+	Queue string `json:"queue" yaml:"queue"`
+
+	// How long completed tasks and their results are retained.
+	Completed time.Duration `json:"completed" yaml:"completed"`
+
+	// How long archived (dead) tasks are retained before deletion.
+	Archived time.Duration `json:"archived" yaml:"archived"`
+}
+
+// RetentionConfig holds the per task type and per queue retention policies
+type RetentionConfig struct {
+	// How often the enforcement job runs. Zero disables enforcement.
+	Interval time.Duration `json:"interval" yaml:"interval" env:"WORKER_RETENTION_INTERVAL" default:"1h"`
+
+	// Number of tasks fetched per page while scanning a queue.
+	BatchSize int `json:"batchSize" yaml:"batchSize" env:"WORKER_RETENTION_BATCH_SIZE" default:"100"`
+
+	Policies []RetentionPolicy `json:"policies" yaml:"policies"`
+
+	// Per queue fallbacks for task types without a matching policy
+	Queues []QueueRetentionPolicy `json:"queues" yaml:"queues"`
+}
+
+// enabled reports whether the retention job has anything to enforce
+func (rc *RetentionConfig) enabled() bool {
+	return rc.Interval > 0 && (len(rc.Policies) > 0 || len(rc.Queues) > 0)
+}
+
+// validate validates the retention configuration
+func (rc *RetentionConfig) validate() error {
+	if rc.Interval < 0 {
+		return fmt.Errorf("retention interval must be non-negative, got %v", rc.Interval)
+	}
+	if (len(rc.Policies) > 0 || len(rc.Queues) > 0) && rc.BatchSize <= 0 {
+		return fmt.Errorf("retention batch size must be positive, got %d", rc.BatchSize)
+	}
+	for i, p := range rc.Policies {
+		if strings.TrimSpace(p.Type) == "" {
+			return fmt.Errorf("retention policy %d: type cannot be empty", i)
+		}
+		if p.Completed < 0 || p.Archived < 0 || p.History < 0 {
+			return fmt.Errorf("retention policy %q: durations must be non-negative", p.Type)
+		}
+	}
+	seen := make(map[string]bool, len(rc.Queues))
+	for i, p := range rc.Queues {
+		if strings.TrimSpace(p.Queue) == "" {
+			return fmt.Errorf("queue retention policy %d: queue cannot be empty", i)
+		}
+		if seen[p.Queue] {
+			return fmt.Errorf("queue retention policy %q declared twice", p.Queue)
+		}
+		seen[p.Queue] = true
+		if p.Completed < 0 || p.Archived < 0 {
+			return fmt.Errorf("queue retention policy %q: durations must be non-negative", p.Queue)
+		}
+	}
+	return nil
+}
+
+// queuePolicyFor returns the policy of the queue, the "*" policy, or nil
+func (rc *RetentionConfig) queuePolicyFor(queue string) *QueueRetentionPolicy {
+	var fallback *QueueRetentionPolicy
+	for i := range rc.Queues {
+		p := &rc.Queues[i]
+		if p.Queue == queue {
+			return p
+		}
+		if p.Queue == "*" {
+			fallback = p
+		}
+	}
+	return fallback
+}
+
+// completedTTL returns the retention of completed tasks of the type in the
+// queue: the task type policy first, then the queue policy; 0 keeps them
+func (rc *RetentionConfig) completedTTL(queue, taskType string) time.Duration {
+	if p := rc.PolicyFor(taskType); p != nil && p.completedTTL() > 0 {
+		return p.completedTTL()
+	}
+	if p := rc.queuePolicyFor(queue); p != nil {
+		return p.Completed
+	}
+	return 0
+}
+
+// archivedTTL returns the retention of archived tasks of the type in the
+// queue: the task type policy first, then the queue policy; 0 keeps them
+func (rc *RetentionConfig) archivedTTL(queue, taskType string) time.Duration {
+	if p := rc.PolicyFor(taskType); p != nil && p.archivedTTL() > 0 {
+		return p.archivedTTL()
+	}
+	if p := rc.queuePolicyFor(queue); p != nil {
+		return p.Archived
+	}
+	return 0
+}
+
+// PolicyFor returns the most specific policy matching the task type, or nil
+func (rc *RetentionConfig) PolicyFor(taskType string) *RetentionPolicy {
+	var best *RetentionPolicy
+	for i := range rc.Policies {
+		p := &rc.Policies[i]
+		if p.Type == taskType {
+			return p
+		}
+		if strings.HasPrefix(taskType, p.Type) && (best == nil || len(p.Type) > len(best.Type)) {
+			best = p
+		}
+	}
+	return best
+}
+
+// EnqueueOptions returns the asynq options producers should pass for the task type
+func (rc *RetentionConfig) EnqueueOptions(taskType string) []asynq.Option {
+	p := rc.PolicyFor(taskType)
+	if p == nil || p.completedTTL() <= 0 {
+		return nil
+	}
+	return []asynq.Option{asynq.Retention(p.completedTTL())}
+}
+
+// retentionEnforcer is the janitor deleting finished tasks that outlived
+// their policy, keeping Redis memory bounded
+type retentionEnforcer struct {
+	config    *RetentionConfig
+	inspector *asynq.Inspector
+	now       func() time.Time
+}
+
+// run scans every queue once and reports how many tasks were deleted
+func (re *retentionEnforcer) run(ctx context.Context) (int, error) {
+	queues, err := re.inspector.Queues()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	deleted := 0
+	for _, queue := range queues {
+		n, err := re.sweep(ctx, queue, re.inspector.ListCompletedTasks, func(t *asynq.TaskInfo) bool {
+			ttl := re.config.completedTTL(queue, t.Type)
+			return ttl > 0 && re.now().Sub(t.CompletedAt) > ttl
+		})
+		deleted += n
+		if err != nil {
+			return deleted, fmt.Errorf("queue %q completed tasks: %w", queue, err)
+		}
+
+		n, err = re.sweep(ctx, queue, re.inspector.ListArchivedTasks, func(t *asynq.TaskInfo) bool {
+			ttl := re.config.archivedTTL(queue, t.Type)
+			return ttl > 0 && re.now().Sub(t.LastFailedAt) > ttl
+		})
+		deleted += n
+		if err != nil {
+			return deleted, fmt.Errorf("queue %q archived tasks: %w", queue, err)
+		}
+	}
+
+	return deleted, nil
+}
+
+// sweep pages through one task list and deletes the expired entries
+func (re *retentionEnforcer) sweep(
+	ctx context.Context,
+	queue string,
+	list func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error),
+	expired func(*asynq.TaskInfo) bool,
+) (int, error) {
+	deleted := 0
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		tasks, err := list(queue, asynq.PageSize(re.config.BatchSize), asynq.Page(page))
+		if err != nil {
+			return deleted, err
+		}
+
+		removed := 0
+		for _, t := range tasks {
+			if !expired(t) {
+				continue
+			}
+			if err := re.inspector.DeleteTask(queue, t.ID); err != nil {
+				return deleted, fmt.Errorf("failed to delete task %s: %w", t.ID, err)
+			}
+			removed++
+		}
+		deleted += removed
+
+		if len(tasks) < re.config.BatchSize {
+			return deleted, nil
+		}
+		// Deleted entries shift the following ones into the current page
+		if removed > 0 {
+			page--
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// RetryAfterError asks the worker to retry the task after a specific delay,
+// or after the delay of its Backoff, instead of following the default
+// backoff curve
+type RetryAfterError struct {
+	Delay   time.Duration
+	Backoff Backoff
+	Err     error
+}
+
+func (e *RetryAfterError) Error() string {
+	if e.Backoff != nil {
+		return fmt.Sprintf("%v (retry with backoff)", e.Err)
+	}
+	if e.Err == nil {
+		return fmt.Sprintf("retry in %v", e.Delay)
+	}
+	return fmt.Sprintf("%v (retry in %v)", e.Err, e.Delay)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryIn wraps err so the next attempt is scheduled after d
+func RetryIn(d time.Duration, err error) error {
+	if err == nil {
+		err = errors.New("retry requested")
+	}
+	return &RetryAfterError{Delay: d, Err: err}
+}
+
+// Backoff returns the delay before retry n, counting from 1
+type Backoff func(n int) time.Duration
+
+// ConstantBackoff waits d before every retry
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration { return d }
+}
+
+// LinearBackoff waits step, 2*step, 3*step... up to max
+func LinearBackoff(step, max time.Duration) Backoff {
+	return func(n int) time.Duration {
+		return min(time.Duration(n)*step, max)
+	}
+}
+
+// ExponentialBackoff waits base, 2*base, 4*base... up to max
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(n int) time.Duration {
+		d := base
+		for i := 1; i < n && d < max; i++ {
+			d *= 2
+		}
+		return min(d, max)
+	}
+}
+
+// RetryWith wraps err so retries are delayed by b, letting each class of
+// failure use its own backoff, e.g. a long exponential one for rate limits
+func RetryWith(b Backoff, err error) error {
+	if err == nil {
+		err = errors.New("retry requested")
+	}
+	return &RetryAfterError{Backoff: b, Err: err}
+}
+
+// retryDirective is a handler error telling asynq to stop retrying the task
+type retryDirective struct {
+	// asynq.RevokeTask or asynq.SkipRetry
+	directive error
+	err       error
+}
+
+func (e *retryDirective) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryDirective) Unwrap() error {
+	return e.err
+}
+
+// SkipRetry wraps err so the task fails without further retries, like
+// returning asynq.SkipRetry: it is archived, where it can be inspected and
+// run again. Use Drop to discard the task instead.
+func SkipRetry(err error) error {
+	if err == nil {
+		err = errors.New("retries skipped")
+	}
+	return &retryDirective{directive: asynq.SkipRetry, err: err}
+}
+
+// Drop wraps err so the task fails without further retries and is dropped
+// instead of archived, e.g. when the work became obsolete. Unlike
+// asynq.SkipRetry, which archives, the task can't be run again.
+func Drop(err error) error {
+	if err == nil {
+		err = errors.New("task dropped")
+	}
+	return &retryDirective{directive: asynq.RevokeTask, err: err}
+}
+
+// Archive wraps err so the task is archived right away without further
+// retries, e.g. for invalid input; it can be inspected and run again later
+func Archive(err error) error {
+	if err == nil {
+		err = errors.New("archive requested")
+	}
+	return &retryDirective{directive: asynq.SkipRetry, err: err}
+}
+
+// retryMiddleware translates the errors of SkipRetry, Drop and Archive into the
+// asynq sentinel errors, so the middleware around it and asynq see them
+func retryMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		err := next.ProcessTask(ctx, t)
+		var d *retryDirective
+		if errors.As(err, &d) {
+			return fmt.Errorf("%w: %w", err, d.directive)
+		}
+		return err
+	})
+}
+
+// RetryAfterFromResponse wraps err with the delay advertised by the response's
+// Retry-After header (delta seconds or HTTP date). If the header is absent or
+// invalid, err is returned unchanged and the default backoff applies.
+func RetryAfterFromResponse(resp *http.Response, err error) error {
+	if resp == nil {
+		return err
+	}
+	if err == nil {
+		err = fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+
+	d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return err
+	}
+	return RetryIn(d, err)
+}
+
+// parseRetryAfter parses a Retry-After header value relative to now
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		d := at.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// retryDelayFunc honours RetryAfterError hints and otherwise defers to asynq's default backoff
+func retryDelayFunc(n int, err error, t *asynq.Task) time.Duration {
+	var retryErr *RetryAfterError
+	if errors.As(err, &retryErr) {
+		if retryErr.Backoff != nil {
+			// n counts the retries already made
+			return retryErr.Backoff(n + 1)
+		}
+		if retryErr.Delay > 0 {
+			return retryErr.Delay
+		}
+	}
+	return asynq.DefaultRetryDelayFunc(n, err, t)
+}
@@ -0,0 +1,49 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestRetryMiddlewareDirectives(t *testing.T) {
+	cause := errors.New("boom")
+	for _, tt := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"skip retry", SkipRetry(cause), asynq.SkipRetry},
+		{"drop", Drop(cause), asynq.RevokeTask},
+		{"archive", Archive(cause), asynq.SkipRetry},
+	} {
+		h := retryMiddleware(asynq.HandlerFunc(func(context.Context, *asynq.Task) error { return tt.err }))
+		err := h.ProcessTask(context.Background(), asynq.NewTask("t", nil))
+		if !errors.Is(err, tt.want) || !errors.Is(err, cause) {
+			t.Errorf("%s: got %v, want %v wrapping %v", tt.name, err, tt.want, cause)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{now.Add(time.Hour).Format(http.TimeFormat), time.Hour, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	} {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
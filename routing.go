@@ -0,0 +1,206 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/time/rate"
+)
+
+// errRateLimited is returned for tasks postponed by a routing rate limit.
+// The task is requeued to run once a token is free, so it keeps its retries.
+var errRateLimited = errors.New("task type rate limited")
+
+// RoutingRule declares how tasks of a type are enqueued and processed
+type RoutingRule struct {
+	// Task type the rule applies to: an exact type name or a prefix such as "email:"
+	Type string `json:"type" yaml:"type"`
+
+	// Queue tasks are enqueued on; empty keeps the default
+	Queue string `json:"queue" yaml:"queue"`
+
+	// Retries of the task; 0 keeps the default
+	MaxRetry int `json:"maxRetry" yaml:"maxRetry"`
+
+	// Handler timeout; 0 keeps the default
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+
+	// Tasks started per second by each worker process; 0 is unlimited
+	RateLimit float64 `json:"rateLimit" yaml:"rateLimit"`
+
+	// Tasks that may start at once before the rate limit applies; 0 means 1
+	Burst int `json:"burst" yaml:"burst"`
+}
+
+// RoutingConfig is the routing table giving ops one place to tune task types.
+// Producers apply it as default enqueue options; workers enforce the timeout,
+// retry and rate limits even for tasks enqueued by other clients.
+type RoutingConfig struct {
+	Rules []RoutingRule `json:"rules" yaml:"rules"`
+
+	// Old task type names mapped to their new name. Workers process tasks of
+	// an old name as the new one, so renamed types keep working while
+	// producers migrate.
+	Aliases map[string]string `json:"aliases" yaml:"aliases"`
+}
+
+// validate validates the routing table
+func (rc *RoutingConfig) validate() error {
+	seen := make(map[string]bool, len(rc.Rules))
+	for i, r := range rc.Rules {
+		if strings.TrimSpace(r.Type) == "" {
+			return fmt.Errorf("routing rule %d: type cannot be empty", i)
+		}
+		if seen[r.Type] {
+			return fmt.Errorf("routing rule %q: duplicate type", r.Type)
+		}
+		seen[r.Type] = true
+		if r.MaxRetry < 0 || r.Timeout < 0 || r.RateLimit < 0 || r.Burst < 0 {
+			return fmt.Errorf("routing rule %q: values must be non-negative", r.Type)
+		}
+	}
+	for from, to := range rc.Aliases {
+		if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			return fmt.Errorf("alias %q -> %q: task types cannot be empty", from, to)
+		}
+		if from == to {
+			return fmt.Errorf("alias %q points to itself", from)
+		}
+		if _, ok := rc.Aliases[to]; ok {
+			return fmt.Errorf("alias %q -> %q: %q is itself an alias", from, to, to)
+		}
+	}
+	return nil
+}
+
+// ruleFor returns the most specific rule matching the task type, or nil
+func (rc *RoutingConfig) ruleFor(taskType string) *RoutingRule {
+	var best *RoutingRule
+	for i := range rc.Rules {
+		r := &rc.Rules[i]
+		if r.Type == taskType {
+			return r
+		}
+		if strings.HasPrefix(taskType, r.Type) && (best == nil || len(r.Type) > len(best.Type)) {
+			best = r
+		}
+	}
+	return best
+}
+
+// EnqueueOptions returns the asynq options producers should pass for the task type
+func (rc *RoutingConfig) EnqueueOptions(taskType string) []asynq.Option {
+	r := rc.ruleFor(taskType)
+	if r == nil {
+		return nil
+	}
+	var opts []asynq.Option
+	if r.Queue != "" {
+		opts = append(opts, asynq.Queue(r.Queue))
+	}
+	if r.MaxRetry > 0 {
+		opts = append(opts, asynq.MaxRetry(r.MaxRetry))
+	}
+	if r.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(r.Timeout))
+	}
+	return opts
+}
+
+// routingLimiters holds one rate limiter per rule, created on first use
+type routingLimiters struct {
+	mu       sync.Mutex
+	limiters map[*RoutingRule]*rate.Limiter
+}
+
+func (l *routingLimiters) get(r *RoutingRule) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limiters == nil {
+		l.limiters = make(map[*RoutingRule]*rate.Limiter)
+	}
+	limiter, ok := l.limiters[r]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(r.RateLimit), max(r.Burst, 1))
+		l.limiters[r] = limiter
+	}
+	return limiter
+}
+
+// routingMiddleware enforces the routing table on the worker. Tasks of an
+// aliased type are renamed first. Rate limited tasks are requeued to run once
+// a token is available, without using up a retry.
+func routingMiddleware(config *RoutingConfig) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if len(config.Rules) == 0 && len(config.Aliases) == 0 {
+			return next
+		}
+		limiters := &routingLimiters{}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			if to, ok := config.Aliases[t.Type()]; ok {
+				Logger(ctx).Debug("task type aliased", "type", t.Type(), "alias_of", to)
+				t = asynq.NewTask(to, t.Payload())
+			}
+
+			r := config.ruleFor(t.Type())
+			if r == nil {
+				return next.ProcessTask(ctx, t)
+			}
+
+			if r.RateLimit > 0 {
+				res := limiters.get(r).Reserve()
+				if d := res.Delay(); d > 0 {
+					res.Cancel()
+					return requeueIn(ctx, d, errRateLimited)
+				}
+			}
+			if r.Timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+				defer cancel()
+			}
+
+			err := next.ProcessTask(ctx, t)
+			if err != nil && r.MaxRetry > 0 {
+				if retried, ok := asynq.GetRetryCount(ctx); ok && retried >= r.MaxRetry {
+					return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+				}
+			}
+			return err
+		})
+	}
+}
+
+// isFailure reports whether a task error counts as a failed attempt
+func isFailure(err error) bool {
+	return !errors.Is(err, errRateLimited) && !errors.Is(err, errBulkheadFull) &&
+		!errors.Is(err, errResourcesExhausted) && !errors.Is(err, errUnhealthy) && !errors.Is(err, errOutsideBusinessHours) &&
+		!errors.Is(err, errTenantBusy)
+}
+
+// warnUnprocessedRoutes logs routing rules whose queue no server of this
+// worker processes, since their tasks would never run here
+func (w *Workerd) warnUnprocessedRoutes() {
+	processed := map[string]bool{}
+	if len(w.config.Queues) == 0 {
+		processed["default"] = true
+	}
+	for q := range w.config.Queues {
+		processed[q] = true
+	}
+	for _, p := range w.pools {
+		for q := range p.config.Queues {
+			processed[q] = true
+		}
+	}
+	for _, r := range w.config.Routing.Rules {
+		if r.Queue != "" && !processed[r.Queue] {
+			w.log.Warn("routing rule queue is not processed by this worker", "type", r.Type, "queue", r.Queue)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package workerd
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+)
+
+func TestRateLimitKeepsTasksWithoutRetries(t *testing.T) {
+	mr := miniredis.RunT(t)
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()}
+	r := newRequeuer(&AsynqConfig{}, opt)
+	defer r.close()
+
+	config := &RoutingConfig{Rules: []RoutingRule{{Type: "email:", RateLimit: 5}}}
+	var processed atomic.Int32
+	done := make(chan struct{})
+	handler := requeueMiddleware(r)(routingMiddleware(config)(asynq.HandlerFunc(func(context.Context, *asynq.Task) error {
+		if processed.Add(1) == 3 {
+			close(done)
+		}
+		return nil
+	})))
+	startTestServer(t, opt, handler)
+
+	client := asynq.NewClient(opt)
+	defer client.Close()
+	for range 3 {
+		if _, err := client.Enqueue(asynq.NewTask("email:send", nil), asynq.MaxRetry(0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%d of 3 rate limited tasks processed", processed.Load())
+	}
+	assertNoneArchived(t, opt)
+}
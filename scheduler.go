@@ -0,0 +1,384 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// SchedulerConfig configures the scheduler enqueuing periodic tasks
+type SchedulerConfig struct {
+	// Elect one instance per worker name to enqueue periodic tasks; when false
+	// every replica enqueues them
+	LeaderElection bool `json:"leaderElection" yaml:"leaderElection" env:"WORKER_SCHEDULER_LEADER_ELECTION" default:"true"`
+
+	// Prefix of the Redis key holding the leader lock, followed by the worker name
+	LeaderKey string `json:"leaderKey" yaml:"leaderKey" env:"WORKER_SCHEDULER_LEADER_KEY" default:"'workerd:scheduler:leader:'"`
+
+	// How long leadership lasts without renewal; a crashed leader is replaced
+	// within this time. It is renewed, and dynamic schedules are reloaded,
+	// every third of it.
+	LeaseTTL time.Duration `json:"leaseTTL" yaml:"leaseTTL" env:"WORKER_SCHEDULER_LEASE_TTL" default:"15s"`
+
+	// Prefix of the Redis hash holding dynamic schedules, followed by the worker name
+	EntriesKey string `json:"entriesKey" yaml:"entriesKey" env:"WORKER_SCHEDULER_ENTRIES_KEY" default:"'workerd:scheduler:entries:'"`
+}
+
+// validate validates the scheduler configuration
+func (sc *SchedulerConfig) validate() error {
+	if sc.LeaderElection && sc.LeaderKey == "" {
+		return fmt.Errorf("scheduler leader key cannot be empty")
+	}
+	if sc.EntriesKey == "" {
+		return fmt.Errorf("scheduler entries key cannot be empty")
+	}
+	if sc.LeaseTTL < 3*time.Second {
+		return fmt.Errorf("scheduler lease TTL must be at least 3s, got %v", sc.LeaseTTL)
+	}
+	return nil
+}
+
+// ScheduleConfig is a periodic task declared in the config file
+type ScheduleConfig struct {
+	// Cron expression, e.g. "0 3 * * *", or a descriptor such as "@every 1h"
+	Cron string `json:"cron" yaml:"cron"`
+
+	// IANA time zone the expression is evaluated in; empty means UTC
+	TZ string `json:"tz" yaml:"tz"`
+
+	// Task type to enqueue
+	Task string `json:"task" yaml:"task"`
+
+	// Payload of the task, encoded as JSON
+	Payload any `json:"payload" yaml:"payload"`
+
+	// Queue of the task; empty keeps the routing table or the default
+	Queue string `json:"queue" yaml:"queue"`
+
+	// Retries of the task; 0 keeps the default
+	MaxRetry int `json:"maxRetry" yaml:"maxRetry"`
+
+	// Handler timeout; 0 keeps the default
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// cronspec returns the cron expression with its time zone
+func (sc *ScheduleConfig) cronspec() string {
+	if sc.TZ == "" {
+		return sc.Cron
+	}
+	return "CRON_TZ=" + sc.TZ + " " + sc.Cron
+}
+
+// validate validates a configured schedule
+func (sc *ScheduleConfig) validate() error {
+	if sc.Task == "" {
+		return fmt.Errorf("task cannot be empty")
+	}
+	if sc.TZ != "" {
+		if _, err := time.LoadLocation(sc.TZ); err != nil {
+			return fmt.Errorf("invalid time zone %q: %w", sc.TZ, err)
+		}
+	}
+	if _, err := cron.ParseStandard(sc.cronspec()); err != nil {
+		return fmt.Errorf("invalid cron expression %q: %w", sc.Cron, err)
+	}
+	if sc.MaxRetry < 0 || sc.Timeout < 0 {
+		return fmt.Errorf("max retry and timeout must be non-negative")
+	}
+	if _, err := json.Marshal(sc.Payload); err != nil {
+		return fmt.Errorf("payload cannot be encoded as JSON: %w", err)
+	}
+	return nil
+}
+
+// task builds the task and its options
+func (sc *ScheduleConfig) task() (*asynq.Task, []asynq.Option, error) {
+	var payload []byte
+	if sc.Payload != nil {
+		var err error
+		if payload, err = json.Marshal(sc.Payload); err != nil {
+			return nil, nil, err
+		}
+	}
+	var opts []asynq.Option
+	if sc.Queue != "" {
+		opts = append(opts, asynq.Queue(sc.Queue))
+	}
+	if sc.MaxRetry > 0 {
+		opts = append(opts, asynq.MaxRetry(sc.MaxRetry))
+	}
+	if sc.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(sc.Timeout))
+	}
+	return asynq.NewTask(sc.Task, payload), opts, nil
+}
+
+// validateSchedules validates the schedules section
+func validateSchedules(schedules []ScheduleConfig) error {
+	for i := range schedules {
+		if err := schedules[i].validate(); err != nil {
+			return fmt.Errorf("schedule %d (%s): %w", i, schedules[i].Task, err)
+		}
+	}
+	return nil
+}
+
+// renewLeaderScript extends the lock only if this instance still holds it
+var renewLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseLeaderScript deletes the lock only if this instance holds it
+var releaseLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// scheduleEntry is a periodic task registered with Workerd.Schedule
+type scheduleEntry struct {
+	cronspec string
+	task     *asynq.Task
+	opts     []asynq.Option
+}
+
+// periodicScheduler runs a cron with the registered and dynamic entries on
+// the elected leader only, so replicas don't enqueue the same periodic task.
+// Tasks are enqueued through the workerd client, like those of producers.
+type periodicScheduler struct {
+	config     *SchedulerConfig
+	routing    *RoutingConfig
+	rdb        redis.UniversalClient
+	key        string
+	entriesKey string
+	id         string
+	log        *slog.Logger
+	// reloaded is called after dynamic schedules were added or removed
+	reloaded func()
+	// client enqueues the periodic tasks, set by start
+	client Enqueuer
+
+	mu      sync.Mutex
+	entries []scheduleEntry
+	sched   *cron.Cron
+	leader  atomic.Bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	// dynamic maps the registered dynamic schedules to their cron entry IDs
+	dynamic map[string]cron.EntryID
+	// version is the dynamic schedules version last loaded, if synced is set
+	version string
+	synced  bool
+}
+
+// newPeriodicScheduler creates the scheduler of the worker named name
+func newPeriodicScheduler(config *SchedulerConfig, routing *RoutingConfig, redisOpt asynq.RedisConnOpt, name string, log *slog.Logger) (*periodicScheduler, error) {
+	rdb, ok := redisOpt.MakeRedisClient().(redis.UniversalClient)
+	if !ok {
+		return nil, fmt.Errorf("unsupported redis connection for the scheduler")
+	}
+	host, _ := os.Hostname()
+	return &periodicScheduler{
+		config:     config,
+		routing:    routing,
+		rdb:        rdb,
+		key:        config.LeaderKey + name,
+		entriesKey: config.EntriesKey + name,
+		id:         fmt.Sprintf("%s:%d", host, os.Getpid()),
+		log:        log,
+	}, nil
+}
+
+// options returns the routing table options of the task followed by opts,
+// as the client applies them
+func (s *periodicScheduler) options(task *asynq.Task, opts []asynq.Option) []asynq.Option {
+	return append(s.routing.EnqueueOptions(task.Type()), opts...)
+}
+
+// register adds a cron entry enqueuing task with opts through the client
+func (s *periodicScheduler) register(sched *cron.Cron, cronspec string, task *asynq.Task, opts []asynq.Option) (cron.EntryID, error) {
+	return sched.AddFunc(cronspec, func() {
+		info, err := s.client.EnqueueContext(context.Background(), task, opts...)
+		if err != nil {
+			s.log.Error("could not enqueue periodic task", "type", task.Type(), "error", err)
+			return
+		}
+		s.log.Debug("periodic task enqueued", "id", info.ID, "type", info.Type, "queue", info.Queue)
+	})
+}
+
+// add registers an entry, on the running cron too while leading
+func (s *periodicScheduler) add(e scheduleEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sched != nil {
+		if _, err := s.register(s.sched, e.cronspec, e.task, e.opts); err != nil {
+			return err
+		}
+	}
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// start runs the election, or leads right away without election, in the
+// background. Periodic tasks are enqueued through client.
+func (s *periodicScheduler) start(client Enqueuer) {
+	s.client = client
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx)
+}
+
+// run acquires or renews the leader lock and, while leading, reloads the
+// dynamic schedules until ctx is cancelled
+func (s *periodicScheduler) run(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.LeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		if s.config.LeaderElection {
+			s.campaign(ctx)
+		} else if !s.leader.Load() {
+			s.lead()
+		}
+		if s.leader.Load() {
+			if err := s.sync(ctx); err != nil && ctx.Err() == nil {
+				s.log.Warn("could not load dynamic schedules", "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign runs one election round
+func (s *periodicScheduler) campaign(ctx context.Context) {
+	ttl := s.config.LeaseTTL
+	if s.leader.Load() {
+		renewed, err := renewLeaderScript.Run(ctx, s.rdb, []string{s.key}, s.id, ttl.Milliseconds()).Int()
+		if err != nil || renewed == 0 {
+			s.log.Warn("lost scheduler leadership", "error", err)
+			s.stepDown()
+		}
+		return
+	}
+
+	acquired, err := s.rdb.SetNX(ctx, s.key, s.id, ttl).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			s.log.Warn("scheduler leader election failed", "error", err)
+		}
+		return
+	}
+	if acquired {
+		s.lead()
+	}
+}
+
+// lead starts the cron with every registered entry
+func (s *periodicScheduler) lead() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Expressions without CRON_TZ are evaluated in UTC
+	sched := cron.New(cron.WithLocation(time.UTC))
+	for _, e := range s.entries {
+		if _, err := s.register(sched, e.cronspec, e.task, e.opts); err != nil {
+			s.log.Error("could not schedule periodic task", "cron", e.cronspec, "type", e.task.Type(), "error", err)
+		}
+	}
+	sched.Start()
+	s.sched = sched
+	s.dynamic = map[string]cron.EntryID{}
+	s.synced = false
+	s.leader.Store(true)
+	s.log.Info("scheduler started on this instance", "entries", len(s.entries))
+}
+
+// stepDown stops the cron, waiting for enqueues in progress
+func (s *periodicScheduler) stepDown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sched != nil {
+		<-s.sched.Stop().Done()
+		s.sched = nil
+	}
+	s.dynamic = nil
+	s.leader.Store(false)
+}
+
+// stop leaves the election and hands leadership to another replica.
+// It is safe to call more than once.
+func (s *periodicScheduler) stop() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+		s.cancel = nil
+	}
+	wasLeader := s.leader.Load()
+	s.stepDown()
+	if wasLeader && s.config.LeaderElection {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := releaseLeaderScript.Run(ctx, s.rdb, []string{s.key}, s.id).Err(); err != nil {
+			s.log.Warn("could not release scheduler leadership", "error", err)
+		}
+	}
+}
+
+func (s *periodicScheduler) close() error {
+	return s.rdb.Close()
+}
+
+// Schedule enqueues task periodically on the cron schedule cronspec, e.g.
+// "0 3 * * *", "CRON_TZ=Africa/Nairobi 0 3 * * *" or "@every 30s". With
+// several replicas, only the elected leader enqueues it. The routing table
+// applies before opts.
+func (w *Workerd) Schedule(cronspec string, task *asynq.Task, opts ...asynq.Option) error {
+	if task == nil {
+		return fmt.Errorf("task cannot be nil")
+	}
+	if _, err := cron.ParseStandard(cronspec); err != nil {
+		return fmt.Errorf("invalid cron spec %q for task %q: %w", cronspec, task.Type(), err)
+	}
+	if err := w.scheduler.add(scheduleEntry{cronspec: cronspec, task: task, opts: opts}); err != nil {
+		return fmt.Errorf("failed to schedule task %q: %w", task.Type(), err)
+	}
+	return nil
+}
+
+// scheduleConfigured adds the schedules of the config file
+func (w *Workerd) scheduleConfigured(schedules []ScheduleConfig) error {
+	for i := range schedules {
+		sc := &schedules[i]
+		task, opts, err := sc.task()
+		if err != nil {
+			return fmt.Errorf("failed to build scheduled task %q: %w", sc.Task, err)
+		}
+		if err := w.Schedule(sc.cronspec(), task, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsLeader reports whether this instance currently enqueues periodic tasks
+func (w *Workerd) IsLeader() bool {
+	return w.scheduler != nil && w.scheduler.leader.Load()
+}
@@ -0,0 +1,44 @@
+package workerd
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+)
+
+// enqueueRecorder is an Enqueuer recording the enqueued task types
+type enqueueRecorder chan string
+
+func (r enqueueRecorder) EnqueueContext(_ context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	r <- task.Type()
+	return &asynq.TaskInfo{Type: task.Type(), Queue: queueOf(opts)}, nil
+}
+
+func TestSchedulerEnqueuesThroughClient(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config := &SchedulerConfig{LeaseTTL: 3 * time.Second, EntriesKey: "workerd:scheduler:entries:"}
+	s, err := newPeriodicScheduler(config, &RoutingConfig{}, asynq.RedisClientOpt{Addr: mr.Addr()}, "test", slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	if err := s.add(scheduleEntry{cronspec: "@every 1s", task: asynq.NewTask("cache:refresh", nil)}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := make(enqueueRecorder, 10)
+	s.start(client)
+	defer s.stop()
+	select {
+	case got := <-client:
+		if got != "cache:refresh" {
+			t.Errorf("enqueued %q, want cache:refresh", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("periodic task not enqueued through the client")
+	}
+}
@@ -0,0 +1,225 @@
+package workerd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrScheduleNotFound is returned when removing an unknown dynamic schedule
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ScheduleEntry describes a periodic task known to the scheduler
+type ScheduleEntry struct {
+	// ID of a dynamic schedule; empty for schedules from code or the config file
+	ID      string    `json:"id,omitempty"`
+	Cron    string    `json:"cron"`
+	Task    string    `json:"task"`
+	Queue   string    `json:"queue"`
+	Dynamic bool      `json:"dynamic"`
+	Next    time.Time `json:"next"`
+}
+
+// versionKey is incremented on every change of the dynamic schedules, so
+// the leader only reloads them when they changed
+func (s *periodicScheduler) versionKey() string {
+	return s.entriesKey + ":version"
+}
+
+// loadDynamic reads the dynamic schedules by ID
+func (s *periodicScheduler) loadDynamic(ctx context.Context) (map[string]ScheduleConfig, error) {
+	values, err := s.rdb.HGetAll(ctx, s.entriesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]ScheduleConfig, len(values))
+	for id, v := range values {
+		var sc ScheduleConfig
+		if err := json.Unmarshal([]byte(v), &sc); err != nil {
+			s.log.Warn("skipping invalid dynamic schedule", "id", id, "error", err)
+			continue
+		}
+		entries[id] = sc
+	}
+	return entries, nil
+}
+
+// sync registers added dynamic schedules on the running cron and
+// unregisters removed ones
+func (s *periodicScheduler) sync(ctx context.Context) error {
+	version, err := s.rdb.Get(ctx, s.versionKey()).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	s.mu.Lock()
+	current := s.synced && s.version == version
+	s.mu.Unlock()
+	if current {
+		return nil
+	}
+
+	entries, err := s.loadDynamic(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	// the first load after becoming leader is not a change
+	changed := s.apply(entries) && s.synced
+	s.version, s.synced = version, true
+	s.mu.Unlock()
+
+	if changed && s.reloaded != nil {
+		s.reloaded()
+	}
+	return nil
+}
+
+// apply makes the registered dynamic schedules match entries and reports
+// whether any changed; s.mu must be held
+func (s *periodicScheduler) apply(entries map[string]ScheduleConfig) bool {
+	if s.sched == nil {
+		return false
+	}
+	changed := false
+	for id, entryID := range s.dynamic {
+		if _, ok := entries[id]; ok {
+			continue
+		}
+		s.sched.Remove(entryID)
+		delete(s.dynamic, id)
+		changed = true
+	}
+	for id, sc := range entries {
+		if _, ok := s.dynamic[id]; ok {
+			continue
+		}
+		task, opts, err := sc.task()
+		if err != nil {
+			s.log.Warn("skipping invalid dynamic schedule", "id", id, "error", err)
+			continue
+		}
+		entryID, err := s.register(s.sched, sc.cronspec(), task, opts)
+		if err != nil {
+			s.log.Warn("could not schedule dynamic schedule", "id", id, "error", err)
+			continue
+		}
+		s.dynamic[id] = entryID
+		changed = true
+	}
+	return changed
+}
+
+// newScheduleID returns a random ID for a dynamic schedule
+func newScheduleID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// AddSchedule stores a periodic task in Redis and returns its ID. Unlike
+// Schedule, it can be called from any replica or from the admin API at any
+// time, and the schedule survives restarts until RemoveSchedule. The leader
+// picks it up within a third of the scheduler lease TTL.
+func (w *Workerd) AddSchedule(ctx context.Context, sc ScheduleConfig) (string, error) {
+	if err := sc.validate(); err != nil {
+		return "", fmt.Errorf("invalid schedule: %w", err)
+	}
+	data, err := json.Marshal(sc)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode schedule: %w", err)
+	}
+	id, err := newScheduleID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate schedule ID: %w", err)
+	}
+
+	s := w.scheduler
+	_, err = s.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, s.entriesKey, id, data)
+		p.Incr(ctx, s.versionKey())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store schedule: %w", err)
+	}
+	w.log.Info("dynamic schedule added", "id", id, "cron", sc.cronspec(), "task", sc.Task)
+	return id, nil
+}
+
+// RemoveSchedule deletes a dynamic schedule added with AddSchedule
+func (w *Workerd) RemoveSchedule(ctx context.Context, id string) error {
+	s := w.scheduler
+	var removed *redis.IntCmd
+	_, err := s.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		removed = p.HDel(ctx, s.entriesKey, id)
+		p.Incr(ctx, s.versionKey())
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove schedule %q: %w", id, err)
+	}
+	if removed.Val() == 0 {
+		return fmt.Errorf("failed to remove schedule %q: %w", id, ErrScheduleNotFound)
+	}
+	w.log.Info("dynamic schedule removed", "id", id)
+	return nil
+}
+
+// Schedules lists the periodic tasks registered in code or the config file
+// followed by the dynamic schedules, with their next run time
+func (w *Workerd) Schedules(ctx context.Context) ([]ScheduleEntry, error) {
+	s := w.scheduler
+	now := time.Now()
+
+	s.mu.Lock()
+	list := make([]ScheduleEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, scheduleEntryOf("", e.cronspec, e.task, s.options(e.task, e.opts), now))
+	}
+	s.mu.Unlock()
+
+	dynamic, err := s.loadDynamic(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	ids := make([]string, 0, len(dynamic))
+	for id := range dynamic {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		sc := dynamic[id]
+		task, opts, err := sc.task()
+		if err != nil {
+			continue
+		}
+		list = append(list, scheduleEntryOf(id, sc.cronspec(), task, s.options(task, opts), now))
+	}
+	return list, nil
+}
+
+// scheduleEntryOf describes a schedule; the last queue option wins as in asynq
+func scheduleEntryOf(id, cronspec string, task *asynq.Task, opts []asynq.Option, now time.Time) ScheduleEntry {
+	e := ScheduleEntry{ID: id, Cron: cronspec, Task: task.Type(), Queue: "default", Dynamic: id != ""}
+	for _, o := range opts {
+		if o.Type() == asynq.QueueOpt {
+			e.Queue = o.Value().(string)
+		}
+	}
+	if sched, err := cron.ParseStandard(cronspec); err == nil {
+		e.Next = sched.Next(now).UTC()
+	}
+	return e
+}
@@ -0,0 +1,162 @@
+package workerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/hibiken/asynq"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// payloadValidator checks a task payload
+type payloadValidator func(payload []byte) error
+
+// schemaRegistry holds payload validators by task type
+type schemaRegistry struct {
+	mu         sync.RWMutex
+	validators map[string]payloadValidator
+	// What the payload is checked against, for Handlers
+	kinds map[string]string
+}
+
+func (r *schemaRegistry) set(taskType string, v payloadValidator, kind string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.validators == nil {
+		r.validators = make(map[string]payloadValidator)
+		r.kinds = make(map[string]string)
+	}
+	r.validators[taskType] = v
+	r.kinds[taskType] = kind
+}
+
+func (r *schemaRegistry) kind(taskType string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.kinds[taskType]
+}
+
+func (r *schemaRegistry) get(taskType string) payloadValidator {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.validators[taskType]
+}
+
+// RegisterSchema declares the JSON Schema payloads of taskType must satisfy.
+// Tasks with a non-conforming payload fail without retries.
+func (w *Workerd) RegisterSchema(taskType string, schema []byte) error {
+	if taskType == "" {
+		return fmt.Errorf("task type cannot be empty")
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return fmt.Errorf("invalid JSON schema for %q: %w", taskType, err)
+	}
+	location := "workerd://schemas/" + url.PathEscape(taskType)
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(location, doc); err != nil {
+		return fmt.Errorf("invalid JSON schema for %q: %w", taskType, err)
+	}
+	compiled, err := compiler.Compile(location)
+	if err != nil {
+		return fmt.Errorf("invalid JSON schema for %q: %w", taskType, err)
+	}
+
+	w.schemas.set(taskType, func(payload []byte) error {
+		inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("payload is not valid JSON: %w", err)
+		}
+		return compiled.Validate(inst)
+	}, "JSON schema")
+	return nil
+}
+
+// RegisterPayloadType declares the Go struct payloads of taskType decode into.
+// Payloads must decode without unknown fields and, if the struct implements
+// Validate() error, pass its validation.
+func (w *Workerd) RegisterPayloadType(taskType string, v any) error {
+	if taskType == "" {
+		return fmt.Errorf("task type cannot be empty")
+	}
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return fmt.Errorf("payload type for %q cannot be nil", taskType)
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	w.schemas.set(taskType, func(payload []byte) error {
+		target := reflect.New(t).Interface()
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(target); err != nil {
+			return err
+		}
+		if validator, ok := target.(interface{ Validate() error }); ok {
+			return validator.Validate()
+		}
+		return nil
+	}, t.String())
+	return nil
+}
+
+// ValidatePayload checks payload against the schema registered for taskType,
+// letting producers reject bad tasks before enqueueing them
+func (w *Workerd) ValidatePayload(taskType string, payload []byte) error {
+	validate := w.schemas.get(taskType)
+	if validate == nil {
+		return nil
+	}
+	if err := validate(payload); err != nil {
+		return fmt.Errorf("invalid payload for %q: %w", taskType, err)
+	}
+	return nil
+}
+
+// registerSchemaFiles loads the JSON Schemas listed in the configuration
+func (w *Workerd) registerSchemaFiles(files map[string]string) error {
+	types := make([]string, 0, len(files))
+	for taskType := range files {
+		types = append(types, taskType)
+	}
+	sort.Strings(types)
+
+	for _, taskType := range types {
+		schema, err := os.ReadFile(files[taskType])
+		if err != nil {
+			return fmt.Errorf("failed to read schema for %q: %w", taskType, err)
+		}
+		if err := w.RegisterSchema(taskType, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaMiddleware rejects tasks whose payload does not match their schema.
+// Validation failures skip retries since the payload cannot become valid.
+func schemaMiddleware(r *schemaRegistry) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			validate := r.get(t.Type())
+			if validate == nil {
+				return next.ProcessTask(ctx, t)
+			}
+			if err := validate(t.Payload()); err != nil {
+				Logger(ctx).Error("task payload rejected", "type", t.Type(), "validation_error", err.Error())
+				return fmt.Errorf("invalid payload for %q: %v: %w", t.Type(), err, asynq.SkipRetry)
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
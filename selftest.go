@@ -0,0 +1,149 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// SelfTestTaskType is the built-in diagnostic task processed by every worker
+const SelfTestTaskType = "workerd:selftest"
+
+const selfTestCommandUsage = "selftest [-timeout 30s] [-queue name]  verify a worker processes tasks end to end"
+
+// selfTestPayload is enqueued by the selftest command
+type selfTestPayload struct {
+	EnqueuedAt time.Time `json:"enqueued_at"`
+}
+
+// SelfTestResult is written by the worker that handled the diagnostic task
+type SelfTestResult struct {
+	Worker      string    `json:"worker"`
+	Host        string    `json:"host"`
+	PID         int       `json:"pid"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// handleSelfTest answers the diagnostic task with the identity of this worker
+func (w *Workerd) handleSelfTest(ctx context.Context, t *asynq.Task) error {
+	host, _ := os.Hostname()
+	result, err := json.Marshal(SelfTestResult{
+		Worker:      w.name,
+		Host:        host,
+		PID:         os.Getpid(),
+		ProcessedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	rw := ResultWriter(ctx)
+	if rw == nil {
+		return fmt.Errorf("selftest task has no result writer")
+	}
+	if _, err := rw.Write(result); err != nil {
+		return fmt.Errorf("failed to write selftest result: %w", err)
+	}
+	return nil
+}
+
+// SelfTest enqueues the diagnostic task and waits until a worker completes it,
+// returning the round-trip latency and the handling worker's identity
+func (w *Workerd) SelfTest(ctx context.Context, queue string) (time.Duration, *SelfTestResult, error) {
+	if queue == "" {
+		queue = w.defaultQueue()
+	}
+
+	client, err := w.Client()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	start := time.Now()
+	payload, err := json.Marshal(selfTestPayload{EnqueuedAt: start})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	info, err := client.EnqueueContext(ctx, asynq.NewTask(SelfTestTaskType, payload),
+		asynq.Queue(queue), asynq.MaxRetry(0), asynq.Retention(time.Hour))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to enqueue selftest task: %w", err)
+	}
+
+	inspector := w.Inspector()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, nil, fmt.Errorf("selftest task %s was not processed: %w", info.ID, ctx.Err())
+		case <-ticker.C:
+		}
+
+		task, err := inspector.GetTaskInfo(queue, info.ID)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to inspect selftest task: %w", err)
+		}
+
+		switch task.State {
+		case asynq.TaskStateCompleted:
+			latency := time.Since(start)
+			var result SelfTestResult
+			if err := json.Unmarshal(task.Result, &result); err != nil {
+				return latency, nil, fmt.Errorf("invalid selftest result: %w", err)
+			}
+			_ = inspector.DeleteTask(queue, info.ID)
+			return latency, &result, nil
+		case asynq.TaskStateArchived, asynq.TaskStateRetry:
+			return 0, nil, fmt.Errorf("selftest task failed: %s", task.LastErr)
+		}
+	}
+}
+
+// defaultQueue returns "default" or, if it isn't processed, the highest priority queue
+func (w *Workerd) defaultQueue() string {
+	queues := w.config.Queues
+	if len(queues) == 0 {
+		return "default"
+	}
+	if _, ok := queues["default"]; ok {
+		return "default"
+	}
+	best, bestPriority := "", 0
+	for q, p := range queues {
+		if p > bestPriority || (p == bestPriority && q < best) {
+			best, bestPriority = q, p
+		}
+	}
+	return best
+}
+
+// runSelfTestCommand implements `selftest`
+func runSelfTestCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "How long to wait for a worker")
+	queue := fs.String("queue", "", "Queue to send the diagnostic task to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	latency, result, err := w.SelfTest(ctx, *queue)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("selftest ok: round trip %v\n", latency.Round(time.Millisecond))
+	fmt.Printf("handled by %s on %s (pid %d) at %s\n",
+		result.Worker, result.Host, result.PID, result.ProcessedAt.Format(time.RFC3339Nano))
+	return nil
+}
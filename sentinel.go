@@ -0,0 +1,287 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// sentinelPingInterval is how often an idle Sentinel subscription is checked
+const sentinelPingInterval = 15 * time.Second
+
+// sentinelResubscribeDelay is the wait before subscribing again, to the next
+// Sentinel, once a subscription is lost
+const sentinelResubscribeDelay = 5 * time.Second
+
+// RedisSentinel configures Redis Sentinel, which reports the current primary
+// and promotes a replica when it fails
+type RedisSentinel struct {
+	// Name of the primary monitored by Sentinel; setting it enables Sentinel
+	MasterName string `json:"masterName" yaml:"masterName" env:"ASYNQ_REDIS_SENTINEL_MASTER"`
+
+	// Sentinel addresses in "host:port" format
+	Addrs []string `json:"addrs" yaml:"addrs"`
+
+	// Credentials of the Sentinels, when they differ from Redis'
+	Username string `json:"username" yaml:"username" env:"ASYNQ_REDIS_SENTINEL_USERNAME"`
+	Password string `json:"password" yaml:"password" env:"ASYNQ_REDIS_SENTINEL_PASSWORD" secret:"true"`
+
+	// Hold fetched tasks from the moment Sentinel starts a failover until the
+	// new primary is announced, instead of running them against a primary
+	// that is going away
+	PauseOnFailover bool `json:"pauseOnFailover" yaml:"pauseOnFailover" env:"ASYNQ_REDIS_SENTINEL_PAUSE_ON_FAILOVER" default:"true"`
+
+	// Longest pause for one failover, after which tasks run again even if no
+	// new primary was announced
+	FailoverTimeout time.Duration `json:"failoverTimeout" yaml:"failoverTimeout" env:"ASYNQ_REDIS_SENTINEL_FAILOVER_TIMEOUT" default:"1m"`
+}
+
+// enabled reports whether the primary is located through Sentinel
+func (rs *RedisSentinel) enabled() bool {
+	return rs.MasterName != ""
+}
+
+// validate validates the Sentinel configuration
+func (rs *RedisSentinel) validate() error {
+	if !rs.enabled() {
+		if len(rs.Addrs) > 0 {
+			return fmt.Errorf("master name is required with sentinel addresses")
+		}
+		return nil
+	}
+	if len(rs.Addrs) == 0 {
+		return fmt.Errorf("at least one sentinel address is required")
+	}
+	for _, addr := range rs.Addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid sentinel address %q: %w", addr, err)
+		}
+	}
+	if rs.FailoverTimeout <= 0 {
+		return fmt.Errorf("failover timeout must be positive, got %v", rs.FailoverTimeout)
+	}
+	return nil
+}
+
+// errFailover is reported by the readiness check during a Redis failover
+var errFailover = errors.New("redis failover in progress")
+
+// failoverGate holds tasks while a Redis failover is in progress
+type failoverGate struct {
+	mu sync.Mutex
+	// Closed when the failover ends; nil while none is in progress
+	resumed chan struct{}
+	since   time.Time
+	timer   *time.Timer
+}
+
+// pause holds tasks until resume is called, or until timeout passes and
+// onTimeout is called with the time paused. It reports false when a failover
+// is already in progress.
+func (g *failoverGate) pause(timeout time.Duration, onTimeout func(paused time.Duration)) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	resumed := make(chan struct{})
+	g.resumed, g.since = resumed, time.Now()
+	g.timer = time.AfterFunc(timeout, func() {
+		g.mu.Lock()
+		if g.resumed != resumed {
+			g.mu.Unlock()
+			return
+		}
+		paused, _ := g.resumeLocked()
+		g.mu.Unlock()
+		onTimeout(paused)
+	})
+	return true
+}
+
+// resume releases the held tasks, returning how long they were held. It
+// reports false when no failover is in progress.
+func (g *failoverGate) resume() (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumeLocked()
+}
+
+func (g *failoverGate) resumeLocked() (time.Duration, bool) {
+	if g.resumed == nil {
+		return 0, false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	g.timer.Stop()
+	return time.Since(g.since), true
+}
+
+// wait blocks while a failover is in progress
+func (g *failoverGate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// err returns errFailover while a failover is in progress
+func (g *failoverGate) err() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return nil
+	}
+	return fmt.Errorf("%w for %v", errFailover, time.Since(g.since).Round(time.Second))
+}
+
+// failoverMiddleware holds tasks while a failover is in progress. Holding
+// them keeps the worker's slots busy, so asynq stops fetching until the new
+// primary is known.
+func failoverMiddleware(g *failoverGate, config *RedisSentinel) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if g == nil || !config.PauseOnFailover {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			if err := g.wait(ctx); err != nil {
+				return err
+			}
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
+
+// sentinelWatcher follows the failover notifications of the Sentinels, so a
+// failover is logged and tasks are held as soon as it starts rather than
+// failing on timeouts against the old primary. The connections themselves
+// are moved to the new primary by the Redis client.
+type sentinelWatcher struct {
+	w      *Workerd
+	config *RedisSentinel
+	gate   *failoverGate
+	// Index of the Sentinel subscribed to next
+	next int
+	// Sentinel subscribed to, to log changes only
+	current string
+}
+
+func newSentinelWatcher(w *Workerd, gate *failoverGate) *sentinelWatcher {
+	return &sentinelWatcher{w: w, config: &w.config.AsynqConfig.RedisClient.Sentinel, gate: gate}
+}
+
+// run subscribes to a Sentinel and handles its notifications until ctx is
+// done or the subscription is lost; the next run tries the next Sentinel
+func (s *sentinelWatcher) run(ctx context.Context) error {
+	addr := s.config.Addrs[s.next%len(s.config.Addrs)]
+	s.next++
+
+	sc := redis.NewSentinelClient(&redis.Options{
+		Addr:        addr,
+		Username:    s.config.Username,
+		Password:    s.config.Password,
+		DialTimeout: s.w.config.AsynqConfig.RedisClient.DialTimeout,
+	})
+	defer sc.Close()
+	ps := sc.PSubscribe(ctx, "+try-failover", "+switch-master", "-failover-abort-*")
+	defer ps.Close()
+
+	// Closing the subscription unblocks the read below on stop
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ps.Close()
+		case <-done:
+		}
+	}()
+
+	if _, err := ps.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to sentinel %s: %w", addr, err)
+	}
+	if s.current != addr {
+		s.w.log.Info("watching redis sentinel for failovers", "sentinel", addr, "master", s.config.MasterName)
+		s.current = addr
+	}
+
+	for {
+		msg, err := ps.ReceiveTimeout(ctx, sentinelPingInterval)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			err = ps.Ping(ctx)
+		}
+		if err != nil {
+			s.current = ""
+			return fmt.Errorf("lost sentinel %s: %w", addr, err)
+		}
+		if m, ok := msg.(*redis.Message); ok {
+			s.handle(m.Channel, m.Payload)
+		}
+	}
+}
+
+// handle reacts to a Sentinel notification about the configured primary
+func (s *sentinelWatcher) handle(channel, payload string) {
+	fields := strings.Fields(payload)
+	log := s.w.log
+	switch {
+	case channel == "+switch-master":
+		// <master name> <old ip> <old port> <new ip> <new port>
+		if len(fields) < 5 || fields[0] != s.config.MasterName {
+			return
+		}
+		from, to := net.JoinHostPort(fields[1], fields[2]), net.JoinHostPort(fields[3], fields[4])
+		attrs := []any{"master", s.config.MasterName, "from", from, "to", to}
+		if paused, ok := s.gate.resume(); ok {
+			attrs = append(attrs, "paused", paused.Round(time.Millisecond))
+		}
+		log.Warn("redis primary switched", attrs...)
+		s.w.emit(LifecycleRedisFailoverEnded, to, nil)
+
+	case len(fields) < 2 || fields[0] != "master" || fields[1] != s.config.MasterName:
+		// Notifications about replicas and other primaries
+
+	case channel == "+try-failover":
+		if !s.gate.pause(s.config.FailoverTimeout, s.timedOut) {
+			return
+		}
+		log.Warn("redis failover started, holding tasks until the new primary is known", "master", s.config.MasterName, "timeout", s.config.FailoverTimeout)
+		s.w.emit(LifecycleRedisFailover, s.config.MasterName, nil)
+
+	case strings.HasPrefix(channel, "-failover-abort-"):
+		if _, ok := s.gate.resume(); !ok {
+			return
+		}
+		reason := strings.TrimPrefix(channel, "-failover-abort-")
+		log.Warn("redis failover aborted, resuming tasks", "master", s.config.MasterName, "reason", reason)
+		s.w.emit(LifecycleRedisFailoverEnded, "", fmt.Errorf("failover aborted: %s", reason))
+	}
+}
+
+// timedOut resumes tasks when no new primary was announced in time
+func (s *sentinelWatcher) timedOut(paused time.Duration) {
+	s.w.log.Warn("redis failover not completed in time, resuming tasks", "master", s.config.MasterName, "paused", paused.Round(time.Second))
+	s.w.emit(LifecycleRedisFailoverEnded, "", fmt.Errorf("no new primary announced within %v", s.config.FailoverTimeout))
+}
@@ -0,0 +1,133 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/hibiken/asynq"
+)
+
+// SentryConfig configures error reporting to Sentry; it is enabled by setting a DSN
+type SentryConfig struct {
+	DSN         string `json:"dsn" yaml:"dsn" env:"WORKER_SENTRY_DSN" secret:"true"`
+	Environment string `json:"environment" yaml:"environment" env:"WORKER_SENTRY_ENVIRONMENT"`
+	Release     string `json:"release" yaml:"release" env:"WORKER_SENTRY_RELEASE"`
+
+	// Fraction of errors sent, between 0 and 1
+	SampleRate float64 `json:"sampleRate" yaml:"sampleRate" env:"WORKER_SENTRY_SAMPLE_RATE" default:"1"`
+
+	// Maximum number of payload bytes attached to an event; 0 omits the payload
+	PayloadLimit int `json:"payloadLimit" yaml:"payloadLimit" env:"WORKER_SENTRY_PAYLOAD_LIMIT" default:"1024"`
+}
+
+// validate validates the Sentry configuration
+func (sc *SentryConfig) validate() error {
+	if sc.DSN == "" {
+		return nil
+	}
+	if sc.SampleRate < 0 || sc.SampleRate > 1 {
+		return fmt.Errorf("sentry sample rate must be between 0 and 1, got %v", sc.SampleRate)
+	}
+	if sc.PayloadLimit < 0 {
+		return fmt.Errorf("sentry payload limit must be non-negative, got %d", sc.PayloadLimit)
+	}
+	return nil
+}
+
+// sentryReporter captures handler errors and panics
+type sentryReporter struct {
+	hub          *sentry.Hub
+	payloadLimit int
+}
+
+// newSentryReporter creates a dedicated Sentry client so the global hub is left untouched
+func newSentryReporter(config *SentryConfig, serverName string) (*sentryReporter, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         config.DSN,
+		Environment: config.Environment,
+		Release:     config.Release,
+		SampleRate:  config.SampleRate,
+		ServerName:  serverName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sentry client: %w", err)
+	}
+	return &sentryReporter{
+		hub:          sentry.NewHub(client, sentry.NewScope()),
+		payloadLimit: config.PayloadLimit,
+	}, nil
+}
+
+// capture reports err, or a recovered panic value, with the task's details as tags
+func (s *sentryReporter) capture(ctx context.Context, t *asynq.Task, err error, recovered any) {
+	hub := s.hub.Clone()
+	hub.ConfigureScope(func(scope *sentry.Scope) {
+		queue, _ := asynq.GetQueueName(ctx)
+		id, _ := asynq.GetTaskID(ctx)
+		retried, _ := asynq.GetRetryCount(ctx)
+		scope.SetTag("task_type", t.Type())
+		scope.SetTag("queue", queue)
+		scope.SetTag("task_id", id)
+		scope.SetTag("retry_count", strconv.Itoa(retried))
+		if cid := CorrelationID(ctx); cid != "" {
+			scope.SetTag("correlation_id", cid)
+		}
+		if s.payloadLimit > 0 {
+			scope.SetContext("task", sentry.Context{"payload": payloadSnippet(t.Payload(), s.payloadLimit)})
+		}
+	})
+
+	if recovered != nil {
+		hub.Recover(recovered)
+		return
+	}
+	hub.CaptureException(err)
+}
+
+// flush waits for buffered events to be sent
+func (s *sentryReporter) flush(timeout time.Duration) {
+	s.hub.Flush(timeout)
+}
+
+// payloadSnippet returns at most limit bytes of the payload in printable form
+func payloadSnippet(payload []byte, limit int) string {
+	truncated := len(payload) > limit
+	if truncated {
+		payload = payload[:limit]
+	}
+	snippet := string(payload)
+	if !utf8.Valid(payload) {
+		snippet = strconv.Quote(snippet)
+	}
+	if truncated {
+		snippet += "...(truncated)"
+	}
+	return snippet
+}
+
+// sentryMiddleware reports failed tasks; panics are reported and then re-raised
+// so asynq still handles them as before
+func sentryMiddleware(s *sentryReporter) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if s == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					s.capture(ctx, t, nil, r)
+					panic(r)
+				}
+			}()
+
+			if err = next.ProcessTask(ctx, t); err != nil {
+				s.capture(ctx, t, err, nil)
+			}
+			return err
+		})
+	}
+}
@@ -2,13 +2,82 @@ package workerd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
 )
 
+// ServerTuning sets how often asynq servers poll Redis. Zero values keep
+// asynq's defaults; shorter intervals lower latency at the cost of more
+// Redis commands.
+type ServerTuning struct {
+	// Wait between checks for new tasks once every queue is empty.
+	// Default is 1 second.
+	TaskCheckInterval time.Duration `json:"taskCheckInterval" yaml:"taskCheckInterval" env:"ASYNQ_TASK_CHECK_INTERVAL" default:"0s"`
+
+	// Wait between moves of due scheduled and retry tasks to pending, which
+	// delays them by up to this long. Default is 5 seconds.
+	DelayedTaskCheckInterval time.Duration `json:"delayedTaskCheckInterval" yaml:"delayedTaskCheckInterval" env:"ASYNQ_DELAYED_TASK_CHECK_INTERVAL" default:"0s"`
+
+	// Wait for another task of a group before aggregating it, restarted by
+	// each task, at least 1 second. Default is 1 minute.
+	GroupGracePeriod time.Duration `json:"groupGracePeriod" yaml:"groupGracePeriod" env:"ASYNQ_GROUP_GRACE_PERIOD" default:"0s"`
+
+	// Longest wait before aggregating a group; 0 sets no limit
+	GroupMaxDelay time.Duration `json:"groupMaxDelay" yaml:"groupMaxDelay" env:"ASYNQ_GROUP_MAX_DELAY" default:"0s"`
+
+	// Tasks that make a group aggregate right away; 0 sets no limit
+	GroupMaxSize int `json:"groupMaxSize" yaml:"groupMaxSize" env:"ASYNQ_GROUP_MAX_SIZE" default:"0"`
+
+	// Average wait between deletions of expired completed tasks, and how
+	// many each deletes. Defaults are 8 seconds and 100.
+	JanitorInterval  time.Duration `json:"janitorInterval" yaml:"janitorInterval" env:"ASYNQ_JANITOR_INTERVAL" default:"0s"`
+	JanitorBatchSize int           `json:"janitorBatchSize" yaml:"janitorBatchSize" env:"ASYNQ_JANITOR_BATCH_SIZE" default:"0"`
+}
+
+// validate validates the tuning; asynq panics on a group grace period below
+// a second
+func (st *ServerTuning) validate() error {
+	for _, interval := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"task check interval", st.TaskCheckInterval},
+		{"delayed task check interval", st.DelayedTaskCheckInterval},
+		{"group grace period", st.GroupGracePeriod},
+		{"group max delay", st.GroupMaxDelay},
+		{"janitor interval", st.JanitorInterval},
+	} {
+		if interval.d < 0 {
+			return fmt.Errorf("%s must be non-negative, got %v", interval.name, interval.d)
+		}
+	}
+	if st.GroupGracePeriod > 0 && st.GroupGracePeriod < time.Second {
+		return fmt.Errorf("group grace period must be at least 1s, got %v", st.GroupGracePeriod)
+	}
+	if st.GroupMaxSize < 0 || st.JanitorBatchSize < 0 {
+		return fmt.Errorf("group max size and janitor batch size must be non-negative, got %d and %d", st.GroupMaxSize, st.JanitorBatchSize)
+	}
+	return nil
+}
+
+// apply sets the tuning on an asynq server configuration
+func (st *ServerTuning) apply(c *asynq.Config) {
+	c.TaskCheckInterval = st.TaskCheckInterval
+	c.DelayedTaskCheckInterval = st.DelayedTaskCheckInterval
+	c.GroupGracePeriod = st.GroupGracePeriod
+	c.GroupMaxDelay = st.GroupMaxDelay
+	c.GroupMaxSize = st.GroupMaxSize
+	c.JanitorInterval = st.JanitorInterval
+	c.JanitorBatchSize = st.JanitorBatchSize
+}
+
 // ServerBuilder handles asynq server creation and configuration
 type ServerBuilder struct {
-	config *workerConfig
+	config   *workerConfig
+	queues   map[string]int
+	redisOpt asynq.RedisConnOpt
+	hooks    []func(*asynq.Config)
 }
 
 // NewServerBuilder creates a new server builder
@@ -19,23 +88,85 @@ func NewServerBuilder(config *workerConfig) (*ServerBuilder, error) {
 	return &ServerBuilder{config: config}, nil
 }
 
+// WithAsynqConfig adds hooks that may modify the asynq.Config before the server is created.
+// Hooks run after workerd's own settings are applied, so they take precedence.
+func (sb *ServerBuilder) WithAsynqConfig(hooks ...func(*asynq.Config)) *ServerBuilder {
+	for _, hook := range hooks {
+		if hook != nil {
+			sb.hooks = append(sb.hooks, hook)
+		}
+	}
+	return sb
+}
+
+// WithQueues overrides the configured queues, e.g. for a worker pool
+func (sb *ServerBuilder) WithQueues(queues map[string]int) *ServerBuilder {
+	sb.queues = queues
+	return sb
+}
+
+// WithRedisConnOpt overrides the configured Redis connection, e.g. for a
+// shard; nil keeps it
+func (sb *ServerBuilder) WithRedisConnOpt(opt asynq.RedisConnOpt) *ServerBuilder {
+	sb.redisOpt = opt
+	return sb
+}
+
+// serverQueues returns the queues the server will process, nil meaning
+// asynq's default queue. Queues stored on a shard are left to the shard's
+// server, so none may be left when every queue is on a shard.
+func (sb *ServerBuilder) serverQueues() map[string]int {
+	if sb.queues != nil {
+		return sb.queues
+	}
+	if len(sb.config.AsynqConfig.Shards) == 0 {
+		return sb.config.Queues
+	}
+	configured := sb.config.Queues
+	if len(configured) == 0 {
+		configured = map[string]int{"default": 1}
+	}
+	queues := make(map[string]int, len(configured))
+	for q, p := range configured {
+		if sb.config.AsynqConfig.shardOf(q) < 0 {
+			queues[q] = p
+		}
+	}
+	return queues
+}
+
 // BuildServer creates and configures an asynq server
 func (sb *ServerBuilder) BuildServer(concurrency int) (*asynq.Server, error) {
-	if concurrency <= 0 {
-		return nil, fmt.Errorf("concurrency must be positive, got %d", concurrency)
+	if err := sb.ValidateServerConfig(concurrency); err != nil {
+		return nil, err
 	}
 
 	// Get Redis client options
-	redisOpt, err := sb.config.AsynqConfig.GetRedisClientOpt()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get Redis client options: %w", err)
+	redisOpt := sb.redisOpt
+	if redisOpt == nil {
+		var err error
+		if redisOpt, err = sb.config.AsynqConfig.RedisConnOpt(); err != nil {
+			return nil, fmt.Errorf("failed to get Redis client options: %w", err)
+		}
 	}
 
 	// Create server configuration
 	serverConfig := asynq.Config{
-		Concurrency: concurrency,
+		Concurrency:    concurrency,
+		Queues:         sb.serverQueues(),
+		StrictPriority: sb.config.StrictPriority,
+		RetryDelayFunc: retryDelayFunc,
+		IsFailure:      isFailure,
 		// Additional server configurations can be added here
 	}
+	sb.config.AsynqConfig.Server.apply(&serverConfig)
+
+	for _, hook := range sb.hooks {
+		hook(&serverConfig)
+	}
+	if serverConfig.Concurrency <= 0 {
+		return nil, fmt.Errorf("concurrency must be positive, got %d", serverConfig.Concurrency)
+	}
 
 	// Create and return the server
 	server := asynq.NewServer(redisOpt, serverConfig)
@@ -60,7 +191,7 @@ func (sb *ServerBuilder) ValidateServerConfig(concurrency int) error {
 	if concurrency <= 0 {
 		return fmt.Errorf("concurrency must be positive, got %d", concurrency)
 	}
-	
+
 	if sb.config.AsynqConfig == nil {
 		return fmt.Errorf("asynq configuration is required")
 	}
@@ -70,5 +201,19 @@ func (sb *ServerBuilder) ValidateServerConfig(concurrency int) error {
 		return fmt.Errorf("invalid asynq configuration: %w", err)
 	}
 
+	queues := sb.serverQueues()
+	// asynq would process the default queue instead of none
+	if queues != nil && len(queues) == 0 {
+		return fmt.Errorf("every queue is stored on a shard, at least one must stay on the primary redis")
+	}
+	for queue, priority := range queues {
+		if queue == "" {
+			return fmt.Errorf("queue name cannot be empty")
+		}
+		if priority <= 0 {
+			return fmt.Errorf("queue %q priority must be positive, got %d", queue, priority)
+		}
+	}
+
 	return nil
-}
\ No newline at end of file
+}
@@ -1,6 +1,7 @@
 package workerd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
@@ -36,6 +37,13 @@ func NewServiceManager(w *Workerd) (*ServiceManager, error) {
 	}
 	sm.logger = logger
 
+	// Route the built-in logger to syslog or the Event Log under a service manager.
+	// It is replaced in place so components already holding it follow along.
+	if w.ownsLogger && w.logOutput == nil && w.config != nil && w.config.Logging.System && !service.Interactive() {
+		*w.log = *newServiceLogger(w.logLevel, logger)
+		w.systemLog = true
+	}
+
 	// Start error logging goroutine
 	go sm.startErrorLogging()
 
@@ -54,6 +62,9 @@ func (sm *ServiceManager) createService() (service.Service, error) {
 	if sm.workerd.configPath != "" {
 		svcConfig.Arguments = append(svcConfig.Arguments, "-config", sm.workerd.configPath)
 	}
+	if sm.workerd.pidFilePath != "" {
+		svcConfig.Arguments = append(svcConfig.Arguments, "-pid-file", sm.workerd.pidFilePath)
+	}
 
 	s, err := service.New(sm.workerd, svcConfig)
 	if err != nil {
@@ -87,7 +98,8 @@ func (sm *ServiceManager) startErrorLogging() {
 		err := <-sm.workerd.errorChan
 		if err != nil {
 			log.Print(err)
-			if sm.workerd.log != nil {
+			// Errors of the system logger itself must not be fed back into it
+			if sm.workerd.log != nil && !sm.workerd.systemLog {
 				sm.workerd.log.Error("Service error", "error", err)
 			}
 		}
@@ -104,23 +116,107 @@ func (sm *ServiceManager) HandleControl(action string) error {
 	}
 
 	switch action {
+	case "status":
+		return sm.printStatus()
 	case "run":
 		if err := sm.service.Run(); err != nil {
 			return fmt.Errorf("failed to run service: %w", err)
 		}
-	case "install", "uninstall", "start", "stop", "restart":
+	case "restart":
+		// With a PID file the running worker restarts in place, draining
+		// in-flight tasks; otherwise the service manager stops and starts it
+		if sm.workerd.pidFilePath != "" {
+			err := signalRestart(sm.workerd.pidFilePath)
+			if err == nil {
+				fmt.Printf("%s: graceful restart requested\n", sm.workerd.name)
+				return nil
+			}
+			sm.workerd.log.Warn("graceful restart unavailable, restarting through the service manager", "error", err)
+		}
+		if err := service.Control(sm.service, action); err != nil {
+			return fmt.Errorf("service control action '%s' failed: %w (valid actions: %q)",
+				action, err, service.ControlAction)
+		}
+	case "install", "uninstall", "start", "stop":
 		if err := service.Control(sm.service, action); err != nil {
 			return fmt.Errorf("service control action '%s' failed: %w (valid actions: %q)",
 				action, err, service.ControlAction)
 		}
 	default:
-		return fmt.Errorf("unknown service action '%s' (valid actions: run, %q)",
+		return fmt.Errorf("unknown service action '%s' (valid actions: run, status, %q)",
 			action, service.ControlAction)
 	}
 
 	return nil
 }
 
+// ServiceStatusError reports a service that is not running.
+// Code follows the LSB init script conventions for the status action.
+type ServiceStatusError struct {
+	Status string
+	Code   int
+}
+
+func (e *ServiceStatusError) Error() string {
+	return fmt.Sprintf("service is %s", e.Status)
+}
+
+// ExitCode returns the process exit code for the status
+func (e *ServiceStatusError) ExitCode() int {
+	return e.Code
+}
+
+// ExitCode maps an error returned by Run to a process exit code
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var statusErr *ServiceStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code
+	}
+	return 1
+}
+
+// Status queries the platform service manager for the service state
+func (sm *ServiceManager) Status() (string, error) {
+	status, err := sm.service.Status()
+	if errors.Is(err, service.ErrNotInstalled) {
+		return "not installed", nil
+	}
+	if err != nil {
+		return "unknown", fmt.Errorf("failed to query service status: %w", err)
+	}
+
+	switch status {
+	case service.StatusRunning:
+		return "running", nil
+	case service.StatusStopped:
+		return "stopped", nil
+	default:
+		return "unknown", nil
+	}
+}
+
+// printStatus prints the service state and returns an error carrying the exit code if not running
+func (sm *ServiceManager) printStatus() error {
+	status, err := sm.Status()
+	if err != nil {
+		sm.workerd.log.Error("could not determine service status", "error", err)
+	}
+
+	fmt.Printf("%s: %s\n", sm.workerd.name, status)
+
+	switch status {
+	case "running":
+		return nil
+	case "stopped":
+		return &ServiceStatusError{Status: status, Code: 3}
+	default:
+		return &ServiceStatusError{Status: status, Code: 4}
+	}
+}
+
 // GetService returns the underlying service instance
 func (sm *ServiceManager) GetService() service.Service {
 	return sm.service
@@ -0,0 +1,221 @@
+package workerd
+
+import (
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/hibiken/asynq"
+)
+
+// RedisShard is a Redis server storing some of the queues instead of the
+// primary, to spread task volumes too high for one Redis
+type RedisShard struct {
+	// Shard address in "host:port" format, or a socket path for the unix
+	// network; credentials, DB, timeouts and namespace are those of the primary
+	Addr string `json:"address" yaml:"address"`
+
+	// Network type to use, either tcp or unix; empty uses the network of
+	// the primary
+	Network string `json:"network" yaml:"network"`
+
+	// Queues stored on the shard, with the priorities set in the queues
+	// section or 1
+	Queues []string `json:"queues" yaml:"queues"`
+
+	// Tasks the shard's server processes at once; 0 uses the worker's
+	// concurrency
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+}
+
+// network returns the network of the shard, given that of the primary
+func (rs *RedisShard) network(primary string) string {
+	if rs.Network == "" {
+		return primary
+	}
+	return rs.Network
+}
+
+// validate validates the shard configuration, given the network of the primary
+func (rs *RedisShard) validate(primary string) error {
+	switch rs.network(primary) {
+	case "tcp":
+		if _, _, err := net.SplitHostPort(rs.Addr); err != nil {
+			return fmt.Errorf("invalid address %q: %w", rs.Addr, err)
+		}
+	case "unix":
+		if rs.Addr == "" {
+			return fmt.Errorf("socket path cannot be empty")
+		}
+	default:
+		return fmt.Errorf("unsupported network %q, want tcp or unix", rs.network(primary))
+	}
+	if len(rs.Queues) == 0 {
+		return fmt.Errorf("at least one queue is required")
+	}
+	if rs.Concurrency < 0 {
+		return fmt.Errorf("concurrency must be non-negative, got %d", rs.Concurrency)
+	}
+	return nil
+}
+
+// validateShards validates every shard and that no queue is on two of them
+func validateShards(shards []RedisShard, network string) error {
+	owner := make(map[string]string)
+	for i := range shards {
+		s := &shards[i]
+		if err := s.validate(network); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+		for _, q := range s.Queues {
+			if q == "" {
+				return fmt.Errorf("shard %s: queue name cannot be empty", s.Addr)
+			}
+			if other, ok := owner[q]; ok {
+				return fmt.Errorf("queue %q is on both shard %s and %s", q, other, s.Addr)
+			}
+			owner[q] = s.Addr
+		}
+	}
+	return nil
+}
+
+// shardOf returns the index of the shard storing queue, or -1 when the
+// primary stores it
+func (a *AsynqConfig) shardOf(queue string) int {
+	for i := range a.Shards {
+		if slices.Contains(a.Shards[i].Queues, queue) {
+			return i
+		}
+	}
+	return -1
+}
+
+// shardConnOpt returns the options of the connection to shard i
+func (a *AsynqConfig) shardConnOpt(i int) asynq.RedisConnOpt {
+	s := &a.Shards[i]
+	return a.namespaced(redisClientOpt{a.RedisClient.options(s.network(a.RedisClient.Network), s.Addr)})
+}
+
+// redisShard is the connection of the worker to a shard
+type redisShard struct {
+	opt       asynq.RedisConnOpt
+	inspector *asynq.Inspector
+}
+
+// addShards adds a worker pool consuming the queues of every shard from it
+func (w *Workerd) addShards(config *workerConfig) error {
+	for i, s := range config.AsynqConfig.Shards {
+		queues := make(map[string]int, len(s.Queues))
+		for _, q := range s.Queues {
+			queues[q] = 1
+			if p, ok := config.Queues[q]; ok {
+				queues[q] = p
+			}
+		}
+		concurrency := s.Concurrency
+		if concurrency == 0 {
+			concurrency = w.concurrency
+		}
+
+		opt := config.AsynqConfig.shardConnOpt(i)
+		shard := &redisShard{opt: w.redisPools.track(opt, s.Addr, config.AsynqConfig.RedisClient.PoolSize)}
+		pool := WorkerPoolConfig{Concurrency: concurrency, Queues: queues}
+		if err := w.addWorkerPool("shard "+s.Addr, pool, shard.opt); err != nil {
+			return err
+		}
+		w.shards = append(w.shards, shard)
+	}
+	return nil
+}
+
+// QueueInspector returns an asynq inspector connected to the Redis storing
+// queue: its shard, or the primary
+func (w *Workerd) QueueInspector(queue string) *asynq.Inspector {
+	i := w.config.AsynqConfig.shardOf(queue)
+	if i < 0 {
+		return w.Inspector()
+	}
+	return w.shardInspector(i)
+}
+
+// shardInspector returns an asynq inspector connected to shard i
+func (w *Workerd) shardInspector(i int) *asynq.Inspector {
+	s := w.shards[i]
+	if s.inspector == nil {
+		s.inspector = asynq.NewInspector(s.opt)
+	}
+	return s.inspector
+}
+
+// inspectors returns the inspectors of the primary and of every shard
+func (w *Workerd) inspectors() []*asynq.Inspector {
+	inspectors := []*asynq.Inspector{w.Inspector()}
+	for i := range w.shards {
+		inspectors = append(inspectors, w.shardInspector(i))
+	}
+	return inspectors
+}
+
+// readQueueInspector is QueueInspector for reads, which come from the read
+// replica for queues on the primary
+func (w *Workerd) readQueueInspector(queue string) *asynq.Inspector {
+	if w.config.AsynqConfig.shardOf(queue) < 0 {
+		return w.ReadInspector()
+	}
+	return w.QueueInspector(queue)
+}
+
+// queueNames lists the queues of the primary and of every shard
+func (w *Workerd) queueNames() ([]string, error) {
+	a := w.config.AsynqConfig
+	primary, err := w.ReadInspector().Queues()
+	if err != nil {
+		return nil, err
+	}
+	// Queues moved to a shard may still be known to the primary
+	queues := slices.DeleteFunc(primary, func(q string) bool { return a.shardOf(q) >= 0 })
+	for i, s := range a.Shards {
+		found, err := w.shardInspector(i).Queues()
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", s.Addr, err)
+		}
+		for _, q := range found {
+			if a.shardOf(q) == i {
+				queues = append(queues, q)
+			}
+		}
+	}
+	return queues, nil
+}
+
+// closeShards closes the inspectors of the shards
+func (w *Workerd) closeShards() {
+	for _, s := range w.shards {
+		if s.inspector == nil {
+			continue
+		}
+		if err := s.inspector.Close(); err != nil {
+			w.log.Warn("could not close shard inspector", "error", err)
+		}
+	}
+}
+
+// clientFor returns the client enqueueing to the Redis storing queue
+func (c *Client) clientFor(queue string) *asynq.Client {
+	if i := c.config.AsynqConfig.shardOf(queue); i >= 0 {
+		return c.shards[i]
+	}
+	return c.client
+}
+
+// queueOf returns the queue opts enqueue to
+func queueOf(opts []asynq.Option) string {
+	queue := "default"
+	for _, o := range opts {
+		if o != nil && o.Type() == asynq.QueueOpt {
+			queue = o.Value().(string)
+		}
+	}
+	return queue
+}
@@ -0,0 +1,75 @@
+package workerd
+
+import (
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestValidateShards(t *testing.T) {
+	shards := []RedisShard{
+		{Addr: "redis-1:6379", Queues: []string{"a"}},
+		{Addr: "redis-2:6379", Queues: []string{"b"}},
+	}
+	if err := validateShards(shards, "tcp"); err != nil {
+		t.Fatal(err)
+	}
+	shards[1].Queues = append(shards[1].Queues, "a")
+	if err := validateShards(shards, "tcp"); err == nil {
+		t.Error("queue on two shards accepted")
+	}
+	if err := validateShards([]RedisShard{{Addr: "redis-1", Queues: []string{"a"}}}, "tcp"); err == nil {
+		t.Error("address without port accepted")
+	}
+	if err := validateShards([]RedisShard{{Addr: "/run/redis.sock", Queues: []string{"a"}}}, "unix"); err != nil {
+		t.Errorf("socket of a unix primary rejected: %v", err)
+	}
+	unix := []RedisShard{{Addr: "/run/redis.sock", Network: "unix", Queues: []string{"a"}}}
+	if err := validateShards(unix, "tcp"); err != nil {
+		t.Errorf("unix shard of a tcp primary rejected: %v", err)
+	}
+	a := &AsynqConfig{RedisClient: RedisClient{Network: "tcp"}, Shards: unix}
+	if opt := a.shardConnOpt(0).MakeRedisClient().(*redis.Client).Options(); opt.Network != "unix" {
+		t.Errorf("shard network = %q, want unix", opt.Network)
+	}
+
+	a = &AsynqConfig{Shards: shards[:1]}
+	if a.shardOf("a") != 0 || a.shardOf("default") != -1 {
+		t.Errorf("shardOf(a) = %d, shardOf(default) = %d", a.shardOf("a"), a.shardOf("default"))
+	}
+}
+
+func TestServerQueuesSkipShards(t *testing.T) {
+	config, err := newWorkerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Queues = map[string]int{"default": 1, "emails": 3}
+	config.AsynqConfig.Shards = []RedisShard{{Addr: "redis-1:6379", Queues: []string{"emails"}}}
+	sb, err := NewServerBuilder(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sb.serverQueues(); len(got) != 1 || got["default"] != 1 {
+		t.Errorf("serverQueues = %v, want only default", got)
+	}
+
+	// asynq would fall back to the default queue, which is on the shard
+	for _, queues := range []map[string]int{{"emails": 3}, nil} {
+		config.Queues = queues
+		config.AsynqConfig.Shards[0].Queues = []string{"emails", "default"}
+		if err := sb.ValidateServerConfig(1); err == nil {
+			t.Errorf("primary server without queues accepted with queues %v", queues)
+		}
+	}
+}
+
+func TestQueueOf(t *testing.T) {
+	if got := queueOf(nil); got != "default" {
+		t.Errorf("queueOf(nil) = %q, want default", got)
+	}
+	if got := queueOf([]asynq.Option{asynq.Queue("a"), asynq.Queue("b")}); got != "b" {
+		t.Errorf("queueOf = %q, want the last queue, b", got)
+	}
+}
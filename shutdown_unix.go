@@ -0,0 +1,18 @@
+//go:build !windows
+
+package workerd
+
+import (
+	"os"
+	"syscall"
+)
+
+// requestShutdown asks the running service to stop gracefully by signalling
+// the current process, which the service runner handles like a normal stop
+func requestShutdown() error {
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return err
+	}
+	return p.Signal(syscall.SIGTERM)
+}
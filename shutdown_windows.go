@@ -0,0 +1,11 @@
+//go:build windows
+
+package workerd
+
+import "errors"
+
+// requestShutdown is not supported on Windows; stop the service through the
+// service control manager instead
+func requestShutdown() error {
+	return errors.New("remote shutdown is not supported on windows")
+}
@@ -0,0 +1,138 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// MetaDueAt holds when a task with an SLA became due: its enqueue time, or
+// the time it was scheduled for
+const MetaDueAt = "due_at"
+
+// SLARule declares how long tasks of a type may take from being due to completing
+type SLARule struct {
+	// Task type the rule applies to: an exact type name or a prefix such as "email:"
+	Type string `json:"type" yaml:"type"`
+
+	MaxLatency time.Duration `json:"maxLatency" yaml:"maxLatency"`
+}
+
+// SLAConfig configures the latency objectives of task types. Clients stamp
+// tasks of these types with their due time; workers compare it with the
+// completion time and report tasks that missed their objective.
+type SLAConfig struct {
+	Rules []SLARule `json:"rules" yaml:"rules"`
+}
+
+// validate validates the SLA rules
+func (sc *SLAConfig) validate() error {
+	seen := make(map[string]bool, len(sc.Rules))
+	for i, r := range sc.Rules {
+		if strings.TrimSpace(r.Type) == "" {
+			return fmt.Errorf("SLA rule %d: type cannot be empty", i)
+		}
+		if seen[r.Type] {
+			return fmt.Errorf("SLA rule %q: duplicate type", r.Type)
+		}
+		seen[r.Type] = true
+		if r.MaxLatency <= 0 {
+			return fmt.Errorf("SLA rule %q: max latency must be positive, got %v", r.Type, r.MaxLatency)
+		}
+	}
+	return nil
+}
+
+// ruleFor returns the most specific rule matching the task type, or nil
+func (sc *SLAConfig) ruleFor(taskType string) *SLARule {
+	var best *SLARule
+	for i := range sc.Rules {
+		r := &sc.Rules[i]
+		if r.Type == taskType {
+			return r
+		}
+		if matchTaskType(r.Type, taskType) && (best == nil || len(r.Type) > len(best.Type)) {
+			best = r
+		}
+	}
+	return best
+}
+
+// dueTime returns when a task enqueued now with opts becomes due
+func dueTime(opts []asynq.Option, now time.Time) time.Time {
+	due := now
+	// Like asynq, the last scheduling option wins
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		switch opt.Type() {
+		case asynq.ProcessAtOpt:
+			due = opt.Value().(time.Time)
+		case asynq.ProcessInOpt:
+			due = now.Add(opt.Value().(time.Duration))
+		}
+	}
+	return due
+}
+
+// slaMiddleware compares the completion time of tasks stamped with a due
+// time to the latency objective of their type. Every latency is recorded;
+// tasks over their objective are logged and alerted on. Failed attempts are
+// not measured, the latency counts once the task finally succeeds.
+func slaMiddleware(config *SLAConfig, m metricsExporter, alerts *alerter, log *slog.Logger) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if len(config.Rules) == 0 {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			err := next.ProcessTask(ctx, t)
+			if err != nil {
+				return err
+			}
+			rule := config.ruleFor(t.Type())
+			if rule == nil {
+				return nil
+			}
+			due, perr := time.Parse(time.RFC3339Nano, MetadataFromContext(ctx)[MetaDueAt])
+			if perr != nil {
+				return nil
+			}
+
+			latency := time.Since(due)
+			breached := latency > rule.MaxLatency
+			queue, _ := asynq.GetQueueName(ctx)
+			if m != nil {
+				m.taskLatency(queue, t.Type(), latency, breached)
+			}
+			if !breached {
+				return nil
+			}
+
+			id, _ := asynq.GetTaskID(ctx)
+			log.Warn("task missed its SLA", "queue", queue, "type", t.Type(), "task_id", id,
+				"latency", latency.Round(time.Millisecond), "sla", rule.MaxLatency)
+			if alerts != nil {
+				alerts.send(Alert{
+					Source:   "sla",
+					Severity: AlertWarning,
+					Key:      "sla:" + queue + ":" + t.Type(),
+					Summary: fmt.Sprintf("%s task completed %v after it was due, over its SLA of %v",
+						t.Type(), latency.Round(time.Second), rule.MaxLatency),
+					Labels: map[string]string{
+						"queue":   queue,
+						"type":    t.Type(),
+						"task_id": id,
+						"latency": latency.Round(time.Millisecond).String(),
+						"sla":     rule.MaxLatency.String(),
+					},
+				})
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package workerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// defaultSlackTemplate renders an alert as a one line Slack message
+const defaultSlackTemplate = `{{if .Resolved}}:white_check_mark: *Resolved*{{else if eq .Severity "critical"}}:rotating_light: *Critical*{{else}}:warning: *Warning*{{end}} [{{.Source}}] {{.Summary}} _on {{.Host}}_`
+
+// AlertSlackConfig posts alerts to a Slack channel through an incoming webhook
+type AlertSlackConfig struct {
+	// Incoming webhook URL, which grants posting to the channel
+	WebhookURL string `json:"webhookUrl" yaml:"webhookUrl" env:"WORKER_ALERTS_SLACK_WEBHOOK_URL" secret:"true"`
+
+	// Go text/template rendering the message from the Alert, e.g.
+	// "{{.Severity}}: {{.Summary}} ({{.Labels.queue}})"; empty uses a default
+	Template string `json:"template" yaml:"template" env:"WORKER_ALERTS_SLACK_TEMPLATE"`
+}
+
+// template parses the message template
+func (sc *AlertSlackConfig) template() (*template.Template, error) {
+	text := sc.Template
+	if text == "" {
+		text = defaultSlackTemplate
+	}
+	tmpl, err := template.New("slack").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Slack template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// slackNotifier posts each alert as a message to a Slack incoming webhook
+type slackNotifier struct {
+	client *http.Client
+	url    string
+	tmpl   *template.Template
+}
+
+func newSlackNotifier(config *AlertSlackConfig) (*slackNotifier, error) {
+	tmpl, err := config.template()
+	if err != nil {
+		return nil, err
+	}
+	return &slackNotifier{client: &http.Client{}, url: config.WebhookURL, tmpl: tmpl}, nil
+}
+
+func (n *slackNotifier) notify(ctx context.Context, a Alert) error {
+	var text strings.Builder
+	if err := n.tmpl.Execute(&text, a); err != nil {
+		return fmt.Errorf("failed to render Slack message: %w", err)
+	}
+	data, err := json.Marshal(map[string]string{"text": text.String()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Slack responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func (n *slackNotifier) close() error {
+	n.client.CloseIdleConnections()
+	return nil
+}
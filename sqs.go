@@ -0,0 +1,216 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/hibiken/asynq"
+)
+
+// sqsRetryDelay is how long the ingestor waits after a failed receive
+const sqsRetryDelay = 5 * time.Second
+
+// SQSIngestConfig configures the AWS SQS ingestor. Each message body becomes
+// the payload of a task whose type is read from a message attribute.
+// Credentials come from the standard AWS environment, profile or role.
+type SQSIngestConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_SQS_ENABLED" default:"false"`
+
+	// URL of the SQS queue to consume
+	QueueURL string `json:"queueURL" yaml:"queueURL" env:"WORKER_SQS_QUEUE_URL"`
+
+	// AWS region; empty uses the region of the AWS environment
+	Region string `json:"region" yaml:"region" env:"WORKER_SQS_REGION"`
+
+	// Custom endpoint, e.g. LocalStack
+	Endpoint string `json:"endpoint" yaml:"endpoint" env:"WORKER_SQS_ENDPOINT"`
+
+	// Message attribute holding the task type
+	TypeAttribute string `json:"typeAttribute" yaml:"typeAttribute" env:"WORKER_SQS_TYPE_ATTRIBUTE" default:"task_type"`
+
+	// Task type of messages without the type attribute; empty leaves them on SQS
+	DefaultType string `json:"defaultType" yaml:"defaultType" env:"WORKER_SQS_DEFAULT_TYPE"`
+
+	// Message attribute overriding the target queue
+	QueueAttribute string `json:"queueAttribute" yaml:"queueAttribute" env:"WORKER_SQS_QUEUE_ATTRIBUTE" default:"queue"`
+
+	// Target queue of messages without the queue attribute; empty uses asynq's default
+	Queue string `json:"queue" yaml:"queue" env:"WORKER_SQS_QUEUE"`
+
+	// Messages received per request, 1 to 10
+	MaxMessages int32 `json:"maxMessages" yaml:"maxMessages" env:"WORKER_SQS_MAX_MESSAGES" default:"10"`
+
+	// Long polling wait, at most 20s
+	WaitTime time.Duration `json:"waitTime" yaml:"waitTime" env:"WORKER_SQS_WAIT_TIME" default:"20s"`
+}
+
+// validate validates the SQS ingest configuration
+func (sc *SQSIngestConfig) validate() error {
+	if !sc.Enabled {
+		return nil
+	}
+	if sc.QueueURL == "" {
+		return fmt.Errorf("queue URL is required when the SQS ingestor is enabled")
+	}
+	if sc.TypeAttribute == "" && sc.DefaultType == "" {
+		return fmt.Errorf("type attribute or default type is required")
+	}
+	if sc.MaxMessages < 1 || sc.MaxMessages > 10 {
+		return fmt.Errorf("max messages must be between 1 and 10, got %d", sc.MaxMessages)
+	}
+	if sc.WaitTime < 0 || sc.WaitTime > 20*time.Second {
+		return fmt.Errorf("wait time must be between 0 and 20s, got %v", sc.WaitTime)
+	}
+	return nil
+}
+
+// sqsIngestor long-polls an SQS queue and enqueues each message as a task.
+// A message is deleted from SQS only once its task is enqueued; otherwise it
+// becomes visible again and is redelivered, or moved to the queue's DLQ.
+type sqsIngestor struct {
+	config *SQSIngestConfig
+	sqs    *sqs.Client
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newSQSIngestor creates an SQS client from the AWS default credential chain
+func newSQSIngestor(config *SQSIngestConfig) (*sqsIngestor, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if config.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(config.Region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.Endpoint)
+		}
+	})
+	return &sqsIngestor{config: config, sqs: client}, nil
+}
+
+// start consumes the queue in the background
+func (s *sqsIngestor) start(w *Workerd) error {
+	client, err := w.Client()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.consume(ctx, client, w)
+	}()
+
+	w.log.Info("SQS ingestor started", "queue_url", s.config.QueueURL)
+	return nil
+}
+
+// stop cancels the current receive and waits for in-flight messages
+func (s *sqsIngestor) stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *sqsIngestor) consume(ctx context.Context, client *Client, w *Workerd) {
+	for ctx.Err() == nil {
+		out, err := s.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(s.config.QueueURL),
+			MaxNumberOfMessages:   s.config.MaxMessages,
+			WaitTimeSeconds:       int32(s.config.WaitTime / time.Second),
+			MessageAttributeNames: []string{"All"},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.log.Error("could not receive SQS messages", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(sqsRetryDelay):
+			}
+			continue
+		}
+
+		for _, msg := range out.Messages {
+			// Finish the batch even when stopping so no enqueued message is left undeleted
+			msgCtx := context.WithoutCancel(ctx)
+			if err := s.ingest(msgCtx, client, msg); err != nil {
+				w.log.Warn("could not ingest SQS message", "message_id", aws.ToString(msg.MessageId), "error", err)
+				continue
+			}
+			_, err := s.sqs.DeleteMessage(msgCtx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(s.config.QueueURL),
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			if err != nil {
+				w.log.Warn("could not delete SQS message", "message_id", aws.ToString(msg.MessageId), "error", err)
+			}
+		}
+	}
+}
+
+// ingest enqueues one message. String attributes other than the type and
+// queue are attached as task metadata. The message ID is used as the task ID
+// so a redelivered message is not enqueued twice.
+func (s *sqsIngestor) ingest(ctx context.Context, client *Client, msg types.Message) error {
+	taskType := s.config.DefaultType
+	queue := s.config.Queue
+	md := Metadata{"sqs_message_id": aws.ToString(msg.MessageId)}
+
+	for name, attr := range msg.MessageAttributes {
+		if attr.StringValue == nil {
+			continue
+		}
+		switch name {
+		case s.config.TypeAttribute:
+			taskType = *attr.StringValue
+		case s.config.QueueAttribute:
+			queue = *attr.StringValue
+		default:
+			md[name] = *attr.StringValue
+		}
+	}
+	if taskType == "" {
+		return fmt.Errorf("message has no %q attribute", s.config.TypeAttribute)
+	}
+
+	opts := []asynq.Option{asynq.TaskID(aws.ToString(msg.MessageId))}
+	if queue != "" {
+		opts = append(opts, asynq.Queue(queue))
+	}
+
+	task := asynq.NewTask(taskType, []byte(aws.ToString(msg.Body)))
+	_, err := client.EnqueueContext(WithMetadata(ctx, md), task, opts...)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
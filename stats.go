@@ -0,0 +1,190 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const statsCommandUsage = "stats [-queue name] [-days 7] [-usage] [-output text|json]  print queue counts and daily throughput, or resource usage by task type"
+
+// DailyStats is the throughput of a queue on one day (UTC)
+type DailyStats struct {
+	Date      string `json:"date"`
+	Processed int    `json:"processed"`
+	Failed    int    `json:"failed"`
+}
+
+// QueueStats is a snapshot of a queue with its recent daily throughput
+type QueueStats struct {
+	Queue     string        `json:"queue"`
+	Paused    bool          `json:"paused"`
+	Size      int           `json:"size"`
+	Pending   int           `json:"pending"`
+	Active    int           `json:"active"`
+	Scheduled int           `json:"scheduled"`
+	Retry     int           `json:"retry"`
+	Archived  int           `json:"archived"`
+	Completed int           `json:"completed"`
+	Latency   time.Duration `json:"latency"`
+	// Most recent day first
+	History []DailyStats `json:"history"`
+}
+
+// Stats returns the current counts and the daily history over the last days
+// of the given queues, or of every queue when none are given
+func (w *Workerd) Stats(days int, queues ...string) ([]QueueStats, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1, got %d", days)
+	}
+	if len(queues) == 0 {
+		var err error
+		if queues, err = w.queueNames(); err != nil {
+			return nil, fmt.Errorf("failed to list queues: %w", err)
+		}
+	}
+
+	stats := make([]QueueStats, 0, len(queues))
+	for _, q := range queues {
+		inspector := w.readQueueInspector(q)
+		info, err := inspector.GetQueueInfo(q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect queue %q: %w", q, err)
+		}
+		history, err := inspector.History(q, days)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history of queue %q: %w", q, err)
+		}
+
+		s := QueueStats{
+			Queue:     q,
+			Paused:    info.Paused,
+			Size:      info.Size,
+			Pending:   info.Pending,
+			Active:    info.Active,
+			Scheduled: info.Scheduled,
+			Retry:     info.Retry,
+			Archived:  info.Archived,
+			Completed: info.Completed,
+			Latency:   info.Latency,
+			History:   make([]DailyStats, 0, len(history)),
+		}
+		for _, d := range history {
+			s.History = append(s.History, DailyStats{
+				Date:      d.Date.Format(time.DateOnly),
+				Processed: d.Processed,
+				Failed:    d.Failed,
+			})
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// runStatsCommand implements `stats`
+func runStatsCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	queue := fs.String("queue", "", "Only show this queue")
+	days := fs.Int("days", 7, "Days of history to show")
+	usage := fs.Bool("usage", false, "Show CPU time and allocations by task type instead")
+	output := fs.String("output", "text", "Output format (text, json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: %s", statsCommandUsage)
+	}
+
+	if *usage {
+		usage, err := w.TaskUsage(context.Background(), *days)
+		if err != nil {
+			return err
+		}
+		return printTaskUsage(os.Stdout, usage, *output)
+	}
+
+	var queues []string
+	if *queue != "" {
+		queues = []string{*queue}
+	}
+	stats, err := w.Stats(*days, queues...)
+	if err != nil {
+		return err
+	}
+	return printStats(os.Stdout, stats, *output)
+}
+
+// printStats renders queue stats as text tables or JSON
+func printStats(out io.Writer, stats []QueueStats, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	case "", "text":
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "QUEUE\tPAUSED\tPENDING\tACTIVE\tSCHEDULED\tRETRY\tARCHIVED\tCOMPLETED\tLATENCY")
+		for _, s := range stats {
+			fmt.Fprintf(tw, "%s\t%t\t%d\t%d\t%d\t%d\t%d\t%d\t%v\n", s.Queue, s.Paused, s.Pending, s.Active,
+				s.Scheduled, s.Retry, s.Archived, s.Completed, s.Latency.Round(time.Millisecond))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(out)
+		fmt.Fprintln(tw, "QUEUE\tDATE\tPROCESSED\tFAILED\tFAILURE RATE")
+		for _, s := range stats {
+			for _, d := range s.History {
+				rate := 0.0
+				if d.Processed > 0 {
+					rate = float64(d.Failed) / float64(d.Processed) * 100
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1f%%\n", s.Queue, d.Date, d.Processed, d.Failed, rate)
+			}
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (valid formats: text, json)", format)
+	}
+}
+
+// printTaskUsage renders task usage as a text table or JSON
+func printTaskUsage(out io.Writer, usage []TaskUsage, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(usage)
+	case "", "text":
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TYPE\tTASKS\tCPU\tCPU/TASK\tALLOCATED\tALLOCATED/TASK")
+		for _, u := range usage {
+			tasks := max(u.Tasks, 1)
+			fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%s\t%s\n", u.Type, u.Tasks, u.CPU.Round(time.Millisecond),
+				(u.CPU / time.Duration(tasks)).Round(time.Microsecond), formatBytes(u.AllocBytes), formatBytes(u.AllocBytes/tasks))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (valid formats: text, json)", format)
+	}
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.5MiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,183 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatsD protocol flavors
+const (
+	StatsDFlavorDogStatsD = "dogstatsd"
+	StatsDFlavorPlain     = "statsd"
+)
+
+// StatsDConfig configures the StatsD/DogStatsD exporter
+type StatsDConfig struct {
+	// UDP address of the StatsD server or Datadog agent
+	Addr string `json:"addr" yaml:"addr" env:"WORKER_METRICS_STATSD_ADDR" default:"127.0.0.1:8125"`
+
+	// "dogstatsd" sends labels as tags; "statsd" folds them into the metric name
+	Flavor string `json:"flavor" yaml:"flavor" env:"WORKER_METRICS_STATSD_FLAVOR" default:"dogstatsd"`
+}
+
+// validate validates the StatsD configuration
+func (sc *StatsDConfig) validate() error {
+	if sc.Addr == "" {
+		return fmt.Errorf("statsd address cannot be empty")
+	}
+	if sc.Flavor != StatsDFlavorDogStatsD && sc.Flavor != StatsDFlavorPlain {
+		return fmt.Errorf("unknown statsd flavor %q, expected %q or %q", sc.Flavor, StatsDFlavorDogStatsD, StatsDFlavorPlain)
+	}
+	return nil
+}
+
+// statsdExporter pushes task metrics over UDP. Sends are fire-and-forget
+// so an unreachable agent never slows task processing down.
+type statsdExporter struct {
+	prefix string
+	tags   bool
+	conn   net.Conn
+	// Pool counts as last reported, by Redis name
+	poolLast map[string]RedisPoolStats
+}
+
+// newStatsDExporter dials the configured address; UDP dialing does not
+// require the agent to be up
+func newStatsDExporter(config *MetricsConfig) (*statsdExporter, error) {
+	conn, err := net.Dial("udp", config.StatsD.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial statsd at %s: %w", config.StatsD.Addr, err)
+	}
+	return &statsdExporter{
+		prefix: config.Namespace,
+		tags:   config.StatsD.Flavor == StatsDFlavorDogStatsD,
+		conn:   conn,
+
+		poolLast: make(map[string]RedisPoolStats),
+	}, nil
+}
+
+func (s *statsdExporter) taskStarted(queue, taskType string) {
+	s.send("tasks.started", "1|c", queue, taskType, "")
+}
+
+func (s *statsdExporter) taskFinished(queue, taskType, status string, d time.Duration) {
+	ms := strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+	s.send("tasks.processed", "1|c", queue, taskType, status)
+	s.send("task.duration", ms+"|ms", queue, taskType, status)
+}
+
+func (s *statsdExporter) taskFailure(queue, taskType string, category FailureCategory) {
+	s.send("tasks.failed."+string(category), "1|c", queue, taskType, "")
+}
+
+func (s *statsdExporter) taskUsage(queue, taskType string, cpu time.Duration, allocBytes int64) {
+	ms := strconv.FormatFloat(float64(cpu)/float64(time.Millisecond), 'f', 3, 64)
+	s.send("task.cpu", ms+"|ms", queue, taskType, "")
+	s.send("task.allocated", strconv.FormatInt(max(allocBytes, 0), 10)+"|c", queue, taskType, "")
+}
+
+func (s *statsdExporter) taskLatency(queue, taskType string, latency time.Duration, breached bool) {
+	ms := strconv.FormatFloat(float64(latency)/float64(time.Millisecond), 'f', 3, 64)
+	s.send("task.latency", ms+"|ms", queue, taskType, "")
+	if breached {
+		s.send("sla.breaches", "1|c", queue, taskType, "")
+	}
+}
+
+func (s *statsdExporter) redisConnected(up bool) {
+	value := "0|g"
+	if up {
+		value = "1|g"
+	}
+	name := "redis.up"
+	if s.prefix != "" {
+		name = s.prefix + "." + name
+	}
+	_, _ = s.conn.Write([]byte(name + ":" + value))
+}
+
+// redisPool sends the connection pool usage of a Redis server; counts are
+// sent as the change since the last report
+func (s *statsdExporter) redisPool(name string, stats RedisPoolStats) {
+	last := s.poolLast[name]
+	s.poolLast[name] = stats
+	for _, m := range []struct{ name, value string }{
+		{"redis.pool.connections.total", strconv.FormatUint(uint64(stats.Total), 10) + "|g"},
+		{"redis.pool.connections.idle", strconv.FormatUint(uint64(stats.Idle), 10) + "|g"},
+		{"redis.pool.connections.stale", strconv.FormatUint(uint64(stats.Stale), 10) + "|g"},
+		{"redis.pool.max_connections", strconv.Itoa(stats.Size) + "|g"},
+		{"redis.pool.hits", strconv.FormatUint(uint64(stats.Hits-min(last.Hits, stats.Hits)), 10) + "|c"},
+		{"redis.pool.misses", strconv.FormatUint(uint64(stats.Misses-min(last.Misses, stats.Misses)), 10) + "|c"},
+		{"redis.pool.timeouts", strconv.FormatUint(uint64(stats.Timeouts-min(last.Timeouts, stats.Timeouts)), 10) + "|c"},
+	} {
+		line := m.name
+		if s.prefix != "" {
+			line = s.prefix + "." + line
+		}
+		if s.tags {
+			line += ":" + m.value + "|#redis:" + statsdSanitize(name, tagReserved)
+		} else {
+			line += "." + statsdSanitize(name, nameReserved) + ":" + m.value
+		}
+		_, _ = s.conn.Write([]byte(line))
+	}
+}
+
+func (s *statsdExporter) start(w *Workerd) error {
+	w.log.Info("pushing metrics to statsd", "addr", s.conn.RemoteAddr().String(), "tags", s.tags)
+	return nil
+}
+
+func (s *statsdExporter) stop(ctx context.Context) error {
+	return s.conn.Close()
+}
+
+// send writes one metric line, e.g. "workerd.tasks.processed:1|c|#queue:default,type:email,status:success"
+// for DogStatsD or "workerd.tasks.processed.default.email.success:1|c" for plain StatsD
+func (s *statsdExporter) send(name, value, queue, taskType, status string) {
+	var b strings.Builder
+	if s.prefix != "" {
+		b.WriteString(s.prefix)
+		b.WriteByte('.')
+	}
+	b.WriteString(name)
+
+	if s.tags {
+		b.WriteString(":" + value + "|#queue:" + statsdSanitize(queue, tagReserved) + ",type:" + statsdSanitize(taskType, tagReserved))
+		if status != "" {
+			b.WriteString(",status:" + status)
+		}
+	} else {
+		b.WriteString("." + statsdSanitize(queue, nameReserved) + "." + statsdSanitize(taskType, nameReserved))
+		if status != "" {
+			b.WriteString("." + status)
+		}
+		b.WriteString(":" + value)
+	}
+
+	_, _ = s.conn.Write([]byte(b.String()))
+}
+
+// Characters reserved by the line protocol in tag values and metric names
+const (
+	tagReserved  = "|,#@ \n"
+	nameReserved = ":|,#@. \n"
+)
+
+// statsdSanitize replaces reserved characters with underscores
+func statsdSanitize(v, reserved string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(reserved, r) {
+			return '_'
+		}
+		return r
+	}, v)
+}
@@ -0,0 +1,111 @@
+package workerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+type taskLoggerKey struct{}
+type correlationIDKey struct{}
+
+// Logger returns a logger annotated with the current task's correlation
+// attributes, or the default logger outside of a task
+func Logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(taskLoggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// CorrelationID returns the correlation ID of the task being processed:
+// the task ID, prefixed by the trace ID when one is known
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// traceIDFromTask looks up a trace ID in the task metadata, then in a
+// top-level "trace_id" or "traceId" field of a JSON payload
+func traceIDFromTask(ctx context.Context, t *asynq.Task) string {
+	if id := MetadataFromContext(ctx)[MetaTraceID]; id != "" {
+		return id
+	}
+
+	payload := t.Payload()
+	if len(payload) == 0 || payload[0] != '{' {
+		return ""
+	}
+	var fields struct {
+		TraceID      string `json:"trace_id"`
+		TraceIDCamel string `json:"traceId"`
+	}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return ""
+	}
+	if fields.TraceID != "" {
+		return fields.TraceID
+	}
+	return fields.TraceIDCamel
+}
+
+// taskLoggingMiddleware injects a correlated logger into the handler context
+// and logs the lifecycle of each task
+func taskLoggingMiddleware(base *slog.Logger, events bool) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			taskID, _ := asynq.GetTaskID(ctx)
+			queue, _ := asynq.GetQueueName(ctx)
+			retry, _ := asynq.GetRetryCount(ctx)
+			traceID := traceIDFromTask(ctx, t)
+
+			correlationID := taskID
+			if traceID != "" {
+				correlationID = traceID + ":" + taskID
+			}
+
+			attrs := []any{
+				"correlation_id", correlationID,
+				"task_id", taskID,
+				"type", t.Type(),
+				"queue", queue,
+				"retry", retry,
+			}
+			if traceID != "" {
+				attrs = append(attrs, "trace_id", traceID)
+			}
+			logger := base.With(attrs...)
+
+			ctx = context.WithValue(ctx, taskLoggerKey{}, logger)
+			ctx = context.WithValue(ctx, correlationIDKey{}, correlationID)
+
+			if !events {
+				return next.ProcessTask(ctx, t)
+			}
+
+			start := time.Now()
+			logger.Debug("task started")
+
+			err := next.ProcessTask(ctx, t)
+			duration := time.Since(start)
+
+			switch {
+			case err == nil:
+				logger.Info("task succeeded", "duration", duration, "outcome", "success")
+			case errors.Is(err, asynq.SkipRetry):
+				logger.Error("task failed", "duration", duration, "outcome", "skip_retry", "category", FailureCategoryOf(err), "error", err)
+			case errors.Is(err, errRequeued):
+				logger.Info("task requeued", "duration", duration, "outcome", "requeued", "reason", err)
+			case errors.Is(err, asynq.RevokeTask):
+				logger.Warn("task revoked", "duration", duration, "outcome", "revoked", "error", err)
+			default:
+				logger.Error("task failed", "duration", duration, "outcome", "failure", "category", FailureCategoryOf(err), "error", err)
+			}
+			return err
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package workerd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const tasksCommandUsage = "tasks cancel <queue> <id> | move -from <queue> -to <queue> [-type pattern] [-dry-run]  abort a running task or move pending tasks"
+
+// ErrTaskNotActive is returned when cancelling a task that is not running
+var ErrTaskNotActive = errors.New("task is not active")
+
+// cancelWait bounds how long CancelTask waits for the handler to stop
+const cancelWait = 10 * time.Second
+
+// CancelTask aborts a running task: its handler's context is cancelled on
+// whichever worker runs it, and the task is then archived instead of retried.
+// An archived task can still be run again with RequeueTask.
+func (w *Workerd) CancelTask(queue, id string) error {
+	if queue == "" || id == "" {
+		return fmt.Errorf("queue name and task ID cannot be empty")
+	}
+
+	inspector := w.QueueInspector(queue)
+	info, err := inspector.GetTaskInfo(queue, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel task %q in queue %q: %w", id, queue, err)
+	}
+	if info.State != asynq.TaskStateActive {
+		return fmt.Errorf("failed to cancel task %q in queue %q: %w (state %s)", id, queue, ErrTaskNotActive, info.State)
+	}
+	if err := inspector.CancelProcessing(id); err != nil {
+		return fmt.Errorf("failed to cancel task %q in queue %q: %w", id, queue, err)
+	}
+
+	// asynq treats a cancelled handler as failed and schedules a retry;
+	// archive the task once it has left the active state
+	deadline := time.Now().Add(cancelWait)
+	for info.State == asynq.TaskStateActive {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("task %q in queue %q is still active %v after cancellation", id, queue, cancelWait)
+		}
+		time.Sleep(100 * time.Millisecond)
+		info, err = inspector.GetTaskInfo(queue, id)
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return fmt.Errorf("task %q in queue %q finished before it was cancelled", id, queue)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to cancel task %q in queue %q: %w", id, queue, err)
+		}
+	}
+
+	switch info.State {
+	case asynq.TaskStateCompleted:
+		return fmt.Errorf("task %q in queue %q finished before it was cancelled", id, queue)
+	case asynq.TaskStateArchived:
+	default:
+		if err := inspector.ArchiveTask(queue, id); err != nil {
+			return fmt.Errorf("failed to archive cancelled task %q in queue %q: %w", id, queue, err)
+		}
+	}
+
+	w.log.Info("task cancelled", "queue", queue, "task_id", id)
+	return nil
+}
+
+// matchTaskType reports whether a task type matches a pattern: an exact type,
+// a prefix such as "report:" or a prefix followed by "*" such as "report:*"
+func matchTaskType(pattern, taskType string) bool {
+	return strings.HasPrefix(taskType, strings.TrimSuffix(pattern, "*"))
+}
+
+// MoveTasks moves the pending tasks of the type pattern from one queue to
+// another, keeping their ID, payload, max retry, timeout, deadline and
+// retention, and returns them. With dryRun it only returns what it would move.
+// Tasks picked up by a worker in the meantime stay where they are.
+func (w *Workerd) MoveTasks(from, to, pattern string, dryRun bool) ([]*asynq.TaskInfo, error) {
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("source and destination queues cannot be empty")
+	}
+	if from == to {
+		return nil, fmt.Errorf("source and destination queues are both %q", from)
+	}
+
+	inspector := w.QueueInspector(from)
+	var pending []*asynq.TaskInfo
+	for page := 1; ; page++ {
+		infos, err := inspector.ListPendingTasks(from, asynq.PageSize(archivePageSize), asynq.Page(page))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pending tasks in queue %q: %w", from, err)
+		}
+		for _, info := range infos {
+			if matchTaskType(pattern, info.Type) {
+				pending = append(pending, info)
+			}
+		}
+		if len(infos) < archivePageSize {
+			break
+		}
+	}
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	client, err := w.Client()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	moved := pending[:0]
+	for _, info := range pending {
+		// Deleting first fails for a task that became active, so a task is
+		// never run in both queues
+		err := inspector.DeleteTask(from, info.ID)
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			continue
+		}
+		if err != nil {
+			w.log.Debug("task not moved", "queue", from, "task_id", info.ID, "error", err)
+			continue
+		}
+
+		// The payload is enqueued as stored, already encoded
+		task := asynq.NewTask(info.Type, info.Payload)
+		if _, err := client.clientFor(to).EnqueueContext(context.Background(), task, movedTaskOptions(info, to)...); err != nil {
+			if _, rerr := client.clientFor(from).EnqueueContext(context.Background(), task, movedTaskOptions(info, from)...); rerr != nil {
+				w.log.Error("task lost while moving, restore failed", "queue", from, "task_id", info.ID, "type", info.Type, "error", rerr)
+			}
+			return moved, fmt.Errorf("failed to move task %q to queue %q: %w", info.ID, to, err)
+		}
+		moved = append(moved, info)
+	}
+
+	w.log.Info("pending tasks moved", "from", from, "to", to, "type", pattern, "count", len(moved))
+	return moved, nil
+}
+
+// movedTaskOptions rebuilds the enqueue options of a pending task for queue
+func movedTaskOptions(info *asynq.TaskInfo, queue string) []asynq.Option {
+	opts := []asynq.Option{asynq.Queue(queue), asynq.TaskID(info.ID), asynq.MaxRetry(info.MaxRetry)}
+	if info.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(info.Timeout))
+	}
+	if !info.Deadline.IsZero() {
+		opts = append(opts, asynq.Deadline(info.Deadline))
+	}
+	if info.Retention > 0 {
+		opts = append(opts, asynq.Retention(info.Retention))
+	}
+	return opts
+}
+
+// runMoveCommand implements `tasks move`
+func runMoveCommand(w *Workerd, args []string) error {
+	fs := flag.NewFlagSet("tasks move", flag.ContinueOnError)
+	from := fs.String("from", "", "Queue to move pending tasks from")
+	to := fs.String("to", "", "Queue to move them to")
+	pattern := fs.String("type", "", "Only tasks of this type or prefix, e.g. \"report:*\"")
+	dryRun := fs.Bool("dry-run", false, "Show the matching tasks without moving them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 || *from == "" || *to == "" {
+		return fmt.Errorf("usage: %s", tasksCommandUsage)
+	}
+
+	tasks, err := w.MoveTasks(*from, *to, *pattern, *dryRun)
+	if *dryRun && err == nil {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTYPE")
+		for _, t := range tasks {
+			fmt.Fprintf(tw, "%s\t%s\n", t.ID, t.Type)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "dry run: would move %d pending tasks from %q to %q\n", len(tasks), *from, *to)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "moved %d pending tasks from %q to %q\n", len(tasks), *from, *to)
+	return err
+}
+
+// runTasksCommand implements `tasks`
+func runTasksCommand(w *Workerd, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", tasksCommandUsage)
+	}
+
+	switch args[0] {
+	case "cancel":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: %s", tasksCommandUsage)
+		}
+		return w.CancelTask(args[1], args[2])
+	case "move":
+		return runMoveCommand(w, args[1:])
+	default:
+		return fmt.Errorf("unknown tasks action %q (valid actions: cancel, move)", args[0])
+	}
+}
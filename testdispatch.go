@@ -0,0 +1,147 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// LogRecord is a log record captured by TestDispatch
+type LogRecord struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	// Attributes including those added with Logger.With, keyed by their
+	// dotted group path
+	Attrs map[string]any
+}
+
+// DispatchResult is the outcome of a task run with TestDispatch
+type DispatchResult struct {
+	// Error returned by the middleware chain, as the server would see it
+	Err error
+	// Records logged by workerd and by the handler through Logger(ctx)
+	Logs []LogRecord
+	// Status recorded by the metrics middleware, "success" or "failure"
+	Status string
+	// Handler duration recorded by the metrics middleware
+	Duration time.Duration
+}
+
+// Logged reports whether a record with the message was logged
+func (r *DispatchResult) Logged(message string) bool {
+	for _, rec := range r.Logs {
+		if rec.Message == message {
+			return true
+		}
+	}
+	return false
+}
+
+// TestDispatch runs a task through the full middleware chain and its handler
+// without a server or Redis, for end-to-end handler tests. Logs are captured
+// in the result instead of being written, and handler panics are returned as
+// errors like the server does. Middleware that talks to Redis, such as the
+// audit trail or checkpoints, only does so if enabled in the configuration.
+func (w *Workerd) TestDispatch(ctx context.Context, task *asynq.Task) *DispatchResult {
+	res := &DispatchResult{}
+	capture := &logCapture{records: &res.Logs, mu: &sync.Mutex{}}
+	metrics := &dispatchMetrics{result: res}
+
+	h := w.handlerWith(slog.New(capture), metrics)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				res.Err, res.Status = fmt.Errorf("panic: %v", r), statusFailure
+			}
+		}()
+		res.Err = h.ProcessTask(ctx, task)
+	}()
+	return res
+}
+
+// logCapture is a slog.Handler collecting records of every level
+type logCapture struct {
+	mu      *sync.Mutex
+	records *[]LogRecord
+	attrs   []slog.Attr
+	group   string
+}
+
+func (c *logCapture) Enabled(context.Context, slog.Level) bool { return true }
+
+func (c *logCapture) Handle(_ context.Context, r slog.Record) error {
+	rec := LogRecord{Time: r.Time, Level: r.Level, Message: r.Message, Attrs: map[string]any{}}
+	for _, a := range c.attrs {
+		addAttr(rec.Attrs, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(rec.Attrs, c.group, a)
+		return true
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.records = append(*c.records, rec)
+	return nil
+}
+
+func (c *logCapture) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *c
+	clone.attrs = append([]slog.Attr{}, c.attrs...)
+	for _, a := range attrs {
+		if c.group != "" {
+			a.Key = c.group + "." + a.Key
+		}
+		clone.attrs = append(clone.attrs, a)
+	}
+	return &clone
+}
+
+func (c *logCapture) WithGroup(name string) slog.Handler {
+	clone := *c
+	clone.group = strings.TrimPrefix(c.group+"."+name, ".")
+	return &clone
+}
+
+// addAttr flattens an attribute into attrs under its dotted group path
+func addAttr(attrs map[string]any, prefix string, a slog.Attr) {
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if v := a.Value.Resolve(); v.Kind() == slog.KindGroup {
+		for _, ga := range v.Group() {
+			addAttr(attrs, key, ga)
+		}
+		return
+	}
+	attrs[key] = a.Value.Resolve().Any()
+}
+
+// dispatchMetrics records the metrics of one TestDispatch run
+type dispatchMetrics struct {
+	result *DispatchResult
+}
+
+func (m *dispatchMetrics) taskStarted(queue, taskType string) {}
+
+func (m *dispatchMetrics) taskFinished(queue, taskType, status string, d time.Duration) {
+	m.result.Status, m.result.Duration = status, d
+}
+
+func (m *dispatchMetrics) taskFailure(queue, taskType string, category FailureCategory) {}
+
+func (m *dispatchMetrics) taskUsage(queue, taskType string, cpu time.Duration, allocBytes int64) {}
+
+func (m *dispatchMetrics) taskLatency(queue, taskType string, latency time.Duration, breached bool) {}
+
+func (m *dispatchMetrics) redisConnected(up bool)                      {}
+func (m *dispatchMetrics) redisPool(name string, stats RedisPoolStats) {}
+func (m *dispatchMetrics) start(w *Workerd) error                      { return nil }
+func (m *dispatchMetrics) stop(ctx context.Context) error              { return nil }
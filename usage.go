@@ -0,0 +1,287 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"runtime/metrics"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// UsageConfig configures the accounting of CPU time and allocations per task
+// type, to find the task types that use the most resources
+type UsageConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_USAGE_ENABLED" default:"false"`
+
+	// Prefix of the Redis keys holding the daily totals of the fleet
+	Prefix string `json:"prefix" yaml:"prefix" env:"WORKER_USAGE_PREFIX" default:"workerd:usage"`
+
+	// How often a worker adds its totals to Redis
+	FlushInterval time.Duration `json:"flushInterval" yaml:"flushInterval" env:"WORKER_USAGE_FLUSH_INTERVAL" default:"10s"`
+
+	// How long daily totals are kept
+	TTL time.Duration `json:"ttl" yaml:"ttl" env:"WORKER_USAGE_TTL" default:"720h"`
+}
+
+// validate validates the usage configuration
+func (uc *UsageConfig) validate() error {
+	if !uc.Enabled {
+		return nil
+	}
+	if uc.Prefix == "" {
+		return fmt.Errorf("usage prefix cannot be empty")
+	}
+	if uc.FlushInterval <= 0 {
+		return fmt.Errorf("usage flush interval must be positive, got %v", uc.FlushInterval)
+	}
+	if uc.TTL < 24*time.Hour {
+		return fmt.Errorf("usage TTL must be at least 24h, got %v", uc.TTL)
+	}
+	return nil
+}
+
+// TaskUsage is the resource usage of a task type
+type TaskUsage struct {
+	Type  string `json:"type"`
+	Tasks int64  `json:"tasks"`
+	// Approximate CPU time and heap allocations of all the tasks
+	CPU        time.Duration `json:"cpu"`
+	AllocBytes int64         `json:"alloc_bytes"`
+}
+
+// usageMark is the share of process usage per running task at a point in time
+type usageMark struct {
+	cpu   float64
+	alloc float64
+}
+
+// usageMeter attributes the CPU time and heap allocations of the process to
+// the running tasks. Go has no per-goroutine counters, so usage is split
+// evenly between the tasks running at the time; work of the runtime and of
+// workerd itself is attributed to tasks too.
+type usageMeter struct {
+	mu        sync.Mutex
+	active    int
+	lastCPU   time.Duration
+	lastAlloc uint64
+	share     usageMark
+	sample    []metrics.Sample
+}
+
+func newUsageMeter() *usageMeter {
+	m := &usageMeter{sample: []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}}
+	m.lastCPU, m.lastAlloc = processCPUTime(), m.allocated()
+	return m
+}
+
+// allocated returns the bytes allocated on the heap since the process started
+func (m *usageMeter) allocated() uint64 {
+	metrics.Read(m.sample)
+	if m.sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return m.sample[0].Value.Uint64()
+}
+
+// advance splits the usage since the last call between the running tasks.
+// The caller holds mu.
+func (m *usageMeter) advance() {
+	cpu, alloc := processCPUTime(), m.allocated()
+	if m.active > 0 {
+		m.share.cpu += float64(cpu-m.lastCPU) / float64(m.active)
+		m.share.alloc += float64(alloc-m.lastAlloc) / float64(m.active)
+	}
+	m.lastCPU, m.lastAlloc = cpu, alloc
+}
+
+// begin records a task starting
+func (m *usageMeter) begin() usageMark {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance()
+	m.active++
+	return m.share
+}
+
+// end records a task started at mark finishing and returns its usage
+func (m *usageMeter) end(mark usageMark) (time.Duration, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance()
+	m.active--
+	return time.Duration(m.share.cpu - mark.cpu), int64(m.share.alloc - mark.alloc)
+}
+
+// usageAccountant meters tasks and keeps the totals not yet flushed to Redis
+type usageAccountant struct {
+	config *UsageConfig
+	meter  *usageMeter
+	rdb    redis.UniversalClient
+
+	mu      sync.Mutex
+	pending map[string]*TaskUsage
+}
+
+func newUsageAccountant(config *UsageConfig, redisOpt asynq.RedisConnOpt) (*usageAccountant, error) {
+	rdb, ok := redisOpt.MakeRedisClient().(redis.UniversalClient)
+	if !ok {
+		return nil, fmt.Errorf("unsupported redis connection for usage accounting")
+	}
+	return &usageAccountant{
+		config:  config,
+		meter:   newUsageMeter(),
+		rdb:     rdb,
+		pending: make(map[string]*TaskUsage),
+	}, nil
+}
+
+// record adds the usage of one task
+func (a *usageAccountant) record(taskType string, cpu time.Duration, alloc int64) {
+	a.add(TaskUsage{Type: taskType, Tasks: 1, CPU: cpu, AllocBytes: alloc})
+}
+
+// add adds usage to the pending totals
+func (a *usageAccountant) add(usage TaskUsage) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u := a.pending[usage.Type]
+	if u == nil {
+		u = &TaskUsage{Type: usage.Type}
+		a.pending[usage.Type] = u
+	}
+	u.Tasks += usage.Tasks
+	u.CPU += usage.CPU
+	u.AllocBytes += usage.AllocBytes
+}
+
+// key returns the key of the daily totals of a UTC day
+func (a *usageAccountant) key(day time.Time) string {
+	return a.config.Prefix + ":" + day.UTC().Format(time.DateOnly)
+}
+
+// flush adds the pending totals to today's totals in Redis. Totals that
+// cannot be written are kept for the next flush.
+func (a *usageAccountant) flush(ctx context.Context) error {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = make(map[string]*TaskUsage)
+	a.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	key := a.key(time.Now())
+	_, err := a.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for t, u := range pending {
+			p.HIncrBy(ctx, key, t+"|tasks", u.Tasks)
+			p.HIncrBy(ctx, key, t+"|cpu", int64(u.CPU))
+			p.HIncrBy(ctx, key, t+"|alloc", u.AllocBytes)
+		}
+		p.Expire(ctx, key, a.config.TTL)
+		return nil
+	})
+	if err != nil {
+		for _, u := range pending {
+			a.add(*u)
+		}
+		return fmt.Errorf("failed to flush task usage: %w", err)
+	}
+	return nil
+}
+
+// totals returns the usage of every task type over the last days, the most
+// CPU intensive first
+func (a *usageAccountant) totals(ctx context.Context, days int) ([]TaskUsage, error) {
+	byType := make(map[string]*TaskUsage)
+	now := time.Now()
+	for i := range days {
+		fields, err := a.rdb.HGetAll(ctx, a.key(now.AddDate(0, 0, -i))).Result()
+		if err != nil {
+			return nil, err
+		}
+		for field, value := range fields {
+			sep := strings.LastIndexByte(field, '|')
+			if sep < 0 {
+				continue
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			t := field[:sep]
+			u := byType[t]
+			if u == nil {
+				u = &TaskUsage{Type: t}
+				byType[t] = u
+			}
+			switch field[sep+1:] {
+			case "tasks":
+				u.Tasks += n
+			case "cpu":
+				u.CPU += time.Duration(n)
+			case "alloc":
+				u.AllocBytes += n
+			}
+		}
+	}
+
+	usage := make([]TaskUsage, 0, len(byType))
+	for _, u := range byType {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].CPU != usage[j].CPU {
+			return usage[i].CPU > usage[j].CPU
+		}
+		return usage[i].Type < usage[j].Type
+	})
+	return usage, nil
+}
+
+func (a *usageAccountant) close() error {
+	return a.rdb.Close()
+}
+
+// TaskUsage returns the resource usage of every task type processed by the
+// fleet over the last days (UTC), the most CPU intensive first
+func (w *Workerd) TaskUsage(ctx context.Context, days int) ([]TaskUsage, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1, got %d", days)
+	}
+	if w.usage == nil {
+		return nil, fmt.Errorf("task usage accounting is disabled")
+	}
+	usage, err := w.usage.totals(ctx, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task usage: %w", err)
+	}
+	return usage, nil
+}
+
+// usageMiddleware measures the CPU time and allocations of each task and
+// reports them to the metrics exporter and the daily totals
+func usageMiddleware(a *usageAccountant, m metricsExporter) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		if a == nil {
+			return next
+		}
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			mark := a.meter.begin()
+			defer func() {
+				cpu, alloc := a.meter.end(mark)
+				a.record(t.Type(), cpu, alloc)
+				if m != nil {
+					queue, _ := asynq.GetQueueName(ctx)
+					m.taskUsage(queue, t.Type(), cpu, alloc)
+				}
+			}()
+			return next.ProcessTask(ctx, t)
+		})
+	}
+}
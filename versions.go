@@ -0,0 +1,124 @@
+package workerd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hibiken/asynq"
+)
+
+// versionSeparator separates a task type from its payload version, as in
+// "email:send@v2"
+const versionSeparator = "@v"
+
+// VersionedType returns the task type of version of a task, e.g.
+// VersionedType("email:send", 2) is "email:send@v2"
+func VersionedType(name string, version int) string {
+	return name + versionSeparator + strconv.Itoa(version)
+}
+
+// TaskVersion splits a versioned task type into its name and version.
+// Types without a version, such as "email:send", are version 1.
+func TaskVersion(taskType string) (string, int) {
+	i := strings.LastIndex(taskType, versionSeparator)
+	if i < 0 {
+		return taskType, 1
+	}
+	version, err := strconv.Atoi(taskType[i+len(versionSeparator):])
+	if err != nil || version < 1 {
+		return taskType, 1
+	}
+	return taskType[:i], version
+}
+
+// PayloadUpgrade converts a payload of one version of a task type to the next
+type PayloadUpgrade func(ctx context.Context, payload []byte) ([]byte, error)
+
+// upgradeRegistry holds payload upgrades by task name and source version
+type upgradeRegistry struct {
+	mu       sync.RWMutex
+	upgrades map[string]map[int]PayloadUpgrade
+}
+
+// latest returns the version tasks of name are upgraded to, or 0 if the name
+// has no upgrades
+func (r *upgradeRegistry) latest(name string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	latest := 0
+	for from := range r.upgrades[name] {
+		latest = max(latest, from+1)
+	}
+	return latest
+}
+
+func (r *upgradeRegistry) get(name string, from int) PayloadUpgrade {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.upgrades[name][from]
+}
+
+// RegisterUpgrade declares how payloads of version from of the task type
+// name are converted to version from+1. Tasks enqueued with an older version,
+// e.g. still in the queue while producers roll out, are upgraded step by step
+// to the latest version before reaching the handler, which is registered for
+// VersionedType(name, latest). Tasks of a newer version than the latest are
+// left to the unknown task policy.
+func (w *Workerd) RegisterUpgrade(name string, from int, upgrade PayloadUpgrade) error {
+	if name == "" || strings.Contains(name, versionSeparator) {
+		return fmt.Errorf("invalid task name %q: expected a task type without version", name)
+	}
+	if from < 1 {
+		return fmt.Errorf("invalid version %d for %q: versions start at 1", from, name)
+	}
+	if upgrade == nil {
+		return fmt.Errorf("upgrade cannot be nil")
+	}
+
+	r := &w.upgrades
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.upgrades == nil {
+		r.upgrades = make(map[string]map[int]PayloadUpgrade)
+	}
+	if r.upgrades[name] == nil {
+		r.upgrades[name] = make(map[int]PayloadUpgrade)
+	}
+	if _, ok := r.upgrades[name][from]; ok {
+		return fmt.Errorf("upgrade of %q from v%d is already registered", name, from)
+	}
+	r.upgrades[name][from] = upgrade
+	return nil
+}
+
+// upgradeMiddleware upgrades payloads of older task versions to the latest
+// version and renames the task accordingly
+func upgradeMiddleware(r *upgradeRegistry) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			name, version := TaskVersion(t.Type())
+			latest := r.latest(name)
+			if version >= latest {
+				return next.ProcessTask(ctx, t)
+			}
+
+			payload := t.Payload()
+			for v := version; v < latest; v++ {
+				upgrade := r.get(name, v)
+				if upgrade == nil {
+					return fmt.Errorf("no upgrade of %q from v%d to v%d: %w", name, v, v+1, asynq.SkipRetry)
+				}
+				var err error
+				if payload, err = upgrade(ctx, payload); err != nil {
+					return fmt.Errorf("failed to upgrade %q from v%d to v%d: %w", name, v, v+1, err)
+				}
+			}
+
+			Logger(ctx).Debug("task payload upgraded", "type", t.Type(), "from", version, "to", latest)
+			return next.ProcessTask(ctx, asynq.NewTask(VersionedType(name, latest), payload))
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package workerd
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Supported webhook signature schemes
+const (
+	// WebhookSchemeHMAC verifies a hex HMAC-SHA256 of the body, optionally
+	// prefixed with "sha256=", as sent by GitHub and most providers
+	WebhookSchemeHMAC = "hmac-sha256"
+	// WebhookSchemeStripe verifies a "t=<unix>,v1=<hex>" header signing
+	// "<unix>.<body>", as sent by Stripe
+	WebhookSchemeStripe = "stripe"
+)
+
+// defaultStripeTolerance is the accepted age of a Stripe signature timestamp
+const defaultStripeTolerance = 5 * time.Minute
+
+// WebhookIngestConfig configures the HTTP listener that receives signed
+// webhooks and enqueues them as tasks
+type WebhookIngestConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" env:"WORKER_WEBHOOK_ENABLED" default:"false"`
+
+	// Listen address of the webhook receiver, host:port or unix:/path/to.sock
+	Addr string `json:"addr" yaml:"addr" env:"WORKER_WEBHOOK_ADDR" default:":8088"`
+
+	// Client IPs or CIDRs accepted, e.g. the sender's published ranges; empty
+	// accepts any
+	AllowFrom []string `json:"allowFrom" yaml:"allowFrom"`
+
+	// Largest accepted request body in bytes
+	MaxBodySize int64 `json:"maxBodySize" yaml:"maxBodySize" env:"WORKER_WEBHOOK_MAX_BODY_SIZE" default:"1048576"`
+
+	Endpoints []WebhookEndpoint `json:"endpoints" yaml:"endpoints"`
+}
+
+// WebhookEndpoint maps a path to the task type its requests are enqueued as
+type WebhookEndpoint struct {
+	// Request path, e.g. /webhooks/github
+	Path string `json:"path" yaml:"path"`
+
+	// Task type the request body is enqueued as
+	Type string `json:"type" yaml:"type"`
+
+	// Target queue; empty uses asynq's default
+	Queue string `json:"queue" yaml:"queue"`
+
+	// Shared secret the sender signs the body with
+	Secret string `json:"secret" yaml:"secret" secret:"true"`
+
+	// "hmac-sha256" (default) or "stripe"
+	Scheme string `json:"scheme" yaml:"scheme"`
+
+	// Header carrying the signature; empty uses X-Hub-Signature-256 or Stripe-Signature
+	Header string `json:"header" yaml:"header"`
+
+	// Header with a unique delivery ID used as the task ID, e.g. X-GitHub-Delivery
+	IDHeader string `json:"idHeader" yaml:"idHeader"`
+
+	// Maximum age of a Stripe signature timestamp, 5m by default
+	Tolerance time.Duration `json:"tolerance" yaml:"tolerance"`
+}
+
+// scheme returns the configured signature scheme or the default
+func (e *WebhookEndpoint) scheme() string {
+	if e.Scheme == "" {
+		return WebhookSchemeHMAC
+	}
+	return e.Scheme
+}
+
+// signatureHeader returns the configured header or the scheme's default
+func (e *WebhookEndpoint) signatureHeader() string {
+	switch {
+	case e.Header != "":
+		return e.Header
+	case e.scheme() == WebhookSchemeStripe:
+		return "Stripe-Signature"
+	default:
+		return "X-Hub-Signature-256"
+	}
+}
+
+// validate validates the webhook configuration
+func (wc *WebhookIngestConfig) validate() error {
+	if !wc.Enabled {
+		return nil
+	}
+	if wc.Addr == "" {
+		return fmt.Errorf("address cannot be empty when the webhook receiver is enabled")
+	}
+	if err := validateListen(wc.Addr, wc.AllowFrom); err != nil {
+		return err
+	}
+	if wc.MaxBodySize <= 0 {
+		return fmt.Errorf("max body size must be positive, got %d", wc.MaxBodySize)
+	}
+	if len(wc.Endpoints) == 0 {
+		return fmt.Errorf("at least one endpoint is required when the webhook receiver is enabled")
+	}
+	paths := make(map[string]bool, len(wc.Endpoints))
+	for i, e := range wc.Endpoints {
+		if !strings.HasPrefix(e.Path, "/") {
+			return fmt.Errorf("endpoint %d: path must start with '/', got %q", i, e.Path)
+		}
+		if paths[e.Path] {
+			return fmt.Errorf("endpoint %d: duplicate path %q", i, e.Path)
+		}
+		paths[e.Path] = true
+		if e.Type == "" {
+			return fmt.Errorf("endpoint %q: type cannot be empty", e.Path)
+		}
+		if e.Secret == "" {
+			return fmt.Errorf("endpoint %q: secret is required", e.Path)
+		}
+		switch e.scheme() {
+		case WebhookSchemeHMAC, WebhookSchemeStripe:
+		default:
+			return fmt.Errorf("endpoint %q: unknown scheme %q, expected %q or %q", e.Path, e.Scheme, WebhookSchemeHMAC, WebhookSchemeStripe)
+		}
+		if e.Tolerance < 0 {
+			return fmt.Errorf("endpoint %q: tolerance must be non-negative, got %v", e.Path, e.Tolerance)
+		}
+	}
+	return nil
+}
+
+// webhookIngestor serves the configured endpoints. A request is answered
+// with 202 only once its task is enqueued, so senders retry on failure.
+type webhookIngestor struct {
+	config *WebhookIngestConfig
+	srv    *http.Server
+	now    func() time.Time
+}
+
+// start binds the listener and serves in the background
+func (h *webhookIngestor) start(w *Workerd) error {
+	client, err := w.Client()
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	for i := range h.config.Endpoints {
+		e := &h.config.Endpoints[i]
+		mux.HandleFunc("POST "+e.Path, func(rw http.ResponseWriter, r *http.Request) {
+			h.handle(rw, r, e, client, w)
+		})
+	}
+	h.srv = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ln, err := listen(h.config.Addr, h.config.AllowFrom, w.log)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := h.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			w.log.Error("webhook receiver stopped unexpectedly", "error", err)
+		}
+	}()
+
+	w.log.Info("webhook receiver listening", "addr", ln.Addr().String(), "endpoints", len(h.config.Endpoints))
+	return nil
+}
+
+// stop gracefully shuts the server down, finishing in-flight requests
+func (h *webhookIngestor) stop(ctx context.Context) error {
+	if h.srv == nil {
+		return nil
+	}
+	return h.srv.Shutdown(ctx)
+}
+
+func (h *webhookIngestor) handle(rw http.ResponseWriter, r *http.Request, e *WebhookEndpoint, client *Client, w *Workerd) {
+	body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, h.config.MaxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(rw, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+			return
+		}
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("could not read request body: %w", err))
+		return
+	}
+
+	if err := h.verify(e, r.Header.Get(e.signatureHeader()), body); err != nil {
+		w.log.Warn("rejected webhook", "path", e.Path, "remote_addr", r.RemoteAddr, "error", err)
+		writeError(rw, http.StatusUnauthorized, errors.New("invalid signature"))
+		return
+	}
+
+	md := Metadata{"webhook_path": e.Path}
+	var opts []asynq.Option
+	if e.Queue != "" {
+		opts = append(opts, asynq.Queue(e.Queue))
+	}
+	if e.IDHeader != "" {
+		if id := r.Header.Get(e.IDHeader); id != "" {
+			md["webhook_delivery_id"] = id
+			opts = append(opts, asynq.TaskID(e.Path+":"+id))
+		}
+	}
+
+	info, err := client.EnqueueContext(WithMetadata(r.Context(), md), asynq.NewTask(e.Type, body), opts...)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		// A redelivery of a webhook that was already enqueued
+		writeJSON(rw, http.StatusAccepted, map[string]any{"duplicate": true})
+		return
+	}
+	if err != nil {
+		w.log.Error("could not enqueue webhook", "path", e.Path, "type", e.Type, "error", err)
+		writeError(rw, http.StatusInternalServerError, errors.New("could not enqueue webhook"))
+		return
+	}
+	writeJSON(rw, http.StatusAccepted, map[string]any{"task_id": info.ID, "queue": info.Queue})
+}
+
+// verify checks the signature header of a request body
+func (h *webhookIngestor) verify(e *WebhookEndpoint, header string, body []byte) error {
+	if header == "" {
+		return fmt.Errorf("missing %s header", e.signatureHeader())
+	}
+	if e.scheme() == WebhookSchemeStripe {
+		return h.verifyStripe(e, header, body)
+	}
+	if !validHMAC(e.Secret, body, strings.TrimPrefix(header, "sha256=")) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// verifyStripe checks a "t=<unix>,v1=<hex>[,v1=<hex>]" signature and rejects
+// stale timestamps to limit replays
+func (h *webhookIngestor) verifyStripe(e *WebhookEndpoint, header string, body []byte) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp %q", timestamp)
+	}
+	tolerance := e.Tolerance
+	if tolerance == 0 {
+		tolerance = defaultStripeTolerance
+	}
+	if age := h.now().Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp outside tolerance of %v", tolerance)
+	}
+
+	signed := append([]byte(timestamp+"."), body...)
+	for _, sig := range signatures {
+		if validHMAC(e.Secret, signed, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature mismatch")
+}
+
+// validHMAC reports whether signature is the hex HMAC-SHA256 of data
+func validHMAC(secret string, data []byte, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -1,9 +1,17 @@
 package workerd
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/kardianos/service"
@@ -21,7 +29,102 @@ type Workerd struct {
 	displayName string
 	description string
 	concurrency int
+	pidFilePath string
+	pidFile     *pidFile
 	errorChan   chan error
+	redisOpt    asynq.RedisConnOpt
+	inspector   *asynq.Inspector
+	client      *Client
+	jobs        jobRunner
+	asynqHooks  []func(*asynq.Config)
+	decorators  []ContextDecorator
+	container   container
+	admin       *adminServer
+	grpcAdmin   *grpcAdminServer
+	metrics     metricsExporter
+	sentry      *sentryReporter
+	audit       *auditLog
+	events      *eventPublisher
+	checkpoints *checkpointStore
+	logLevel    *slog.LevelVar
+	logOutput   io.Writer
+	pools       []*workerPool
+	running     atomic.Bool
+	logFile     io.WriteCloser
+	ownsLogger  bool
+	systemLog   bool
+	levelBefore slog.Level
+	stopSignals func()
+	handlers    handlerRegistry
+	notFound    asynq.Handler
+	patterns    patternRouter
+	upgrades    upgradeRegistry
+	recorder    *recorder
+	resources   *resourceGuard
+	usage       *usageAccountant
+	history     *historyStore
+	alerts      *alerter
+	health      *healthChecks
+	maintenance *maintenanceScheduler
+	calendar    *businessCalendar
+	schemas     schemaRegistry
+	decoders    payloadDecoders
+	// configSources records which layer supplied each merged setting
+	configSources map[string]string
+	// strictPriority is set by WithStrictPriority
+	strictPriority bool
+	// keys encrypt and decrypt payloads, from WithKeyProvider or the config
+	keys KeyProvider
+	// blobs hold oversized payloads, from WithBlobStore or the config
+	blobs BlobStore
+	// outboxDB holds the outbox table relayed to Redis, set by WithOutbox
+	outboxDB *sql.DB
+	// ingestors feed tasks from external systems such as SQS
+	ingestors []ingestor
+	// kafka is the consumer of the Kafka ingestor, set by WithKafkaConsumer
+	kafka KafkaConsumer
+	// draining is set by Drain; active counts the tasks being processed
+	draining atomic.Bool
+	active   atomic.Int64
+	// version is reported in the worker registry, from WithVersion or the build info
+	version string
+	// started is when Start was called
+	started time.Time
+	// registry announces this worker to the fleet
+	registry *workerRegistry
+	// scheduler enqueues the periodic tasks added with Schedule
+	scheduler *periodicScheduler
+	// startHooks are the pre-flight checks added with OnStart
+	startHooks []func(ctx context.Context) error
+	// stopHooks run on shutdown, added with OnStop
+	stopHooks []func(ctx context.Context) error
+	// lifecycleListeners are called on lifecycle events, added with OnLifecycle
+	lifecycleListeners []func(LifecycleEvent)
+	// redisMonitor tracks the Redis connection
+	redisMonitor *redisMonitor
+
+	// failover holds tasks during a Sentinel failover; nil without Sentinel
+	failover *failoverGate
+
+	// Connection and inspector of inspection reads, see ReadInspector
+	readRedisOpt  asynq.RedisConnOpt
+	readInspector *asynq.Inspector
+
+	// Connections to the Redis shards, in the order of asynq.shards
+	shards []*redisShard
+
+	// requeuer enqueues again the tasks deferred until they can run
+	requeuer *requeuer
+
+	// Middleware chain shared by the servers and ProcessTask
+	chain     asynq.Handler
+	chainOnce sync.Once
+
+	// Clients made through the connections above, for pool metrics
+	redisPools *redisPools
+
+	// waitForRedis bounds the startup wait for Redis, set by WithWaitForRedis
+	waitForRedis time.Duration
 }
 
 // === Functional Option Type ===
@@ -76,27 +179,337 @@ func WithServiceFlag(serviceFlag string) Option {
 	}
 }
 
+// WithAsynqConfig modifies the asynq server configuration directly, for settings
+// workerd does not expose. It may be passed several times; hooks run in order.
+func WithAsynqConfig(fn func(*asynq.Config)) Option {
+	return func(w *Workerd) {
+		w.asynqHooks = append(w.asynqHooks, fn)
+	}
+}
+
+// WithLogOutput sends the built-in logger's output to out instead of stdout or
+// logging.file, e.g. os.Stderr, a buffer in tests or an io.MultiWriter.
+// It has no effect together with WithLogger.
+func WithLogOutput(out io.Writer) Option {
+	return func(w *Workerd) {
+		w.logOutput = out
+	}
+}
+
+// WithStrictPriority drains higher priority queues completely before
+// processing lower priority ones, like strict_priority in the config file
+func WithStrictPriority() Option {
+	return func(w *Workerd) {
+		w.strictPriority = true
+	}
+}
+
+// WithKeyProvider supplies payload encryption keys, e.g. from a KMS,
+// instead of the keys in the encryption config section
+func WithKeyProvider(p KeyProvider) Option {
+	return func(w *Workerd) {
+		w.keys = p
+	}
+}
+
+// WithBlobStore offloads oversized payloads to s, e.g. an S3 or GCS bucket,
+// instead of the store in the blobs config section
+func WithBlobStore(s BlobStore) Option {
+	return func(w *Workerd) {
+		w.blobs = s
+	}
+}
+
+// WithOutbox relays tasks written with Client.EnqueueTx from the outbox table
+// in db to Redis while the worker runs
+func WithOutbox(db *sql.DB) Option {
+	return func(w *Workerd) {
+		w.outboxDB = db
+	}
+}
+
+// WithKafkaConsumer enqueues the records fetched by c as tasks, mapped as
+// described in the ingest.kafka config section
+func WithKafkaConsumer(c KafkaConsumer) Option {
+	return func(w *Workerd) {
+		w.kafka = c
+	}
+}
+
+// WithVersion sets the version this worker reports in the fleet registry,
+// instead of the module version from the build info
+func WithVersion(version string) Option {
+	return func(w *Workerd) {
+		w.version = version
+	}
+}
+
+func WithPIDFile(path string) Option {
+	return func(w *Workerd) {
+		w.pidFilePath = path
+	}
+}
+
 // === Service Interface Implementation ===
 func (w *Workerd) Start(s service.Service) error {
 	w.log.Info("Workerd service starting...")
+	w.emit(LifecycleStarting, "", nil)
+	w.logEffectiveConfig()
+
+	// Guard against a second instance of the same worker on this host
+	if w.pidFilePath != "" {
+		pf, err := acquirePIDFile(w.pidFilePath)
+		if err != nil {
+			w.log.Error("could not acquire PID file", "path", w.pidFilePath, "error", err)
+			return err
+		}
+		w.pidFile = pf
+	}
 
-	// Start the asynq server
-	if err := w.srv.Start(w.ServeMux); err != nil {
+	// Check that Redis answers, or wait for it
+	if err := w.awaitRedis(); err != nil {
+		w.log.Error("could not connect to redis", "error", err)
+		w.releasePIDFile()
+		return err
+	}
+	if w.metrics != nil {
+		w.metrics.redisConnected(true)
+	}
+
+	// Run pre-flight checks before fetching any task
+	if err := w.runStartHooks(); err != nil {
+		w.releasePIDFile()
+		return err
+	}
+
+	// Start the asynq server and any additional worker pools
+	w.warnUnprocessedRoutes()
+	handler := w.handler()
+	if err := w.srv.Start(handler); err != nil {
 		w.log.Error("could not start asynq server", "error", err)
+		w.releasePIDFile()
 		return err
 	}
+	if err := w.startPools(handler); err != nil {
+		w.log.Error("could not start worker pools", "error", err)
+		w.srv.Shutdown()
+		w.releasePIDFile()
+		return err
+	}
+	w.running.Store(true)
+	w.started = time.Now().UTC()
+
+	// Announce the worker to the fleet
+	if w.registry != nil {
+		if err := w.heartbeat(context.Background()); err != nil {
+			w.log.Warn("could not register worker", "error", err)
+		}
+	}
+
+	// Enqueue periodic tasks once elected, through the client so they are
+	// encrypted, stamped and limited like the tasks of producers
+	if client, err := w.Client(); err != nil {
+		w.log.Error("could not start scheduler", "error", err)
+	} else {
+		w.scheduler.start(client)
+	}
+
+	// Start the admin API
+	if w.config.Admin.Enabled {
+		w.admin = newAdminServer(w)
+		if err := w.admin.start(w.config.Admin.Addr); err != nil {
+			w.log.Error("could not start admin API", "error", err)
+			w.shutdownPools()
+			w.srv.Shutdown()
+			w.releasePIDFile()
+			return err
+		}
+
+		if w.config.Admin.GRPCAddr != "" {
+			grpcAdmin, err := newGRPCAdminServer(w)
+			if err == nil {
+				w.grpcAdmin = grpcAdmin
+				err = w.grpcAdmin.start(w.config.Admin.GRPCAddr)
+			}
+			if err != nil {
+				w.log.Error("could not start gRPC admin API", "error", err)
+				w.admin.stop(context.Background())
+				w.shutdownPools()
+				w.srv.Shutdown()
+				w.releasePIDFile()
+				return err
+			}
+		}
+	}
+
+	// Start the metrics endpoint
+	if w.metrics != nil {
+		if err := w.metrics.start(w); err != nil {
+			w.log.Error("could not start metrics endpoint", "error", err)
+		}
+	}
+
+	// Start consuming external sources
+	for _, in := range w.ingestors {
+		if err := in.start(w); err != nil {
+			w.log.Error("could not start ingestor", "error", err)
+		}
+	}
+
+	// Toggle debug logging on SIGUSR2, restart gracefully on SIGHUP and
+	// drain on SIGUSR1 where supported
+	stopLogLevel := w.watchLogLevelSignal()
+	stopRestart := w.watchRestartSignal()
+	stopDrain := w.watchDrainSignal()
+	w.stopSignals = func() {
+		stopLogLevel()
+		stopRestart()
+		stopDrain()
+	}
+
+	// Start internal background jobs
+	w.jobs.start(func(name string, err error) {
+		w.log.Error("internal job failed", "job", name, "error", err)
+	})
 
 	w.log.Info("Workerd service started successfully")
+	w.emit(LifecycleStarted, "", nil)
 	return nil
 }
 
 func (w *Workerd) Stop(s service.Service) error {
 	w.log.Info("Workerd service stopping...")
+	w.emit(LifecycleStopping, "", nil)
+	if w.stopSignals != nil {
+		w.stopSignals()
+	}
+	// Stop ingesting first so nothing is enqueued through a closed client
+	for _, in := range w.ingestors {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := in.stop(ctx); err != nil {
+			w.log.Warn("could not stop ingestor", "error", err)
+		}
+		cancel()
+	}
+	if w.admin != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := w.admin.stop(ctx); err != nil {
+			w.log.Warn("could not stop admin API", "error", err)
+		}
+		cancel()
+	}
+	if w.metrics != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := w.metrics.stop(ctx); err != nil {
+			w.log.Warn("could not stop metrics endpoint", "error", err)
+		}
+		cancel()
+	}
+	if w.grpcAdmin != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		w.grpcAdmin.stop(ctx)
+		cancel()
+	}
+	w.jobs.stop()
+	w.scheduler.stop()
+	if err := w.scheduler.close(); err != nil {
+		w.log.Warn("could not close scheduler", "error", err)
+	}
+	w.shutdownPools()
 	w.srv.Shutdown()
+	w.running.Store(false)
+	w.runStopHooks()
+	if w.registry != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := w.registry.deregister(ctx, w.workerInfo().ID); err != nil {
+			w.log.Warn("could not deregister worker", "error", err)
+		}
+		cancel()
+		if err := w.registry.close(); err != nil {
+			w.log.Warn("could not close worker registry", "error", err)
+		}
+	}
+	if w.usage != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := w.usage.flush(ctx); err != nil {
+			w.log.Warn("could not flush task usage", "error", err)
+		}
+		cancel()
+		if err := w.usage.close(); err != nil {
+			w.log.Warn("could not close usage store", "error", err)
+		}
+	}
+	if w.sentry != nil {
+		w.sentry.flush(2 * time.Second)
+	}
+	if w.audit != nil {
+		if err := w.audit.close(); err != nil {
+			w.log.Warn("could not close audit log", "error", err)
+		}
+	}
+	if w.events != nil {
+		if err := w.events.close(); err != nil {
+			w.log.Warn("could not close event publisher", "error", err)
+		}
+	}
+	if w.history != nil {
+		if err := w.history.close(); err != nil {
+			w.log.Warn("could not close task history", "error", err)
+		}
+	}
+	if w.maintenance != nil {
+		if err := w.maintenance.close(); err != nil {
+			w.log.Warn("could not close maintenance scheduler", "error", err)
+		}
+	}
+	if w.alerts != nil {
+		if err := w.alerts.close(); err != nil {
+			w.log.Warn("could not close alert notifiers", "error", err)
+		}
+	}
+	if w.checkpoints != nil {
+		if err := w.checkpoints.close(); err != nil {
+			w.log.Warn("could not close checkpoint store", "error", err)
+		}
+	}
+	if w.client != nil {
+		if err := w.client.Close(); err != nil {
+			w.log.Warn("could not close client", "error", err)
+		}
+	}
+	if w.requeuer != nil {
+		if err := w.requeuer.close(); err != nil {
+			w.log.Warn("could not close requeue connections", "error", err)
+		}
+	}
+	if w.inspector != nil {
+		if err := w.inspector.Close(); err != nil {
+			w.log.Warn("could not close inspector", "error", err)
+		}
+	}
+	if w.readInspector != nil {
+		if err := w.readInspector.Close(); err != nil {
+			w.log.Warn("could not close read replica inspector", "error", err)
+		}
+	}
+	w.closeShards()
+	w.releasePIDFile()
 	w.log.Info("Workerd service stopped")
+	w.emit(LifecycleStopped, "", nil)
+	if w.logFile != nil {
+		w.logFile.Close()
+	}
 	return nil
 }
 
+// releasePIDFile removes the PID file if this instance holds it
+func (w *Workerd) releasePIDFile() {
+	if err := w.pidFile.release(); err != nil {
+		w.log.Warn("could not release PID file", "error", err)
+	}
+	w.pidFile = nil
+}
+
 // === Utility Functions ===
 func splitConfigPath(configPath string) []string {
 	if len(configPath) == 0 {
@@ -111,15 +524,84 @@ func (w *Workerd) initializeComponents(config *workerConfig) error {
 		return fmt.Errorf("config cannot be nil")
 	}
 
-	// Initialize logger if not provided
+	// Initialize logger if not provided; its level can be changed at runtime
+	w.logLevel = new(slog.LevelVar)
+	w.logLevel.Set(config.LogLevel)
 	if w.log == nil {
-		w.log = newLogger(config.LogLevel)
+		var out io.Writer = os.Stdout
+		if w.logOutput != nil {
+			out = w.logOutput
+		} else if f := config.Logging.rotatingFile(); f != nil {
+			w.logFile, out = f, f
+		}
+		w.log = newLogger(w.logLevel, out)
+		w.ownsLogger = true
 	}
 
 	// Initialize ServeMux if not provided
 	if w.ServeMux == nil {
 		w.ServeMux = asynq.NewServeMux()
 	}
+	w.ServeMux.HandleFunc(SelfTestTaskType, w.handleSelfTest)
+	w.DescribeHandler(SelfTestTaskType, "Round trip check enqueued by the selftest command")
+
+	w.decoders = defaultPayloadDecoders()
+	if w.keys == nil {
+		keys, err := config.Encryption.keyProvider()
+		if err != nil {
+			return fmt.Errorf("failed to load encryption keys: %w", err)
+		}
+		w.keys = keys
+	}
+	if w.keys != nil {
+		w.decoders[encryptionAlgorithm] = encryptionDecoder(w.keys)
+	}
+	if w.blobs == nil {
+		blobs, err := config.Blobs.blobStore()
+		if err != nil {
+			return fmt.Errorf("failed to open blob store: %w", err)
+		}
+		w.blobs = blobs
+	}
+	if w.blobs != nil {
+		w.decoders[blobEncoding] = blobDecoder(w.blobs)
+	}
+
+	if config.Metrics.Enabled {
+		exporter, err := newMetricsExporter(&config.Metrics)
+		if err != nil {
+			return fmt.Errorf("failed to create metrics exporter: %w", err)
+		}
+		w.metrics = exporter
+	}
+
+	ingestors, err := newIngestors(&config.Ingest, w.kafka)
+	if err != nil {
+		return err
+	}
+	w.ingestors = ingestors
+
+	if config.Sentry.DSN != "" {
+		reporter, err := newSentryReporter(&config.Sentry, config.Name)
+		if err != nil {
+			return err
+		}
+		w.sentry = reporter
+	}
+
+	w.health = newHealthChecks(&config.HealthChecks, w.log)
+
+	redisOpt, err := config.AsynqConfig.RedisConnOpt()
+	if err != nil {
+		return fmt.Errorf("failed to get Redis client options: %w", err)
+	}
+	poolSize := config.AsynqConfig.RedisClient.PoolSize
+	w.redisPools = newRedisPools()
+	w.redisOpt = w.redisPools.track(redisOpt, "primary", poolSize)
+	w.readRedisOpt = w.redisOpt
+	if config.AsynqConfig.ReadReplica.enabled() {
+		w.readRedisOpt = w.redisPools.track(config.AsynqConfig.readConnOpt(), "replica", poolSize)
+	}
 
 	// Initialize asynq server using ServerBuilder
 	serverBuilder, err := NewServerBuilder(config)
@@ -127,22 +609,326 @@ func (w *Workerd) initializeComponents(config *workerConfig) error {
 		return fmt.Errorf("failed to create server builder: %w", err)
 	}
 
-	w.srv, err = serverBuilder.BuildServer(w.concurrency)
+	w.srv, err = serverBuilder.WithRedisConnOpt(w.redisOpt).WithAsynqConfig(w.asynqConfigHooks()...).BuildServerWithDefaults()
 	if err != nil {
 		return fmt.Errorf("failed to build asynq server: %w", err)
 	}
 
+	if err := w.addConfiguredPools(config.Pools); err != nil {
+		return err
+	}
+	if err := w.addShards(config); err != nil {
+		return err
+	}
+	w.requeuer = newRequeuer(config.AsynqConfig, w.redisOpt)
+
+	if err := w.registerSchemaFiles(config.Schemas); err != nil {
+		return err
+	}
+
+	w.audit, err = newAuditLog(&config.Audit, w.redisOpt, w.log)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	w.events, err = newEventPublisher(&config.Events, w.redisOpt, w.log)
+	if err != nil {
+		return fmt.Errorf("failed to start event publisher: %w", err)
+	}
+
+	w.checkpoints, err = newCheckpointStore(&config.Checkpoint, w.redisOpt)
+	if err != nil {
+		return err
+	}
+
+	w.scheduler, err = newPeriodicScheduler(&config.Scheduler, &config.Routing, w.redisOpt, w.name, w.log)
+	if err != nil {
+		return err
+	}
+	w.scheduler.reloaded = func() {
+		w.emit(LifecycleConfigReloaded, "schedules", nil)
+	}
+	if err := w.scheduleConfigured(config.Schedules); err != nil {
+		return err
+	}
+
+	if config.Registry.Enabled {
+		w.registry, err = newWorkerRegistry(&config.Registry, w.redisOpt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Recording.Enabled {
+		w.recorder = newRecorder(&config.Recording, w.log)
+	}
+	if config.Resources.Enabled {
+		w.resources = newResourceGuard(&config.Resources, w.log)
+	}
+	if config.Usage.Enabled {
+		w.usage, err = newUsageAccountant(&config.Usage, w.redisOpt)
+		if err != nil {
+			return err
+		}
+	}
+	if config.History.Enabled {
+		host, _ := os.Hostname()
+		worker := fmt.Sprintf("%s:%d", host, os.Getpid())
+		w.history, err = newHistoryStore(&config.History, w.redisOpt, w.readRedisOpt, worker, w.log)
+		if err != nil {
+			return err
+		}
+	}
+	w.calendar, err = newBusinessCalendar(&config.BusinessHours)
+	if err != nil {
+		return err
+	}
+	if len(config.Maintenance.Windows) > 0 {
+		w.maintenance, err = newMaintenanceScheduler(&config.Maintenance, w)
+		if err != nil {
+			return err
+		}
+	}
+	w.alerts, err = newAlerter(&config.Alerts, w.log)
+	if err != nil {
+		return fmt.Errorf("failed to create alert notifiers: %w", err)
+	}
+	if config.Chaos.Enabled {
+		w.log.Warn("chaos mode enabled: faults are injected into tasks on purpose",
+			"error_rate", config.Chaos.ErrorRate, "panic_rate", config.Chaos.PanicRate,
+			"cancel_rate", config.Chaos.CancelRate, "delay_rate", config.Chaos.DelayRate)
+	}
+
+	w.redisMonitor = newRedisMonitor(w)
+	if config.AsynqConfig.RedisClient.Sentinel.enabled() {
+		w.failover = &failoverGate{}
+	}
+	w.registerInternalJobs(config)
+
 	return nil
 }
 
+// handler wraps the ServeMux with workerd's internal middleware.
+func (w *Workerd) handler() asynq.Handler {
+	w.chainOnce.Do(func() {
+		w.chain = w.handlerWith(w.log, w.metrics)
+	})
+	return w.chain
+}
+
+// ProcessTask runs a task through workerd's middleware and handlers, as the
+// server does. It makes the worker an asynq.Handler for servers started
+// outside workerd and for workerdtest.Broker.
+func (w *Workerd) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	return w.handler().ProcessTask(ctx, t)
+}
+
+// handlerWith builds the middleware chain logging to log and recording task
+// metrics with metrics. The first middleware listed is the outermost.
+func (w *Workerd) handlerWith(log *slog.Logger, metrics metricsExporter) asynq.Handler {
+	mws := []asynq.MiddlewareFunc{
+		activeMiddleware(w),
+		requeueMiddleware(w.requeuer),
+		failoverMiddleware(w.failover, &w.config.AsynqConfig.RedisClient.Sentinel),
+		metadataMiddleware(w.decoders, w.blobs, log),
+		taskLoggingMiddleware(log, w.config.Logging.TaskEvents),
+		recordingMiddleware(w.recorder),
+		routingMiddleware(&w.config.Routing),
+		deadlineMiddleware,
+		businessHoursMiddleware(w.calendar),
+		healthMiddleware(w.health),
+		resourceMiddleware(w.resources),
+		fairnessMiddleware(&w.config.Fairness, w.concurrency),
+		bulkheadMiddleware(&w.config.Bulkheads),
+		metricsMiddleware(metrics),
+		usageMiddleware(w.usage, metrics),
+		slaMiddleware(&w.config.SLA, metrics, w.alerts, log),
+		sentryMiddleware(w.sentry),
+		auditMiddleware(w.audit),
+		historyMiddleware(w.history),
+		eventsMiddleware(w.events),
+		upgradeMiddleware(&w.upgrades),
+		schemaMiddleware(&w.schemas),
+		heartbeatMiddleware(&w.config.Heartbeat),
+		checkpointMiddleware(w.checkpoints),
+		contextMiddleware(w.decorators),
+		retryMiddleware,
+		failureMiddleware(&w.config.Failures),
+		chaosMiddleware(&w.config.Chaos),
+	}
+
+	h := w.dispatch()
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// asynqConfigHooks returns workerd's own asynq settings followed by the
+// WithAsynqConfig hooks, which take precedence
+func (w *Workerd) asynqConfigHooks() []func(*asynq.Config) {
+	own := func(c *asynq.Config) {
+		// Custom checks run along with asynq's Redis check
+		c.HealthCheckInterval = w.config.HealthChecks.Interval
+		c.HealthCheckFunc = func(error) {
+			w.health.run(context.Background())
+		}
+		if w.config.Alerts.ArchivedTasks {
+			c.ErrorHandler = asynq.ErrorHandlerFunc(w.alertArchived)
+		}
+	}
+	return append([]func(*asynq.Config){own}, w.asynqHooks...)
+}
+
+// Inspector returns an asynq inspector connected to the worker's Redis
+func (w *Workerd) Inspector() *asynq.Inspector {
+	if w.inspector == nil {
+		w.inspector = asynq.NewInspector(w.redisOpt)
+	}
+	return w.inspector
+}
+
+// registerInternalJobs registers the periodic jobs enabled by the configuration
+func (w *Workerd) registerInternalJobs(config *workerConfig) {
+	if config.Retention.enabled() {
+		w.jobs.add(internalJob{
+			name:     "retention",
+			interval: config.Retention.Interval,
+			run: func(ctx context.Context) error {
+				var errs []error
+				for _, inspector := range w.inspectors() {
+					enforcer := &retentionEnforcer{
+						config:    &config.Retention,
+						inspector: inspector,
+						now:       time.Now,
+					}
+					deleted, err := enforcer.run(ctx)
+					if deleted > 0 {
+						w.log.Info("retention job deleted expired tasks", "count", deleted)
+					}
+					errs = append(errs, err)
+				}
+				return errors.Join(errs...)
+			},
+		})
+	}
+
+	if config.Registry.Enabled {
+		w.jobs.add(internalJob{
+			name:     "registry-heartbeat",
+			interval: config.Registry.Interval,
+			run:      w.heartbeat,
+		})
+	}
+
+	if w.resources != nil {
+		w.jobs.add(internalJob{
+			name:     "resource-guard",
+			interval: config.Resources.Interval,
+			run:      w.resources.check,
+		})
+	}
+
+	if w.usage != nil {
+		w.jobs.add(internalJob{
+			name:     "usage-flush",
+			interval: config.Usage.FlushInterval,
+			run:      w.usage.flush,
+		})
+	}
+
+	if w.alerts != nil && len(config.Alerts.Queues) > 0 {
+		w.jobs.add(internalJob{
+			name:     "queue-alerts",
+			interval: config.Alerts.CheckInterval,
+			run:      newQueueMonitor(&config.Alerts, w).check,
+		})
+	}
+
+	if w.maintenance != nil {
+		w.jobs.add(internalJob{
+			name:     "maintenance-windows",
+			interval: config.Maintenance.Interval,
+			run:      w.maintenance.check,
+		})
+	}
+
+	if config.HealthPing.URL != "" {
+		w.jobs.add(internalJob{
+			name:     "health-ping",
+			interval: config.HealthPing.Interval,
+			run:      newHealthPinger(&config.HealthPing, w.checkReady).ping,
+		})
+	}
+
+	w.jobs.add(internalJob{
+		name:     "redis-monitor",
+		interval: config.AsynqConfig.Connection.CheckInterval,
+		run:      w.redisMonitor.check,
+	})
+
+	if w.failover != nil {
+		w.jobs.add(internalJob{
+			name:     "sentinel-watch",
+			interval: sentinelResubscribeDelay,
+			run:      newSentinelWatcher(w, w.failover).run,
+		})
+	}
+
+	if config.Admin.Enabled && config.Admin.GRPCAddr != "" {
+		w.jobs.add(internalJob{
+			name:     "grpc-health",
+			interval: grpcHealthInterval,
+			run: func(ctx context.Context) error {
+				if w.grpcAdmin != nil {
+					w.grpcAdmin.updateHealth()
+				}
+				return nil
+			},
+		})
+	}
+
+	if w.outboxDB != nil {
+		relay := &outboxRelay{db: w.outboxDB, config: &config.Outbox, client: w.Client, log: w.log}
+		w.jobs.add(internalJob{
+			name:     "outbox-relay",
+			interval: config.Outbox.Interval,
+			run:      relay.run,
+		})
+	}
+
+	if config.Migration.Enabled && config.Migration.ReconcileInterval > 0 {
+		w.jobs.add(internalJob{
+			name:     "migration-reconcile",
+			interval: config.Migration.ReconcileInterval,
+			run: func(ctx context.Context) error {
+				legacyOpt, err := config.Migration.legacyRedisOpt()
+				if err != nil {
+					return err
+				}
+				reports, err := Reconcile(w.redisOpt, legacyOpt)
+				if err != nil {
+					return err
+				}
+				for _, r := range reports {
+					w.log.Info("migration reconciliation",
+						"queue", r.Queue,
+						"new", r.New,
+						"legacy", r.Legacy,
+						"missing_in_legacy", len(r.MissingInLegacy),
+						"missing_in_new", len(r.MissingInNew))
+				}
+				return nil
+			},
+		})
+	}
+}
+
 // === Constructor ===
 func NewWorkerd(opts ...Option) (*Workerd, error) {
-	w := &Workerd{
-		name:        "workerd",
-		displayName: "Workerd Service",
-		description: "Background worker service",
-		concurrency: 10,
-	}
+	// Defaults are supplied by the merger so that options only override
+	// configuration files when they are explicitly set
+	w := &Workerd{}
 
 	// Apply functional options
 	for _, opt := range opts {
@@ -161,6 +947,9 @@ func NewWorkerd(opts ...Option) (*Workerd, error) {
 		Logger:      w.log,
 		ConfigPath:  w.configPath,
 		ServiceFlag: w.serviceFlag,
+		PIDFile:     w.pidFilePath,
+		// Only an explicit WithStrictPriority is an option override
+		StrictPriority: w.strictPriority,
 	}
 
 	// Load configuration
@@ -195,7 +984,12 @@ func NewWorkerd(opts ...Option) (*Workerd, error) {
 	w.concurrency = mergedConfig.Concurrency
 	w.configPath = mergedConfig.ConfigPath
 	w.serviceFlag = mergedConfig.ServiceFlag
+	w.pidFilePath = mergedConfig.PIDFile
 	w.config = mergedConfig.Config
+	w.configSources = mergedConfig.Sources
+	if w.version == "" {
+		w.version = buildVersion()
+	}
 
 	// Use provided logger or create default
 	if mergedConfig.Logger != nil {
@@ -3,6 +3,7 @@ package workerd
 import (
 	"flag"
 	"fmt"
+	"time"
 )
 
 type cliFlags struct {
@@ -12,16 +13,20 @@ type cliFlags struct {
 	displayName string
 	description string
 	concurrency int
+	pidFile     string
+	waitRedis   time.Duration
 }
 
 func parseFlags() *cliFlags {
 	flags := &cliFlags{}
-	flag.StringVar(&flags.service, "service", "run", "Control the system service (install, uninstall, start, stop, restart, run)")
+	flag.StringVar(&flags.service, "service", "run", "Control the system service (install, uninstall, start, stop, restart, run, status)")
 	flag.StringVar(&flags.configPath, "config", "", "Path to either a file or directory to load configuration from")
 	flag.StringVar(&flags.name, "name", "", "Service name")
 	flag.StringVar(&flags.displayName, "display-name", "", "Service display name")
 	flag.StringVar(&flags.description, "description", "", "Service description")
-	flag.IntVar(&flags.concurrency, "concurrency", 1, "Number of concurrent workers")
+	flag.IntVar(&flags.concurrency, "concurrency", 0, "Number of concurrent workers (overrides the configuration when set)")
+	flag.StringVar(&flags.pidFile, "pid-file", "", "Path of the PID file used to prevent duplicate instances")
+	flag.DurationVar(&flags.waitRedis, "wait-for-redis", 0, "Wait up to this long for Redis to answer before starting, e.g. 60s")
 	flag.Parse()
 	return flags
 }
@@ -58,6 +63,12 @@ func (c *WorkerdWithFlags) buildOptions(flags *cliFlags) []Option {
 	if flags.concurrency > 0 {
 		opts = append(opts, WithConcurrency(flags.concurrency))
 	}
+	if flags.pidFile != "" {
+		opts = append(opts, WithPIDFile(flags.pidFile))
+	}
+	if flags.waitRedis > 0 {
+		opts = append(opts, WithWaitForRedis(flags.waitRedis))
+	}
 
 	return opts
 }
@@ -71,11 +82,16 @@ func (c *WorkerdWithFlags) Run() error {
 	// Create and run workerd
 	workerd, err := NewWorkerd(opts...)
 	if err != nil {
-		return fmt.Errorf("failed to create workerd: %v", err)
+		return fmt.Errorf("failed to create workerd: %w", err)
+	}
+
+	// Subcommands run instead of the service
+	if args := flag.Args(); len(args) > 0 {
+		return runCommand(workerd, args)
 	}
 
 	if err := workerd.Run(); err != nil {
-		return fmt.Errorf("failed to run workerd: %v", err)
+		return fmt.Errorf("failed to run workerd: %w", err)
 	}
 
 	return nil
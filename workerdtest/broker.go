@@ -0,0 +1,417 @@
+// Package workerdtest provides an in-process broker for unit tests of task
+// handlers and enqueue logic, so they run without a Redis server.
+//
+// Code under test enqueues through a workerd.Enqueuer, which the Broker
+// satisfies, and tests process the enqueued tasks synchronously:
+//
+//	b := workerdtest.NewBroker(t)
+//	err := signup(ctx, b, user) // enqueues "email:welcome"
+//	task := b.AssertEnqueued(t, "email:welcome")
+//	n, err := b.ProcessAll(ctx, mux)
+package workerdtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+)
+
+// retryHold is the retry delay of failed tasks, which wait for Retry instead
+const retryHold = 100 * 365 * 24 * time.Hour
+
+// completedRetention keeps completed tasks listed for assertions
+const completedRetention = 24 * time.Hour
+
+// Task is a task held by the Broker
+type Task struct {
+	ID        string
+	Queue     string
+	Type      string
+	Payload   []byte
+	State     asynq.TaskState
+	MaxRetry  int
+	Retried   int
+	Timeout   time.Duration
+	Deadline  time.Time
+	ProcessAt time.Time
+	LastErr   string
+	// Data written by the handler with its task's ResultWriter
+	Result []byte
+	// seq is the enqueue order; tasks enqueued around the broker come last
+	seq int
+}
+
+func taskOf(info *asynq.TaskInfo, seq int) Task {
+	return Task{
+		ID:        info.ID,
+		Queue:     info.Queue,
+		Type:      info.Type,
+		Payload:   info.Payload,
+		State:     info.State,
+		MaxRetry:  info.MaxRetry,
+		Retried:   info.Retried,
+		Timeout:   info.Timeout,
+		Deadline:  info.Deadline,
+		ProcessAt: info.NextProcessAt,
+		LastErr:   info.LastErr,
+		Result:    info.Result,
+		seq:       seq,
+	}
+}
+
+// Broker runs asynq on an in-process Redis, so tasks are stored and
+// processed exactly as in production. Tasks are processed in enqueue order,
+// only when a test calls ProcessOne or ProcessAll, and scheduled tasks only
+// once the broker clock, moved with Advance, reaches them. Failed tasks with
+// retries left wait in the retry state until Retry is called. It is safe for
+// concurrent use.
+type Broker struct {
+	t         testing.TB
+	redis     *miniredis.Miniredis
+	opt       asynq.RedisClientOpt
+	client    *asynq.Client
+	inspector *asynq.Inspector
+
+	mu     sync.Mutex
+	offset time.Duration
+	// seqs records the enqueue order by queue and task ID
+	seqs map[[2]string]int
+	// processing serializes ProcessOne
+	processing sync.Mutex
+}
+
+// NewBroker returns an empty broker, closed when the test ends
+func NewBroker(t testing.TB) *Broker {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	opt := asynq.RedisClientOpt{Addr: mr.Addr()}
+	b := &Broker{
+		t:         t,
+		redis:     mr,
+		opt:       opt,
+		client:    asynq.NewClient(opt),
+		inspector: asynq.NewInspector(opt),
+		seqs:      make(map[[2]string]int),
+	}
+	t.Cleanup(func() {
+		b.client.Close()
+		b.inspector.Close()
+	})
+	return b
+}
+
+// Addr is the address of the broker's Redis, e.g. to point a worker or a
+// workerd.Client at it through ASYNQ_REDIS_ADDRESS
+func (b *Broker) Addr() string {
+	return b.redis.Addr()
+}
+
+// now is the broker clock: the wall clock moved forward by Advance
+func (b *Broker) now() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Add(b.offset)
+}
+
+// Enqueue enqueues a task using a background context
+func (b *Broker) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	return b.EnqueueContext(context.Background(), task, opts...)
+}
+
+// EnqueueContext enqueues a task with asynq, so every option given to
+// asynq.NewTask or here applies. ProcessIn given here counts from the broker
+// clock. Completed tasks are retained for a day unless opts set a retention.
+func (b *Broker) EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	if task == nil {
+		return nil, fmt.Errorf("task cannot be nil")
+	}
+
+	now := b.now()
+	all := []asynq.Option{asynq.Retention(completedRetention)}
+	for _, o := range opts {
+		if o != nil && o.Type() == asynq.ProcessInOpt {
+			o = asynq.ProcessAt(now.Add(o.Value().(time.Duration)))
+		}
+		all = append(all, o)
+	}
+	info, err := b.client.EnqueueContext(ctx, task, all...)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seqs[[2]string{info.Queue, info.ID}] = len(b.seqs)
+	return info, nil
+}
+
+// Advance moves the broker clock forward, making scheduled tasks due
+func (b *Broker) Advance(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.offset += d
+}
+
+// Retry makes the tasks waiting for a retry pending again
+func (b *Broker) Retry() {
+	queues, err := b.inspector.Queues()
+	if err != nil {
+		b.t.Fatalf("workerdtest: could not list queues: %v", err)
+	}
+	for _, q := range queues {
+		if _, err := b.inspector.RunAllRetryTasks(q); err != nil {
+			b.t.Fatalf("workerdtest: could not retry the tasks of %s: %v", q, err)
+		}
+	}
+}
+
+// Reset removes every task
+func (b *Broker) Reset() {
+	b.redis.FlushAll()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seqs = make(map[[2]string]int)
+}
+
+// listFuncs list the tasks of a queue in each state
+var listFuncs = map[asynq.TaskState]func(*asynq.Inspector, string, ...asynq.ListOption) ([]*asynq.TaskInfo, error){
+	asynq.TaskStatePending:   (*asynq.Inspector).ListPendingTasks,
+	asynq.TaskStateActive:    (*asynq.Inspector).ListActiveTasks,
+	asynq.TaskStateScheduled: (*asynq.Inspector).ListScheduledTasks,
+	asynq.TaskStateRetry:     (*asynq.Inspector).ListRetryTasks,
+	asynq.TaskStateArchived:  (*asynq.Inspector).ListArchivedTasks,
+	asynq.TaskStateCompleted: (*asynq.Inspector).ListCompletedTasks,
+}
+
+// Tasks returns copies of the tasks in the given states, or of all tasks,
+// in enqueue order
+func (b *Broker) Tasks(states ...asynq.TaskState) []Task {
+	list, err := b.tasks(states...)
+	if err != nil {
+		b.t.Fatalf("workerdtest: could not list tasks: %v", err)
+	}
+	return list
+}
+
+func (b *Broker) tasks(states ...asynq.TaskState) ([]Task, error) {
+	if len(states) == 0 {
+		for s := range listFuncs {
+			states = append(states, s)
+		}
+	}
+	queues, err := b.inspector.Queues()
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	seqs := maps.Clone(b.seqs)
+	b.mu.Unlock()
+
+	var list []Task
+	for _, q := range queues {
+		for _, s := range states {
+			list, err = b.appendTasks(list, listFuncs[s], q, seqs)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	slices.SortStableFunc(list, func(a, b Task) int { return a.seq - b.seq })
+	return list, nil
+}
+
+// appendTasks appends every page of the tasks list returns
+func (b *Broker) appendTasks(tasks []Task, list func(*asynq.Inspector, string, ...asynq.ListOption) ([]*asynq.TaskInfo, error), queue string, seqs map[[2]string]int) ([]Task, error) {
+	const pageSize = 1000
+	for page := 1; ; page++ {
+		infos, err := list(b.inspector, queue, asynq.Page(page), asynq.PageSize(pageSize))
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			seq, ok := seqs[[2]string{info.Queue, info.ID}]
+			if !ok {
+				seq = math.MaxInt
+			}
+			tasks = append(tasks, taskOf(info, seq))
+		}
+		if len(infos) < pageSize {
+			return tasks, nil
+		}
+	}
+}
+
+// Enqueued returns copies of the pending and scheduled tasks of the type, or
+// of every type when taskType is empty
+func (b *Broker) Enqueued(taskType string) []Task {
+	var list []Task
+	for _, t := range b.Tasks(asynq.TaskStatePending, asynq.TaskStateScheduled) {
+		if taskType == "" || t.Type == taskType {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+// next makes the scheduled tasks the broker clock reached pending and
+// returns the first pending task, or nil
+func (b *Broker) next() (*Task, error) {
+	now := b.now()
+	scheduled, err := b.tasks(asynq.TaskStateScheduled)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range scheduled {
+		if !t.ProcessAt.After(now) {
+			if err := b.inspector.RunTask(t.Queue, t.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	pending, err := b.tasks(asynq.TaskStatePending)
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+	return &pending[0], nil
+}
+
+// ProcessOne runs the first due task through the handler and reports whether
+// there was one. The task is processed by an asynq server, so the handler
+// gets the asynq task context (task ID, queue, retry count, deadline) with
+// the values of ctx, and a working ResultWriter. The task then completes,
+// waits for a retry, is archived, or is removed if the handler returns
+// asynq.RevokeTask. Handler panics are returned as errors.
+//
+// Pass a *workerd.Workerd as h to run the task through workerd's middleware,
+// which unwraps metadata and decodes payloads, as well as its handlers.
+func (b *Broker) ProcessOne(ctx context.Context, h asynq.Handler) (bool, error) {
+	b.processing.Lock()
+	defer b.processing.Unlock()
+
+	t, err := b.next()
+	if err != nil {
+		return false, err
+	}
+	if t == nil {
+		return false, nil
+	}
+
+	type result struct {
+		id, typ string
+		err     error
+	}
+	done := make(chan result, 1)
+	srv := asynq.NewServer(b.opt, asynq.Config{
+		Concurrency:       1,
+		Queues:            map[string]int{t.Queue: 1},
+		BaseContext:       func() context.Context { return ctx },
+		RetryDelayFunc:    func(int, error, *asynq.Task) time.Duration { return retryHold },
+		TaskCheckInterval: 10 * time.Millisecond,
+		LogLevel:          asynq.FatalLevel,
+	})
+	err = srv.Start(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		// Leave the rest of the queue for the next call
+		srv.Stop()
+		id, _ := asynq.GetTaskID(ctx)
+		err := run(ctx, h, task)
+		done <- result{id, task.Type(), err}
+		return err
+	}))
+	if err != nil {
+		return false, err
+	}
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		srv.Shutdown()
+		return false, ctx.Err()
+	}
+	// Waits for asynq to record the outcome
+	srv.Shutdown()
+	if res.err != nil {
+		return true, fmt.Errorf("task %s (%s): %w", res.id, res.typ, res.err)
+	}
+	return true, nil
+}
+
+// run calls the handler, turning a panic into an error
+func run(ctx context.Context, h asynq.Handler, task *asynq.Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h.ProcessTask(ctx, task)
+}
+
+// ProcessAll runs due tasks until none is left, including tasks the handlers
+// enqueue on the broker, and returns how many ran along with the handler
+// errors joined
+func (b *Broker) ProcessAll(ctx context.Context, h asynq.Handler) (int, error) {
+	var errs []error
+	n := 0
+	for {
+		ok, err := b.ProcessOne(ctx, h)
+		if !ok {
+			if err != nil {
+				errs = append(errs, err)
+			}
+			return n, errors.Join(errs...)
+		}
+		n++
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
+
+// AssertEnqueued fails the test unless a task of the type is pending or
+// scheduled, and returns the first one
+func (b *Broker) AssertEnqueued(t testing.TB, taskType string) Task {
+	t.Helper()
+	tasks := b.Enqueued(taskType)
+	if len(tasks) == 0 {
+		t.Fatalf("no %q task enqueued; enqueued types: %v", taskType, b.enqueuedTypes())
+		return Task{}
+	}
+	return tasks[0]
+}
+
+// AssertNotEnqueued fails the test if a task of the type is pending or scheduled
+func (b *Broker) AssertNotEnqueued(t testing.TB, taskType string) {
+	t.Helper()
+	if n := len(b.Enqueued(taskType)); n > 0 {
+		t.Errorf("%d %q tasks enqueued, expected none", n, taskType)
+	}
+}
+
+// AssertEnqueuedCount fails the test unless exactly n tasks of the type are
+// pending or scheduled
+func (b *Broker) AssertEnqueuedCount(t testing.TB, taskType string, n int) {
+	t.Helper()
+	if got := len(b.Enqueued(taskType)); got != n {
+		t.Errorf("%d %q tasks enqueued, expected %d", got, taskType, n)
+	}
+}
+
+// enqueuedTypes lists the types of the enqueued tasks for failure messages
+func (b *Broker) enqueuedTypes() []string {
+	var types []string
+	for _, t := range b.Enqueued("") {
+		types = append(types, t.Type)
+	}
+	return types
+}
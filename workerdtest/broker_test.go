@@ -0,0 +1,185 @@
+package workerdtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/paulgrammer/workerd"
+)
+
+func TestBrokerProcessesTasksInOrder(t *testing.T) {
+	b := NewBroker(t)
+	ctx := context.Background()
+	for _, typ := range []string{"a", "b"} {
+		if _, err := b.EnqueueContext(ctx, asynq.NewTask(typ, []byte(typ))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.AssertEnqueuedCount(t, "a", 1)
+
+	var seen []string
+	h := asynq.HandlerFunc(func(_ context.Context, task *asynq.Task) error {
+		seen = append(seen, task.Type())
+		return nil
+	})
+	n, err := b.ProcessAll(ctx, h)
+	if err != nil || n != 2 {
+		t.Fatalf("ProcessAll = %d, %v; want 2, nil", n, err)
+	}
+	if len(seen) != 2 || seen[0] != "a" || seen[1] != "b" {
+		t.Errorf("processed %v, want [a b]", seen)
+	}
+	if got := len(b.Tasks(asynq.TaskStateCompleted)); got != 2 {
+		t.Errorf("%d completed tasks, want 2", got)
+	}
+}
+
+func TestBrokerHonoursTaskOptions(t *testing.T) {
+	b := NewBroker(t)
+	info, err := b.Enqueue(asynq.NewTask("a", nil, asynq.Queue("critical"), asynq.MaxRetry(1)), nil, asynq.TaskID("id-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Queue != "critical" || info.MaxRetry != 1 || info.ID != "id-1" {
+		t.Errorf("enqueued %s/%s max retry %d, want critical/id-1 max retry 1", info.Queue, info.ID, info.MaxRetry)
+	}
+	if _, err := b.Enqueue(asynq.NewTask("a", nil), asynq.Queue("critical"), asynq.TaskID("id-1")); !errors.Is(err, asynq.ErrTaskIDConflict) {
+		t.Errorf("duplicate task ID: got %v, want ErrTaskIDConflict", err)
+	}
+}
+
+func TestBrokerRetriesThenArchives(t *testing.T) {
+	b := NewBroker(t)
+	ctx := context.Background()
+	if _, err := b.Enqueue(asynq.NewTask("a", nil), asynq.MaxRetry(1)); err != nil {
+		t.Fatal(err)
+	}
+	fail := asynq.HandlerFunc(func(context.Context, *asynq.Task) error { return errors.New("boom") })
+
+	if _, err := b.ProcessOne(ctx, fail); err == nil {
+		t.Fatal("ProcessOne: want the handler error")
+	}
+	if got := len(b.Tasks(asynq.TaskStateRetry)); got != 1 {
+		t.Fatalf("%d tasks waiting for a retry, want 1", got)
+	}
+	b.Retry()
+	b.ProcessOne(ctx, fail)
+	if got := len(b.Tasks(asynq.TaskStateArchived)); got != 1 {
+		t.Errorf("%d archived tasks, want 1", got)
+	}
+}
+
+func TestBrokerSkipRetryAndRevoke(t *testing.T) {
+	b := NewBroker(t)
+	ctx := context.Background()
+	b.Enqueue(asynq.NewTask("archive", nil))
+	b.Enqueue(asynq.NewTask("revoke", nil))
+	h := asynq.HandlerFunc(func(_ context.Context, task *asynq.Task) error {
+		if task.Type() == "archive" {
+			return asynq.SkipRetry
+		}
+		return asynq.RevokeTask
+	})
+	b.ProcessAll(ctx, h)
+
+	if tasks := b.Tasks(); len(tasks) != 1 || tasks[0].Type != "archive" || tasks[0].State != asynq.TaskStateArchived {
+		t.Errorf("tasks = %+v, want only the archived task", tasks)
+	}
+}
+
+func TestBrokerScheduledTasksWaitForClock(t *testing.T) {
+	b := NewBroker(t)
+	ctx := context.Background()
+	b.Enqueue(asynq.NewTask("later", nil), asynq.ProcessIn(time.Hour))
+	noop := asynq.HandlerFunc(func(context.Context, *asynq.Task) error { return nil })
+
+	if ok, _ := b.ProcessOne(ctx, noop); ok {
+		t.Fatal("scheduled task ran before it was due")
+	}
+	b.Advance(time.Hour)
+	if ok, _ := b.ProcessOne(ctx, noop); !ok {
+		t.Fatal("scheduled task did not run once due")
+	}
+}
+
+func TestBrokerRejectsDuplicateUniqueTasks(t *testing.T) {
+	b := NewBroker(t)
+	if _, err := b.Enqueue(asynq.NewTask("a", []byte("x")), asynq.Unique(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Enqueue(asynq.NewTask("a", []byte("x")), asynq.Unique(time.Hour)); !errors.Is(err, asynq.ErrDuplicateTask) {
+		t.Errorf("got %v, want ErrDuplicateTask", err)
+	}
+}
+
+func TestBrokerRecoversHandlerPanics(t *testing.T) {
+	b := NewBroker(t)
+	b.Enqueue(asynq.NewTask("a", nil))
+	ok, err := b.ProcessOne(context.Background(), asynq.HandlerFunc(func(context.Context, *asynq.Task) error {
+		panic("boom")
+	}))
+	if !ok || err == nil {
+		t.Errorf("ProcessOne = %v, %v; want the panic as an error", ok, err)
+	}
+}
+
+func TestBrokerRunsHandlersWithTaskContext(t *testing.T) {
+	b := NewBroker(t)
+	ctx := context.Background()
+	info, err := b.Enqueue(asynq.NewTask("a", nil), asynq.Queue("critical"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = b.ProcessOne(ctx, asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		id, _ := asynq.GetTaskID(ctx)
+		queue, _ := asynq.GetQueueName(ctx)
+		retried, ok := asynq.GetRetryCount(ctx)
+		if id != info.ID || queue != "critical" || retried != 0 || !ok {
+			t.Errorf("task context = %s/%s retried %d, want %s/critical", queue, id, retried, info.ID)
+		}
+		_, err := task.ResultWriter().Write([]byte("done"))
+		return err
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks := b.Tasks(asynq.TaskStateCompleted); len(tasks) != 1 || string(tasks[0].Result) != "done" {
+		t.Errorf("completed tasks = %+v, want one with the handler result", tasks)
+	}
+}
+
+func TestBrokerRunsWorkerMiddleware(t *testing.T) {
+	b := NewBroker(t)
+	t.Setenv("ASYNQ_REDIS_ADDRESS", b.Addr())
+	w, err := workerd.NewWorkerd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got workerd.Metadata
+	w.HandleFunc("a", func(ctx context.Context, task *asynq.Task) error {
+		got = workerd.MetadataFromContext(ctx)
+		if string(task.Payload()) != "payload" {
+			t.Errorf("payload = %q, want it unwrapped", task.Payload())
+		}
+		return nil
+	})
+
+	client, err := w.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := workerd.WithMetadata(context.Background(), workerd.Metadata{"tenant": "acme"})
+	if _, err := client.EnqueueContext(ctx, asynq.NewTask("a", []byte("payload"))); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := b.ProcessAll(context.Background(), w); n != 1 || err != nil {
+		t.Fatalf("ProcessAll = %d, %v; want 1, nil", n, err)
+	}
+	if got["tenant"] != "acme" {
+		t.Errorf("metadata = %v, want the tenant", got)
+	}
+}